@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"dongman/internal/models"
+	"dongman/internal/storage"
+	"dongman/internal/utils"
+)
+
+// runCheckAssets 检查所有图片引用的完整性，返回进程退出码
+// 发现问题时返回1；-repair 会把缺失文件的引用改写为按文件名找到的唯一同名文件，需要 -yes 或交互确认
+func runCheckAssets(args []string) int {
+	fs := flag.NewFlagSet("check-assets", flag.ExitOnError)
+	deep := fs.Bool("deep", false, "完整解码每张图片，默认只解析文件头")
+	jsonOutput := fs.Bool("json", false, "以JSON格式输出检查报告")
+	repair := fs.Bool("repair", false, "把缺失文件的引用改写为同名文件")
+	yes := fs.Bool("yes", false, "修复时不再询问确认")
+	concurrency := fs.Int("concurrency", 8, "并发检查数")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "用法: %s check-assets [选项]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if _, err := models.InitDB(); err != nil {
+		log.Printf("数据库初始化失败: %v", err)
+		return 2
+	}
+	defer models.DB.Close()
+	if err := storage.Init(); err != nil {
+		log.Printf("存储后端初始化失败: %v", err)
+		return 2
+	}
+
+	report, err := utils.CheckAssets(utils.AssetCheckOptions{Deep: *deep, Concurrency: *concurrency})
+	if err != nil {
+		log.Printf("资源检查失败: %v", err)
+		return 2
+	}
+
+	// JSON输出时其余提示写到标准错误，保证标准输出可以直接解析
+	out := os.Stdout
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Printf("输出报告失败: %v", err)
+			return 2
+		}
+		out = os.Stderr
+	} else {
+		printAssetReport(report)
+	}
+
+	if !*repair {
+		if len(report.Problems) > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	mapping := report.RepairMapping()
+	if len(mapping) == 0 {
+		fmt.Fprintln(out, "\n没有可以自动修复的引用")
+		if len(report.Problems) > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(out, "\n将改写以下 %d 个缺失文件的引用:\n", len(mapping))
+	for _, oldPath := range sortedKeys(mapping) {
+		fmt.Fprintf(out, "  %s -> %s\n", oldPath, mapping[oldPath])
+	}
+	if !*yes && !confirm(out, "确认修复? [y/N] ") {
+		fmt.Fprintln(out, "已取消")
+		return 1
+	}
+
+	applied, result, err := utils.RepairAssets(mapping)
+	if err != nil {
+		log.Printf("修复图片引用失败: %v", err)
+		return 2
+	}
+	fmt.Fprintf(out, "已修复 %d 个文件的引用\n", len(applied))
+	for _, column := range sortedKeys(result.Rows) {
+		fmt.Fprintf(out, "  %s: %d 行\n", column, result.Rows[column])
+	}
+	for _, post := range result.Posts {
+		fmt.Fprintf(out, "  文章: %s\n", post)
+	}
+
+	// 修复后仍有其他问题（空文件、无法解码等）需要人工处理
+	if len(report.Problems) > len(applied) {
+		return 1
+	}
+	return 0
+}
+
+// printAssetReport 以文本形式输出检查报告
+func printAssetReport(report *utils.AssetCheckReport) {
+	fmt.Printf("检查了 %d 处引用，%d 个文件，发现 %d 个问题\n", report.CheckedRefs, report.CheckedFiles, len(report.Problems))
+	for _, issue := range sortedKeys(report.Summary) {
+		fmt.Printf("  %s: %d\n", issue, report.Summary[issue])
+	}
+	for _, p := range report.Problems {
+		fmt.Printf("\n[%s] %s\n", p.Issue, p.Path)
+		if p.Detail != "" {
+			fmt.Printf("  详情: %s\n", p.Detail)
+		}
+		fmt.Printf("  引用: %s\n", strings.Join(p.Sources, ", "))
+		if p.Repair != "" {
+			fmt.Printf("  修复建议: %s\n", p.Repair)
+		}
+		for _, c := range p.Candidates {
+			fmt.Printf("  候选: %s\n", c)
+		}
+	}
+}
+
+// confirm 从标准输入读取确认
+func confirm(out *os.File, prompt string) bool {
+	fmt.Fprint(out, prompt)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
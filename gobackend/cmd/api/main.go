@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"syscall"
 	"time"
 
@@ -15,11 +14,18 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"dongman/internal/handlers"
+	"dongman/internal/jobs"
+	"dongman/internal/linkcheck"
 	"dongman/internal/models"
 	"dongman/internal/config"
+	"dongman/internal/storage"
 )
 
 func main() {
+	// 子命令: api check-assets [-deep] [-json] [-repair [-yes]]
+	if len(os.Args) > 1 && os.Args[1] == "check-assets" {
+		os.Exit(runCheckAssets(os.Args[2:]))
+	}
 	
 	// 检查数据库文件
 	dbPath := config.GetDbPath()
@@ -48,6 +54,17 @@ func main() {
 		log.Printf("设置WAL自动检查点阈值失败: %v", err)
 	}
 
+	// 初始化对象存储后端
+	if err := storage.Init(); err != nil {
+		log.Fatalf("存储后端初始化失败: %v", err)
+	}
+
+	// 启动后台任务队列
+	handlers.RegisterJobHandlers()
+	jobs.Start(config.JobWorkers)
+	handlers.StartEnhanceWorkers()
+	linkcheck.Start()
+
 	// 创建初始管理员账号
 	if err := models.CreateInitialAdmin(); err != nil {
 		log.Printf("创建初始管理员账号失败: %v", err)
@@ -79,8 +96,8 @@ func main() {
 	}))
 
 	// 配置静态文件服务
-	router.Static("/assets", config.AssetPath)
-	router.Static("/public", filepath.Join(config.AssetPath, "public"))
+	handlers.MountAssets(router, "/assets", "")
+	handlers.MountAssets(router, "/public", "public")
 
 	// 设置路由
 	handlers.SetupRoutes(router)
@@ -126,6 +143,13 @@ func main() {
 		log.Printf("服务器强制关闭: %v", err)
 	}
 
+	// 等待正在执行的后台任务完成，未完成的任务下次启动时继续执行
+	jobCtx, jobCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer jobCancel()
+	if err := jobs.Shutdown(jobCtx); err != nil {
+		log.Printf("等待后台任务完成超时: %v", err)
+	}
+
 	// 安全关闭数据库连接，确保WAL数据被写入主数据库
 	if err := models.CloseDB(); err != nil {
 		log.Printf("数据库关闭错误: %v", err)
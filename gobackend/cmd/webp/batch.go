@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"dongman/internal/utils"
+)
+
+// 单个文件的处理结果状态
+const (
+	statusConverted    = "converted"     // 已转换
+	statusKeptOriginal = "kept_original" // WebP未达到节省阈值，保留原图
+	statusSkipped      = "skipped"       // 上次运行已处理，断点续传时跳过
+	statusDryRun       = "dry_run"       // 预览模式，未写入任何文件
+	statusFailed       = "failed"        // 处理失败
+)
+
+// batchOptions 批量转换参数
+type batchOptions struct {
+	maxWidth     int
+	maxHeight    int
+	keepRatio    bool
+	keepOriginal bool
+	useWebp      bool
+	concurrency  int
+	dryRun       bool
+	minSavings   float64 // WebP相对原图至少节省的百分比，达不到时保留原图，小于0表示不检查
+}
+
+// fileResult 单个文件的处理结果，用于生成报告
+type fileResult struct {
+	Input        string `json:"input"`
+	Output       string `json:"output,omitempty"`
+	Status       string `json:"status"`
+	InputSize    int64  `json:"input_size"`
+	OutputSize   int64  `json:"output_size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	OutputWidth  int    `json:"output_width"`
+	OutputHeight int    `json:"output_height"`
+	DurationMs   int64  `json:"duration_ms"`
+	Error        string `json:"error,omitempty"`
+}
+
+// globList 可重复或用逗号分隔的glob参数
+type globList []string
+
+func (g *globList) String() string { return strings.Join(*g, ",") }
+
+func (g *globList) Set(value string) error {
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("无效的glob: %s", pattern)
+		}
+		*g = append(*g, pattern)
+	}
+	return nil
+}
+
+// matches 判断路径或文件名是否匹配任一glob，rel 使用 / 分隔
+func (g globList) matches(rel string) bool {
+	base := path.Base(rel)
+	for _, pattern := range g {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fileFilter 按 -include/-exclude 过滤文件，include 为空时不限制
+type fileFilter struct {
+	include globList
+	exclude globList
+}
+
+func (f fileFilter) allow(rel string) bool {
+	if len(f.include) > 0 && !f.include.matches(rel) {
+		return false
+	}
+	return !f.exclude.matches(rel)
+}
+
+// isBatchImage 判断是否为需要批量处理的图片，跳过自动生成的派生图片
+func isBatchImage(p string) bool {
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return !utils.IsDerivedImagePath(p)
+	}
+	return false
+}
+
+// collectDirImages 遍历目录收集待处理图片，按路径排序返回；-include/-exclude 匹配相对目录的路径（/ 分隔）或文件名
+func collectDirImages(dir string, recursive bool, filter fileFilter) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("访问路径失败 %s: %v", p, err)
+			return nil // 继续遍历其他文件
+		}
+		if d.IsDir() {
+			if p != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isBatchImage(p) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil
+		}
+		if filter.allow(filepath.ToSlash(rel)) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// batchState 断点续传状态文件
+// 第一行记录处理的目录，之后每处理完一个文件追加一行，进程中断后已写入的行仍然有效
+type batchState struct {
+	path string
+	dir  string
+	done map[string]stateEntry
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+type stateHeader struct {
+	Dir string `json:"dir"`
+}
+
+type stateEntry struct {
+	File   string `json:"file"`
+	Status string `json:"status"`
+	Output string `json:"output,omitempty"`
+}
+
+// openBatchState 打开状态文件，目录与上次不同时重新开始
+func openBatchState(statePath, dir string) (*batchState, error) {
+	state := &batchState{path: statePath, dir: dir, done: make(map[string]stateEntry)}
+
+	if f, err := os.Open(statePath); err == nil {
+		scanner := bufio.NewScanner(f)
+		var header stateHeader
+		if scanner.Scan() && json.Unmarshal(scanner.Bytes(), &header) == nil && header.Dir == dir {
+			for scanner.Scan() {
+				var entry stateEntry
+				// 中断时可能留下写了一半的最后一行，忽略即可
+				if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.File != "" {
+					state.done[entry.File] = entry
+				}
+			}
+		} else {
+			log.Printf("状态文件 %s 属于其他目录，将重新开始", statePath)
+		}
+		f.Close()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("读取状态文件失败: %w", err)
+	}
+
+	if len(state.done) == 0 {
+		data, _ := json.Marshal(stateHeader{Dir: dir})
+		if err := os.WriteFile(statePath, append(data, '\n'), 0644); err != nil {
+			return nil, fmt.Errorf("创建状态文件失败: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(statePath, os.O_APPEND|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开状态文件失败: %w", err)
+	}
+	// 上次中断时最后一行可能不完整，补一个换行避免与新记录连在一起
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			f.Write([]byte{'\n'})
+		}
+	}
+	state.file = f
+	return state, nil
+}
+
+// key 返回文件在状态文件中的记录名（相对目录的路径）
+func (s *batchState) key(file string) string {
+	rel, err := filepath.Rel(s.dir, file)
+	if err != nil {
+		return file
+	}
+	return filepath.ToSlash(rel)
+}
+
+// lookup 返回文件在上次运行中的处理记录
+func (s *batchState) lookup(file string) (stateEntry, bool) {
+	entry, ok := s.done[s.key(file)]
+	return entry, ok
+}
+
+// record 记录已处理完成的文件，失败的文件不记录，下次运行时会重试
+func (s *batchState) record(file string, result fileResult) {
+	data, _ := json.Marshal(stateEntry{File: s.key(file), Status: result.Status, Output: result.Output})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		log.Printf("写入状态文件失败: %v", err)
+	}
+}
+
+// close 关闭状态文件，全部处理成功时删除
+func (s *batchState) close(completed bool) {
+	s.file.Close()
+	if completed {
+		if err := os.Remove(s.path); err != nil {
+			log.Printf("删除状态文件失败: %v", err)
+		}
+	}
+}
+
+func lookupState(state *batchState, file string) (stateEntry, bool) {
+	if state == nil {
+		return stateEntry{}, false
+	}
+	return state.lookup(file)
+}
+
+// runBatch 并发处理文件列表，结果顺序与输入一致
+func runBatch(files []string, opts batchOptions, state *batchState) []fileResult {
+	if opts.concurrency <= 0 {
+		opts.concurrency = 4
+	}
+
+	results := make([]fileResult, len(files))
+	semaphore := make(chan struct{}, opts.concurrency)
+	var wg sync.WaitGroup
+
+	for i, file := range files {
+		if entry, ok := lookupState(state, file); ok {
+			// 保留上次的输出路径，-rewrite-db 需要据此改写引用
+			results[i] = fileResult{Input: file, Status: statusSkipped}
+			if entry.Status == statusConverted {
+				results[i].Output = entry.Output
+			}
+			if info, err := os.Stat(file); err == nil {
+				results[i].InputSize = info.Size()
+			}
+			continue
+		}
+
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, file string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			log.Printf("处理图片 [%d/%d]: %s", i+1, len(files), file)
+			result := convertFile(file, opts)
+			if result.Error != "" {
+				log.Printf("转换图片失败 %s: %s", file, result.Error)
+			} else if state != nil {
+				state.record(file, result)
+			}
+			results[i] = result
+		}(i, file)
+	}
+	wg.Wait()
+	return results
+}
+
+// convertFile 转换单个图片并收集报告数据
+func convertFile(file string, opts batchOptions) fileResult {
+	start := time.Now()
+	result := fileResult{Input: file}
+	fail := func(err error) fileResult {
+		result.Status = statusFailed
+		result.Error = err.Error()
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result
+	}
+
+	srcPath, err := utils.ResolveAssetPath(file)
+	if err != nil {
+		return fail(err)
+	}
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return fail(err)
+	}
+	result.InputSize = info.Size()
+	if result.Width, result.Height, err = utils.OrientedImageSize(srcPath); err != nil {
+		return fail(err)
+	}
+
+	if opts.dryRun {
+		result.Status = statusDryRun
+		result.Output = plannedWebPPath(srcPath, opts.useWebp)
+		maxWidth, maxHeight := opts.maxWidth, opts.maxHeight
+		if !opts.keepRatio {
+			maxWidth, maxHeight = 0, 0
+		}
+		result.OutputWidth, result.OutputHeight = utils.ResizeDimensions(result.Width, result.Height, maxWidth, maxHeight)
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result
+	}
+
+	// 保持原扩展名时原图会被原地改写，先备份以便WebP不够小时恢复
+	var original []byte
+	if !opts.useWebp && opts.minSavings >= 0 {
+		if original, err = os.ReadFile(srcPath); err != nil {
+			return fail(err)
+		}
+	}
+
+	// 原图由这里根据节省比例决定是否删除，转换函数始终保留原图
+	var outputPath string
+	if opts.keepRatio {
+		outputPath, err = utils.ConvertToWebPWithRatio(srcPath, opts.maxWidth, opts.maxHeight, true, opts.useWebp)
+	} else {
+		outputPath, err = utils.ConvertToWebP(srcPath, opts.useWebp)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	webpPath := outputPath
+	if !opts.useWebp {
+		webpPath = utils.ImageSiblingKey(outputPath, utils.WebPSiblingSuffix)
+	}
+	result.Output = webpPath
+	if webpInfo, err := os.Stat(webpPath); err == nil {
+		result.OutputSize = webpInfo.Size()
+	}
+	if cfg, err := decodeFileConfig(webpPath); err == nil {
+		result.OutputWidth, result.OutputHeight = cfg.Width, cfg.Height
+	}
+
+	if opts.minSavings >= 0 && !enoughSavings(result.InputSize, result.OutputSize, opts.minSavings) {
+		if err := keepOriginalImage(srcPath, webpPath, original, opts.useWebp); err != nil {
+			return fail(err)
+		}
+		log.Printf("WebP (%d 字节) 未达到节省阈值 %.1f%%，保留原图 %s (%d 字节)",
+			result.OutputSize, opts.minSavings, srcPath, result.InputSize)
+		result.Status = statusKeptOriginal
+		result.Output = srcPath
+		result.OutputSize = result.InputSize
+		result.OutputWidth, result.OutputHeight = result.Width, result.Height
+		result.DurationMs = time.Since(start).Milliseconds()
+		return result
+	}
+
+	if opts.useWebp && !opts.keepOriginal && outputPath != srcPath {
+		log.Printf("删除原始图片: %s", srcPath)
+		if err := os.Remove(srcPath); err != nil {
+			log.Printf("警告：删除原始图片失败: %v", err)
+		}
+	}
+
+	result.Status = statusConverted
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// plannedWebPPath 返回转换后WebP文件的路径
+func plannedWebPPath(srcPath string, useWebp bool) string {
+	if useWebp {
+		return filepath.Join(filepath.Dir(srcPath), strings.TrimSuffix(filepath.Base(srcPath), filepath.Ext(srcPath))+".webp")
+	}
+	return utils.ImageSiblingKey(srcPath, utils.WebPSiblingSuffix)
+}
+
+// enoughSavings 判断WebP相对原图的节省比例是否达到阈值（百分比）
+func enoughSavings(inputSize, outputSize int64, minSavings float64) bool {
+	if inputSize <= 0 || outputSize <= 0 {
+		return false
+	}
+	saved := inputSize - outputSize
+	return saved > 0 && float64(saved)*100 >= minSavings*float64(inputSize)
+}
+
+// keepOriginalImage 撤销转换：删除生成的WebP/AVIF文件以及转换时记录的响应式尺寸和感知哈希，
+// 保持原扩展名时恢复原图内容；最后按原图重新生成响应式尺寸和感知哈希
+func keepOriginalImage(srcPath, webpPath string, original []byte, useWebp bool) error {
+	if useWebp {
+		if webpPath == srcPath {
+			return nil
+		}
+		if err := removeIfExists(webpPath); err != nil {
+			return err
+		}
+		if err := utils.RemoveImageDerivatives(webpPath); err != nil {
+			return err
+		}
+	} else {
+		tempFile := srcPath + ".tmp"
+		if err := os.WriteFile(tempFile, original, 0644); err != nil {
+			os.Remove(tempFile)
+			return fmt.Errorf("恢复原图失败: %w", err)
+		}
+		if err := os.Rename(tempFile, srcPath); err != nil {
+			os.Remove(tempFile)
+			return fmt.Errorf("恢复原图失败: %w", err)
+		}
+		for _, suffix := range []string{utils.WebPSiblingSuffix, utils.AVIFSiblingSuffix} {
+			if err := removeIfExists(utils.ImageSiblingKey(srcPath, suffix)); err != nil {
+				return err
+			}
+		}
+		// 转换时按缩放后的图片生成的记录已不再对应原图
+		if err := utils.RemoveImageDerivatives(srcPath); err != nil {
+			return err
+		}
+	}
+
+	// 响应式图片按去掉扩展名的文件名命名，a.png 的尺寸文件在生成 a.webp 时已被覆盖，按原图重新生成
+	if assetPath, ok := utils.AssetPathFromLocal(srcPath); ok {
+		if _, err := utils.GenerateStoredImageVariants(assetPath); err != nil {
+			log.Printf("按原图重新生成响应式图片失败 %s: %v", assetPath, err)
+		}
+	}
+	return nil
+}
+
+func removeIfExists(p string) error {
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("删除 %s 失败: %w", p, err)
+	}
+	return nil
+}
+
+func decodeFileConfig(p string) (image.Config, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	return cfg, err
+}
+
+// writeReport 按格式输出处理报告
+func writeReport(w io.Writer, format string, results []fileResult) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	case "csv":
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"input", "output", "status", "input_size", "output_size",
+			"width", "height", "output_width", "output_height", "duration_ms", "error"})
+		for _, r := range results {
+			writer.Write([]string{r.Input, r.Output, r.Status,
+				strconv.FormatInt(r.InputSize, 10), strconv.FormatInt(r.OutputSize, 10),
+				strconv.Itoa(r.Width), strconv.Itoa(r.Height),
+				strconv.Itoa(r.OutputWidth), strconv.Itoa(r.OutputHeight),
+				strconv.FormatInt(r.DurationMs, 10), r.Error})
+		}
+		writer.Flush()
+		return writer.Error()
+	}
+	return fmt.Errorf("不支持的报告格式: %s", format)
+}
+
+// printSummary 输出人类可读的统计信息
+func printSummary(w io.Writer, results []fileResult) {
+	counts := make(map[string]int)
+	var inputTotal, outputTotal int64
+	for _, r := range results {
+		counts[r.Status]++
+		if r.Status == statusConverted {
+			inputTotal += r.InputSize
+			outputTotal += r.OutputSize
+		}
+	}
+
+	fmt.Fprintf(w, "\n处理完成! 共 %d 个文件\n", len(results))
+	fmt.Fprintf(w, "已转换: %d\n", counts[statusConverted])
+	fmt.Fprintf(w, "保留原图: %d\n", counts[statusKeptOriginal])
+	fmt.Fprintf(w, "续传跳过: %d\n", counts[statusSkipped])
+	fmt.Fprintf(w, "预览: %d\n", counts[statusDryRun])
+	fmt.Fprintf(w, "失败: %d\n", counts[statusFailed])
+	if inputTotal > 0 {
+		fmt.Fprintf(w, "转换前 %.2f KB，WebP %.2f KB，节省 %.1f%%\n",
+			float64(inputTotal)/1024, float64(outputTotal)/1024, float64(inputTotal-outputTotal)*100/float64(inputTotal))
+	}
+	for _, r := range results {
+		if r.Status == statusFailed {
+			fmt.Fprintf(w, "  失败: %s - %s\n", r.Input, r.Error)
+		}
+	}
+}
@@ -1,172 +1,321 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	
-	"dongman/internal/models"
+	"strings"
+	"sync"
+
 	"dongman/internal/config"
+	"dongman/internal/models"
+	"dongman/internal/storage"
+	"dongman/internal/utils"
 )
 
 func main() {
 	// 解析命令行参数
-	imgPath := flag.String("img", "", "要转换的图片路径，可以是相对路径或绝对路径")
+	imgPath := flag.String("img", "", "要转换的图片路径，可以是相对当前目录或资源目录的路径，或绝对路径")
 	dirPath := flag.String("dir", "", "要批量处理的目录路径")
-	jsonList := flag.String("json", "", "图片路径的JSON列表，例如：[\"img1.jpg\",\"img2.png\"]")
+	jsonList := flag.String("json", "", "图片路径的JSON列表，支持/assets/...、相对资源目录的路径或绝对路径，例如：[\"/assets/imgs/1/a.jpg\",\"imgs/1/b.png\"]")
 	recursive := flag.Bool("recursive", true, "是否递归处理子目录")
 	keepRatio := flag.Bool("ratio", true, "是否保持原始宽高比")
 	maxWidth := flag.Int("w", 0, "最大宽度(0表示自动判断：横图1280px，竖图600px)")
 	maxHeight := flag.Int("h", 0, "最大高度(0表示自动判断：横图720px，竖图900px)")
 	keepOriginal := flag.Bool("keep", true, "是否保留原始图片")
-	useWebp := flag.Bool("webp", false, "是否使用.webp扩展名（默认保持原扩展名）")
+	useWebp := flag.Bool("webp", false, "是否使用.webp扩展名（默认保持原格式并在旁边生成 .webp 版本）")
+	avif := flag.Bool("avif", config.ImageAVIF, "是否同时生成 .avif 版本（需使用 -tags avif 构建），默认取环境变量IMAGE_AVIF")
 	concurrency := flag.Int("concurrency", 4, "并发处理的数量，仅批量处理时有效")
 	noAsync := flag.Bool("sync", false, "使用同步模式处理（不使用并发），批量处理时有效")
+	variants := flag.Bool("variants", false, "为数据库中已有资源的图片回填响应式尺寸和BlurHash")
+	force := flag.Bool("force", false, "回填时重新生成已有的响应式尺寸，配合-variants使用")
+	dryRun := flag.Bool("dry-run", false, "预览模式：只列出将要处理的文件和输出尺寸，不写入任何文件")
+	reportFormat := flag.String("report", "", "输出处理报告，格式为json或csv（每个文件的输入输出大小、尺寸、耗时和错误）")
+	reportPath := flag.String("report-out", "", "报告文件路径，默认输出到标准输出")
+	statePath := flag.String("state", ".webp_state.jsonl", "批量处理目录时的断点续传状态文件，中断后再次运行会跳过已完成的文件，为空时不使用")
+	minSavings := flag.Float64("min-savings", 0, "WebP相对原图至少节省的百分比，达不到时保留原图；0表示只要更小即可，负数表示不检查")
+	rewriteDB := flag.Bool("rewrite-db", false, "配合-webp使用：转换后在一个事务中把数据库和文章中对原图的引用改写为.webp路径，完成后检查引用")
+	verifyDB := flag.Bool("verify-db", false, "检查数据库和文章中引用的图片是否存在，可单独使用")
+	var include, exclude globList
+	flag.Var(&include, "include", "只处理匹配的文件，glob匹配相对路径或文件名，可重复或用逗号分隔，如 -include '*.png'")
+	flag.Var(&exclude, "exclude", "跳过匹配的文件，规则同-include")
 	flag.Parse()
-	
+
+	if *reportFormat != "" && *reportFormat != "json" && *reportFormat != "csv" {
+		log.Fatalf("不支持的报告格式: %s，可选json或csv", *reportFormat)
+	}
+
 	// 检查是否提供了图片路径、目录路径或JSON列表
-	if *imgPath == "" && *dirPath == "" && *jsonList == "" {
-		fmt.Println("请提供要转换的图片路径(-img)、要批量处理的目录路径(-dir)或图片路径的JSON列表(-json)")
+	if *imgPath == "" && *dirPath == "" && *jsonList == "" && !*variants && !*verifyDB {
+		fmt.Println("请提供要转换的图片路径(-img)、要批量处理的目录路径(-dir)、图片路径的JSON列表(-json)，或使用-variants回填响应式图片、-verify-db检查引用")
 		flag.Usage()
 		os.Exit(1)
 	}
-	
-	// 初始化数据库连接，以确保可以正常使用utils包
-	db, err := models.InitDB()
-	if err != nil {
-		log.Fatalf("数据库初始化失败: %v", err)
-	}
-	defer db.Close()
-	
-	// 处理JSON列表批量转换
-	if *jsonList != "" {
-		log.Printf("开始处理JSON图片列表")
-		log.Printf("参数: 保留原图=%v, 使用WebP扩展名=%v, 并发数=%d", 
-			*keepOriginal, *useWebp, *concurrency)
-		
-		// 调用处理JSON列表的函数
-		results, err := utils.ConvertMultipleImages(*jsonList, *keepOriginal, *useWebp, *concurrency)
+
+	config.ImageAVIF = *avif
+	if *avif && !utils.AVIFSupported() {
+		log.Printf("警告: 当前构建不支持AVIF编码（需使用 -tags avif 构建），将只生成WebP版本")
+	}
+
+	if *rewriteDB && !*useWebp {
+		log.Printf("提示: 未使用-webp时图片保持原扩展名，路径不变，-rewrite-db 只会执行引用检查")
+	}
+	if *useWebp && !*keepOriginal && !*rewriteDB && !*dryRun {
+		log.Printf("警告: 原图将被删除，数据库和文章中的引用不会更新，可使用 -rewrite-db 同步改写")
+	}
+
+	// 转换时会记录感知哈希并生成响应式图片，需要数据库和与API相同的存储后端；预览模式只读取本地文件
+	if !*dryRun || *rewriteDB || *verifyDB {
+		db, err := models.InitDB()
 		if err != nil {
-			log.Printf("警告: 部分图片处理失败: %v", err)
-		}
-		
-		// 打印输出结果
-		fmt.Println("\nJSON列表转换完成!")
-		fmt.Printf("成功处理图片数量: %d\n", len(results))
-		
-		if len(results) > 0 {
-			fmt.Println("\n转换后的图片路径:")
-			for i, path := range results {
-				if i < 10 || i == len(results)-1 { // 只显示前10个和最后1个
-					fmt.Printf("  %s\n", path)
-				} else if i == 10 {
-					fmt.Printf("  ... 以及其他 %d 张图片\n", len(results)-11)
-				}
-			}
+			log.Fatalf("数据库初始化失败: %v", err)
+		}
+		defer db.Close()
+
+		if err := storage.Init(); err != nil {
+			log.Fatalf("存储后端初始化失败: %v", err)
+		}
+	}
+	if *dryRun && *variants {
+		log.Fatalf("-variants 不支持预览模式")
+	}
+
+	// 回填已有资源的响应式图片
+	if *variants {
+		log.Printf("开始回填响应式图片，并发数=%d, 强制重新生成=%v", *concurrency, *force)
+		generated, failed, err := backfillImageVariants(*concurrency, *force)
+		if err != nil {
+			log.Fatalf("回填响应式图片失败: %v", err)
 		}
+		fmt.Println("\n响应式图片回填完成!")
+		fmt.Printf("生成图片数量: %d\n", generated)
+		fmt.Printf("失败图片数量: %d\n", failed)
 		return
 	}
-	
-	// 处理目录批量转换
-	if *dirPath != "" {
-		// 验证目录是否存在
+
+	// 只检查引用
+	if *imgPath == "" && *dirPath == "" && *jsonList == "" {
+		missing, err := verifyDBRefs(os.Stdout)
+		if err != nil {
+			log.Fatalf("检查引用失败: %v", err)
+		}
+		if len(missing) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	opts := batchOptions{
+		maxWidth:     *maxWidth,
+		maxHeight:    *maxHeight,
+		keepRatio:    *keepRatio,
+		keepOriginal: *keepOriginal,
+		useWebp:      *useWebp,
+		concurrency:  *concurrency,
+		dryRun:       *dryRun,
+		minSavings:   *minSavings,
+	}
+	if *noAsync {
+		opts.concurrency = 1
+	}
+	filter := fileFilter{include: include, exclude: exclude}
+
+	var results []fileResult
+
+	switch {
+	case *jsonList != "":
+		// 处理JSON列表批量转换
+		var imagePaths []string
+		if err := json.Unmarshal([]byte(*jsonList), &imagePaths); err != nil {
+			log.Fatalf("解析图片列表JSON失败: %v", err)
+		}
+		files := make([]string, 0, len(imagePaths))
+		for _, p := range imagePaths {
+			if filter.allow(filepath.ToSlash(p)) {
+				files = append(files, p)
+			}
+		}
+		log.Printf("开始处理JSON图片列表，图片数量: %d", len(files))
+		log.Printf("参数: 保留原图=%v, 使用WebP扩展名=%v, 并发数=%d, 预览=%v",
+			*keepOriginal, *useWebp, opts.concurrency, *dryRun)
+		results = runBatch(files, opts, nil)
+
+	case *dirPath != "":
+		// 处理目录批量转换
 		if _, err := os.Stat(*dirPath); os.IsNotExist(err) {
 			log.Fatalf("指定的目录不存在: %s", *dirPath)
 		}
-		
-		// 获取绝对路径
 		absPath, err := filepath.Abs(*dirPath)
 		if err != nil {
 			log.Fatalf("获取目录绝对路径失败: %v", err)
 		}
-		
-		log.Printf("开始批量处理目录: %s", absPath)
-		log.Printf("参数: 递归处理=%v, 保留原图=%v, 使用WebP扩展名=%v, 并发数=%d, 同步模式=%v", 
-			*recursive, *keepOriginal, *useWebp, *concurrency, *noAsync)
-		
-		var count int
-		
-		// 选择同步或异步处理方式
-		if *noAsync {
-			count, err = utils.ProcessDirectorySync(absPath, *recursive, *keepOriginal, *useWebp)
+
+		files, err := collectDirImages(absPath, *recursive, filter)
+		if err != nil {
+			log.Fatalf("遍历目录失败: %v", err)
+		}
+		log.Printf("开始批量处理目录: %s，图片数量: %d", absPath, len(files))
+		log.Printf("参数: 递归处理=%v, 保留原图=%v, 使用WebP扩展名=%v, 并发数=%d, 预览=%v",
+			*recursive, *keepOriginal, *useWebp, opts.concurrency, *dryRun)
+
+		// 预览模式不写状态文件
+		var state *batchState
+		if *statePath != "" && !*dryRun {
+			if state, err = openBatchState(*statePath, absPath); err != nil {
+				log.Fatalf("%v", err)
+			}
+			if len(state.done) > 0 {
+				log.Printf("从状态文件 %s 继续，已完成 %d 个文件", *statePath, len(state.done))
+			}
+		}
+		results = runBatch(files, opts, state)
+		if state != nil {
+			state.close(countStatus(results, statusFailed) == 0)
+		}
+
+	default:
+		// 单个图片处理，相对路径先尝试当前目录
+		fullPath := *imgPath
+		if !filepath.IsAbs(fullPath) && !strings.HasPrefix(fullPath, storage.AssetURLPrefix) {
+			workDir, err := os.Getwd()
+			if err != nil {
+				log.Fatalf("获取工作目录失败: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(workDir, fullPath)); err == nil {
+				fullPath = filepath.Join(workDir, fullPath)
+			} else {
+				log.Printf("注意: 文件在 %s 不存在，将按资源目录下的路径处理: %s", workDir, fullPath)
+			}
+		}
+		log.Printf("开始处理图片: %s", fullPath)
+		log.Printf("参数: 保持比例=%v, 最大宽度=%d, 最大高度=%d, 保留原图=%v, 使用WebP扩展名=%v, 预览=%v",
+			*keepRatio, *maxWidth, *maxHeight, *keepOriginal, *useWebp, *dryRun)
+		results = runBatch([]string{fullPath}, opts, nil)
+	}
+
+	// 报告输出到标准输出时，统计信息改为输出到标准错误，方便管道处理
+	summaryOut := os.Stdout
+	if *reportFormat != "" {
+		reportOut := os.Stdout
+		if *reportPath != "" {
+			f, err := os.Create(*reportPath)
+			if err != nil {
+				log.Fatalf("创建报告文件失败: %v", err)
+			}
+			defer f.Close()
+			reportOut = f
 		} else {
-			count, err = utils.BatchProcessImages(absPath, *recursive, *keepOriginal, *useWebp, *concurrency)
+			summaryOut = os.Stderr
 		}
-		
-		if err != nil {
-			log.Fatalf("批量处理失败: %v", err)
+		if err := writeReport(reportOut, *reportFormat, results); err != nil {
+			log.Fatalf("写入报告失败: %v", err)
 		}
-		
-		// 打印输出结果
-		fmt.Println("\n批量转换成功!")
-		fmt.Printf("处理目录: %s\n", absPath)
-		fmt.Printf("处理文件数量: %d\n", count)
-		return
 	}
-	
-	// 单个图片处理
-	log.Printf("开始处理图片: %s", *imgPath)
-	log.Printf("参数: 保持比例=%v, 最大宽度=%d, 最大高度=%d, 保留原图=%v, 使用WebP扩展名=%v", 
-		*keepRatio, *maxWidth, *maxHeight, *keepOriginal, *useWebp)
-	
-	// 验证图片路径是否存在
-	var fullPath string
-	
-	// 检查是否为绝对路径
-	if filepath.IsAbs(*imgPath) {
-		fullPath = *imgPath
-	} else {
-		// 相对路径，先尝试当前目录
-		workDir, err := os.Getwd()
+	printSummary(summaryOut, results)
+
+	exitCode := 0
+	if countStatus(results, statusFailed) > 0 {
+		exitCode = 1
+	}
+	if *rewriteDB {
+		if err := rewriteDBRefs(summaryOut, buildRenameMapping(results), *dryRun); err != nil {
+			log.Fatalf("改写数据库引用失败: %v", err)
+		}
+	}
+	if *rewriteDB || *verifyDB {
+		missing, err := verifyDBRefs(summaryOut)
 		if err != nil {
-			log.Fatalf("获取工作目录失败: %v", err)
-		}
-		
-		fullPath = filepath.Join(workDir, *imgPath)
-	}
-	
-	// 检查文件是否存在
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		// 文件不存在，打印更详细的提示
-		log.Printf("注意: 文件在 %s 不存在", fullPath)
-		log.Printf("将尝试按原始路径处理: %s", *imgPath)
-	} else {
-		log.Printf("找到文件: %s", fullPath)
-		// 使用完整路径替换输入路径
-		*imgPath = fullPath
-	}
-	
-	var outputPath string
-	
-	// 根据参数选择合适的转换方法
-	if *keepRatio {
-		outputPath, err = utils.ConvertToWebPWithRatio(*imgPath, *maxWidth, *maxHeight, *keepOriginal, *useWebp)
-	} else {
-		outputPath, err = utils.ConvertToWebP(*imgPath, *useWebp)
-	}
-	
-	if err != nil {
-		log.Fatalf("图片转换失败: %v", err)
-	}
-	
-	// 打印输出结果
-	fmt.Println("\n转换成功!")
-	fmt.Printf("原始图片: %s\n", *imgPath)
-	
-	// 确定输出格式的显示方式
-	if *useWebp {
-		fmt.Printf("WebP图片路径: %s\n", outputPath)
-	} else {
-		fmt.Printf("输出图片路径: %s (内容已转换为WebP格式但保留原扩展名)\n", outputPath)
-	}
-	
-	// 检查输出文件是否存在
-	if _, err := os.Stat(outputPath); err == nil {
-		fileInfo, err := os.Stat(outputPath)
-		if err == nil {
-			fmt.Printf("输出文件大小: %.2f KB\n", float64(fileInfo.Size())/1024)
-		}
-	}
-} 
\ No newline at end of file
+			log.Fatalf("检查引用失败: %v", err)
+		}
+		if len(missing) > 0 {
+			exitCode = 1
+		}
+	}
+	if exitCode != 0 {
+		os.Exit(exitCode)
+	}
+}
+
+// countStatus 统计指定状态的文件数量
+func countStatus(results []fileResult, status string) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// backfillImageVariants 为所有资源引用的本站图片生成响应式尺寸和BlurHash，并刷新资源的image_variants字段
+func backfillImageVariants(concurrency int, force bool) (int, int, error) {
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	var resources []models.Resource
+	if err := models.DB.Select(&resources, `SELECT * FROM resources`); err != nil {
+		return 0, 0, fmt.Errorf("查询资源失败: %w", err)
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		generated int
+		failed    int
+		semaphore = make(chan struct{}, concurrency)
+	)
+
+	for _, resource := range resources {
+		paths := make([]string, 0, len(resource.Images)+1)
+		for _, img := range resource.Images {
+			if strings.HasPrefix(img, storage.AssetURLPrefix) {
+				paths = append(paths, img)
+			}
+		}
+		if resource.PosterImage != nil && strings.HasPrefix(*resource.PosterImage, storage.AssetURLPrefix) {
+			paths = append(paths, *resource.PosterImage)
+		}
+
+		existing := models.ImageVariantMap{}
+		if !force {
+			var err error
+			if existing, err = models.GetImageVariants(paths); err != nil {
+				return generated, failed, err
+			}
+		}
+
+		seen := make(map[string]bool, len(paths))
+		for _, p := range paths {
+			if _, ok := existing[p]; ok || seen[p] {
+				continue
+			}
+			seen[p] = true
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(assetPath string) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+
+				_, err := utils.GenerateStoredImageVariants(assetPath)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					log.Printf("生成响应式图片失败 %s: %v", assetPath, err)
+					failed++
+					return
+				}
+				generated++
+			}(p)
+		}
+		wg.Wait()
+
+		if err := models.SyncResourceImageVariants(resource.ID); err != nil {
+			log.Printf("刷新资源ID=%d的响应式图片信息失败: %v", resource.ID, err)
+		}
+	}
+	return generated, failed, nil
+}
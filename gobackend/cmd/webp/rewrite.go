@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+
+	"dongman/internal/models"
+	"dongman/internal/storage"
+	"dongman/internal/utils"
+)
+
+// buildRenameMapping 根据转换结果生成 旧路径 -> 新路径 的映射（/assets/... 形式）
+// 只包含改为 .webp 扩展名的文件；保持原扩展名时生成的是 a.jpg.webp 这类同名版本，路径不变
+func buildRenameMapping(results []fileResult) map[string]string {
+	mapping := make(map[string]string)
+	for _, r := range results {
+		switch r.Status {
+		case statusConverted, statusSkipped, statusDryRun:
+		default:
+			continue
+		}
+		if r.Output == "" {
+			continue
+		}
+		srcPath, err := utils.ResolveAssetPath(r.Input)
+		if err != nil {
+			continue
+		}
+		oldPath, ok := utils.AssetPathFromLocal(srcPath)
+		if !ok {
+			log.Printf("图片 %s 不在资源目录内，跳过改写", srcPath)
+			continue
+		}
+		newPath, ok := utils.AssetPathFromLocal(r.Output)
+		if !ok || newPath == oldPath || utils.IsImageSiblingPath(newPath) {
+			continue
+		}
+		mapping[oldPath] = newPath
+	}
+	return mapping
+}
+
+// rewriteDBRefs 将数据库和文章中的旧路径改写为转换后的新路径
+func rewriteDBRefs(w io.Writer, mapping map[string]string, dryRun bool) error {
+	if len(mapping) == 0 {
+		fmt.Fprintln(w, "\n没有改名的图片，无需改写数据库引用")
+		return nil
+	}
+
+	result, err := models.RewriteAssetRefs(mapping, storage.Default(), dryRun)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Fprintf(w, "\n预览: %d 个图片改名，将改写以下引用（未写入）:\n", len(mapping))
+	} else {
+		fmt.Fprintf(w, "\n已改写 %d 个图片的引用:\n", len(mapping))
+	}
+	columns := make([]string, 0, len(result.Rows))
+	for column := range result.Rows {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		fmt.Fprintf(w, "  %s: %d 行\n", column, result.Rows[column])
+	}
+	for _, post := range result.Posts {
+		fmt.Fprintf(w, "  文章: %s\n", post)
+	}
+	if len(columns) == 0 && len(result.Posts) == 0 {
+		fmt.Fprintln(w, "  (没有找到引用)")
+	}
+	return nil
+}
+
+// verifyDBRefs 检查数据库和文章中引用的图片是否存在，返回缺失的引用
+func verifyDBRefs(w io.Writer) ([]models.AssetRef, error) {
+	refs, err := models.ListAssetRefs(storage.Default())
+	if err != nil {
+		return nil, err
+	}
+
+	backend := storage.Default()
+	exists := make(map[string]bool)
+	var missing []models.AssetRef
+	for _, ref := range refs {
+		found, checked := exists[ref.Path]
+		if !checked {
+			key, ok := storage.KeyFromAssetPath(ref.Path)
+			found = ok && storage.Exists(backend, key)
+			exists[ref.Path] = found
+		}
+		if !found {
+			missing = append(missing, ref)
+		}
+	}
+
+	fmt.Fprintf(w, "\n引用检查: 共 %d 处引用，%d 个不同路径，缺失 %d 处\n", len(refs), len(exists), len(missing))
+	for _, ref := range missing {
+		fmt.Fprintf(w, "  缺失: %s -> %s\n", ref.Source, ref.Path)
+	}
+	return missing, nil
+}
@@ -4,6 +4,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 )
 
 var (
@@ -11,6 +13,40 @@ var (
 	DbPath    string
 	AssetsDir string
 	AssetPath string // 保留原有变量以保持兼容性
+
+	// 对象存储配置
+	StorageBackend string // local 或 s3，默认 local
+	S3Endpoint     string // S3兼容服务地址，如 http://127.0.0.1:9000
+	S3Region       string
+	S3Bucket       string
+	S3AccessKey    string
+	S3SecretKey    string
+	S3PublicURL    string // 可选，对外访问对象的基础URL（如CDN），为空时通过 /assets 由API转发
+	S3PathStyle    bool   // 是否使用路径风格访问（MinIO需要开启）
+
+	// 图片缩放服务配置
+	ImageSigningKey string // 缩放参数签名密钥，为空时每次启动随机生成
+	ImageCacheDir   string // 缩放结果缓存目录，默认 <资源目录>/../cache/img
+	ImageAVIF       bool   // 是否在WebP之外额外生成AVIF版本（需 -tags avif 编译）
+
+	// 后台任务配置
+	JobWorkers int // 后台任务并发数，默认 2
+
+	// 图像处理工具配置
+	EnhanceWorkers   int           // 图像增强并发数，默认 2
+	EnhanceResultTTL time.Duration // 增强结果保留时间，过期后从存储后端的 handles/ 删除，默认 1 小时
+	EnhanceHTTPURL   string        // http增强后端的服务地址，不放在网站设置中以免公开
+	EnhanceHTTPToken string        // http增强后端的Bearer令牌
+	WatermarkFont    string        // 文字水印字体文件（TTF/OTF），为空时使用内置的Go字体，不含中文
+
+	// 链接有效性检查配置
+	LinkCheckEnabled  bool          // 是否定期检查网盘分享链接，默认开启
+	LinkCheckInterval time.Duration // 有效链接的复查间隔，默认 24 小时
+	LinkCheckRate     int           // 每分钟最多检查的链接数，默认 20
+
+	// 链接举报配置
+	LinkReportLimit  int    // 每个客户端每小时最多提交的举报数，默认 10
+	LinkReportSecret string // 计算客户端标识的HMAC密钥，为空时每次启动随机生成
 )
 
 // 初始化配置
@@ -51,12 +87,63 @@ func init() {
 		log.Printf("使用默认资源目录: %s", AssetsDir)
 	}
 	
+	// 初始化对象存储配置
+	loadStorageConfig()
+	
+	// 初始化图片缩放服务配置
+	loadImageConfig()
+	
+	// 初始化后台任务配置
+	loadJobConfig()
+	
+	// 初始化图像处理工具配置
+	loadImgToolsConfig()
+	
+	// 初始化链接检查和举报配置
+	loadLinkCheckConfig()
+	
 	// 确保目录存在
 	ensureDirExists(filepath.Dir(DbPath))
 	ensureDirExists(AssetsDir)
 	ensureDirExists(filepath.Join(AssetsDir, "uploads"))
 	ensureDirExists(filepath.Join(AssetsDir, "imgs"))
 	ensureDirExists(filepath.Join(AssetsDir, "public"))
+	ensureDirExists(ImageCacheDir)
+}
+
+// loadStorageConfig 从环境变量加载对象存储配置
+func loadStorageConfig() {
+	StorageBackend = os.Getenv("STORAGE_BACKEND")
+	if StorageBackend == "" {
+		StorageBackend = "local"
+	}
+	
+	S3Endpoint = os.Getenv("S3_ENDPOINT")
+	S3Region = os.Getenv("S3_REGION")
+	if S3Region == "" {
+		S3Region = "us-east-1"
+	}
+	S3Bucket = os.Getenv("S3_BUCKET")
+	S3AccessKey = os.Getenv("S3_ACCESS_KEY")
+	S3SecretKey = os.Getenv("S3_SECRET_KEY")
+	S3PublicURL = os.Getenv("S3_PUBLIC_URL")
+	S3PathStyle = os.Getenv("S3_PATH_STYLE") != "false" // 默认开启，兼容MinIO
+	
+	if StorageBackend != "local" {
+		log.Printf("使用对象存储后端: %s (endpoint=%s, bucket=%s)", StorageBackend, S3Endpoint, S3Bucket)
+	}
+}
+
+// loadImageConfig 从环境变量加载图片缩放服务配置
+func loadImageConfig() {
+	ImageSigningKey = os.Getenv("IMAGE_SIGNING_KEY")
+	ImageAVIF = os.Getenv("IMAGE_AVIF") == "true"
+	
+	// 缓存目录不放在资源目录下，避免被静态服务直接暴露
+	ImageCacheDir = os.Getenv("IMAGE_CACHE_PATH")
+	if ImageCacheDir == "" {
+		ImageCacheDir = filepath.Join(AssetsDir, "..", "cache", "img")
+	}
 }
 
 // 确保目录存在
@@ -74,4 +161,81 @@ func GetAssetsDir() string {
 // GetDbPath 获取数据库路径
 func GetDbPath() string {
 	return DbPath
-}
\ No newline at end of file
+}
+
+// loadJobConfig 从环境变量加载后台任务配置
+func loadJobConfig() {
+	JobWorkers = 2
+	if v := os.Getenv("JOB_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			JobWorkers = n
+		} else {
+			log.Printf("无效的JOB_WORKERS: %s，使用默认值 %d", v, JobWorkers)
+		}
+	}
+}
+
+// loadImgToolsConfig 从环境变量加载图像处理工具配置
+func loadImgToolsConfig() {
+	EnhanceWorkers = 2
+	if v := os.Getenv("ENHANCE_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			EnhanceWorkers = n
+		} else {
+			log.Printf("无效的ENHANCE_WORKERS: %s，使用默认值 %d", v, EnhanceWorkers)
+		}
+	}
+
+	EnhanceResultTTL = time.Hour
+	if v := os.Getenv("ENHANCE_RESULT_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			EnhanceResultTTL = d
+		} else {
+			log.Printf("无效的ENHANCE_RESULT_TTL: %s，使用默认值 %s", v, EnhanceResultTTL)
+		}
+	}
+
+	EnhanceHTTPURL = os.Getenv("ENHANCE_HTTP_URL")
+	EnhanceHTTPToken = os.Getenv("ENHANCE_HTTP_TOKEN")
+	WatermarkFont = os.Getenv("WATERMARK_FONT")
+}
+
+// loadLinkCheckConfig 从环境变量加载链接有效性检查和链接举报配置
+func loadLinkCheckConfig() {
+	LinkCheckEnabled = true
+	if v := os.Getenv("LINK_CHECK_ENABLED"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			LinkCheckEnabled = b
+		} else {
+			log.Printf("无效的LINK_CHECK_ENABLED: %s，使用默认值 %v", v, LinkCheckEnabled)
+		}
+	}
+
+	LinkCheckInterval = 24 * time.Hour
+	if v := os.Getenv("LINK_CHECK_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= time.Minute {
+			LinkCheckInterval = d
+		} else {
+			log.Printf("无效的LINK_CHECK_INTERVAL: %s，使用默认值 %s", v, LinkCheckInterval)
+		}
+	}
+
+	LinkCheckRate = 20
+	if v := os.Getenv("LINK_CHECK_RATE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			LinkCheckRate = n
+		} else {
+			log.Printf("无效的LINK_CHECK_RATE: %s，使用默认值 %d", v, LinkCheckRate)
+		}
+	}
+
+	LinkReportLimit = 10
+	if v := os.Getenv("LINK_REPORT_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			LinkReportLimit = n
+		} else {
+			log.Printf("无效的LINK_REPORT_LIMIT: %s，使用默认值 %d", v, LinkReportLimit)
+		}
+	}
+	LinkReportSecret = os.Getenv("LINK_REPORT_SECRET")
+}
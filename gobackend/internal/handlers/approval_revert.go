@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/models"
+	"dongman/internal/utils"
+)
+
+// RevertApprovalRecord 撤销一条审批记录对资源的修改并写入一条撤销记录 - 仅管理员可访问
+// 审批新增的图片和链接被去掉、移除的被加回，其他字段恢复为审批前的值；补充内容审批被撤销后补充内容恢复为待审批。
+// 审批时移入资源目录的图片不会移回，撤销后按新路径引用
+func RevertApprovalRecord(c *gin.Context) {
+	resourceID, errParse := strconv.Atoi(c.Param("id"))
+	if errParse != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的资源ID"})
+		return
+	}
+	recordID, errParse := strconv.Atoi(c.Param("rid"))
+	if errParse != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的审批记录ID"})
+		return
+	}
+
+	// 请求体可选，notes 写入撤销记录的备注
+	var req struct {
+		Notes string `json:"notes"`
+	}
+	if c.Request.ContentLength > 0 {
+		if errBind := c.ShouldBindJSON(&req); errBind != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+			return
+		}
+	}
+
+	var record models.ApprovalRecord
+	errGet := models.DB.Get(&record, `SELECT * FROM approval_records WHERE id = ? AND resource_id = ?`, recordID, resourceID)
+	if errors.Is(errGet, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "审批记录不存在"})
+		return
+	}
+	if errGet != nil {
+		log.Printf("查询审批记录失败: %v", errGet)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询审批记录失败"})
+		return
+	}
+	switch {
+	case record.RevertsRecordID != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "撤销记录不能再撤销"})
+		return
+	case record.RevertedAt != nil:
+		c.JSON(http.StatusConflict, gin.H{"error": "该审批记录已被撤销", "reverted_by_record_id": record.RevertedByRecordID})
+		return
+	case record.Snapshot == nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "该审批记录没有保存修改前后的资源状态，无法撤销"})
+		return
+	}
+
+	tx, errTx := models.DB.Beginx()
+	if errTx != nil {
+		log.Printf("开始事务失败: %v", errTx)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销审批失败"})
+		return
+	}
+	defer tx.Rollback()
+
+	// 先标记原记录以持有写锁，同一记录被并发撤销时只有一次生效
+	now := time.Now()
+	result, errMark := tx.Exec(`UPDATE approval_records SET reverted_at = ? WHERE id = ? AND reverted_at IS NULL`, now, record.ID)
+	if errMark != nil {
+		log.Printf("标记审批记录 %d 失败: %v", record.ID, errMark)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销审批失败"})
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "该审批记录已被撤销"})
+		return
+	}
+
+	var resource models.Resource
+	if errGet := tx.Get(&resource, `SELECT * FROM resources WHERE id = ?`, resourceID); errGet != nil {
+		log.Printf("查询资源失败: %v", errGet)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销审批失败"})
+		return
+	}
+
+	current := models.CaptureResourceState(&resource)
+	restored, conflicts := record.Snapshot.Revert(current, utils.LinkKey)
+	if len(conflicts) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "资源在该审批之后又被修改，无法撤销", "conflicts": conflicts})
+		return
+	}
+	restored.Apply(&resource)
+	resource.UpdatedAt = now
+
+	_, errUpdate := tx.Exec(
+		`UPDATE resources SET
+			status = ?, title = ?, title_en = ?, description = ?, images = ?, poster_image = ?, links = ?,
+			needs_link_replacement = ?, updated_at = ?
+		WHERE id = ?`,
+		resource.Status, resource.Title, resource.TitleEn, resource.Description, resource.Images, resource.PosterImage, resource.Links,
+		resource.NeedsLinkReplacement, resource.UpdatedAt, resourceID,
+	)
+	if errUpdate != nil {
+		log.Printf("更新资源失败: %v", errUpdate)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销审批失败"})
+		return
+	}
+
+	notes := fmt.Sprintf("撤销审批记录 #%d", record.ID)
+	if extra := strings.TrimSpace(req.Notes); extra != "" {
+		notes += "：" + extra
+	}
+	revertRecord := models.ApprovalRecord{
+		ResourceID:           resourceID,
+		Status:               resource.Status,
+		FieldApprovals:       models.JsonMap{},
+		FieldRejections:      models.JsonMap{},
+		ApprovedImages:       models.JsonList{},
+		RejectedImages:       models.JsonList{},
+		Notes:                notes,
+		ApprovedLinks:        models.JsonMap{},
+		RejectedLinks:        models.JsonMap{},
+		IsSupplementApproval: record.IsSupplementApproval,
+		Snapshot:             models.NewApprovalSnapshot(current, restored, nil),
+		RevertsRecordID:      &record.ID,
+		CreatedAt:            now,
+	}
+	revertID, errInsert := models.InsertApprovalRecord(tx, &revertRecord)
+	if errInsert != nil {
+		log.Printf("%v", errInsert)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销审批失败"})
+		return
+	}
+	if _, errLink := tx.Exec(`UPDATE approval_records SET reverted_by_record_id = ? WHERE id = ?`, revertID, record.ID); errLink != nil {
+		log.Printf("关联撤销记录失败: %v", errLink)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销审批失败"})
+		return
+	}
+
+	// 补充内容和新资源恢复为待审批后，提交者查询到的状态也恢复为待审批
+	supplementID, errReopen := models.ReopenSupplement(tx, record.ID, record.Snapshot.Renamed)
+	if errReopen != nil {
+		log.Printf("%v", errReopen)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销审批失败"})
+		return
+	}
+	if supplementID != nil || resource.Status == models.ResourceStatusPending {
+		if errReview := models.ReviewSubmission(tx, resourceID, supplementID, models.ResourceStatusPending, nil); errReview != nil {
+			log.Printf("%v", errReview)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销审批失败"})
+			return
+		}
+	}
+
+	if errCommit := tx.Commit(); errCommit != nil {
+		log.Printf("提交事务失败: %v", errCommit)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销审批失败"})
+		return
+	}
+	log.Printf("已撤销资源 %d 的审批记录 %d，撤销记录ID: %d", resourceID, record.ID, revertID)
+	syncLinkHealth(resourceID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"resource": resource,
+		"record":   revertRecord,
+	})
+}
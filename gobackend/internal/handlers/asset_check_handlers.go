@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/utils"
+)
+
+// assetCheckRunning 防止同时运行多个资源检查或修复
+var assetCheckRunning sync.Mutex
+
+// CheckAssets 检查所有图片引用的完整性 - 仅管理员可访问
+// deep=true 时完整解码每张图片，否则只解析文件头
+func CheckAssets(c *gin.Context) {
+	if !assetCheckRunning.TryLock() {
+		c.JSON(http.StatusConflict, gin.H{"error": "资源检查正在进行中"})
+		return
+	}
+	defer assetCheckRunning.Unlock()
+
+	report, err := utils.CheckAssets(utils.AssetCheckOptions{Deep: c.Query("deep") == "true"})
+	if err != nil {
+		log.Printf("资源检查失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "资源检查失败"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// assetRepairRequest 修复请求
+type assetRepairRequest struct {
+	Confirm bool              `json:"confirm"`
+	Repairs map[string]string `json:"repairs"` // 旧路径 -> 新路径，只能从检查得到的修复建议中选择，为空时应用全部建议
+}
+
+// RepairAssets 把引用缺失文件的位置改写为按文件名找到的同名文件 - 仅管理员可访问
+// 必须显式传入 confirm=true，建议先调用 GET /api/admin/assets/check 查看修复建议
+// 修复前重新检查，只应用按文件名查找得到的建议，不接受任意的路径映射
+func RepairAssets(c *gin.Context) {
+	var req assetRepairRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求数据"})
+		return
+	}
+	if !req.Confirm {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "修复会改写数据库和文章中的图片路径，请设置 confirm 为 true 确认"})
+		return
+	}
+
+	if !assetCheckRunning.TryLock() {
+		c.JSON(http.StatusConflict, gin.H{"error": "资源检查正在进行中"})
+		return
+	}
+	defer assetCheckRunning.Unlock()
+
+	report, err := utils.CheckAssets(utils.AssetCheckOptions{})
+	if err != nil {
+		log.Printf("资源检查失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "资源检查失败"})
+		return
+	}
+	repairs := report.RepairMapping()
+	rejected := []string{}
+	if len(req.Repairs) > 0 {
+		repairs, rejected = report.SelectRepairs(req.Repairs)
+	}
+
+	applied, result, err := utils.RepairAssets(repairs)
+	if err != nil {
+		log.Printf("修复图片引用失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修复图片引用失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "图片引用已修复",
+		"applied":  applied,
+		"skipped":  len(repairs) - len(applied),
+		"rejected": rejected, // 不在修复建议中的旧路径
+		"rows":     result.Rows,
+		"posts":    result.Posts,
+	})
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/storage"
+	"dongman/internal/utils"
+)
+
+// MountAssets 挂载静态资源路由
+// 本地存储直接发送本地文件，其他存储后端由API从存储后端读取后转发
+// 图片请求按 Accept 头优先返回已生成的 .avif、.webp 版本，否则返回原图
+// keyPrefix 为对象key前缀，如 "public" 表示 /public/favicon.ico 对应 public/favicon.ico
+func MountAssets(router gin.IRoutes, urlPrefix, keyPrefix string) {
+	handler := func(c *gin.Context) {
+		key, err := storage.CleanKey(path.Join(keyPrefix, c.Param("filepath")))
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		key = negotiateImageKey(c, key)
+
+		if pather, ok := storage.Default().(storage.LocalPather); ok {
+			serveLocalAsset(c, pather.LocalPath(key))
+			return
+		}
+		serveAsset(c, key)
+	}
+	pattern := strings.TrimSuffix(urlPrefix, "/") + "/*filepath"
+	router.GET(pattern, handler)
+	router.HEAD(pattern, handler)
+}
+
+// negotiateImageKey 根据 Accept 头选择图片的最佳格式版本，返回实际要发送的对象key
+// 可协商的图片总是设置 Vary: Accept，避免缓存把某种格式返回给不支持的客户端
+func negotiateImageKey(c *gin.Context, key string) string {
+	ext := strings.ToLower(path.Ext(key))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+	default:
+		return key
+	}
+	if utils.IsImageSiblingPath(key) {
+		return key
+	}
+	c.Header("Vary", "Accept")
+
+	accept := c.GetHeader("Accept")
+	candidates := []struct {
+		mimeType string
+		suffix   string
+	}{
+		{"image/avif", utils.AVIFSiblingSuffix},
+		{"image/webp", utils.WebPSiblingSuffix},
+	}
+	backend := storage.Default()
+	for _, candidate := range candidates {
+		if ext == candidate.suffix || !acceptsMIME(accept, candidate.mimeType) {
+			continue
+		}
+		siblingKey := utils.ImageSiblingKey(key, candidate.suffix)
+		if storage.Exists(backend, siblingKey) {
+			c.Header("Content-Type", candidate.mimeType)
+			return siblingKey
+		}
+	}
+	return key
+}
+
+// acceptsMIME 判断 Accept 头是否明确接受指定类型（q=0 视为不接受）
+// 通配符不参与判断，只有客户端明确声明支持时才返回新格式
+func acceptsMIME(accept, mimeType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(fields[0]), mimeType) {
+			continue
+		}
+		for _, param := range fields[1:] {
+			name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if !found || !strings.EqualFold(strings.TrimSpace(name), "q") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// serveLocalAsset 发送本地文件，不提供目录列表
+func serveLocalAsset(c *gin.Context, localPath string) {
+	info, err := os.Stat(localPath)
+	if err != nil || info.IsDir() {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.File(localPath)
+}
+
+// serveAsset 从存储后端读取对象并返回给客户端
+func serveAsset(c *gin.Context, key string) {
+	cleaned, err := storage.CleanKey(key)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	backend := storage.Default()
+	info, err := backend.Stat(cleaned)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotExist) {
+			log.Printf("获取资源信息失败 %s: %v", cleaned, err)
+			c.Status(http.StatusBadGateway)
+			return
+		}
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	// 协商缓存
+	if info.ETag != "" {
+		c.Header("ETag", info.ETag)
+		if match := c.GetHeader("If-None-Match"); match != "" && match == info.ETag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+	if !info.LastModified.IsZero() {
+		c.Header("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if info.ContentType != "" {
+		c.Header("Content-Type", info.ContentType)
+	}
+	if info.Size >= 0 {
+		c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+
+	if c.Request.Method == http.MethodHead {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	reader, err := backend.Get(cleaned)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		log.Printf("读取资源失败 %s: %v", cleaned, err)
+		c.Status(http.StatusBadGateway)
+		return
+	}
+	defer reader.Close()
+
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, reader); err != nil {
+		log.Printf("发送资源失败 %s: %v", cleaned, err)
+	}
+}
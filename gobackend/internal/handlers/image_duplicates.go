@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/models"
+	"dongman/internal/storage"
+	"dongman/internal/utils"
+)
+
+// imageOwner 图片所属资源
+type imageOwner struct {
+	ResourceID int
+	Title      string
+}
+
+// duplicateIndex 已有图片的感知哈希索引，一次请求内复用
+type duplicateIndex struct {
+	owners map[string]imageOwner // 图片路径 -> 所属资源
+	hashes map[string]uint64     // 图片路径 -> 感知哈希
+	known  map[string]string     // 数据库中已保存的哈希
+}
+
+// newDuplicateIndex 收集所有资源引用的本站图片及其感知哈希
+func newDuplicateIndex() (*duplicateIndex, error) {
+	var resources []models.Resource
+	if err := models.DB.Select(&resources, `SELECT * FROM resources`); err != nil {
+		return nil, err
+	}
+
+	idx := &duplicateIndex{
+		owners: make(map[string]imageOwner),
+		hashes: make(map[string]uint64),
+		known:  make(map[string]string),
+	}
+	titles := make(map[int]string, len(resources))
+	for _, resource := range resources {
+		titles[resource.ID] = resource.Title
+		owner := imageOwner{ResourceID: resource.ID, Title: resource.Title}
+		for _, img := range resourceImagePaths(resource) {
+			if _, exists := idx.owners[img]; !exists {
+				idx.owners[img] = owner
+			}
+		}
+	}
+
+	// 待审批补充内容中的图片归属于对应的资源
+	supplements, err := models.ListPendingSupplements()
+	if err != nil {
+		return nil, err
+	}
+	for _, supplement := range supplements {
+		owner := imageOwner{ResourceID: supplement.ResourceID, Title: titles[supplement.ResourceID]}
+		for _, img := range supplementImagePaths(supplement.Images) {
+			if _, exists := idx.owners[img]; !exists {
+				idx.owners[img] = owner
+			}
+		}
+	}
+
+	allHashes, err := models.GetAllImageHashes()
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range allHashes {
+		idx.known[h.ImagePath] = h.Hash
+		if _, referenced := idx.owners[h.ImagePath]; !referenced {
+			continue
+		}
+		if hash, err := utils.ParseImageHash(h.Hash); err == nil {
+			idx.hashes[h.ImagePath] = hash
+		}
+	}
+	return idx, nil
+}
+
+// find 查找待审批图片的近似重复图片，按汉明距离升序返回
+func (idx *duplicateIndex) find(resourceID int, images []string) []models.ImageDuplicate {
+	duplicates := []models.ImageDuplicate{}
+	candidates := make(map[string]bool, len(images))
+	for _, img := range images {
+		candidates[img] = true
+	}
+
+	// 待审批图片的哈希只从数据库读取并加入索引，以便同一批图片之间也能互相比较；
+	// 请求中不读取和解码图片，尚未记录哈希的图片交给后台任务计算，计算完成前不参与比较
+	imageHashes := make(map[string]uint64, len(images))
+	var missing []string
+	for _, img := range images {
+		if !strings.HasPrefix(img, storage.AssetURLPrefix) {
+			continue
+		}
+		hexHash, ok := idx.known[img]
+		if !ok {
+			missing = append(missing, img)
+			continue
+		}
+		hash, err := utils.ParseImageHash(hexHash)
+		if err != nil {
+			continue
+		}
+		imageHashes[img] = hash
+		if _, referenced := idx.owners[img]; referenced {
+			idx.hashes[img] = hash
+		}
+	}
+	if len(missing) > 0 {
+		enqueueImageHash(missing)
+	}
+
+	for img, hash := range imageHashes {
+		for match, matchHash := range idx.hashes {
+			if match == img {
+				continue
+			}
+			// 同一批待审批图片之间只报告一次
+			if candidates[match] && match < img {
+				continue
+			}
+			distance := utils.HammingDistance(hash, matchHash)
+			if distance > utils.NearDuplicateThreshold {
+				continue
+			}
+			owner := idx.owners[match]
+			duplicates = append(duplicates, models.ImageDuplicate{
+				Image:         img,
+				Match:         match,
+				Distance:      distance,
+				ResourceID:    owner.ResourceID,
+				ResourceTitle: owner.Title,
+				SameResource:  owner.ResourceID == resourceID,
+			})
+		}
+	}
+
+	sort.Slice(duplicates, func(i, j int) bool {
+		if duplicates[i].Distance != duplicates[j].Distance {
+			return duplicates[i].Distance < duplicates[j].Distance
+		}
+		return duplicates[i].Match < duplicates[j].Match
+	})
+	return duplicates
+}
+
+// resourceImagePaths 返回资源引用的所有本站图片
+func resourceImagePaths(resource models.Resource) []string {
+	var paths []string
+	for _, img := range resource.Images {
+		if strings.HasPrefix(img, storage.AssetURLPrefix) {
+			paths = append(paths, img)
+		}
+	}
+	if resource.PosterImage != nil && strings.HasPrefix(*resource.PosterImage, storage.AssetURLPrefix) {
+		paths = append(paths, *resource.PosterImage)
+	}
+	return paths
+}
+
+// supplementImagePaths 提取补充内容中的本站图片
+func supplementImagePaths(images []string) []string {
+	var paths []string
+	for _, img := range images {
+		if strings.HasPrefix(img, storage.AssetURLPrefix) {
+			paths = append(paths, img)
+		}
+	}
+	return paths
+}
+
+// pendingReviewImages 返回资源中待审核的图片，已审批的资源为待审批补充内容中的图片
+func pendingReviewImages(resource models.Resource, supplement *models.Supplement) []string {
+	if resource.Status == models.ResourceStatusPending {
+		var paths []string
+		for _, img := range resource.Images {
+			if strings.HasPrefix(img, storage.AssetURLPrefix) {
+				paths = append(paths, img)
+			}
+		}
+		if resource.PosterImage != nil && strings.HasPrefix(*resource.PosterImage, storage.AssetURLPrefix) {
+			paths = append(paths, *resource.PosterImage)
+		}
+		return paths
+	}
+	if supplement == nil {
+		return nil
+	}
+	return supplementImagePaths(supplement.Images)
+}
+
+// RebuildImageHashes 为尚未记录感知哈希的已有图片创建后台计算任务 - 仅管理员可访问
+func RebuildImageHashes(c *gin.Context) {
+	idx, err := newDuplicateIndex()
+	if err != nil {
+		log.Printf("查询图片失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询图片失败"})
+		return
+	}
+
+	var missing []string
+	for img := range idx.owners {
+		if _, ok := idx.known[img]; !ok {
+			missing = append(missing, img)
+		}
+	}
+	queued := enqueueImageHash(missing)
+	log.Printf("已为 %d 张缺少感知哈希的图片创建 %d 个计算任务", len(missing), queued)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "感知哈希计算任务已创建",
+		"total":   len(missing),
+		"queued":  queued,
+	})
+}
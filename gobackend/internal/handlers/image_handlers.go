@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/storage"
+	"dongman/internal/utils"
+)
+
+// 缩放结果的浏览器缓存时间，源图更新后ETag会变化
+const resizedImageCacheControl = "public, max-age=86400, stale-while-revalidate=604800"
+
+// ResizeImageHandler 按需生成缩放图片
+// GET /api/img/{path}?preset=list 或 /api/img/{path}?w=&h=&fit=&fmt=&sig=
+// 预设尺寸无需签名，自定义参数必须携带管理员签发的签名，防止任意尺寸消耗服务器资源
+func ResizeImageHandler(c *gin.Context) {
+	key, err := imageKeyFromParam(c.Param("path"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在"})
+		return
+	}
+
+	var opts utils.ResizeOptions
+	if preset := c.Query("preset"); preset != "" {
+		presetOpts, ok := utils.ResizePresets[preset]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的预设尺寸: " + preset})
+			return
+		}
+		opts = presetOpts
+	} else {
+		opts, err = parseResizeQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !utils.VerifyResizeSignature(key, opts, c.Query("sig")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "签名无效"})
+			return
+		}
+	}
+
+	resized, err := utils.ResizeStoredImage(key, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotExist):
+			c.JSON(http.StatusNotFound, gin.H{"error": "图片不存在"})
+		case errors.Is(err, utils.ErrInvalidResizeOptions):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, utils.ErrSourceTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		default:
+			log.Printf("生成缩放图片失败 %s: %v", key, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成缩放图片失败"})
+		}
+		return
+	}
+
+	// http.ServeFile 会根据ETag处理 If-None-Match 并返回304
+	c.Header("ETag", resized.ETag)
+	c.Header("Cache-Control", resizedImageCacheControl)
+	c.Header("Content-Type", resized.ContentType)
+	c.Header("Vary", "Accept-Encoding")
+	c.File(resized.Path)
+}
+
+// SignImageURLHandler 为自定义缩放参数生成签名URL，仅管理员可用
+// GET /api/admin/img/sign?path=/assets/imgs/1/a.webp&w=300&h=450&fit=cover&fmt=webp
+func SignImageURLHandler(c *gin.Context) {
+	key, err := imageKeyFromParam(c.Query("path"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的图片路径"})
+		return
+	}
+
+	opts, err := parseResizeQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := url.Values{}
+	if opts.Width > 0 {
+		query.Set("w", strconv.Itoa(opts.Width))
+	}
+	if opts.Height > 0 {
+		query.Set("h", strconv.Itoa(opts.Height))
+	}
+	query.Set("fit", opts.Fit)
+	query.Set("fmt", opts.Format)
+	query.Set("sig", utils.SignResizeOptions(key, opts))
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":     "/api/img/" + key + "?" + query.Encode(),
+		"presets": utils.ResizePresets,
+	})
+}
+
+// parseResizeQuery 解析并校验 w、h、fit、fmt 查询参数
+func parseResizeQuery(c *gin.Context) (utils.ResizeOptions, error) {
+	opts := utils.ResizeOptions{
+		Fit:    strings.ToLower(c.Query("fit")),
+		Format: strings.ToLower(c.Query("fmt")),
+	}
+
+	var err error
+	if w := c.Query("w"); w != "" {
+		if opts.Width, err = strconv.Atoi(w); err != nil {
+			return opts, errors.New("无效的宽度参数")
+		}
+	}
+	if h := c.Query("h"); h != "" {
+		if opts.Height, err = strconv.Atoi(h); err != nil {
+			return opts, errors.New("无效的高度参数")
+		}
+	}
+
+	if err := opts.Normalize(); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// imageKeyFromParam 将路由参数或 /assets/... 路径转换为对象key
+func imageKeyFromParam(p string) (string, error) {
+	p = "/" + strings.TrimPrefix(p, "/")
+	if key, ok := storage.KeyFromAssetPath(p); ok {
+		return key, nil
+	}
+	return storage.CleanKey(p)
+}
@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"dongman/internal/config"
+	"dongman/internal/models"
+	"dongman/internal/storage"
 	"dongman/internal/utils"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,22 +19,83 @@ import (
 )
 
 // ImageEnhanceRequest 图像增强请求结构
+// 后端和参数为空时使用网站设置 imgtools_config 中的默认值
 type ImageEnhanceRequest struct {
-	SaveResult bool `json:"save_result" form:"save_result"`
+	SaveResult bool     `json:"save_result" form:"save_result"`
+	Backend    string   `json:"backend" form:"backend"` // remote、local、http
+	Scale      int      `json:"scale" form:"scale"`
+	Sharpen    *float64 `json:"sharpen" form:"sharpen"`
 }
 
-// ImageEnhanceResponse 图像增强响应结构
+// enhanceSettingsKey 图像增强默认设置在 site_settings 中的键名
+// 值如 {"enhance_backend": "local", "enhance_scale": 2, "enhance_sharpen": 1, "enhance_http_params": {"model": "x4"}}，
+// http后端的地址和令牌通过环境变量配置，不放在公开的网站设置中
+const enhanceSettingsKey = "imgtools_config"
+
+// resolveEnhancer 按请求参数和网站设置选择增强后端及参数
+func resolveEnhancer(req ImageEnhanceRequest) (utils.Enhancer, utils.EnhanceOptions, error) {
+	var settings models.SiteSettings
+	if err := models.GetDB().Get(&settings, "SELECT * FROM site_settings WHERE setting_key = ?", enhanceSettingsKey); err != nil {
+		settings.SettingValue = models.JsonMap{}
+	}
+
+	backend := req.Backend
+	if backend == "" {
+		backend, _ = settings.SettingValue["enhance_backend"].(string)
+	}
+	if backend == "" {
+		backend = utils.EnhancerRemote
+	}
+	enhancer, err := utils.GetEnhancer(backend)
+	if err != nil {
+		return nil, utils.EnhanceOptions{}, err
+	}
+
+	opts := utils.EnhanceOptions{Scale: req.Scale, Sharpen: utils.DefaultEnhanceSharpen}
+	if opts.Scale == 0 {
+		if v, ok := settings.SettingValue["enhance_scale"].(float64); ok {
+			opts.Scale = int(v)
+		}
+	}
+	if req.Sharpen != nil {
+		opts.Sharpen = *req.Sharpen
+	} else if v, ok := settings.SettingValue["enhance_sharpen"].(float64); ok {
+		opts.Sharpen = v
+	}
+	if backend == utils.EnhancerHTTP {
+		if config.EnhanceHTTPURL == "" {
+			return nil, opts, fmt.Errorf("未配置图像增强服务地址（ENHANCE_HTTP_URL）")
+		}
+		opts.URL = config.EnhanceHTTPURL
+		opts.Token = config.EnhanceHTTPToken
+		if params, ok := settings.SettingValue["enhance_http_params"].(map[string]interface{}); ok {
+			opts.Params = make(map[string]string, len(params))
+			for k, v := range params {
+				opts.Params[k] = fmt.Sprint(v)
+			}
+		}
+	}
+	if err := opts.Normalize(); err != nil {
+		return nil, opts, err
+	}
+	return enhancer, opts, nil
+}
+
+// ImageEnhanceResponse 提交图像增强任务的响应结构
 type ImageEnhanceResponse struct {
-	Success        bool   `json:"success"`
-	Message        string `json:"message,omitempty"`
-	OriginalURL    string `json:"original_url,omitempty"`
-	EnhancedURL    string `json:"enhanced_url,omitempty"`
-	OriginalPath   string `json:"original_path,omitempty"`
-	EnhancedPath   string `json:"enhanced_path,omitempty"`
-	ProcessingTime string `json:"processing_time,omitempty"`
+	Success      bool   `json:"success"`
+	Message      string `json:"message,omitempty"`
+	JobID        string `json:"job_id"`
+	Status       string `json:"status"`
+	Backend      string `json:"backend"`
+	StatusURL    string `json:"status_url"`    // 查询任务状态
+	EventsURL    string `json:"events_url"`    // SSE推送任务进度
+	OriginalURL  string `json:"original_url,omitempty"`
+	OriginalPath string `json:"original_path,omitempty"`
 }
 
-// EnhanceImageHandler 处理图像增强请求
+// EnhanceImageHandler 提交图像增强任务，保存上传的图片后立即返回任务ID
+// 处理进度通过 GET /api/imgtools/jobs/:id 查询或 GET /api/imgtools/jobs/:id/events 订阅
 func EnhanceImageHandler(c *gin.Context) {
 	// 获取上传的文件
 	file, header, err := c.Request.FormFile("image")
@@ -52,13 +117,21 @@ func EnhanceImageHandler(c *gin.Context) {
 		})
 		return
 	}
+	enhancer, opts, err := resolveEnhancer(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 
-	// 确保 handles 目录存在
-	handlesDir := filepath.Join(config.AssetPath, "handles")
-	if err := os.MkdirAll(handlesDir, 0755); err != nil {
+	// 增强后端只处理本地文件：原图先写到临时目录，再保存到存储后端的 handles/ 下供前端访问
+	workDir, err := os.MkdirTemp("", "enhance-*")
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": fmt.Sprintf("创建目录失败: %v", err),
+			"message": fmt.Sprintf("创建临时目录失败: %v", err),
 		})
 		return
 	}
@@ -68,11 +141,13 @@ func EnhanceImageHandler(c *gin.Context) {
 	filename := filepath.Base(header.Filename)
 	ext := filepath.Ext(filename)
 	originalFilename := fmt.Sprintf("original_%s%s", fileID, ext)
-	originalPath := filepath.Join(handlesDir, originalFilename)
+	originalPath := filepath.Join(workDir, originalFilename)
+	originalKey := path.Join(enhanceKeyPrefix, originalFilename)
 
 	// 保存上传的原始图片
 	originalFile, err := os.Create(originalPath)
 	if err != nil {
+		os.RemoveAll(workDir)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"message": fmt.Sprintf("创建文件失败: %v", err),
@@ -84,6 +159,7 @@ func EnhanceImageHandler(c *gin.Context) {
 	// 将上传的文件内容写入磁盘
 	_, err = io.Copy(originalFile, file)
 	if err != nil {
+		os.RemoveAll(workDir)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
 			"message": fmt.Sprintf("保存上传文件失败: %v", err),
@@ -93,93 +169,74 @@ func EnhanceImageHandler(c *gin.Context) {
 	// 确保文件内容已写入
 	originalFile.Close()
 
-	// 开始计时
-	startTime := time.Now()
-
-	// 调用图像增强功能
-	result, err := utils.EnhanceImage(originalPath)
-	if err != nil {
+	if err := saveEnhanceFile(originalKey, originalPath); err != nil {
+		os.RemoveAll(workDir)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": fmt.Sprintf("图像处理失败: %v", err),
+			"message": fmt.Sprintf("保存上传文件失败: %v", err),
 		})
 		return
 	}
 
-	// 结束计时
-	duration := time.Since(startTime)
-
-	// 如果处理失败
-	if !result.Success {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": result.ErrorMessage,
-		})
-		return
+	// 构造URL和相对路径（用于前端显示）
+	baseURL := c.GetHeader("Origin")
+	originalRelPath := strings.TrimPrefix(storage.AssetPath(originalKey), "/")
+	// 本地后端需要按扩展名编码结果，不支持的格式输出为PNG
+	enhancedExt := ext
+	if enhancer.Name() == utils.EnhancerLocal && !isEncodableImageExt(ext) {
+		enhancedExt = ".png"
 	}
-
-	// 构造处理后的图片保存路径
-	enhancedFilename := fmt.Sprintf("enhanced_%s%s", fileID, ext)
-	enhancedPath := filepath.Join(handlesDir, enhancedFilename)
-
-	// 下载并保存增强后的图片
-	err = downloadEnhancedImage(result.EnhancedImage, enhancedPath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	enhancedFilename := fmt.Sprintf("enhanced_%s%s", fileID, enhancedExt)
+
+	now := time.Now()
+	job := &enhanceJob{
+		status: EnhanceJobStatus{
+			ID:           fileID,
+			Status:       enhanceStatusQueued,
+			Stage:        enhanceStageQueued,
+			Backend:      enhancer.Name(),
+			OriginalURL:  fmt.Sprintf("%s/%s", baseURL, originalRelPath),
+			OriginalPath: originalRelPath,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+		changed:      make(chan struct{}),
+		enhancer:     enhancer,
+		opts:         opts,
+		baseURL:      baseURL,
+		workDir:      workDir,
+		originalFile: originalPath,
+		enhancedFile: filepath.Join(workDir, enhancedFilename),
+		originalKey:  originalKey,
+		enhancedKey:  path.Join(enhanceKeyPrefix, enhancedFilename),
+	}
+	if err := submitEnhanceJob(job); err != nil {
+		os.RemoveAll(workDir)
+		storage.Default().Delete(originalKey)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"success": false,
-			"message": fmt.Sprintf("保存增强图片失败: %v", err),
+			"message": err.Error(),
 		})
 		return
 	}
 
-	// 构造URL和相对路径（用于前端显示）
-	baseURL := c.GetHeader("Origin")
-	
-	originalRelPath := filepath.Join("assets", "handles", originalFilename)
-	enhancedRelPath := filepath.Join("assets", "handles", enhancedFilename)
-	
-	originalURL := fmt.Sprintf("%s/%s", baseURL, originalRelPath)
-	enhancedURL := fmt.Sprintf("%s/%s", baseURL, enhancedRelPath)
-
-	// 构造响应
-	response := ImageEnhanceResponse{
-		Success:        true,
-		OriginalURL:    originalURL,
-		EnhancedURL:    enhancedURL,
-		OriginalPath:   originalRelPath,
-		EnhancedPath:   enhancedRelPath,
-		ProcessingTime: duration.String(),
-	}
-
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusAccepted, ImageEnhanceResponse{
+		Success:      true,
+		JobID:        fileID,
+		Status:       enhanceStatusQueued,
+		Backend:      enhancer.Name(),
+		StatusURL:    "/api/imgtools/jobs/" + fileID,
+		EventsURL:    "/api/imgtools/jobs/" + fileID + "/events",
+		OriginalURL:  job.status.OriginalURL,
+		OriginalPath: originalRelPath,
+	})
 }
 
-// 下载增强后的图片并保存到本地
-func downloadEnhancedImage(url, savePath string) error {
-	// 创建HTTP请求
-	resp, err := http.Get(url)
-	if err != nil {
-		return fmt.Errorf("请求图片失败: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// 检查响应状态码
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("请求图片返回非200状态码: %d", resp.StatusCode)
-	}
-
-	// 创建输出文件
-	out, err := os.Create(savePath)
-	if err != nil {
-		return fmt.Errorf("创建输出文件失败: %v", err)
-	}
-	defer out.Close()
-
-	// 将响应内容写入文件
-	_, err = io.Copy(out, resp.Body)
-	if err != nil {
-		return fmt.Errorf("写入图片数据失败: %v", err)
+// isEncodableImageExt 本地后端能否按该扩展名编码结果
+func isEncodableImageExt(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".jpg", ".jpeg", ".png", ".webp":
+		return true
 	}
-
-	return nil
-} 
\ No newline at end of file
+	return false
+}
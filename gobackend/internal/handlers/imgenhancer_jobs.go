@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/config"
+	"dongman/internal/storage"
+	"dongman/internal/utils"
+)
+
+// 图像增强任务状态
+const (
+	enhanceStatusQueued    = "queued"
+	enhanceStatusRunning   = "running"
+	enhanceStatusSucceeded = "succeeded"
+	enhanceStatusFailed    = "failed"
+)
+
+// 图像增强任务的处理阶段，uploading/processing/downloading 由增强后端报告
+const (
+	enhanceStageQueued = "queued"
+	enhanceStageDone   = "done"
+)
+
+// enhanceQueueSize 等待处理的任务上限，超过时拒绝新任务
+const enhanceQueueSize = 100
+
+// enhanceKeyPrefix 原图和增强结果在存储后端中的目录，通过 /assets/handles/ 访问
+const enhanceKeyPrefix = "handles"
+
+// ErrEnhanceQueueFull 等待处理的图像增强任务过多
+var ErrEnhanceQueueFull = errors.New("图像增强任务过多，请稍后再试")
+
+// EnhanceJobStatus 图像增强任务的状态，GET /api/imgtools/jobs/:id 和SSE事件返回该结构
+type EnhanceJobStatus struct {
+	ID             string     `json:"id"`
+	Status         string     `json:"status"`
+	Backend        string     `json:"backend"`
+	Stage          string     `json:"stage"`
+	Progress       int        `json:"progress"`
+	Message        string     `json:"message,omitempty"`
+	OriginalURL    string     `json:"original_url,omitempty"`
+	EnhancedURL    string     `json:"enhanced_url,omitempty"`
+	OriginalPath   string     `json:"original_path,omitempty"`
+	EnhancedPath   string     `json:"enhanced_path,omitempty"`
+	ProcessingTime string     `json:"processing_time,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"` // 任务结束后设置，过期后任务和文件被删除
+}
+
+// finished 任务是否已结束
+func (s EnhanceJobStatus) finished() bool {
+	return s.Status == enhanceStatusSucceeded || s.Status == enhanceStatusFailed
+}
+
+// enhanceJob 一个图像增强任务
+type enhanceJob struct {
+	mu      sync.Mutex
+	status  EnhanceJobStatus
+	changed chan struct{} // 状态变化时关闭并替换，用于通知SSE订阅者
+
+	enhancer     utils.Enhancer
+	opts         utils.EnhanceOptions
+	baseURL      string
+	workDir      string // 增强后端处理用的临时目录，任务结束后删除
+	originalFile string // 临时目录中的原图
+	enhancedFile string // 临时目录中的结果
+	originalKey  string // 原图在存储后端中的key
+	enhancedKey  string // 结果在存储后端中的key
+}
+
+// snapshot 返回当前状态和下次变化时会被关闭的通道
+func (j *enhanceJob) snapshot() (EnhanceJobStatus, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status, j.changed
+}
+
+// update 修改任务状态并通知订阅者
+func (j *enhanceJob) update(fn func(s *EnhanceJobStatus)) {
+	j.mu.Lock()
+	fn(&j.status)
+	j.status.UpdatedAt = time.Now()
+	close(j.changed)
+	j.changed = make(chan struct{})
+	j.mu.Unlock()
+}
+
+// expired 任务是否已结束且超过保留时间
+func (j *enhanceJob) expired(now time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status.ExpiresAt != nil && now.After(*j.status.ExpiresAt)
+}
+
+var (
+	enhanceJobsMu sync.RWMutex
+	enhanceJobs   = make(map[string]*enhanceJob)
+
+	enhanceQueue     = make(chan *enhanceJob, enhanceQueueSize)
+	enhanceStartOnce sync.Once
+)
+
+// StartEnhanceWorkers 启动图像增强worker和过期结果清理
+// 任务只保存在内存中，重启后未完成的任务丢失，残留的文件由清理任务按修改时间删除
+func StartEnhanceWorkers() {
+	enhanceStartOnce.Do(func() {
+		for i := 0; i < config.EnhanceWorkers; i++ {
+			go enhanceWorker()
+		}
+		go enhanceCleanupLoop()
+	})
+}
+
+// submitEnhanceJob 登记任务并放入队列
+func submitEnhanceJob(job *enhanceJob) error {
+	enhanceJobsMu.Lock()
+	defer enhanceJobsMu.Unlock()
+	select {
+	case enhanceQueue <- job:
+	default:
+		return ErrEnhanceQueueFull
+	}
+	enhanceJobs[job.status.ID] = job
+	return nil
+}
+
+// getEnhanceJob 按ID查找任务
+func getEnhanceJob(id string) *enhanceJob {
+	enhanceJobsMu.RLock()
+	defer enhanceJobsMu.RUnlock()
+	return enhanceJobs[id]
+}
+
+// enhanceWorker 循环执行队列中的任务
+func enhanceWorker() {
+	for job := range enhanceQueue {
+		runEnhanceJob(job)
+	}
+}
+
+// runEnhanceJob 执行图像增强并把结果保存到存储后端，panic时标记为失败
+func runEnhanceJob(job *enhanceJob) {
+	defer os.RemoveAll(job.workDir)
+	startTime := time.Now()
+	job.update(func(s *EnhanceJobStatus) {
+		s.Status = enhanceStatusRunning
+	})
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("图像处理时发生严重错误: %v", r)
+			}
+		}()
+
+		err = job.enhancer.Enhance(job.originalFile, job.enhancedFile, job.opts, func(stage string, percent int) {
+			job.update(func(s *EnhanceJobStatus) {
+				s.Stage = stage
+				s.Progress = percent
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("图像处理失败: %v", err)
+		}
+		if err := saveEnhanceFile(job.enhancedKey, job.enhancedFile); err != nil {
+			return fmt.Errorf("保存处理结果失败: %v", err)
+		}
+		return nil
+	}()
+
+	duration := time.Since(startTime)
+	expiresAt := time.Now().Add(config.EnhanceResultTTL)
+	if err != nil {
+		log.Printf("图像增强任务 %s 失败: %v", job.status.ID, err)
+		job.update(func(s *EnhanceJobStatus) {
+			s.Status = enhanceStatusFailed
+			s.Message = err.Error()
+			s.ProcessingTime = duration.String()
+			s.ExpiresAt = &expiresAt
+		})
+		return
+	}
+
+	enhancedRelPath := strings.TrimPrefix(storage.AssetPath(job.enhancedKey), "/")
+	job.update(func(s *EnhanceJobStatus) {
+		s.Status = enhanceStatusSucceeded
+		s.Stage = enhanceStageDone
+		s.Progress = 100
+		s.EnhancedPath = enhancedRelPath
+		s.EnhancedURL = fmt.Sprintf("%s/%s", job.baseURL, enhancedRelPath)
+		s.ProcessingTime = duration.String()
+		s.ExpiresAt = &expiresAt
+	})
+}
+
+// enhanceCleanupLoop 定期删除过期的任务及其文件
+func enhanceCleanupLoop() {
+	interval := config.EnhanceResultTTL / 4
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	if interval > 10*time.Minute {
+		interval = 10 * time.Minute
+	}
+
+	cleanupEnhanceResults()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		cleanupEnhanceResults()
+	}
+}
+
+// saveEnhanceFile 把临时目录中的文件保存到存储后端
+func saveEnhanceFile(key, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	size := int64(-1)
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	return storage.Default().Put(key, file, size, "")
+}
+
+// cleanupEnhanceResults 删除过期的任务和它们在存储后端中的文件，
+// 以及 handles/ 下不属于任何任务且超过保留时间的文件（如重启前的结果），后端不支持列出对象时跳过后者
+func cleanupEnhanceResults() {
+	now := time.Now()
+	backend := storage.Default()
+	live := make(map[string]bool)
+
+	enhanceJobsMu.Lock()
+	var expired []*enhanceJob
+	for id, job := range enhanceJobs {
+		if job.expired(now) {
+			expired = append(expired, job)
+			delete(enhanceJobs, id)
+			continue
+		}
+		live[job.originalKey] = true
+		live[job.enhancedKey] = true
+	}
+	enhanceJobsMu.Unlock()
+
+	removed := 0
+	for _, job := range expired {
+		for _, key := range []string{job.originalKey, job.enhancedKey} {
+			if err := backend.Delete(key); err != nil {
+				log.Printf("删除过期的增强结果失败 %s: %v", key, err)
+				continue
+			}
+			removed++
+		}
+	}
+
+	err := storage.List(backend, enhanceKeyPrefix+"/", func(info storage.ObjectInfo) error {
+		name := path.Base(info.Key)
+		if live[info.Key] || !(strings.HasPrefix(name, "original_") || strings.HasPrefix(name, "enhanced_")) {
+			return nil
+		}
+		if now.Sub(info.LastModified) < config.EnhanceResultTTL {
+			return nil
+		}
+		if err := backend.Delete(info.Key); err == nil {
+			removed++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, storage.ErrListUnsupported) {
+		log.Printf("列出 %s 下的文件失败: %v", enhanceKeyPrefix, err)
+	}
+
+	if removed > 0 {
+		log.Printf("已清理 %d 个过期的图像增强文件", removed)
+	}
+}
+
+// GetEnhanceJob 查询图像增强任务状态
+func GetEnhanceJob(c *gin.Context) {
+	job := getEnhanceJob(c.Param("id"))
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "任务不存在或已过期",
+		})
+		return
+	}
+	status, _ := job.snapshot()
+	c.JSON(http.StatusOK, status)
+}
+
+// StreamEnhanceJob 以SSE推送图像增强任务的进度
+// 每次状态变化发送一个 status 事件，任务结束后发送最终状态并关闭连接
+func StreamEnhanceJob(c *gin.Context) {
+	job := getEnhanceJob(c.Param("id"))
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "任务不存在或已过期",
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁止nginx缓冲事件
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		status, changed := job.snapshot()
+		c.SSEvent("status", status)
+		c.Writer.Flush()
+		if status.finished() {
+			return
+		}
+
+		for waiting := true; waiting; {
+			select {
+			case <-changed:
+				waiting = false
+			case <-heartbeat.C:
+				// 注释行保持连接，避免代理超时断开
+				if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+	}
+}
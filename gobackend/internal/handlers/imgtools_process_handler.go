@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/storage"
+	"dongman/internal/utils"
+)
+
+// processSlots 限制同时处理的图片数，避免占满CPU和内存
+var processSlots = make(chan struct{}, runtime.NumCPU())
+
+// ProcessImageHandler 按操作列表处理上传的图片并直接返回结果 - 公开接口
+// multipart/form-data 字段：image（图片）、ops（JSON数组，见 utils.ImageOp）、watermark（可选，水印图片）
+func ProcessImageHandler(c *gin.Context) {
+	// 两张图片加上表单字段的大小上限
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 2*utils.MaxUploadImageBytes+(1<<20))
+
+	data, filename, err := readFormImage(c, "image")
+	if err != nil {
+		respondProcessError(c, err)
+		return
+	}
+	if data == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请提供图像文件", "code": utils.UploadErrInvalidImage})
+		return
+	}
+
+	var ops []utils.ImageOp
+	if err := json.Unmarshal([]byte(c.PostForm("ops")), &ops); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ops 必须是操作列表的JSON数组", "code": utils.UploadErrInvalidOps})
+		return
+	}
+	if err := utils.ValidateImageOps(ops); err != nil {
+		respondProcessError(c, err)
+		return
+	}
+
+	watermark, _, err := readFormImage(c, "watermark")
+	if err != nil {
+		respondProcessError(c, err)
+		return
+	}
+
+	select {
+	case processSlots <- struct{}{}:
+		defer func() { <-processSlots }()
+	case <-c.Request.Context().Done():
+		return
+	}
+
+	result, err := utils.ProcessImage(data, ops, watermark, loadWatermarkAsset)
+	if err != nil {
+		respondProcessError(c, err)
+		return
+	}
+
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	if result.Format == "jpeg" {
+		name += ".jpg"
+	} else {
+		name += "." + result.Format
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", name))
+	c.Header("X-Image-Width", strconv.Itoa(result.Width))
+	c.Header("X-Image-Height", strconv.Itoa(result.Height))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, result.ContentType, result.Data)
+}
+
+// readFormImage 读取表单中的文件，字段不存在时返回nil
+func readFormImage(c *gin.Context, field string) ([]byte, string, error) {
+	file, header, err := c.Request.FormFile(field)
+	if err != nil {
+		if errors.Is(err, http.ErrMissingFile) {
+			return nil, "", nil
+		}
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, "", &utils.UploadError{Code: utils.UploadErrFileTooLarge, Message: fmt.Sprintf("文件过大，最大支持 %dMB", utils.MaxUploadImageBytes>>20)}
+		}
+		return nil, "", &utils.UploadError{Code: utils.UploadErrInvalidImage, Message: "无效的上传数据"}
+	}
+	defer file.Close()
+
+	if header.Size > utils.MaxUploadImageBytes {
+		return nil, "", &utils.UploadError{Code: utils.UploadErrFileTooLarge, Message: fmt.Sprintf("文件过大，最大支持 %dMB", utils.MaxUploadImageBytes>>20)}
+	}
+	// 多读一个字节用于判断是否超过大小限制，由 utils.ProcessImage 校验
+	data, err := io.ReadAll(io.LimitReader(file, utils.MaxUploadImageBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("读取上传文件失败: %w", err)
+	}
+	return data, header.Filename, nil
+}
+
+// loadWatermarkAsset 从存储读取 /assets/... 水印图片
+func loadWatermarkAsset(assetPath string) ([]byte, error) {
+	key, ok := storage.KeyFromAssetPath(assetPath)
+	if !ok {
+		return nil, storage.ErrNotExist
+	}
+	reader, err := storage.Default().Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, utils.MaxUploadImageBytes+1))
+}
+
+// respondProcessError 校验错误按上传接口的规则返回错误码，其他错误记录日志后返回500
+func respondProcessError(c *gin.Context, err error) {
+	var uploadErr *utils.UploadError
+	if errors.As(err, &uploadErr) {
+		respondUploadError(c, err)
+		return
+	}
+	log.Printf("处理图片失败: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "处理图片失败", "code": utils.UploadErrProcessFailed})
+}
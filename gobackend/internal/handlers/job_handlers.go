@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"dongman/internal/jobs"
+	"dongman/internal/models"
+	"dongman/internal/storage"
+	"dongman/internal/utils"
+)
+
+// JobTypeConvertImage 将单张已批准的图片转换为WebP并生成响应式尺寸
+const JobTypeConvertImage = "convert_image"
+
+// convertImagePayload convert_image 任务参数
+type convertImagePayload struct {
+	ResourceID int    `json:"resource_id"`
+	Path       string `json:"path"`
+}
+
+// JobTypeImageHash 计算单张图片的感知哈希，供近似重复检测使用
+const JobTypeImageHash = "image_hash"
+
+// imageHashPayload image_hash 任务参数
+type imageHashPayload struct {
+	Path string `json:"path"`
+}
+
+// queuedImageHashes 已创建且尚未执行完的感知哈希任务，避免同一图片重复排队
+var queuedImageHashes sync.Map
+
+// RegisterJobHandlers 注册后台任务处理函数，需在 jobs.Start 之前调用
+func RegisterJobHandlers() {
+	jobs.Register(JobTypeConvertImage, handleConvertImageJob)
+	jobs.Register(JobTypeImageHash, handleImageHashJob)
+}
+
+// enqueueImageConversion 为资源的本站图片逐张创建WebP转换任务
+// 每张图片单独一个任务，重试时不会重复处理已成功的图片
+func enqueueImageConversion(resourceID int, imagePaths []string) {
+	queued := 0
+	for _, path := range imagePaths {
+		if !strings.HasPrefix(path, storage.AssetURLPrefix) {
+			log.Printf("[INFO] 跳过非本站图片: %s，不进行WebP转换", path)
+			continue
+		}
+		payload := convertImagePayload{ResourceID: resourceID, Path: path}
+		if _, err := jobs.Enqueue(JobTypeConvertImage, payload); err != nil {
+			log.Printf("[ERROR] 创建WebP转换任务失败 %s: %v", path, err)
+			continue
+		}
+		queued++
+	}
+	if queued > 0 {
+		log.Printf("[INFO] 已为资源ID=%d创建 %d 个WebP转换任务", resourceID, queued)
+	}
+}
+
+// enqueueImageConversionTx 在审批事务中为资源的本站图片创建WebP转换任务，事务提交后需调用 jobs.Wake
+func enqueueImageConversionTx(tx *sqlx.Tx, resourceID int, imagePaths []string) error {
+	for _, path := range imagePaths {
+		if !strings.HasPrefix(path, storage.AssetURLPrefix) {
+			continue
+		}
+		payload := convertImagePayload{ResourceID: resourceID, Path: path}
+		if _, err := jobs.EnqueueTx(tx, JobTypeConvertImage, payload); err != nil {
+			return fmt.Errorf("创建WebP转换任务失败 %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// handleConvertImageJob 执行 convert_image 任务，完成后刷新资源的响应式图片信息
+func handleConvertImageJob(raw json.RawMessage) error {
+	var payload convertImagePayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return jobs.Permanent(fmt.Errorf("解析任务参数失败: %w", err))
+	}
+
+	key, ok := storage.KeyFromAssetPath(payload.Path)
+	if !ok {
+		return jobs.Permanent(fmt.Errorf("无效的图片路径: %s", payload.Path))
+	}
+	if _, err := storage.Default().Stat(key); err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			// 图片已被删除或移动，重试没有意义
+			return jobs.Permanent(fmt.Errorf("图片不存在: %s", payload.Path))
+		}
+		return err
+	}
+
+	if err := utils.OptimizeStoredImage(payload.Path, 0, 0); err != nil {
+		return fmt.Errorf("处理图片失败 %s: %w", payload.Path, err)
+	}
+
+	if err := models.SyncResourceImageVariants(payload.ResourceID); err != nil {
+		log.Printf("[ERROR] 刷新资源ID=%d的响应式图片信息失败: %v", payload.ResourceID, err)
+	}
+	return nil
+}
+
+// enqueueImageHash 为尚未记录感知哈希的本站图片创建计算任务，返回新创建的任务数
+func enqueueImageHash(imagePaths []string) int {
+	queued := 0
+	for _, path := range imagePaths {
+		if !strings.HasPrefix(path, storage.AssetURLPrefix) {
+			continue
+		}
+		if _, loaded := queuedImageHashes.LoadOrStore(path, true); loaded {
+			continue
+		}
+		if _, err := jobs.Enqueue(JobTypeImageHash, imageHashPayload{Path: path}); err != nil {
+			queuedImageHashes.Delete(path)
+			log.Printf("[ERROR] 创建感知哈希任务失败 %s: %v", path, err)
+			continue
+		}
+		queued++
+	}
+	return queued
+}
+
+// handleImageHashJob 执行 image_hash 任务，数据库中已有哈希时直接返回
+func handleImageHashJob(raw json.RawMessage) error {
+	var payload imageHashPayload
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return jobs.Permanent(fmt.Errorf("解析任务参数失败: %w", err))
+	}
+	defer queuedImageHashes.Delete(payload.Path)
+
+	known, err := models.GetImageHashes([]string{payload.Path})
+	if err != nil {
+		return err
+	}
+	if _, ok := known[payload.Path]; ok {
+		return nil
+	}
+
+	key, ok := storage.KeyFromAssetPath(payload.Path)
+	if !ok {
+		return jobs.Permanent(fmt.Errorf("无效的图片路径: %s", payload.Path))
+	}
+	if _, err := storage.Default().Stat(key); err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return jobs.Permanent(fmt.Errorf("图片不存在: %s", payload.Path))
+		}
+		return err
+	}
+	if _, err := utils.EnsureImageHash(payload.Path, known); err != nil {
+		return fmt.Errorf("计算图片感知哈希失败 %s: %w", payload.Path, err)
+	}
+	return nil
+}
+
+// GetJobs 查询后台任务 - 仅管理员可访问
+// status 默认为 FAILED，传 all 查询全部
+func GetJobs(c *gin.Context) {
+	skip, _ := strconv.Atoi(c.DefaultQuery("skip", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if skip < 0 {
+		skip = 0
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	status := models.JobStatus(strings.ToUpper(c.DefaultQuery("status", string(models.JobStatusFailed))))
+	switch status {
+	case "ALL":
+		status = ""
+	case models.JobStatusPending, models.JobStatusRunning, models.JobStatusSucceeded, models.JobStatusFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务状态"})
+		return
+	}
+
+	list, err := models.ListJobs(status, limit, skip)
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询后台任务失败"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// RetryJob 将失败的任务重新排队 - 仅管理员可访问
+func RetryJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	retried, err := models.RetryJob(id)
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重新排队任务失败"})
+		return
+	}
+	if !retried {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在或不是失败状态"})
+		return
+	}
+	jobs.Wake()
+	c.JSON(http.StatusOK, gin.H{"message": "任务已重新排队", "id": id})
+}
+
+// RetryFailedJobs 将所有失败的任务重新排队 - 仅管理员可访问
+func RetryFailedJobs(c *gin.Context) {
+	count, err := models.RetryFailedJobs()
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重新排队任务失败"})
+		return
+	}
+	if count > 0 {
+		jobs.Wake()
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "失败任务已重新排队", "count": count})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/linkcheck"
+	"dongman/internal/models"
+)
+
+// GetBrokenLinks 查询失效链接列表 - 仅管理员可访问
+// status 默认 broken，可选 suspect、ok、unknown、all 或逗号分隔的多个状态
+func GetBrokenLinks(c *gin.Context) {
+	skip, _ := strconv.Atoi(c.DefaultQuery("skip", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if skip < 0 {
+		skip = 0
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	var statuses []models.LinkHealthStatus
+	if status := strings.ToLower(c.DefaultQuery("status", string(models.LinkHealthBroken))); status != "all" {
+		for _, s := range strings.Split(status, ",") {
+			switch st := models.LinkHealthStatus(strings.TrimSpace(s)); st {
+			case models.LinkHealthBroken, models.LinkHealthSuspect, models.LinkHealthOK, models.LinkHealthUnknown:
+				statuses = append(statuses, st)
+			default:
+				c.JSON(http.StatusBadRequest, gin.H{"error": "无效的链接状态"})
+				return
+			}
+		}
+	}
+
+	items, total, err := models.ListLinkHealth(statuses, skip, limit)
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询失效链接失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+}
+
+// RecheckLink 立即重新检查一个链接 - 仅管理员可访问
+func RecheckLink(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的链接ID"})
+		return
+	}
+
+	if err := models.ScheduleLinkCheck(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "链接检查记录不存在"})
+			return
+		}
+		log.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "安排链接检查失败"})
+		return
+	}
+	linkcheck.Wake()
+	c.JSON(http.StatusAccepted, gin.H{"message": "已安排重新检查"})
+}
+
+// syncLinkHealth 资源的链接或状态被修改后同步链接检查列表，与 linkcheck.SyncAll 一致：
+// 已审批的资源按当前链接更新，其他状态或已删除的资源删除检查记录。新增的链接立即安排检查
+func syncLinkHealth(resourceIDs ...int) {
+	for _, id := range resourceIDs {
+		var resource struct {
+			Status models.ResourceStatus `db:"status"`
+			Links  models.JsonMap        `db:"links"`
+		}
+		err := models.DB.Get(&resource, `SELECT status, links FROM resources WHERE id = ?`, id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("查询资源 %d 的链接失败: %v", id, err)
+			continue
+		}
+		var links models.JsonMap
+		if err == nil && resource.Status == models.ResourceStatusApproved {
+			links = resource.Links
+		}
+		if err := linkcheck.SyncResource(id, links); err != nil {
+			log.Printf("同步资源 %d 的链接检查列表失败: %v", id, err)
+		}
+	}
+	linkcheck.Wake()
+}
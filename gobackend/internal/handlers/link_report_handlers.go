@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/config"
+	"dongman/internal/models"
+	"dongman/internal/utils"
+)
+
+// maxLinkReportNoteLength 举报说明的最大字数
+const maxLinkReportNoteLength = 500
+
+// linkReportWindow 举报限流的时间窗口，窗口内每个客户端最多提交 config.LinkReportLimit 次
+const linkReportWindow = time.Hour
+
+// linkReportActions 处理动作对应的举报状态
+var linkReportActions = map[string]models.LinkReportStatus{
+	"dismiss":           models.LinkReportDismissed,
+	"remove":            models.LinkReportRemoved,
+	"needs_replacement": models.LinkReportNeedsReplacement,
+}
+
+// linkReportActionNames 处理结果在审批记录中的说明
+var linkReportActionNames = map[models.LinkReportStatus]string{
+	models.LinkReportDismissed:        "驳回举报",
+	models.LinkReportRemoved:          "移除链接",
+	models.LinkReportNeedsReplacement: "标记需要替换链接",
+}
+
+// clientRateLimiter 按客户端计数的固定窗口限流器，只保存在内存中，重启后清零
+type clientRateLimiter struct {
+	mu      sync.Mutex
+	window  time.Duration
+	clients map[string]*clientWindow
+}
+
+type clientWindow struct {
+	start time.Time
+	count int
+}
+
+func newClientRateLimiter(window time.Duration) *clientRateLimiter {
+	return &clientRateLimiter{window: window, clients: make(map[string]*clientWindow)}
+}
+
+// Allow 记录一次请求，超过 limit 时返回false和需要等待的时间
+func (l *clientRateLimiter) Allow(client string, limit int) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if len(l.clients) > 10000 {
+		for key, w := range l.clients {
+			if now.Sub(w.start) >= l.window {
+				delete(l.clients, key)
+			}
+		}
+	}
+
+	w, ok := l.clients[client]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &clientWindow{start: now}
+		l.clients[client] = w
+	}
+	if w.count >= limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
+
+var linkReportLimiter = newClientRateLimiter(linkReportWindow)
+
+var (
+	clientKeySecret     []byte
+	clientKeySecretOnce sync.Once
+)
+
+// linkReportSecret 获取计算客户端标识的密钥，未配置时生成随机密钥（重启后同一客户端的标识会变化）
+func linkReportSecret() []byte {
+	clientKeySecretOnce.Do(func() {
+		if config.LinkReportSecret != "" {
+			clientKeySecret = []byte(config.LinkReportSecret)
+			return
+		}
+		clientKeySecret = make([]byte, 32)
+		if _, err := rand.Read(clientKeySecret); err != nil {
+			log.Printf("生成链接举报密钥失败: %v", err)
+		}
+		log.Printf("警告：未设置 LINK_REPORT_SECRET，使用随机密钥，重启后同一客户端的重复举报无法去重")
+	})
+	return clientKeySecret
+}
+
+// clientKey 返回客户端IP的HMAC，避免在数据库中保存访客IP；不加密钥的哈希可以穷举IPv4地址还原
+func clientKey(c *gin.Context) string {
+	mac := hmac.New(sha256.New, linkReportSecret())
+	mac.Write([]byte(c.ClientIP()))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// ReportLink 举报资源中的一个链接 - 公开接口，按客户端限流
+func ReportLink(c *gin.Context) {
+	resourceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的资源ID"})
+		return
+	}
+
+	var req models.LinkReportCreate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	if !models.IsLinkReportReason(req.Reason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的举报原因"})
+		return
+	}
+	if req.Category != "" && !models.IsLinkCategory(req.Category) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的链接分类"})
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if utf8.RuneCountInString(req.Note) > maxLinkReportNoteLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("说明不能超过%d个字", maxLinkReportNoteLength)})
+		return
+	}
+	linkKey := strings.TrimSpace(req.LinkKey)
+	if linkKey == "" && strings.TrimSpace(req.URL) != "" {
+		linkKey = utils.LinkKey(req.URL)
+	}
+	if linkKey == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少链接"})
+		return
+	}
+
+	client := clientKey(c)
+	if ok, wait := linkReportLimiter.Allow(client, config.LinkReportLimit); !ok {
+		c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "举报过于频繁，请稍后再试"})
+		return
+	}
+
+	var resource models.Resource
+	err = models.DB.Get(&resource, `SELECT * FROM resources WHERE id = ? AND status = ?`, resourceID, models.ResourceStatusApproved)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "资源未找到"})
+		return
+	}
+
+	// 在资源的链接中查找被举报的链接，指定了分类时只在该分类中查找
+	var category string
+	var link models.Link
+	links := models.ParseLinkMap(resource.Links)
+	for _, cat := range models.LinkCategories {
+		if req.Category != "" && cat != req.Category {
+			continue
+		}
+		for _, l := range links[cat] {
+			if utils.LinkKey(l.URL) == linkKey {
+				category, link = cat, l
+				break
+			}
+		}
+		if category != "" {
+			break
+		}
+	}
+	if category == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "资源中没有该链接"})
+		return
+	}
+
+	report := models.LinkReport{
+		ResourceID: resourceID,
+		Category:   category,
+		LinkKey:    linkKey,
+		URL:        link.URL,
+		Reason:     models.LinkReportReason(req.Reason),
+		Note:       req.Note,
+		Reporter:   submitterName(c),
+		ClientKey:  client,
+	}
+	created, err := models.CreateLinkReport(&report)
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交举报失败"})
+		return
+	}
+	count, err := models.CountOpenLinkReports(resourceID, linkKey)
+	if err != nil {
+		log.Printf("%v", err)
+	}
+
+	if !created {
+		c.JSON(http.StatusOK, gin.H{"message": "你已举报过该链接，正在等待处理", "link_key": linkKey, "report_count": count})
+		return
+	}
+	log.Printf("资源 %d 的链接 %s 被举报: %s（共 %d 条待处理）", resourceID, linkKey, report.Reason, count)
+	c.JSON(http.StatusCreated, gin.H{"message": "举报已提交", "link_key": linkKey, "report_count": count})
+}
+
+// GetLinkReports 按链接聚合的待处理举报队列 - 仅管理员可访问
+func GetLinkReports(c *gin.Context) {
+	skip, _ := strconv.Atoi(c.DefaultQuery("skip", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if skip < 0 {
+		skip = 0
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	items, total, err := models.ListOpenLinkReports(skip, limit)
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询链接举报失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total})
+}
+
+// ResolveLinkReport 处理一个链接的全部待处理举报 - 仅管理员可访问
+// dismiss 驳回举报，remove 从资源中移除该链接，needs_replacement 把资源标记为需要补充新链接，每次处理都写入一条审批记录
+func ResolveLinkReport(c *gin.Context) {
+	reportID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的举报ID"})
+		return
+	}
+
+	var req models.LinkReportResolve
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	action, ok := linkReportActions[req.Action]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的处理动作"})
+		return
+	}
+
+	report, err := models.GetLinkReport(reportID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "举报不存在"})
+			return
+		}
+		log.Printf("查询举报 %d 失败: %v", reportID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询举报失败"})
+		return
+	}
+	if report.Status != models.LinkReportOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "举报已处理", "status": report.Status})
+		return
+	}
+
+	var resource models.Resource
+	if err := models.DB.Get(&resource, `SELECT * FROM resources WHERE id = ?`, report.ResourceID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "资源未找到"})
+		return
+	}
+
+	reasons, err := linkReportReasonSummary(report.ResourceID, report.LinkKey)
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询举报失败"})
+		return
+	}
+
+	notes := fmt.Sprintf("链接举报处理（%s）: [%s] %s，%s", linkReportActionNames[action], report.Category, report.URL, reasons)
+	if req.Notes != "" {
+		notes += "；" + req.Notes
+	}
+	resolution := models.LinkReportResolution{
+		ResourceID: report.ResourceID,
+		LinkKey:    report.LinkKey,
+		Status:     action,
+		ResolvedBy: c.GetString("username"),
+		Record: models.ApprovalRecord{
+			ResourceID:      report.ResourceID,
+			Status:          models.ResourceStatusApproved,
+			FieldApprovals:  models.JsonMap{},
+			FieldRejections: models.JsonMap{},
+			Notes:           notes,
+			ApprovedLinks:   models.JsonMap{},
+			RejectedLinks:   models.JsonMap{},
+			CreatedAt:       time.Now(),
+		},
+	}
+
+	switch action {
+	case models.LinkReportDismissed:
+		resolution.Record.Status = models.ResourceStatusRejected
+	case models.LinkReportRemoved:
+		resolution.RemoveLink = true
+		resolution.Record.FieldApprovals["links"] = true
+	case models.LinkReportNeedsReplacement:
+		resolution.NeedsReplacement = true
+		resolution.Record.FieldApprovals["needs_link_replacement"] = true
+	}
+
+	recordID, closed, err := models.ResolveLinkReports(resolution, utils.LinkKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{"error": "举报已处理"})
+		return
+	}
+	if err != nil {
+		log.Printf("处理资源 %d 的链接举报失败: %v", report.ResourceID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "处理举报失败"})
+		return
+	}
+	log.Printf("资源 %d 的链接 %s 的 %d 条举报已处理: %s", report.ResourceID, report.LinkKey, closed, action)
+
+	// 移除链接后按资源当前的链接同步链接检查列表
+	if resolution.RemoveLink {
+		syncLinkHealth(report.ResourceID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "举报已处理",
+		"action":             req.Action,
+		"closed_reports":     closed,
+		"approval_record_id": recordID,
+	})
+}
+
+// linkReportReasonSummary 汇总链接待处理举报的原因，如 "3 条举报（dead×2, malware×1）"
+func linkReportReasonSummary(resourceID int, linkKey string) (string, error) {
+	var reasons []string
+	err := models.DB.Select(&reasons, `SELECT reason FROM link_reports WHERE resource_id = ? AND link_key = ? AND status = ?`,
+		resourceID, linkKey, models.LinkReportOpen)
+	if err != nil {
+		return "", fmt.Errorf("查询链接举报失败: %w", err)
+	}
+	counts := make(map[string]int)
+	for _, r := range reasons {
+		counts[r]++
+	}
+	parts := make([]string, 0, len(counts))
+	for reason, n := range counts {
+		parts = append(parts, fmt.Sprintf("%s×%d", reason, n))
+	}
+	sort.Strings(parts)
+	return fmt.Sprintf("%d 条举报（%s）", len(reasons), strings.Join(parts, ", ")), nil
+}
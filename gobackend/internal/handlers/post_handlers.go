@@ -1,22 +1,21 @@
 package handlers
 
 import (
-	"io"
+	"bytes"
 	"net/http"
-	"os"
+	"path"
 	"path/filepath"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
-	"dongman/internal/config"
 	"dongman/internal/models"
+	"dongman/internal/storage"
 )
 
 // GetAllPosts 获取所有文章列表
 func GetAllPosts(c *gin.Context) {
-	posts, err := models.GetAllPosts(config.AssetPath)
+	posts, err := models.GetAllPosts(storage.Default())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -28,7 +27,7 @@ func GetAllPosts(c *gin.Context) {
 // GetPostByID 根据ID获取文章
 func GetPostByID(c *gin.Context) {
 	id := c.Param("id")
-	post, err := models.GetPostByID(id, config.AssetPath)
+	post, err := models.GetPostByID(id, storage.Default())
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
 		return
@@ -40,7 +39,7 @@ func GetPostByID(c *gin.Context) {
 // GetPostBySlug 根据Slug获取文章
 func GetPostBySlug(c *gin.Context) {
 	slug := c.Param("slug")
-	post, err := models.GetPostBySlug(slug, config.AssetPath)
+	post, err := models.GetPostBySlug(slug, storage.Default())
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
 		return
@@ -65,7 +64,7 @@ func CreatePost(c *gin.Context) {
 	}
 
 	// 保存文章
-	if err := models.SavePost(&post, config.AssetPath); err != nil {
+	if err := models.SavePost(&post, storage.Default()); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -79,7 +78,7 @@ func UpdatePost(c *gin.Context) {
 	id := c.Param("id")
 	
 	// 检查文章是否存在
-	_, err := models.GetPostByID(id, config.AssetPath)
+	_, err := models.GetPostByID(id, storage.Default())
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "文章不存在"})
 		return
@@ -95,7 +94,7 @@ func UpdatePost(c *gin.Context) {
 	post.ID = id
 
 	// 保存文章
-	if err := models.SavePost(&post, config.AssetPath); err != nil {
+	if err := models.SavePost(&post, storage.Default()); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -108,7 +107,7 @@ func DeletePost(c *gin.Context) {
 	id := c.Param("id")
 	
 	// 删除文章
-	if err := models.DeletePost(id, config.AssetPath); err != nil {
+	if err := models.DeletePost(id, storage.Default()); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -120,7 +119,7 @@ func DeletePost(c *gin.Context) {
 func SearchPosts(c *gin.Context) {
 	query := c.Query("q")
 	
-	posts, err := models.SearchPosts(query, config.AssetPath)
+	posts, err := models.SearchPosts(query, storage.Default())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -139,32 +138,18 @@ func UploadPostImage(c *gin.Context) {
 	}
 	defer file.Close()
 
-	// 检查文件类型
-	if !strings.HasPrefix(header.Header.Get("Content-Type"), "image/") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "只允许上传图片文件"})
-		return
-	}
-
-	// 生成唯一文件名
-	filename := uuid.New().String() + filepath.Ext(header.Filename)
-	
-	// 确保目录存在
-	imgsDir := filepath.Join(config.AssetPath, "posts", "imgs")
-	if err := os.MkdirAll(imgsDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法创建目录"})
-		return
-	}
-
-	// 创建目标文件
-	dst, err := os.Create(filepath.Join(imgsDir, filename))
+	// 按文件内容校验图片并去除元数据
+	sanitized, err := readAndSanitizeImage(file, header)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法创建文件"})
+		respondUploadError(c, err)
 		return
 	}
-	defer dst.Close()
 
-	// 复制文件内容
-	if _, err = io.Copy(dst, file); err != nil {
+	// 生成唯一文件名，扩展名以检测到的真实类型为准
+	filename := uuid.New().String() + sanitized.Ext
+
+	// 保存到存储后端
+	if err := storage.Default().Put(path.Join("posts", "imgs", filename), bytes.NewReader(sanitized.Data), int64(len(sanitized.Data)), "image/"+sanitized.Type); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法保存文件"})
 		return
 	}
@@ -186,24 +171,9 @@ func UploadPostFile(c *gin.Context) {
 
 	// 生成唯一文件名
 	filename := uuid.New().String() + filepath.Ext(header.Filename)
-	
-	// 确保目录存在
-	filesDir := filepath.Join(config.AssetPath, "posts", "files")
-	if err := os.MkdirAll(filesDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法创建目录"})
-		return
-	}
-
-	// 创建目标文件
-	dst, err := os.Create(filepath.Join(filesDir, filename))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法创建文件"})
-		return
-	}
-	defer dst.Close()
 
-	// 复制文件内容
-	if _, err = io.Copy(dst, file); err != nil {
+	// 保存到存储后端
+	if err := storage.Default().Put(path.Join("posts", "files", filename), file, header.Size, ""); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "无法保存文件"})
 		return
 	}
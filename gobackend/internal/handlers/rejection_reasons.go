@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/models"
+)
+
+// GetRejectionReasons 获取驳回原因目录 - 仅管理员可访问
+// enabled=true 时只返回启用的原因（审批界面使用）
+func GetRejectionReasons(c *gin.Context) {
+	reasons, err := models.ListRejectionReasons(c.Query("enabled") == "true")
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询驳回原因失败"})
+		return
+	}
+	c.JSON(http.StatusOK, reasons)
+}
+
+// CreateRejectionReason 新增驳回原因 - 仅管理员可访问
+func CreateRejectionReason(c *gin.Context) {
+	var input models.RejectionReasonInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	input.Code = strings.TrimSpace(input.Code)
+	input.Label = strings.TrimSpace(input.Label)
+	if !models.IsRejectionReasonCode(input.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "原因标识只能包含小写字母、数字和下划线，最长32个字符"})
+		return
+	}
+	if input.Label == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "原因名称不能为空"})
+		return
+	}
+
+	reason := models.RejectionReason{
+		Code:        input.Code,
+		Label:       input.Label,
+		Description: input.Description,
+		Enabled:     true,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if input.Enabled != nil {
+		reason.Enabled = *input.Enabled
+	}
+	if input.SortOrder != nil {
+		reason.SortOrder = *input.SortOrder
+	} else if err := models.DB.Get(&reason.SortOrder, `SELECT COALESCE(MAX(sort_order), 0) + 10 FROM rejection_reasons`); err != nil {
+		log.Printf("查询驳回原因排序失败: %v", err)
+	}
+
+	var count int
+	if err := models.DB.Get(&count, `SELECT COUNT(*) FROM rejection_reasons WHERE code = ?`, reason.Code); err != nil {
+		log.Printf("检查驳回原因是否存在失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建驳回原因失败"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "原因标识已存在"})
+		return
+	}
+
+	result, err := models.DB.Exec(
+		`INSERT INTO rejection_reasons (code, label, description, enabled, sort_order, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		reason.Code, reason.Label, reason.Description, reason.Enabled, reason.SortOrder, reason.CreatedAt, reason.UpdatedAt,
+	)
+	if err != nil {
+		log.Printf("创建驳回原因失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建驳回原因失败"})
+		return
+	}
+	id, _ := result.LastInsertId()
+	reason.ID = int(id)
+
+	c.JSON(http.StatusCreated, reason)
+}
+
+// UpdateRejectionReason 修改驳回原因的名称、说明、启用状态或排序 - 仅管理员可访问
+// 原因标识不能修改；已有审批记录中保存的是审批时的名称，不受影响
+func UpdateRejectionReason(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的驳回原因ID"})
+		return
+	}
+	var input models.RejectionReasonInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	reason, err := models.GetRejectionReason(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "驳回原因不存在"})
+		return
+	}
+	if err != nil {
+		log.Printf("查询驳回原因失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新驳回原因失败"})
+		return
+	}
+	if input.Code != "" && input.Code != reason.Code {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "原因标识不能修改"})
+		return
+	}
+
+	if label := strings.TrimSpace(input.Label); label != "" {
+		reason.Label = label
+	}
+	if input.Description != "" {
+		reason.Description = input.Description
+	}
+	if input.Enabled != nil {
+		reason.Enabled = *input.Enabled
+	}
+	if input.SortOrder != nil {
+		reason.SortOrder = *input.SortOrder
+	}
+	reason.UpdatedAt = time.Now()
+
+	_, err = models.DB.Exec(
+		`UPDATE rejection_reasons SET label = ?, description = ?, enabled = ?, sort_order = ?, updated_at = ? WHERE id = ?`,
+		reason.Label, reason.Description, reason.Enabled, reason.SortOrder, reason.UpdatedAt, reason.ID,
+	)
+	if err != nil {
+		log.Printf("更新驳回原因失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新驳回原因失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reason)
+}
+
+// DeleteRejectionReason 删除驳回原因 - 仅管理员可访问
+// 已有审批记录和提交状态中保存了原因的名称，删除后仍可正常显示
+func DeleteRejectionReason(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的驳回原因ID"})
+		return
+	}
+	result, err := models.DB.Exec(`DELETE FROM rejection_reasons WHERE id = ?`, id)
+	if err != nil {
+		log.Printf("删除驳回原因失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除驳回原因失败"})
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "驳回原因不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "驳回原因已删除"})
+}
@@ -1,24 +1,31 @@
 package handlers
 
 import (
+	"dongman/internal/jobs"
 	"dongman/internal/models"
-	"dongman/internal/config"
 	"dongman/internal/utils"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
 )
 
+// approvalReplayWindow 相同的审批请求在该时间内再次提交时视为重复提交
+const approvalReplayWindow = 2 * time.Minute
+
 // ApproveResource 审批资源 - 仅管理员可访问
+// 审批作为一个整体执行：批准的图片先复制到资源目录，资源更新、审批记录、补充内容状态和WebP转换任务在同一个事务中写入，
+// 事务提交后才删除原图片；任何一步失败都会撤销已复制的图片和数据库修改。重复提交的审批请求不会再次执行
 func ApproveResource(c *gin.Context) {
 	// 获取资源ID
 	resourceID, errParse := strconv.Atoi(c.Param("id"))
@@ -34,6 +41,11 @@ func ApproveResource(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
 		return
 	}
+	approval.Status = models.ResourceStatus(strings.ToUpper(string(approval.Status)))
+	if approval.Status != models.ResourceStatusApproved && approval.Status != models.ResourceStatusRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的审批状态"})
+		return
+	}
 
 	// 检查资源是否存在
 	var resource models.Resource
@@ -43,826 +55,803 @@ func ApproveResource(c *gin.Context) {
 		return
 	}
 
+	// 相同的审批请求在短时间内再次提交（如重复点击）时直接返回当前资源
+	requestHash := approvalRequestHash(resourceID, approval)
+	recent, errRecent := models.FindRecentApprovalRecord(resourceID, requestHash, time.Now().Add(-approvalReplayWindow))
+	if errRecent != nil {
+		log.Printf("[ERROR] %v", errRecent)
+	} else if recent != nil {
+		respondApprovalReplay(c, resourceID)
+		return
+	}
+
+	// 校验驳回原因并从目录中补全名称
+	rejectionReasons, errReasons := models.ResolveRejectionReasons(approval.RejectionReasons)
+	if errors.Is(errReasons, models.ErrInvalidRejectionReason) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errReasons.Error()})
+		return
+	}
+	if errReasons != nil {
+		log.Printf("[ERROR] %v", errReasons)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询驳回原因失败"})
+		return
+	}
+	approval.RejectionReasons = rejectionReasons
+
+	// 确定要审批的补充内容，指定的补充内容已是请求的状态时视为重复提交
+	supplement, errSupplement := pendingSupplementFor(resource, approval.SupplementID)
+	if errors.Is(errSupplement, errSupplementNotPending) && supplement.Status == approval.Status {
+		respondApprovalReplay(c, resourceID)
+		return
+	}
+	if errSupplement != nil {
+		respondSupplementError(c, resourceID, errSupplement)
+		return
+	}
+
+	// 按差异条目ID审批时，展开为图片、链接和字段列表
+	if len(approval.ApprovedEntries) > 0 {
+		diff, errDiff := buildResourceDiff(resource, supplement)
+		if errors.Is(errDiff, errNoPendingChanges) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errDiff.Error()})
+			return
+		}
+		if errDiff != nil {
+			log.Printf("生成资源 %d 的差异失败: %v", resourceID, errDiff)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "生成差异失败"})
+			return
+		}
+		if unknown := applyDiffEntries(&approval, diff); len(unknown) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "差异条目不存在，待审批内容可能已变化", "unknown_entries": unknown})
+			return
+		}
+	}
+
 	// 如果是补充内容审批
-	if resource.Supplement != nil && resource.IsSupplementApproval == false {
-		log.Printf("当前是补充资源审批")
-		approveResourceSupplement(c, resourceID, resource, approval)
-		resource.Status = models.ResourceStatus(strings.ToUpper(string(approval.Status)))
-		resource.UpdatedAt = time.Now()
+	if supplement != nil {
+		log.Printf("当前是补充资源审批，补充内容ID: %d", supplement.ID)
+		approveResourceSupplement(c, resourceID, *supplement, approval, requestHash)
 		return
 	}
 
-	// 更新资源状态
-	resource.Status = models.ResourceStatus(strings.ToUpper(string(approval.Status)))
-	resource.UpdatedAt = time.Now()
+	// 资源已是请求的状态时视为重复提交
+	if resource.Status == approval.Status {
+		respondApprovalReplay(c, resourceID)
+		return
+	}
 
 	log.Printf("当前是初始资源审批 Received approval: %+v", approval)
+	approveInitialResource(c, resource, approval, requestHash)
+}
 
-	// 如果资源被批准，处理图片移动
-	// 保存所有新路径
-	newImagePaths := make([]string, 0, len(approval.ApprovedImages))
-	if strings.ToLower(string(approval.Status)) == strings.ToLower(string(models.ResourceStatusApproved)){
-		// 检查是否没有批准任何图片和链接
-		if len(approval.ApprovedImages) == 0 && len(approval.ApprovedLinks) == 0 {
-			log.Printf("[INFO] 资源ID: %d 被批准但没有批准任何图片和链接，将直接删除该资源", resourceID)
-			
-			// 删除资源
-			_, errDelete := models.DB.Exec(`DELETE FROM resources WHERE id = ?`, resourceID)
-			if errDelete != nil {
-				log.Printf("[ERROR] 删除资源失败: %v", errDelete)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("删除资源失败: %v", errDelete)})
-				return
-			}
-			
-			// 返回成功消息
-			c.JSON(http.StatusOK, gin.H{
-				"message": "资源已删除，因为没有批准任何图片和链接",
-				"deleted": true,
-				"resource_id": resourceID,
-			})
+// approveInitialResource 处理新提交资源的审批
+// 新资源只保留批准的链接，驳回的可选字段被清空；修改已有资源（original_resource_id）时，
+// 批准的字段、图片、海报和链接合并到原资源，并为原资源单独写入一条可撤销的审批记录
+func approveInitialResource(c *gin.Context, resource models.Resource, approval models.ResourceApproval, requestHash string) {
+	resourceID := resource.ID
+	previousStatus := resource.Status
+	approved := approval.Status == models.ResourceStatusApproved
+	before := models.CaptureResourceState(&resource)
+	isEdit := resource.OriginalResourceID != nil
+
+	// 检查是否没有批准任何图片和链接，修改已有资源时批准的字段修改同样有效
+	if approved && len(approval.ApprovedImages) == 0 && len(approval.ApprovedLinks) == 0 && (!isEdit || len(approvedFields(approval)) == 0) {
+		log.Printf("[INFO] 资源ID: %d 被批准但没有批准任何图片和链接，将直接删除该资源", resourceID)
+
+		tx, errTx := models.DB.Beginx()
+		if errTx != nil {
+			log.Printf("[ERROR] 开始事务失败: %v", errTx)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除资源失败"})
 			return
 		}
-		
-		// 移动已批准的图片
-		if len(approval.ApprovedImages) > 0 {
-			log.Printf("[DEBUG] 开始移动已批准的图片，资源ID: %d, 图片数量: %d", resource.ID, len(approval.ApprovedImages))
-			log.Printf("[DEBUG] 原始图片路径: %v", approval.ApprovedImages)
-
-			// 获取assets目录路径
-			assetsDir := config.GetAssetsDir()
-			log.Printf("[DEBUG] Assets目录路径: %s", assetsDir)
-			
-			// 创建目标目录
-			imgsDir := filepath.Join(assetsDir, "imgs", fmt.Sprintf("%d", resourceID))
-			log.Printf("[DEBUG] 创建目标目录: %s", imgsDir)
-			if errMkdir := os.MkdirAll(imgsDir, 0755); errMkdir != nil {
-				log.Printf("[ERROR] 创建目录失败: %v", errMkdir)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建图片目录失败: %v", errMkdir)})
-				return
-			}
-			
-			// 手动执行每个图片的移动操作
-			for _, imgPath := range approval.ApprovedImages {
-				if imgPath == "" {
-					continue
-				}
-				
-				// 检查是否为TMDB外部图片链接
-				if strings.HasPrefix(imgPath, "@https://image.tmdb.org/") {
-					// 对于TMDB外部图片链接，直接保存原始链接，无需移动
-					log.Printf("[DEBUG] 检测到TMDB外部图片链接: %s，直接保存原始链接", imgPath)
-					newImagePaths = append(newImagePaths, imgPath)
-					continue
-				}
-				
-				// 检查是否为TMDB外部图片链接（无@前缀）
-				if strings.HasPrefix(imgPath, "https://image.tmdb.org/") {
-					// 对于TMDB外部图片链接，直接保存原始链接，无需移动
-					log.Printf("[DEBUG] 检测到TMDB外部图片链接: %s，直接保存原始链接", imgPath)
-					newImagePaths = append(newImagePaths, imgPath)
-					continue
-				}
-				
-				// 提取文件名
-				filename := filepath.Base(imgPath)
-				log.Printf("[DEBUG] 处理图片: %s, 文件名: %s", imgPath, filename)
-				
-				// 源文件路径
-				sourcePath := filepath.Join(assetsDir, imgPath[7:]) // 去掉前面的"/assets"
-				
-				// 目标文件路径
-				destPath := filepath.Join(imgsDir, filename)
-				
-				log.Printf("[DEBUG] 移动图片: %s -> %s", sourcePath, destPath)
-				
-				// 检查源文件是否存在
-				if _, errStat := os.Stat(sourcePath); os.IsNotExist(errStat) {
-					log.Printf("[ERROR] 源文件不存在: %s", sourcePath)
-					continue
-				} else {
-					log.Printf("[DEBUG] 源文件存在: %s", sourcePath)
-				}
-				
-				// 确保目标目录存在
-				errDir := os.MkdirAll(filepath.Dir(destPath), 0755)
-				if errDir != nil {
-					log.Printf("[ERROR] 创建目标目录失败: %v", errDir)
-					continue
-				}
-				
-				// 移动文件（复制后删除）
-				// 1. 复制文件
-				sourceFile, errOpen := os.Open(sourcePath)
-				if errOpen != nil {
-					log.Printf("[ERROR] 打开源文件失败: %v", errOpen)
-					continue
-				}
-				defer sourceFile.Close()
+		defer tx.Rollback()
 
-				// 创建目标文件
-				destFile, errCreate := os.Create(destPath)
-				if errCreate != nil {
-					log.Printf("[ERROR] 创建目标文件失败: %v", errCreate)
-					continue
-				}
-				defer destFile.Close()
+		// 删除资源，资源状态已被其他请求修改时不删除
+		result, errDelete := tx.Exec(`DELETE FROM resources WHERE id = ? AND status = ?`, resourceID, previousStatus)
+		if errDelete != nil {
+			log.Printf("[ERROR] 删除资源失败: %v", errDelete)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("删除资源失败: %v", errDelete)})
+			return
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "资源已被其他审批修改，请刷新后重试"})
+			return
+		}
 
-				// 复制内容
-				_, errCopy := io.Copy(destFile, sourceFile)
-				if errCopy != nil {
-					log.Printf("[ERROR] 复制文件内容失败: %v", errCopy)
-					continue
-				}
+		// 对提交者而言等同于驳回
+		if errReview := models.ReviewSubmission(tx, resourceID, nil, models.ResourceStatusRejected, approval.RejectionReasons); errReview != nil {
+			log.Printf("[ERROR] %v", errReview)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除资源失败"})
+			return
+		}
+		if errCommit := tx.Commit(); errCommit != nil {
+			log.Printf("[ERROR] 提交事务失败: %v", errCommit)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "删除资源失败"})
+			return
+		}
 
-				// 关闭文件以确保所有内容都已写入
-				errSource := sourceFile.Close()
-				if errSource != nil {
-					log.Printf("[ERROR] 关闭源文件失败: %v", errSource)
-				}
+		// 返回成功消息
+		c.JSON(http.StatusOK, gin.H{
+			"message": "资源已删除，因为没有批准任何图片和链接",
+			"deleted": true,
+			"resource_id": resourceID,
+		})
+		return
+	}
 
-				errDest := destFile.Close()
-				if errDest != nil {
-					log.Printf("[ERROR] 关闭目标文件失败: %v", errDest)
-				}
+	// 只能批准资源中已有的链接，未批准的链接视为驳回
+	var approvedLinks []map[string]interface{}
+	if approved {
+		keptLinks, linkObjects, foreign := selectApprovedLinks(resource.Links, approval.ApprovedLinks)
+		if len(foreign) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "批准的链接不属于该资源", "invalid_entries": foreign})
+			return
+		}
+		resource.Links = keptLinks
+		approvedLinks = linkObjects
+	}
 
-				// 验证目标文件已创建
-				if _, errStat := os.Stat(destPath); os.IsNotExist(errStat) {
-					log.Printf("[ERROR] 复制后目标文件不存在: %s", destPath)
-					continue
-				} else {
-					log.Printf("[DEBUG] 成功创建目标文件: %s", destPath)
-				}
-				
-				// 2. 删除原文件
-				if errRemove := os.Remove(sourcePath); errRemove != nil {
-					log.Printf("[WARN] 删除源文件失败，将重试: %v", errRemove)
-					time.Sleep(100 * time.Millisecond)
-					if errRetry := os.Remove(sourcePath); errRetry != nil {
-						log.Printf("[ERROR] 第二次删除源文件失败: %v", errRetry)
-					} else {
-						log.Printf("[DEBUG] 第二次尝试删除源文件成功")
-					}
-				} else {
-					log.Printf("[DEBUG] 成功删除源文件: %s", sourcePath)
-				}
-				
-				log.Printf("[INFO] 成功移动图片: %s -> %s", sourcePath, destPath)
-				
-				// 生成新路径并保存
-				newPath := fmt.Sprintf("/assets/imgs/%d/%s", resourceID, filename)
-				log.Printf("[DEBUG] 生成新路径: %s", newPath)
-				newImagePaths = append(newImagePaths, newPath)
+	// 修改已有资源时图片移入原资源的目录
+	targetID := resourceID
+	if isEdit {
+		targetID = *resource.OriginalResourceID
+	}
+
+	// 暂存批准的图片，事务提交前原图片保持不变
+	stage := utils.NewAssetStage()
+	defer stage.Rollback()
+
+	newImagePaths := make([]string, 0, len(approval.ApprovedImages))
+	var posterPath *string
+	if approved {
+		if len(approval.ApprovedImages) > 0 {
+			log.Printf("[DEBUG] 开始暂存已批准的图片，资源ID: %d, 图片数量: %d", resourceID, len(approval.ApprovedImages))
+			staged, errStage := stageApprovedImages(stage, targetID, approval.ApprovedImages)
+			if errStage != nil {
+				stage.Rollback()
+				log.Printf("[ERROR] 资源ID: %d 暂存图片失败，已回滚: %v", resourceID, errStage)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "审批资源失败"})
+				return
 			}
-			
+			newImagePaths = staged
 			resource.Images = newImagePaths
 			log.Printf("[INFO] 变为 %v", resource.Images)
-			
-			// 异步调用WebP转换工具处理批准的图片
-			go func(paths []string) {
-				log.Printf("[INFO] 开始异步转换批准的图片为WebP格式，图片数量: %d", len(paths))
-				convertImagesToWebP(paths)
-			}(newImagePaths)
 		}
-
-		// 处理海报图片
 		if approval.PosterImage != "" {
-			log.Printf("[DEBUG] 开始移动海报图片，资源ID: %d, 原路径: %s", resource.ID, approval.PosterImage)	
-			
-			// 检查是否为TMDB外部图片链接
-			if strings.HasPrefix(approval.PosterImage, "@https://image.tmdb.org/") || 
-			   strings.HasPrefix(approval.PosterImage, "https://image.tmdb.org/") {
-				// 对于TMDB外部图片链接，直接保存原始链接，无需移动
-				log.Printf("[DEBUG] 检测到TMDB外部海报图片链接: %s，直接保存原始链接", approval.PosterImage)
-				posterPath := approval.PosterImage
-				resource.PosterImage = &posterPath
-			} else {
-				// 提取文件名
-				filename := filepath.Base(approval.PosterImage)
-				log.Printf("[DEBUG] 海报文件名: %s", filename)
-				// 生成新路径
-				newPosterPath := fmt.Sprintf("/assets/imgs/%d/%s", resourceID, filename)
-				log.Printf("[INFO] 更新资源海报图片路径，从 %v 变为 %s", resource.PosterImage, newPosterPath)
-				resource.PosterImage = &newPosterPath
-			}
-			
-			// 异步调用WebP转换工具处理海报图片
-			if resource.PosterImage != nil {
-				posterPaths := []string{*resource.PosterImage}
-				go func(paths []string) {
-					log.Printf("[INFO] 开始异步转换海报图片为WebP格式")
-					convertImagesToWebP(paths)
-				}(posterPaths)
+			poster, errStage := stageApprovedPoster(stage, targetID, approval.PosterImage)
+			if errStage != nil {
+				stage.Rollback()
+				log.Printf("[ERROR] 资源ID: %d 暂存海报图片失败，已回滚: %v", resourceID, errStage)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "审批资源失败"})
+				return
 			}
+			resource.PosterImage = poster
+			posterPath = poster
+		}
+		if !isEdit {
+			applyFieldRejections(&resource, approval.FieldRejections)
 		}
 	}
+	resource.Status = approval.Status
+	resource.UpdatedAt = time.Now()
 
 	// 创建审批记录
 	approvalRecord := models.ApprovalRecord{
 		ResourceID:      resourceID,
 		Status:          resource.Status,
-		FieldApprovals:  models.JsonMap{},
-		FieldRejections: models.JsonMap{},
-		ApprovedImages:  approval.ApprovedImages,
+		FieldApprovals:  fieldDecisionsRecord(approval.FieldApprovals),
+		FieldRejections: fieldDecisionsRecord(approval.FieldRejections),
+		ApprovedImages:  models.JsonList(newImagePaths),
 		RejectedImages:  approval.RejectedImages,
 		PosterImage:     approval.PosterImage,
 		Notes:           approval.Notes,
-		ApprovedLinks:   models.JsonMap{},
-		RejectedLinks:   models.JsonMap{},
+		ApprovedLinks:   approvedLinksRecord(approvedLinks),
+		RejectedLinks:   rejectedLinksRecord(approval.RejectedLinks),
+		RequestHash:     requestHash,
+		RejectionReasons: approval.RejectionReasons,
+		Snapshot:        models.NewApprovalSnapshot(before, models.CaptureResourceState(&resource), stage.Renamed()),
 		CreatedAt:       time.Now(),
 	}
-	// 处理批准的链接，将它们追加到原始资源的Links字段中
-	if len(approval.ApprovedLinks) > 0 {
-		log.Printf("[DEBUG] 处理批准的链接，资源ID: %d, 链接数量: %d", resourceID, len(approval.ApprovedLinks))
-		
-		// 如果原始资源的Links字段为空，则初始化
-		if resource.Links == nil {
-			resource.Links = models.JsonMap{}
-		}
-		
-		// 先按category分组链接
-		linksByCategory := make(map[string][]map[string]interface{})
-		// 遍历批准的链接，按category分组
-		for _, link := range approval.ApprovedLinks {
-			// 使用category作为键，将链接添加到对应分组
-			if category, ok := link["category"].(string); ok && category != "" {
-				// 创建不包含category字段的新map
-				linkData := make(map[string]interface{})
-				for k, v := range link {
-					if k != "category" {
-						linkData[k] = v
-					}
-				}
-				
-				linksByCategory[category] = append(linksByCategory[category], linkData)
-			} else {
-				// 如果没有有效的category，使用"unknown"作为键
-				linksByCategory["other"] = append(linksByCategory["other"], link)
-			}
-		}
-
-		log.Printf("[DEBUG] 分组后的链接: %v", linksByCategory)
-		// 赋值给 approvalRecord.ApprovedLinks
-		jsonMap := make(map[string]interface{})
-		for k, v := range linksByCategory {
-			jsonMap[k] = v // []map[string]interface{} 可作为 interface{}
-		}
-		approvalRecord.ApprovedLinks = models.JsonMap(jsonMap)
-	}
-
-
-	// 更新记录
-	log.Printf("[DEBUG] 开始更新数据库记录，资源ID: %d", resourceID)
-	log.Printf("[DEBUG] 资源状态: %s", resource.Status)
-	log.Printf("[DEBUG] 资源图片: %v", resource.Images)
-	log.Printf("[DEBUG] 资源海报图片: %v", resource.PosterImage)
-	
-	// approval_records插入审批记录
-	result, errInsert := models.DB.Exec(
-		`INSERT INTO approval_records (
-			resource_id, status, field_approvals, field_rejections,
-			approved_images, rejected_images, poster_image, notes,
-			approved_links, rejected_links, created_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		approvalRecord.ResourceID, approvalRecord.Status,
-		approvalRecord.FieldApprovals, approvalRecord.FieldRejections,
-		models.JsonList(newImagePaths), approvalRecord.RejectedImages,
-		approvalRecord.PosterImage, approvalRecord.Notes,
-		approvalRecord.ApprovedLinks, approvalRecord.RejectedLinks,
-		approvalRecord.CreatedAt,
-	)
 
-	if errInsert != nil {
-		log.Printf("创建审批记录失败: %v", errInsert)
-		// 继续处理，不要因为审批记录创建失败而中断流程
-	} else {
-		id, _ := result.LastInsertId()
-		log.Printf("已创建审批记录，ID: %d", id)
+	tx, errTx := models.DB.Beginx()
+	if errTx != nil {
+		log.Printf("[ERROR] 开始事务失败: %v", errTx)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "审批资源失败"})
+		return
 	}
+	defer tx.Rollback()
 
-		
-	
-	// resources 更新资源
-	var errUpdate error
-	_, errUpdate = models.DB.Exec(
+	// 以审批前的状态为条件更新资源，重复提交或并发审批时只有一次生效
+	result, errUpdate := tx.Exec(
 		`UPDATE resources SET 
-			status = ?, images = ?, poster_image = ?, 
+			status = ?, title_en = ?, description = ?, images = ?, poster_image = ?, links = ?,
 			approval_history = ?, updated_at = ?
-		WHERE id = ?`,
-		resource.Status, resource.Images, resource.PosterImage,
-		resource.ApprovalHistory, resource.UpdatedAt, resource.ID,
+		WHERE id = ? AND status = ?`,
+		resource.Status, resource.TitleEn, resource.Description, resource.Images, resource.PosterImage, resource.Links,
+		resource.ApprovalHistory, resource.UpdatedAt, resource.ID, previousStatus,
 	)
-
 	if errUpdate != nil {
 		log.Printf("[ERROR] 更新资源失败: %v", errUpdate)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新资源失败: %v", errUpdate)})
 		return
 	}
-	
-	log.Printf("[INFO] 成功更新资源，ID: %d", resourceID)
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		tx.Rollback()
+		stage.Rollback()
+		respondApprovalConflict(c, resourceID, approval.Status)
+		return
+	}
+
+	recordID, errInsert := models.InsertApprovalRecord(tx, &approvalRecord)
+	if errInsert != nil {
+		log.Printf("[ERROR] %v", errInsert)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建审批记录失败"})
+		return
+	}
+
+	// 修改已有资源：把批准的内容合并到原资源
+	var duplicateLinks []models.LinkDuplicate
+	if approved && isEdit {
+		duplicates, errApply := applyApprovedEdit(tx, resource, approval, newImagePaths, posterPath, approvedLinks, stage.Renamed())
+		if errApply != nil {
+			log.Printf("[ERROR] 资源ID: %d 合并到原资源 %d 失败: %v", resourceID, targetID, errApply)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "审批资源失败"})
+			return
+		}
+		duplicateLinks = duplicates
+	}
+
+	// 提交者凭跟踪令牌可以看到审批结果和驳回原因
+	if errReview := models.ReviewSubmission(tx, resourceID, nil, resource.Status, approval.RejectionReasons); errReview != nil {
+		log.Printf("[ERROR] %v", errReview)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "审批资源失败"})
+		return
+	}
+
+	if errCommit := commitApproval(tx, stage, targetID, convertiblePaths(newImagePaths, posterPath)); errCommit != nil {
+		log.Printf("[ERROR] 资源ID: %d 审批失败，已回滚: %v", resourceID, errCommit)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "审批资源失败"})
+		return
+	}
+	log.Printf("[INFO] 成功审批资源，ID: %d，审批记录ID: %d", resourceID, recordID)
+	if targetID != resourceID {
+		syncLinkHealth(resourceID, targetID)
+	} else {
+		syncLinkHealth(resourceID)
+	}
 
 	// 再次从数据库获取资源，确保返回最新数据
-	errGet = models.DB.Get(&resource, `SELECT * FROM resources WHERE id = ?`, resourceID)
-	if errGet != nil {
+	if errGet := models.DB.Get(&resource, `SELECT * FROM resources WHERE id = ?`, resourceID); errGet != nil {
 		log.Printf("警告：获取更新后的资源失败，但资源已更新: %v", errGet)
 	}
+	resource.DuplicateLinks = duplicateLinks
 
 	c.JSON(http.StatusOK, resource)
 }
 
-// approveResourceSupplement 处理资源补充内容的审批
-func approveResourceSupplement(c *gin.Context, resourceID int, resource models.Resource, approval models.ResourceApproval) {
-	log.Printf("处理资源补充内容审批，资源ID: %d", resourceID)
+// editableFields 修改已有资源时可以按字段批准的文本字段
+var editableFields = []string{"title", "title_en", "description"}
 
-	// 检查补充内容是否存在且状态为待审批
-	if resource.Supplement == nil {
-		log.Printf("资源 %d 没有补充内容", resourceID)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "资源没有补充内容"})
-		return
+// approvedFields 返回批准的文本字段
+func approvedFields(approval models.ResourceApproval) []string {
+	var fields []string
+	for _, field := range editableFields {
+		if approval.FieldApprovals[field] {
+			fields = append(fields, field)
+		}
 	}
+	return fields
+}
 
-	status, ok := resource.Supplement["status"]
-	if !ok {
-		log.Printf("资源 %d 的补充内容没有status字段", resourceID)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "补充内容缺少状态信息"})
-		return
+// applyFieldRejections 清空新资源中被驳回的可选字段；标题是必填字段，只能驳回整个资源
+func applyFieldRejections(resource *models.Resource, rejections map[string]bool) {
+	if rejections["title_en"] {
+		resource.TitleEn = ""
+	}
+	if rejections["description"] {
+		resource.Description = ""
+	}
+	if rejections["poster_image"] {
+		resource.PosterImage = nil
 	}
+}
 
-	statusStr, ok := status.(string)
-	if !ok {
-		log.Printf("资源 %d 的补充内容status字段不是字符串", resourceID)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "补充内容状态格式错误"})
-			return
-		}
-		
-	if statusStr != string(models.ResourceStatusPending) {
-		log.Printf("资源 %d 的补充内容状态不是待审批: %s", resourceID, statusStr)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "补充内容不是待审批状态"})
-			return
+// applyApprovedEdit 在审批事务中把修改的批准内容合并到原资源，并为原资源写入审批记录，返回因已存在而跳过的链接
+// 批准的字段使用修改中的新值，图片追加、链接去重后追加，与审批补充内容相同
+func applyApprovedEdit(tx *sqlx.Tx, edit models.Resource, approval models.ResourceApproval, newImagePaths []string, posterPath *string, approvedLinks []map[string]interface{}, renamed map[string]string) ([]models.LinkDuplicate, error) {
+	var original models.Resource
+	if err := tx.Get(&original, `SELECT * FROM resources WHERE id = ?`, *edit.OriginalResourceID); err != nil {
+		return nil, fmt.Errorf("查询原资源失败: %w", err)
+	}
+	before := models.CaptureResourceState(&original)
+
+	duplicateLinks := mergeApprovedSupplement(&original, newImagePaths, posterPath, approvedLinks)
+	for _, field := range approvedFields(approval) {
+		switch field {
+		case "title":
+			if edit.Title != "" {
+				original.Title = edit.Title
+			}
+		case "title_en":
+			if edit.TitleEn != "" {
+				original.TitleEn = edit.TitleEn
+			}
+		case "description":
+			if edit.Description != "" {
+				original.Description = edit.Description
+			}
 		}
-		
-	// 创建补充内容审批记录
-	approvalRecord := models.ApprovalRecord{
-		ResourceID:           resourceID,
-		Status:               models.ResourceStatus(strings.ToUpper(string(approval.Status))),
-		FieldApprovals:       models.JsonMap{},
-		FieldRejections:      models.JsonMap{},
-		ApprovedImages:       approval.ApprovedImages,
-		RejectedImages:       approval.RejectedImages,
-		PosterImage:          approval.PosterImage,
-		Notes:                approval.Notes,
-		ApprovedLinks:        models.JsonMap{},
-		RejectedLinks:        models.JsonMap{},
-		IsSupplementApproval: true,
-		CreatedAt:            time.Now(),
 	}
+	original.UpdatedAt = time.Now()
 
-	// 转换字段审批信息
-	if approval.FieldApprovals != nil {
-		for k, v := range approval.FieldApprovals {
-			approvalRecord.FieldApprovals[k] = v
-		}
+	_, err := tx.Exec(
+		`UPDATE resources SET
+			title = ?, title_en = ?, description = ?, images = ?, poster_image = ?, links = ?,
+			needs_link_replacement = ?, updated_at = ?
+		WHERE id = ?`,
+		original.Title, original.TitleEn, original.Description, original.Images, original.PosterImage, original.Links,
+		original.NeedsLinkReplacement, original.UpdatedAt, original.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("更新原资源失败: %w", err)
+	}
+
+	record := models.ApprovalRecord{
+		ResourceID:      original.ID,
+		Status:          approval.Status,
+		FieldApprovals:  fieldDecisionsRecord(approval.FieldApprovals),
+		FieldRejections: fieldDecisionsRecord(approval.FieldRejections),
+		ApprovedImages:  models.JsonList(newImagePaths),
+		RejectedImages:  approval.RejectedImages,
+		PosterImage:     approval.PosterImage,
+		Notes:           strings.TrimSpace(fmt.Sprintf("合并修改 #%d %s", edit.ID, approval.Notes)),
+		ApprovedLinks:   approvedLinksRecord(approvedLinks),
+		RejectedLinks:   rejectedLinksRecord(approval.RejectedLinks),
+		Snapshot:        models.NewApprovalSnapshot(before, models.CaptureResourceState(&original), renamed),
+		CreatedAt:       time.Now(),
+	}
+	if _, err := models.InsertApprovalRecord(tx, &record); err != nil {
+		return nil, err
 	}
+	return duplicateLinks, nil
+}
 
-	if approval.FieldRejections != nil {
-		for k, v := range approval.FieldRejections {
-			approvalRecord.FieldRejections[k] = v
+// selectApprovedLinks 按规范键从资源的链接中选出批准的链接
+// 返回保留的链接、带 category 字段的批准链接对象，以及资源中没有的链接
+func selectApprovedLinks(links models.JsonMap, approved []map[string]interface{}) (models.JsonMap, []map[string]interface{}, []string) {
+	available := make(map[string]bool)
+	for _, items := range models.ParseLinkMap(links) {
+		for _, link := range items {
+			available[utils.LinkKey(link.URL)] = true
 		}
 	}
+	keys := make(map[string]bool, len(approved))
+	var foreign []string
+	for _, link := range approved {
+		url, _ := link["url"].(string)
+		key := utils.LinkKey(url)
+		if url == "" || !available[key] {
+			foreign = append(foreign, url)
+			continue
+		}
+		keys[key] = true
+	}
 
-	// 处理批准的链接，将它们追加到原始资源的Links字段中
-	if len(approval.ApprovedLinks) > 0 {
-		log.Printf("[DEBUG] 处理批准的链接，资源ID: %d, 链接数量: %d", resourceID, len(approval.ApprovedLinks))
-		
-		// 如果原始资源的Links字段为空，则初始化
-		if resource.Links == nil {
-			resource.Links = models.JsonMap{}
+	kept := models.JsonMap{}
+	var objects []map[string]interface{}
+	for _, category := range models.LinkCategories {
+		value, ok := links[category]
+		if !ok {
+			continue
 		}
-		
-		// 先按category分组链接
-		linksByCategory := make(map[string][]map[string]interface{})
-		// 遍历批准的链接，按category分组
-		for _, link := range approval.ApprovedLinks {
-			// 使用category作为键，将链接添加到对应分组
-			if category, ok := link["category"].(string); ok && category != "" {
-				// 创建不包含category字段的新map
-				linkData := make(map[string]interface{})
-				for k, v := range link {
-					if k != "category" {
-						linkData[k] = v
-					}
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		var keptItems []interface{}
+		for _, item := range items {
+			link, err := models.LinkFromValue(item)
+			if err != nil || !keys[utils.LinkKey(link.URL)] {
+				continue
+			}
+			keptItems = append(keptItems, item)
+			object := map[string]interface{}{"url": link.URL}
+			if m, ok := item.(map[string]interface{}); ok {
+				for k, v := range m {
+					object[k] = v
 				}
-				
-				linksByCategory[category] = append(linksByCategory[category], linkData)
-			} else {
-				// 如果没有有效的category，使用"other"作为键
-				linksByCategory["other"] = append(linksByCategory["other"], link)
 			}
+			object["category"] = category
+			objects = append(objects, object)
 		}
-
-		log.Printf("[DEBUG] 分组后的链接: %v", linksByCategory)
-		// 赋值给 approvalRecord.ApprovedLinks
-		jsonMap := make(map[string]interface{})
-		for k, v := range linksByCategory {
-			jsonMap[k] = v // []map[string]interface{} 可作为 interface{}
+		if len(keptItems) > 0 {
+			kept[category] = keptItems
 		}
-		approvalRecord.ApprovedLinks = models.JsonMap(jsonMap)
 	}
+	return kept, objects, foreign
+}
 
-	if len(approval.RejectedLinks) > 0 {
-		linksMap := make(map[string]interface{})
-		for i, link := range approval.RejectedLinks {
-			linksMap[fmt.Sprintf("link_%d", i)] = link
-		}
-		approvalRecord.RejectedLinks = linksMap
+// fieldDecisionsRecord 把字段的批准或驳回写入审批记录
+func fieldDecisionsRecord(decisions map[string]bool) models.JsonMap {
+	record := models.JsonMap{}
+	for k, v := range decisions {
+		record[k] = v
 	}
+	return record
+}
 
-	// 保存所有新路径
-	newImagePaths := make([]string, 0, len(approval.ApprovedImages))
-	
-	// 如果资源被批准，处理图片移动
-	if strings.ToLower(string(approval.Status)) == strings.ToLower(string(models.ResourceStatusApproved)) {
-		// 移动已批准的图片
-		if len(approval.ApprovedImages) > 0 {
-			log.Printf("[DEBUG] 开始移动已批准的补充图片，资源ID: %d, 图片数量: %d", resource.ID, len(approval.ApprovedImages))
-			log.Printf("[DEBUG] 原始图片路径: %v", approval.ApprovedImages)
-
-			// 获取assets目录路径
-			assetsDir := config.GetAssetsDir()
-			log.Printf("[DEBUG] Assets目录路径: %s", assetsDir)
-			
-			// 创建目标目录
-			imgsDir := filepath.Join(assetsDir, "imgs", fmt.Sprintf("%d", resourceID))
-			log.Printf("[DEBUG] 创建目标目录: %s", imgsDir)
-			if errMkdir := os.MkdirAll(imgsDir, 0755); errMkdir != nil {
-				log.Printf("[ERROR] 创建目录失败: %v", errMkdir)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建图片目录失败: %v", errMkdir)})
-				return
-			}
-			
-			// 手动执行每个图片的移动操作
-			for _, imgPath := range approval.ApprovedImages {
-				if imgPath == "" {
-					continue
-				}
-				
-				// 检查是否为TMDB外部图片链接
-				if strings.HasPrefix(imgPath, "@https://image.tmdb.org/") {
-					// 对于TMDB外部图片链接，直接保存原始链接，无需移动
-					log.Printf("[DEBUG] 检测到TMDB外部图片链接: %s，直接保存原始链接", imgPath)
-					newImagePaths = append(newImagePaths, imgPath)
-					continue
-				}
-				
-				// 检查是否为TMDB外部图片链接（无@前缀）
-				if strings.HasPrefix(imgPath, "https://image.tmdb.org/") {
-					// 对于TMDB外部图片链接，直接保存原始链接，无需移动
-					log.Printf("[DEBUG] 检测到TMDB外部图片链接: %s，直接保存原始链接", imgPath)
-					newImagePaths = append(newImagePaths, imgPath)
-					continue
-				}
-				
-				// 提取文件名
-				filename := filepath.Base(imgPath)
-				log.Printf("[DEBUG] 处理图片: %s, 文件名: %s", imgPath, filename)
-				
-				// 源文件路径
-				sourcePath := filepath.Join(assetsDir, imgPath[7:]) // 去掉前面的"/assets"
-				
-				// 目标文件路径
-				destPath := filepath.Join(imgsDir, filename)
-				
-				log.Printf("[DEBUG] 移动图片: %s -> %s", sourcePath, destPath)
-				
-				// 检查源文件是否存在
-				if _, errStat := os.Stat(sourcePath); os.IsNotExist(errStat) {
-					log.Printf("[ERROR] 源文件不存在: %s", sourcePath)
-					continue
-				} else {
-					log.Printf("[DEBUG] 源文件存在: %s", sourcePath)
-				}
-				
-				// 确保目标目录存在
-				errDir := os.MkdirAll(filepath.Dir(destPath), 0755)
-				if errDir != nil {
-					log.Printf("[ERROR] 创建目标目录失败: %v", errDir)
-					continue
-				}
-				
-				// 移动文件（复制后删除）
-				// 1. 复制文件
-				sourceFile, errOpen := os.Open(sourcePath)
-				if errOpen != nil {
-					log.Printf("[ERROR] 打开源文件失败: %v", errOpen)
-					continue
-				}
-				defer sourceFile.Close()
+// rejectedLinksRecord 把驳回的链接写入审批记录
+func rejectedLinksRecord(links []map[string]interface{}) models.JsonMap {
+	record := models.JsonMap{}
+	for i, link := range links {
+		record[fmt.Sprintf("link_%d", i)] = link
+	}
+	return record
+}
 
-				// 创建目标文件
-				destFile, errCreate := os.Create(destPath)
-				if errCreate != nil {
-					log.Printf("[ERROR] 创建目标文件失败: %v", errCreate)
-					continue
-				}
-				defer destFile.Close()
+// approveResourceSupplement 处理资源补充内容的审批
+func approveResourceSupplement(c *gin.Context, resourceID int, supplement models.Supplement, approval models.ResourceApproval, requestHash string) {
+	log.Printf("处理资源补充内容审批，资源ID: %d, 补充内容ID: %d", resourceID, supplement.ID)
+	approved := approval.Status == models.ResourceStatusApproved
 
-				// 复制内容
-				_, errCopy := io.Copy(destFile, sourceFile)
-				if errCopy != nil {
-					log.Printf("[ERROR] 复制文件内容失败: %v", errCopy)
-					continue
-				}
+	// 只能批准该补充内容中的图片和链接，否则会把其他补充内容或资源的文件移入本资源目录
+	if foreign := foreignSupplementEntries(supplement, approval); len(foreign) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "批准的图片或链接不属于该补充内容", "invalid_entries": foreign})
+		return
+	}
 
-				// 关闭文件以确保所有内容都已写入
-				errSource := sourceFile.Close()
-				if errSource != nil {
-					log.Printf("[ERROR] 关闭源文件失败: %v", errSource)
-				}
+	// 创建补充内容审批记录
+	approvalRecord := models.ApprovalRecord{
+		ResourceID:           resourceID,
+		Status:               approval.Status,
+		FieldApprovals:       fieldDecisionsRecord(approval.FieldApprovals),
+		FieldRejections:      fieldDecisionsRecord(approval.FieldRejections),
+		ApprovedImages:       models.JsonList{},
+		RejectedImages:       approval.RejectedImages,
+		PosterImage:          approval.PosterImage,
+		Notes:                approval.Notes,
+		ApprovedLinks:        approvedLinksRecord(approval.ApprovedLinks),
+		RejectedLinks:        rejectedLinksRecord(approval.RejectedLinks),
+		IsSupplementApproval: true,
+		RequestHash:          requestHash,
+		RejectionReasons:     approval.RejectionReasons,
+		CreatedAt:            time.Now(),
+	}
 
-				errDest := destFile.Close()
-				if errDest != nil {
-					log.Printf("[ERROR] 关闭目标文件失败: %v", errDest)
-				}
 
-				// 验证目标文件已创建
-				if _, errStat := os.Stat(destPath); os.IsNotExist(errStat) {
-					log.Printf("[ERROR] 复制后目标文件不存在: %s", destPath)
-					continue
-				} else {
-					log.Printf("[DEBUG] 成功创建目标文件: %s", destPath)
-				}
-				
-				// 2. 删除原文件
-				if errRemove := os.Remove(sourcePath); errRemove != nil {
-					log.Printf("[WARN] 删除源文件失败，将重试: %v", errRemove)
-					time.Sleep(100 * time.Millisecond)
-					if errRetry := os.Remove(sourcePath); errRetry != nil {
-						log.Printf("[ERROR] 第二次删除源文件失败: %v", errRetry)
-					} else {
-						log.Printf("[DEBUG] 第二次尝试删除源文件成功")
-					}
-				} else {
-					log.Printf("[DEBUG] 成功删除源文件: %s", sourcePath)
-				}
-				
-				log.Printf("[INFO] 成功移动图片: %s -> %s", sourcePath, destPath)
-				
-				// 生成新路径并保存
-				newPath := fmt.Sprintf("/assets/imgs/%d/%s", resourceID, filename)
-				log.Printf("[DEBUG] 生成新路径: %s", newPath)
-				newImagePaths = append(newImagePaths, newPath)
-			}
-			
-			// 获取资源当前的图片
-			currentImages := resource.Images
-			if currentImages == nil {
-				currentImages = []string{}
+	// 暂存批准的图片，事务提交前原图片保持不变
+	stage := utils.NewAssetStage()
+	defer stage.Rollback()
+
+	var posterPath *string
+	if approved {
+		if len(approval.ApprovedImages) > 0 {
+			log.Printf("[DEBUG] 开始暂存已批准的补充图片，资源ID: %d, 图片数量: %d", resourceID, len(approval.ApprovedImages))
+			staged, errStage := stageApprovedImages(stage, resourceID, approval.ApprovedImages)
+			if errStage != nil {
+				stage.Rollback()
+				log.Printf("[ERROR] 资源ID: %d 暂存补充图片失败，已回滚: %v", resourceID, errStage)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "审批补充内容失败"})
+				return
 			}
-			
-			// 将批准的图片路径追加到resource.Images，而不是覆盖
-			log.Printf("[INFO] 更新资源图片路径，从 %v", resource.Images)
-			resource.Images = append(currentImages, newImagePaths...)
-			log.Printf("[INFO] 变为 %v（追加而非覆盖）", resource.Images)
-			
-			// 异步调用WebP转换工具处理批准的图片
-			go func(paths []string) {
-				log.Printf("[INFO] 开始异步转换批准的补充图片为WebP格式，图片数量: %d", len(paths))
-				convertImagesToWebP(paths)
-			}(newImagePaths)
+			approvalRecord.ApprovedImages = models.JsonList(staged)
 		}
-		
-		// 处理海报图片，如果补充内容中设置了新的海报图片
 		if approval.PosterImage != "" {
-			log.Printf("[DEBUG] 处理补充内容的海报图片，资源ID: %d, 原路径: %s", resource.ID, approval.PosterImage)
-			
-			// 检查是否为TMDB外部图片链接
-			if strings.HasPrefix(approval.PosterImage, "@https://image.tmdb.org/") || 
-			   strings.HasPrefix(approval.PosterImage, "https://image.tmdb.org/") {
-				// 对于TMDB外部图片链接，直接保存原始链接，无需移动
-				log.Printf("[DEBUG] 检测到TMDB外部海报图片链接: %s，直接保存原始链接", approval.PosterImage)
-				posterPath := approval.PosterImage
-				resource.PosterImage = &posterPath
-			} else {
-				// 提取文件名
-				filename := filepath.Base(approval.PosterImage)
-				log.Printf("[DEBUG] 海报文件名: %s", filename)
-				
-				// 更新资源的海报图片
-				newPosterPath := fmt.Sprintf("/assets/imgs/%d/%s", resourceID, filename)
-				log.Printf("[INFO] 更新资源海报图片，从 %v 变为 %s", resource.PosterImage, newPosterPath)
-				resource.PosterImage = &newPosterPath
-			}
-			
-			// 异步调用WebP转换工具处理海报图片
-			if resource.PosterImage != nil {
-				posterPaths := []string{*resource.PosterImage}
-				go func(paths []string) {
-					log.Printf("[INFO] 开始异步转换补充资源的海报图片为WebP格式")
-					convertImagesToWebP(paths)
-				}(posterPaths)
+			poster, errStage := stageApprovedPoster(stage, resourceID, approval.PosterImage)
+			if errStage != nil {
+				stage.Rollback()
+				log.Printf("[ERROR] 资源ID: %d 暂存海报图片失败，已回滚: %v", resourceID, errStage)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "审批补充内容失败"})
+				return
 			}
+			posterPath = poster
 		}
-		
-		// 处理批准的链接，将它们追加到原始资源的Links字段中
-		if len(approval.ApprovedLinks) > 0 {
-			log.Printf("[DEBUG] 处理批准的链接，资源ID: %d, 链接数量: %d", resourceID, len(approval.ApprovedLinks))
-			
-			// 如果原始资源的Links字段为空，则初始化
-			if resource.Links == nil {
-				resource.Links = models.JsonMap{}
-			}
-			
-			// 先按category分组链接
-			linksByCategory := make(map[string][]map[string]interface{})
-			
-			// 遍历批准的链接，按category分组
-			for _, link := range approval.ApprovedLinks {
-				// 使用category作为键，将链接添加到对应分组
-				if category, ok := link["category"].(string); ok && category != "" {
-					// 创建不包含category字段的新map
-					linkData := make(map[string]interface{})
-					for k, v := range link {
-						if k != "category" {
-							linkData[k] = v
-						}
-					}
-					
-					linksByCategory[category] = append(linksByCategory[category], linkData)
-				} else {
-					// 如果没有有效的category，使用"unknown"作为键
-					linksByCategory["unknown"] = append(linksByCategory["unknown"], link)
-				}
-			}
-			
-			// 将分组后的链接添加到resource.Links中
-			for category, links := range linksByCategory {
-				log.Printf("[DEBUG] 添加链接组，键: %s, 数量: %d", category, len(links))
-				
-				// 检查是否已存在该category的链接
-				if existingLinks, ok := resource.Links[category]; ok {
-					// 已存在该category的链接，将新链接追加到现有数组
-					if existingLinksArray, ok := existingLinks.([]interface{}); ok {
-						// 已经是数组格式，追加新链接
-						for _, link := range links {
-							existingLinksArray = append(existingLinksArray, link)
-						}
-						resource.Links[category] = existingLinksArray
-					} else {
-						// 不是数组格式，转换为数组后追加
-						newLinks := []interface{}{existingLinks}
-						for _, link := range links {
-							newLinks = append(newLinks, link)
-						}
-						resource.Links[category] = newLinks
-					}
-				} else {
-					// 不存在该category的链接，直接添加
-					interfaceLinks := make([]interface{}, len(links))
-					for i, link := range links {
-						interfaceLinks[i] = link
-					}
-					resource.Links[category] = interfaceLinks
-				}
-			}
-			
-			log.Printf("[INFO] 更新后的资源链接: %v", resource.Links)
+	}
+
+	tx, errTx := models.DB.Beginx()
+	if errTx != nil {
+		log.Printf("[ERROR] 开始事务失败: %v", errTx)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "审批补充内容失败"})
+		return
+	}
+	defer tx.Rollback()
+
+	recordID, errInsert := models.InsertApprovalRecord(tx, &approvalRecord)
+	if errInsert != nil {
+		log.Printf("[ERROR] %v", errInsert)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建审批记录失败"})
+		return
+	}
+
+	// 以待审批状态为条件更新补充内容，重复提交或并发审批时只有一次生效
+	if errReview := models.ReviewSupplement(tx, supplement.ID, approval.Status, recordID, c.GetString("username")); errReview != nil {
+		if errors.Is(errReview, sql.ErrNoRows) {
+			tx.Rollback()
+			stage.Rollback()
+			respondSupplementConflict(c, resourceID, supplement.ID, approval.Status)
+			return
 		}
-		
-		// 更新数据库中的资源信息
-		var errUpdate error
-		_, errUpdate = models.DB.Exec(
+		log.Printf("[ERROR] %v", errReview)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新补充内容状态失败"})
+		return
+	}
+
+	// 提交者凭跟踪令牌可以看到审批结果和驳回原因
+	if errReview := models.ReviewSubmission(tx, resourceID, &supplement.ID, approval.Status, approval.RejectionReasons); errReview != nil {
+		log.Printf("[ERROR] %v", errReview)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "审批补充内容失败"})
+		return
+	}
+
+	// 审批事务已持有写锁，此时读取的资源不会再被其他审批修改
+	var resource models.Resource
+	if errGet := tx.Get(&resource, `SELECT * FROM resources WHERE id = ?`, resourceID); errGet != nil {
+		log.Printf("[ERROR] 查询资源失败: %v", errGet)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "审批补充内容失败"})
+		return
+	}
+
+	before := models.CaptureResourceState(&resource)
+
+	// 审批时因已存在而跳过的链接
+	var duplicateLinks []models.LinkDuplicate
+	if approved {
+		duplicateLinks = mergeApprovedSupplement(&resource, approvalRecord.ApprovedImages, posterPath, approval.ApprovedLinks)
+
+		_, errUpdate := tx.Exec(
 			`UPDATE resources SET 
 				images = ?, poster_image = ?, links = ?,
-				updated_at = ?
+				needs_link_replacement = ?, updated_at = ?
 			WHERE id = ?`,
 			resource.Images, resource.PosterImage, resource.Links,
-			time.Now(), resourceID,
+			resource.NeedsLinkReplacement, time.Now(), resourceID,
 		)
-		
 		if errUpdate != nil {
 			log.Printf("[ERROR] 更新资源图片失败: %v", errUpdate)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新资源图片失败: %v", errUpdate)})
 			return
 		}
-		
-		log.Printf("[INFO] 成功更新资源图片，ID: %d", resourceID)
 	}
 
-	// 更新补充内容状态
-	resource.IsSupplementApproval = true
-	resource.Supplement = nil // 清空补充内容
-	resource.UpdatedAt = time.Now()
-
-	// 更新资源
-	var errUpdate error
-	_, errUpdate = models.DB.Exec(
-		`UPDATE resources SET is_supplement_approval = 'True', supplement = NULL, updated_at = ? WHERE id = ?`,
-		resource.UpdatedAt, resourceID,
-	)
-
-	// 检查错误
-	if errUpdate != nil {
-		log.Printf("更新资源is_supplement_approval失败: %v", errUpdate)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新资源is_supplement_approval失败: %v", errUpdate)})
+	// 保存修改前后的资源字段，用于撤销
+	snapshot := models.NewApprovalSnapshot(before, models.CaptureResourceState(&resource), stage.Renamed())
+	if errSnapshot := models.SetApprovalSnapshot(tx, recordID, snapshot); errSnapshot != nil {
+		log.Printf("[ERROR] %v", errSnapshot)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "审批补充内容失败"})
 		return
 	}
 
-	log.Printf("资源ID: %d 的is_supplement_approval已成功更新为True，supplement已清空", resourceID)
-
-	// 插入审批记录
-	result, errInsert := models.DB.Exec(
-		`INSERT INTO approval_records (
-			resource_id, status, field_approvals, field_rejections,
-			approved_images, rejected_images, poster_image, notes,
-			approved_links, rejected_links, is_supplement_approval, created_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-		approvalRecord.ResourceID, approvalRecord.Status,
-		approvalRecord.FieldApprovals, approvalRecord.FieldRejections,
-		models.JsonList(newImagePaths), approvalRecord.RejectedImages,
-		approvalRecord.PosterImage, approvalRecord.Notes,
-		approvalRecord.ApprovedLinks, approvalRecord.RejectedLinks,
-		approvalRecord.IsSupplementApproval, approvalRecord.CreatedAt,
-	)
-	
-
-	if errInsert != nil {
-		log.Printf("创建补充内容审批记录失败: %v", errInsert)
-		// 继续处理，不要因为审批记录创建失败而中断流程
-	} else {
-		id, _ := result.LastInsertId()
-		log.Printf("已创建补充内容审批记录，ID: %d", id)
+	if errCommit := commitApproval(tx, stage, resourceID, convertiblePaths(approvalRecord.ApprovedImages, posterPath)); errCommit != nil {
+		log.Printf("[ERROR] 资源ID: %d 的补充内容 %d 审批失败，已回滚: %v", resourceID, supplement.ID, errCommit)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "审批补充内容失败"})
+		return
 	}
-	
-	
+	log.Printf("已审批资源ID: %d 的补充内容 %d，审批记录ID: %d", resourceID, supplement.ID, recordID)
+	syncLinkHealth(resourceID)
+
 	// 返回更新后的资源
 	var updatedResource models.Resource
 	errGet := models.DB.Get(&updatedResource, `SELECT * FROM resources WHERE id = ?`, resourceID)
 	if errGet != nil {
 		log.Printf("警告：获取更新后的资源失败，但资源已更新: %v", errGet)
+		resource.DuplicateLinks = duplicateLinks
 		c.JSON(http.StatusOK, resource)
 	} else {
+		updatedResource.DuplicateLinks = duplicateLinks
 		c.JSON(http.StatusOK, updatedResource)
 	}
 }
 
-// convertImagesToWebP 将批准的图片转换成WebP格式
-func convertImagesToWebP(imagePaths []string) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("[ERROR] WebP转换过程中发生严重错误: %v", r)
+// foreignSupplementEntries 返回审批请求中不属于该补充内容的图片、海报和链接
+// 补充内容没有单独的海报，海报必须是补充内容中的图片；链接按规范键比较
+func foreignSupplementEntries(supplement models.Supplement, approval models.ResourceApproval) []string {
+	images := make(map[string]bool, len(supplement.Images))
+	for _, img := range supplement.Images {
+		images[img] = true
+	}
+	links := make(map[string]bool)
+	for _, items := range models.ParseLinkMap(supplement.Links) {
+		for _, link := range items {
+			links[utils.LinkKey(link.URL)] = true
 		}
-	}()
+	}
 
-	if len(imagePaths) == 0 {
-		log.Printf("[INFO] 没有需要转换为WebP的图片")
-		return
+	var foreign []string
+	for _, img := range approval.ApprovedImages {
+		if img != "" && !images[img] {
+			foreign = append(foreign, img)
+		}
 	}
-	
-	log.Printf("[INFO] 开始将 %d 张批准的图片转换为WebP格式", len(imagePaths))
-	startTime := time.Now()
-	
-	// 过滤掉TMDB外部图片链接，这些不需要转换为WebP
-	localImagePaths := make([]string, 0, len(imagePaths))
-	
-	for _, path := range imagePaths {
-		// 跳过TMDB外部图片链接
-		if strings.HasPrefix(path, "@https://image.tmdb.org/") || 
-		   strings.HasPrefix(path, "https://image.tmdb.org/") {
-			log.Printf("[INFO] 跳过TMDB外部图片链接: %s，不进行WebP转换", path)
+	if approval.PosterImage != "" && !images[approval.PosterImage] {
+		foreign = append(foreign, approval.PosterImage)
+	}
+	for _, link := range approval.ApprovedLinks {
+		url, _ := link["url"].(string)
+		if url == "" || !links[utils.LinkKey(url)] {
+			foreign = append(foreign, url)
+		}
+	}
+	return foreign
+}
+
+// mergeApprovedSupplement 把补充内容中批准的图片、海报和链接合并到资源中，返回因已存在而跳过的链接
+func mergeApprovedSupplement(resource *models.Resource, newImagePaths []string, posterPath *string, approvedLinks []map[string]interface{}) []models.LinkDuplicate {
+	// 将批准的图片路径追加到resource.Images，而不是覆盖
+	if len(newImagePaths) > 0 {
+		log.Printf("[INFO] 更新资源图片路径，从 %v", resource.Images)
+		resource.Images = append(resource.Images, newImagePaths...)
+		log.Printf("[INFO] 变为 %v（追加而非覆盖）", resource.Images)
+	}
+
+	// 补充内容中设置了新的海报图片
+	if posterPath != nil {
+		log.Printf("[INFO] 更新资源海报图片，从 %v 变为 %s", resource.PosterImage, *posterPath)
+		resource.PosterImage = posterPath
+	}
+
+	if len(approvedLinks) == 0 {
+		return nil
+	}
+	log.Printf("[DEBUG] 处理批准的链接，资源ID: %d, 链接数量: %d", resource.ID, len(approvedLinks))
+
+	// 如果原始资源的Links字段为空，则初始化
+	if resource.Links == nil {
+		resource.Links = models.JsonMap{}
+	}
+
+	// 按category分组链接，跳过资源已有的链接，避免同一链接重复出现
+	linksByCategory, duplicateLinks := dedupeApprovedLinks(resource.Links, groupApprovedLinks(approvedLinks))
+	if len(duplicateLinks) > 0 {
+		log.Printf("[INFO] 资源ID: %d 跳过 %d 个已存在的链接", resource.ID, len(duplicateLinks))
+	}
+
+	// 将分组后的链接添加到resource.Links中
+	for category, links := range linksByCategory {
+		log.Printf("[DEBUG] 添加链接组，键: %s, 数量: %d", category, len(links))
+
+		// 检查是否已存在该category的链接
+		var merged []interface{}
+		if existingLinks, ok := resource.Links[category]; ok {
+			if existingLinksArray, ok := existingLinks.([]interface{}); ok {
+				// 已经是数组格式，追加新链接
+				merged = existingLinksArray
+			} else {
+				// 不是数组格式，转换为数组后追加
+				merged = []interface{}{existingLinks}
+			}
+		}
+		for _, link := range links {
+			merged = append(merged, link)
+		}
+		resource.Links[category] = merged
+	}
+
+	log.Printf("[INFO] 更新后的资源链接: %v", resource.Links)
+
+	// 补充了新链接后不再需要替换链接
+	if len(linksByCategory) > 0 {
+		resource.NeedsLinkReplacement = false
+	}
+	return duplicateLinks
+}
+
+// stageApprovedImages 暂存批准的图片，返回新路径；TMDB外部图片直接保存原始链接
+// 任一图片暂存失败时返回错误，由调用方回滚已暂存的图片
+func stageApprovedImages(stage *utils.AssetStage, resourceID int, images []string) ([]string, error) {
+	newImagePaths := make([]string, 0, len(images))
+	for _, imgPath := range images {
+		if imgPath == "" {
 			continue
 		}
-		
-		// 将 /assets/... 转换为 ../assets/...
-		if strings.HasPrefix(path, "/assets/") {
-			adjustedPath := "../" + strings.TrimPrefix(path, "/")
-			localImagePaths = append(localImagePaths, adjustedPath)
-		} else {
-			log.Printf("[WARN] 图片路径格式不符合预期: %s，跳过处理", path)
+
+		// 检查是否为TMDB外部图片链接（带或不带@前缀）
+		if strings.HasPrefix(imgPath, "@https://image.tmdb.org/") || strings.HasPrefix(imgPath, "https://image.tmdb.org/") {
+			// 对于TMDB外部图片链接，直接保存原始链接，无需移动
+			log.Printf("[DEBUG] 检测到TMDB外部图片链接: %s，直接保存原始链接", imgPath)
+			newImagePaths = append(newImagePaths, imgPath)
+			continue
+		}
+
+		// 复制到资源目录（经由存储后端），事务提交后再删除原文件
+		newPath, errStage := stage.StageToResource(resourceID, imgPath)
+		if errStage != nil {
+			return nil, errStage
 		}
+		log.Printf("[DEBUG] 生成新路径: %s", newPath)
+		newImagePaths = append(newImagePaths, newPath)
 	}
-	
-	if len(localImagePaths) == 0 {
-		log.Printf("[WARN] 没有有效的图片路径可供转换")
+	return newImagePaths, nil
+}
+
+// stageApprovedPoster 暂存批准的海报图片并返回新路径，TMDB外部图片直接保存原始链接
+func stageApprovedPoster(stage *utils.AssetStage, resourceID int, poster string) (*string, error) {
+	log.Printf("[DEBUG] 开始暂存海报图片，资源ID: %d, 原路径: %s", resourceID, poster)
+	if strings.HasPrefix(poster, "@https://image.tmdb.org/") || strings.HasPrefix(poster, "https://image.tmdb.org/") {
+		// 对于TMDB外部图片链接，直接保存原始链接，无需移动
+		log.Printf("[DEBUG] 检测到TMDB外部海报图片链接: %s，直接保存原始链接", poster)
+		return &poster, nil
+	}
+	newPath, errStage := stage.StageToResource(resourceID, poster)
+	if errStage != nil {
+		return nil, errStage
+	}
+	log.Printf("[INFO] 海报图片路径变为 %s", newPath)
+	return &newPath, nil
+}
+
+// approvedLinksRecord 把批准的链接按分类分组，写入审批记录
+func approvedLinksRecord(links []map[string]interface{}) models.JsonMap {
+	record := models.JsonMap{}
+	for category, grouped := range groupApprovedLinks(links) {
+		record[category] = grouped
+	}
+	return record
+}
+
+// convertiblePaths 返回需要转换WebP的图片：批准的图片和海报
+func convertiblePaths(images []string, poster *string) []string {
+	paths := append([]string(nil), images...)
+	if poster != nil {
+		paths = append(paths, *poster)
+	}
+	return paths
+}
+
+// commitApproval 在审批事务中更新图片哈希路径并创建WebP转换任务，然后提交事务、删除已暂存图片的原文件
+// 返回错误时事务未提交，调用方的 defer 负责回滚事务和暂存的图片
+func commitApproval(tx *sqlx.Tx, stage *utils.AssetStage, resourceID int, convertPaths []string) error {
+	for oldPath, newPath := range stage.Renamed() {
+		if err := models.RenameImageHashTx(tx, oldPath, newPath); err != nil {
+			return err
+		}
+	}
+	if err := enqueueImageConversionTx(tx, resourceID, convertPaths); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	stage.Commit()
+	jobs.Wake()
+	return nil
+}
+
+// approvalRequestHash 计算审批请求的哈希，同一资源的相同请求哈希相同
+func approvalRequestHash(resourceID int, approval models.ResourceApproval) string {
+	data, _ := json.Marshal(approval)
+	sum := sha256.Sum256(append([]byte(strconv.Itoa(resourceID)+":"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// respondApprovalReplay 重复提交的审批请求不再执行，返回资源的当前状态
+func respondApprovalReplay(c *gin.Context, resourceID int) {
+	log.Printf("[INFO] 资源ID: %d 的审批请求重复提交，返回当前状态", resourceID)
+	var resource models.Resource
+	if errGet := models.DB.Get(&resource, `SELECT * FROM resources WHERE id = ?`, resourceID); errGet != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "资源未找到"})
 		return
 	}
-	
-	// 将路径转换为JSON字符串
-	pathsJSON, errJSON := json.Marshal(localImagePaths)
-	if errJSON != nil {
-		log.Printf("[ERROR] 无法将图片路径转为JSON: %v", errJSON)
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(http.StatusOK, resource)
+}
+
+// respondApprovalConflict 资源在审批过程中被其他请求审批时，状态与本次请求相同视为重复提交，否则返回409
+func respondApprovalConflict(c *gin.Context, resourceID int, status models.ResourceStatus) {
+	var current models.ResourceStatus
+	if errGet := models.DB.Get(&current, `SELECT status FROM resources WHERE id = ?`, resourceID); errGet == nil && current == status {
+		respondApprovalReplay(c, resourceID)
 		return
 	}
-	
-	log.Printf("[DEBUG] 准备调用WebP转换工具，处理以下图片: %s", string(pathsJSON))
-	
-	// 调用WebP转换工具
-	resultPaths, errConvert := utils.ConvertMultipleImages(string(pathsJSON), true, false, 4)
-	if errConvert != nil {
-		log.Printf("[ERROR] 转换WebP过程中发生错误: %v", errConvert)
+	c.JSON(http.StatusConflict, gin.H{"error": "资源已被其他审批修改，请刷新后重试"})
+}
+
+// respondSupplementConflict 补充内容在审批过程中被其他请求审批时，状态与本次请求相同视为重复提交，否则返回400
+func respondSupplementConflict(c *gin.Context, resourceID, supplementID int, status models.ResourceStatus) {
+	if current, errGet := models.GetSupplement(supplementID); errGet == nil && current.Status == status {
+		respondApprovalReplay(c, resourceID)
 		return
 	}
-	
-	elapsedTime := time.Since(startTime)
-	log.Printf("[INFO] 成功将 %d 张图片转换为WebP格式，耗时: %v", len(resultPaths), elapsedTime)
+	c.JSON(http.StatusBadRequest, gin.H{"error": errSupplementNotPending.Error()})
 }
 
 // DeleteApprovalRecord 删除审批记录 - 仅管理员可访问
@@ -933,130 +922,85 @@ func SupplementResource(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "资源未找到"})
 		return
 	}
-
-	// 检查是否已有待审批的补充内容
-	if resource.Supplement != nil {
-		// 尝试获取现有补充内容的状态
-		if status, ok := resource.Supplement["status"]; ok {
-			if statusStr, ok := status.(string); ok && statusStr == string(models.ResourceStatusPending) {
-				// 有待审批的补充内容，需要合并而不是覆盖
-				log.Printf("资源 %d 已有待审批的补充内容，将进行合并", resourceID)
-				
-				// 合并图片列表
-				existingImages := []string{}
-				if imgs, ok := resource.Supplement["images"]; ok {
-					if imgList, ok := imgs.([]interface{}); ok {
-						for _, img := range imgList {
-							if imgStr, ok := img.(string); ok {
-								existingImages = append(existingImages, imgStr)
-							}
-						}
-					}
-				}
-				
-				// 将新图片追加到现有图片列表中
-				mergedImages := append(existingImages, supplement.Images...)
-				
-				// 处理链接 - 合并现有链接和新链接
-				existingLinks := make(map[string][]interface{})
-				if links, ok := resource.Supplement["links"]; ok {
-					if linksMap, ok := links.(map[string]interface{}); ok {
-						for category, categoryLinks := range linksMap {
-							if catLinks, ok := categoryLinks.([]interface{}); ok {
-								existingLinks[category] = catLinks
-							}
-						}
-					}
-				}
-				
-				// 将新链接合并到现有链接中
-				mergedLinks := make(map[string]interface{})
-				for category, links := range existingLinks {
-					mergedLinks[category] = links
-				}
-				
-				// 合并新提交的链接
-				for category, categoryLinks := range supplement.Links {
-					if existingCatLinks, ok := mergedLinks[category]; ok {
-						// 已有该分类的链接，追加
-						if existingArr, ok := existingCatLinks.([]interface{}); ok {
-							// 根据categoryLinks的类型进行不同处理
-							if newLinksArray, ok := categoryLinks.([]interface{}); ok {
-								// 如果已经是[]interface{}类型，直接追加
-								mergedLinks[category] = append(existingArr, newLinksArray...)
-							} else if newLinksArray, ok := categoryLinks.([]map[string]interface{}); ok {
-								// 如果是[]map[string]interface{}类型，转换后追加
-								for _, link := range newLinksArray {
-									existingArr = append(existingArr, link)
-								}
-								mergedLinks[category] = existingArr
-							} else {
-								// 单个链接对象，直接追加
-								mergedLinks[category] = append(existingArr, categoryLinks)
-							}
-						}
-					} else {
-						// 没有该分类的链接，直接添加
-						mergedLinks[category] = categoryLinks
-					}
-				}
-				
-				// 更新合并后的补充内容
-				supplementData := models.JsonMap{
-					"images":          mergedImages,
-					"links":           mergedLinks,
-					"status":          string(models.ResourceStatusPending),
-					"submission_date": time.Now().Format(time.RFC3339),
-				}
-				
-				// 更新资源，添加补充内容
-				_, errUpdate := models.DB.Exec(
-					`UPDATE resources SET supplement = ?, is_supplement_approval = ?, updated_at = ? WHERE id = ?`,
-					supplementData, false, time.Now(), resourceID,
-				)
-				
-				if errUpdate != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("添加补充内容失败: %v", errUpdate)})
-					return
-				}
-				
-				// 更新内存中的资源对象
-				resource.Supplement = supplementData
-				resource.UpdatedAt = time.Now()
-				
-				c.JSON(http.StatusOK, resource)
-				return
-			}
-		}
+	if resource.Status != models.ResourceStatusApproved {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只能为已审批的资源提交补充内容"})
+		return
 	}
 
-	// 没有待审批的补充内容，直接创建新的
-	supplementData := models.JsonMap{
-		"images":          supplement.Images,
-		"links":           supplement.Links,
-		"status":          string(models.ResourceStatusPending),
-		"submission_date": time.Now().Format(time.RFC3339),
+	// 校验并规范化提交的链接
+	submittedLinks, ok := normalizeSubmittedLinks(c, supplement.Links)
+	if !ok {
+		return
 	}
 
-	// 更新资源，添加补充内容
-	_, errUpdate := models.DB.Exec(
-		`UPDATE resources SET supplement = ?, is_supplement_approval = ?, updated_at = ? WHERE id = ?`,
-		supplementData, false, time.Now(), resourceID,
-	)
+	// 去掉资源已有的、各份待审批补充内容中已有的以及本次提交中重复的链接
+	pending, errPending := models.ListSupplements(resourceID, models.ResourceStatusPending)
+	if errPending != nil {
+		log.Printf("%v", errPending)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询补充内容失败"})
+		return
+	}
+	linkIndex := utils.LinkIndex{}
+	linkIndex.Add(models.ParseLinkMap(resource.Links), models.LinkSourceResource)
+	for _, p := range pending {
+		linkIndex.Add(models.ParseLinkMap(p.Links), models.LinkSourceSupplement)
+	}
+	submittedLinks, resource.DuplicateLinks = linkIndex.Dedupe(submittedLinks, models.LinkSourceSubmission)
+	if len(supplement.Images) == 0 && submittedLinks.Count() == 0 && len(resource.DuplicateLinks) > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "提交的链接都已存在", "duplicate_links": resource.DuplicateLinks})
+		return
+	}
 
-	if errUpdate != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("添加补充内容失败: %v", errUpdate)})
+	// 记录贡献者，每次提交单独保存为一份待审批的补充内容
+	contributorID, contributorToken, errContributor := supplementContributor(c)
+	if errContributor != nil {
+		log.Printf("确定补充内容贡献者失败: %v", errContributor)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加补充内容失败"})
 		return
 	}
+	record := models.Supplement{
+		ResourceID:       resourceID,
+		ContributorID:    contributorID,
+		ContributorToken: contributorToken,
+		Images:           models.JsonList(supplement.Images),
+		Links:            submittedLinks.JsonMap(),
+		Notes:            strings.TrimSpace(supplement.Notes),
+	}
+	if record.Images == nil {
+		record.Images = models.JsonList{}
+	}
+	if contributorID != nil {
+		name := submitterName(c)
+		record.ContributorName = &name
+	}
+	if errCreate := models.CreateSupplement(&record); errCreate != nil {
+		log.Printf("%v", errCreate)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("添加补充内容失败: %v", errCreate)})
+		return
+	}
+	log.Printf("资源 %d 新增待审批补充内容 %d（待审批共 %d 份）", resourceID, record.ID, len(pending)+1)
 
-	// 更新内存中的资源对象
-	resource.Supplement = supplementData
-	resource.UpdatedAt = time.Now()
+	// 匿名提交时返回令牌，之后的提交带上它即可归为同一贡献者
+	response := record.AsJsonMap()
+	if contributorToken != nil {
+		c.Header(contributorTokenHeader, *contributorToken)
+		response["contributor_token"] = *contributorToken
+	}
+	resource.Supplement = response
+	resource.HasPendingSupplement = true
+	resource.PendingSupplements = len(pending) + 1
+
+	// 提交者凭跟踪令牌查询审批结果，生成失败不影响提交
+	if token, errToken := models.CreateSubmission(resourceID, &record.ID); errToken != nil {
+		log.Printf("%v", errToken)
+	} else {
+		resource.TrackingToken = token
+	}
 
 	c.JSON(http.StatusOK, resource)
 }
 
-// GetResourceSupplement 获取资源的补充内容 - 仅管理员可访问
+// GetResourceSupplement 获取资源最早提交的待审批补充内容，supplement_id 参数可以指定其他补充内容 - 仅管理员可访问
 func GetResourceSupplement(c *gin.Context) {
 	// 获取路径参数
 	resourceID, errParse := strconv.Atoi(c.Param("id"))
@@ -1073,12 +1017,43 @@ func GetResourceSupplement(c *gin.Context) {
 		return
 	}
 
-	if resource.Supplement == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "资源没有补充内容"})
-		return
+	var supplement *models.Supplement
+	if raw := c.Query("supplement_id"); raw != "" {
+		supplementID, errID := strconv.Atoi(raw)
+		if errID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的补充内容ID"})
+			return
+		}
+		found, errFind := models.GetSupplement(supplementID)
+		if errFind != nil || found.ResourceID != resourceID {
+			c.JSON(http.StatusNotFound, gin.H{"error": errSupplementNotFound.Error()})
+			return
+		}
+		supplement = found
+	} else {
+		pending, errPending := models.ListSupplements(resourceID, models.ResourceStatusPending)
+		if errPending != nil {
+			respondSupplementError(c, resourceID, errPending)
+			return
+		}
+		if len(pending) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "资源没有补充内容"})
+			return
+		}
+		supplement = &pending[0]
 	}
 
-	c.JSON(http.StatusOK, resource.Supplement)
+	// 附加补充图片的近似重复图片
+	response := gin.H(supplement.AsJsonMap())
+	response["contributor_token"] = supplement.ContributorToken
+	response["near_duplicates"] = []models.ImageDuplicate{}
+	if idx, err := newDuplicateIndex(); err != nil {
+		log.Printf("构建图片感知哈希索引失败: %v", err)
+	} else {
+		response["near_duplicates"] = idx.find(resource.ID, supplementImagePaths(supplement.Images))
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // GetPendingSupplementResources 获取待审批补充内容的资源列表 - 仅管理员可访问
@@ -1087,41 +1062,25 @@ func GetPendingSupplementResources(c *gin.Context) {
 	skip, _ := strconv.Atoi(c.DefaultQuery("skip", "0"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
 
-	// 查询所有包含补充内容的资源
-	var resources []models.Resource
+	// 查询有待审批补充内容的资源
+	resources := []models.Resource{}
 	errSelect := models.DB.Select(&resources, 
-		`SELECT * FROM resources WHERE supplement IS NOT NULL LIMIT ? OFFSET ?`,
-		limit, skip)
+		`SELECT * FROM resources WHERE id IN (SELECT resource_id FROM supplements WHERE status = ?) ORDER BY id LIMIT ? OFFSET ?`,
+		models.ResourceStatusPending, limit, skip)
 	if errSelect != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询待审批补充内容资源失败"})
 		return
 	}
-	
-	// 确保resources不为null
-	if resources == nil {
-		resources = []models.Resource{}
-	}
-
-	// 筛选待审批的补充内容资源
-	pendingSupplements := []models.Resource{}
-	for _, resource := range resources {
-		if resource.Supplement == nil {
-			continue
-		}
 
-		status, ok := resource.Supplement["status"]
-		if !ok {
-			continue
-		}
-
-		if statusStr, ok := status.(string); ok && statusStr == string(models.ResourceStatusPending) {
-			resource.HasPendingSupplement = true
-			pendingSupplements = append(pendingSupplements, resource)
-		}
+	// 附加最早提交的待审批补充内容和份数
+	if _, errAttach := attachPendingSupplements(resources); errAttach != nil {
+		log.Printf("%v", errAttach)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询待审批补充内容资源失败"})
+		return
 	}
 
 	// 即使没有待审批补充内容也返回空数组
-	c.JSON(http.StatusOK, pendingSupplements)
+	c.JSON(http.StatusOK, resources)
 }
 
 // DeleteApprovalRecords 批量删除审批记录 - 仅管理员可访问
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/models"
+	"dongman/internal/utils"
+)
+
+// errNoPendingChanges 资源没有待审批的补充内容，也不是待审批资源
+var errNoPendingChanges = errors.New("资源没有待审批的内容")
+
+// GetResourceDiff 返回待审批的补充内容或修改与现有资源的结构化差异 - 仅管理员可访问
+func GetResourceDiff(c *gin.Context) {
+	resourceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的资源ID"})
+		return
+	}
+
+	var resource models.Resource
+	if err := models.DB.Get(&resource, `SELECT * FROM resources WHERE id = ?`, resourceID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "资源未找到"})
+		return
+	}
+
+	var supplementID *int
+	if raw := c.Query("supplement_id"); raw != "" {
+		id, errID := strconv.Atoi(raw)
+		if errID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的补充内容ID"})
+			return
+		}
+		supplementID = &id
+	}
+	supplement, err := pendingSupplementFor(resource, supplementID)
+	if err != nil {
+		respondSupplementError(c, resourceID, err)
+		return
+	}
+
+	diff, err := buildResourceDiff(resource, supplement)
+	if err != nil {
+		if errors.Is(err, errNoPendingChanges) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("生成资源 %d 的差异失败: %v", resourceID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成差异失败"})
+		return
+	}
+
+	// 为新增图片附加近似重复图片
+	var images []string
+	for _, entry := range diff.Entries {
+		if entry.Type == models.DiffEntryImage {
+			images = append(images, entry.New)
+		}
+	}
+	if len(images) > 0 {
+		if idx, err := newDuplicateIndex(); err != nil {
+			log.Printf("构建图片感知哈希索引失败: %v", err)
+		} else {
+			byImage := make(map[string][]models.ImageDuplicate)
+			for _, dup := range idx.find(resource.ID, images) {
+				byImage[dup.Image] = append(byImage[dup.Image], dup)
+			}
+			for i := range diff.Entries {
+				if diff.Entries[i].Type == models.DiffEntryImage {
+					diff.Entries[i].NearDuplicates = byImage[diff.Entries[i].New]
+				}
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// buildResourceDiff 按 ApproveResource 的判断方式确定待审批内容并与现有资源比较：
+// 有要审批的补充内容（见 pendingSupplementFor）时比较补充内容与资源本身，
+// 待审批资源与 original_resource_id 指向的资源（没有时为空资源）比较
+func buildResourceDiff(resource models.Resource, supplement *models.Supplement) (*models.ResourceDiff, error) {
+	diff := &models.ResourceDiff{ResourceID: resource.ID, Entries: []models.ResourceDiffEntry{}}
+
+	var (
+		base     models.Resource
+		proposed models.Resource
+	)
+	switch {
+	case supplement != nil:
+		diff.Kind = models.DiffKindSupplement
+		diff.BaseResourceID = &resource.ID
+		diff.SupplementID = &supplement.ID
+		base = resource
+		proposed.Images = supplement.Images
+		proposed.Links = supplement.Links
+	case resource.Status == models.ResourceStatusPending:
+		diff.Kind = models.DiffKindNew
+		if resource.OriginalResourceID != nil {
+			if err := models.DB.Get(&base, `SELECT * FROM resources WHERE id = ?`, *resource.OriginalResourceID); err != nil {
+				return nil, fmt.Errorf("查询原资源 %d 失败: %w", *resource.OriginalResourceID, err)
+			}
+			diff.Kind = models.DiffKindEdit
+			diff.BaseResourceID = resource.OriginalResourceID
+		}
+		proposed = resource
+	default:
+		return nil, errNoPendingChanges
+	}
+
+	// 字段修改
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"title", base.Title, proposed.Title},
+		{"title_en", base.TitleEn, proposed.TitleEn},
+		{"description", base.Description, proposed.Description},
+		{"poster_image", stringValue(base.PosterImage), stringValue(proposed.PosterImage)},
+	}
+	for _, f := range fields {
+		if f.new == "" || f.new == f.old {
+			continue
+		}
+		entry := models.ResourceDiffEntry{ID: diffEntryID("field", f.name+"|"+f.new), Type: models.DiffEntryField, Field: f.name, New: f.new}
+		if diff.BaseResourceID != nil {
+			old := f.old
+			entry.Old = &old
+		}
+		diff.Entries = append(diff.Entries, entry)
+		diff.Summary.Fields++
+	}
+
+	// 新增图片
+	existingImages := make(map[string]bool, len(base.Images))
+	for _, img := range base.Images {
+		existingImages[img] = true
+	}
+	for _, img := range proposed.Images {
+		if img == "" || existingImages[img] {
+			continue
+		}
+		existingImages[img] = true
+		diff.Entries = append(diff.Entries, models.ResourceDiffEntry{ID: diffEntryID("image", img), Type: models.DiffEntryImage, New: img})
+		diff.Summary.Images++
+	}
+
+	// 新增链接，与现有资源的链接或同一批中前面的链接重复时标记出来
+	idx := utils.LinkIndex{}
+	idx.Add(models.ParseLinkMap(base.Links), models.LinkSourceResource)
+	proposedLinks := models.ParseLinkMap(proposed.Links)
+	for _, category := range models.LinkCategories {
+		for _, link := range proposedLinks[category] {
+			link := link
+			entry := models.ResourceDiffEntry{
+				ID:       diffEntryID("link", category+"|"+link.URL),
+				Type:     models.DiffEntryLink,
+				Category: category,
+				Link:     &link,
+			}
+			key := utils.LinkKey(link.URL)
+			if dup, ok := idx[key]; ok {
+				dup.Category = category
+				dup.URL = link.URL
+				entry.Duplicate = &dup
+				diff.Summary.DuplicateLinks++
+			} else {
+				idx[key] = models.LinkDuplicate{Key: key, ExistingCategory: category, ExistingURL: link.URL, Existing: models.LinkSourceSubmission}
+			}
+			diff.Entries = append(diff.Entries, entry)
+			diff.Summary.Links++
+		}
+	}
+	return diff, nil
+}
+
+// diffEntryID 根据条目内容生成稳定的ID
+func diffEntryID(kind, content string) string {
+	sum := sha1.Sum([]byte(content))
+	return kind + ":" + hex.EncodeToString(sum[:6])
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// applyDiffEntries 把审批请求中的差异条目ID展开为图片、链接和字段的批准/拒绝列表，覆盖请求中的对应字段
+// 未列出的条目视为拒绝；包含不存在的条目ID时返回这些ID
+func applyDiffEntries(approval *models.ResourceApproval, diff *models.ResourceDiff) []string {
+	approved := make(map[string]bool, len(approval.ApprovedEntries))
+	for _, id := range approval.ApprovedEntries {
+		approved[strings.TrimSpace(id)] = true
+	}
+	known := make(map[string]bool, len(diff.Entries))
+	for _, entry := range diff.Entries {
+		known[entry.ID] = true
+	}
+	var unknown []string
+	for id := range approved {
+		if !known[id] {
+			unknown = append(unknown, id)
+		}
+	}
+	if len(unknown) > 0 {
+		return unknown
+	}
+
+	approval.ApprovedImages, approval.RejectedImages = []string{}, []string{}
+	approval.ApprovedLinks, approval.RejectedLinks = nil, nil
+	approval.FieldApprovals, approval.FieldRejections = map[string]bool{}, map[string]bool{}
+	approval.PosterImage = ""
+	for _, entry := range diff.Entries {
+		ok := approved[entry.ID]
+		switch entry.Type {
+		case models.DiffEntryField:
+			if ok {
+				approval.FieldApprovals[entry.Field] = true
+				if entry.Field == "poster_image" {
+					approval.PosterImage = entry.New
+				}
+			} else {
+				approval.FieldRejections[entry.Field] = true
+			}
+		case models.DiffEntryImage:
+			if ok {
+				approval.ApprovedImages = append(approval.ApprovedImages, entry.New)
+			} else {
+				approval.RejectedImages = append(approval.RejectedImages, entry.New)
+			}
+		case models.DiffEntryLink:
+			link := diffLinkObject(entry)
+			if ok {
+				approval.ApprovedLinks = append(approval.ApprovedLinks, link)
+			} else {
+				approval.RejectedLinks = append(approval.RejectedLinks, link)
+			}
+		}
+	}
+	return nil
+}
+
+// diffLinkObject 把链接条目转换为审批请求中的链接对象（带 category 字段）
+func diffLinkObject(entry models.ResourceDiffEntry) map[string]interface{} {
+	link := map[string]interface{}{}
+	if data, err := json.Marshal(entry.Link); err == nil {
+		json.Unmarshal(data, &link)
+	}
+	link["category"] = entry.Category
+	return link
+}
@@ -7,7 +7,6 @@ import (
 	"strconv"
 	"strings"
 	"time"
-	"path/filepath"
 	"net/url"
 	"encoding/json"
 
@@ -118,64 +117,32 @@ func GetPendingResources(c *gin.Context) {
 		addedResourceIDs[resource.ID] = true
 	}
 
-	// 查询补充待审批资源
+	// 查询有待审批补充内容的资源
 	var supplementResources []models.Resource
 	err = models.DB.Select(&supplementResources, 
-		`SELECT * FROM resources WHERE supplement IS NOT NULL AND (is_supplement_approval = 0 OR is_supplement_approval = 'False')`)
+		`SELECT * FROM resources WHERE status != ? AND id IN (SELECT resource_id FROM supplements WHERE status = ?)`,
+		models.ResourceStatusPending, models.ResourceStatusPending)
 	if err != nil {
 		log.Printf("查询待审批补充资源失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询待审批补充资源失败"})
 		return
 	}
-	
-	if supplementResources != nil {
-		log.Printf("找到 %d 个可能有补充内容的资源", len(supplementResources))
-		
-		// 筛选待审批的补充内容资源
-		for _, resource := range supplementResources {
-			// 如果该资源ID已经在结果集中，跳过
-			if addedResourceIDs[resource.ID] {
-				continue
-			}
-			
-			log.Printf("处理资源ID=%d的补充内容", resource.ID)
-			
-			if resource.Supplement == nil || len(resource.Supplement) == 0 {
-				log.Printf("资源ID=%d的补充内容为空", resource.ID)
-				continue
-			}
-
-			log.Printf("资源ID=%d的补充内容: %v", resource.ID, resource.Supplement)
-
-			status, ok := resource.Supplement["status"]
-			if !ok {
-				log.Printf("资源ID=%d的补充内容没有status字段", resource.ID)
-				continue
-			}
-
-			// 安全地转换status到字符串
-			var statusStr string
-			switch s := status.(type) {
-			case string:
-				statusStr = strings.ToLower(s) // 转换为小写以便比较
-			case float64:
-				log.Printf("警告：资源ID=%d的status字段是数字: %f", resource.ID, s)
-				continue
-			default:
-				log.Printf("警告：资源ID=%d的status字段类型不支持: %T", resource.ID, status)
-				continue
-			}
 
-			// 不区分大小写比较
-			if strings.ToLower(string(models.ResourceStatusPending)) == statusStr {
-				log.Printf("资源ID=%d有待审批的补充内容", resource.ID)
-				resource.HasPendingSupplement = true
-				allPendingResources = append(allPendingResources, resource)
-				addedResourceIDs[resource.ID] = true
-			} else {
-				log.Printf("资源ID=%d的补充内容状态不是待审批: %s", resource.ID, statusStr)
-			}
+	// 附加最早提交的待审批补充内容，审批时默认审批这一份
+	oldestSupplements, err := attachPendingSupplements(supplementResources)
+	if err != nil {
+		log.Printf("查询待审批补充内容失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询待审批补充资源失败"})
+		return
+	}
+	log.Printf("找到 %d 个有待审批补充内容的资源", len(supplementResources))
+	for _, resource := range supplementResources {
+		// 如果该资源ID已经在结果集中，跳过
+		if addedResourceIDs[resource.ID] {
+			continue
 		}
+		allPendingResources = append(allPendingResources, resource)
+		addedResourceIDs[resource.ID] = true
 	}
 	
 	log.Printf("总共找到 %d 个待审批资源（初始审批+补充审批）", len(allPendingResources))
@@ -196,6 +163,15 @@ func GetPendingResources(c *gin.Context) {
 	
 	pagedResources := allPendingResources[startIndex:endIndex]
 	
+	// 为待审核图片查找近似重复图片，便于审核时快速驳回重复内容
+	if idx, err := newDuplicateIndex(); err != nil {
+		log.Printf("构建图片感知哈希索引失败: %v", err)
+	} else {
+		for i := range pagedResources {
+			pagedResources[i].NearDuplicates = idx.find(pagedResources[i].ID, pendingReviewImages(pagedResources[i], oldestSupplements[pagedResources[i].ID]))
+		}
+	}
+	
 	// 返回结果
 	c.JSON(http.StatusOK, pagedResources)
 }
@@ -398,6 +374,15 @@ func GetResourceByID(c *gin.Context) {
 		// TODO: 过滤被拒绝的链接
 	}
 
+	// 附加网盘链接的有效性检查结果
+	if resource.Status == models.ResourceStatusApproved {
+		if health, err := models.GetResourceLinkHealth(resource.ID); err != nil {
+			log.Printf("%v", err)
+		} else if len(health) > 0 {
+			resource.LinkHealth = health
+		}
+	}
+
 	c.JSON(http.StatusOK, resource)
 }
 
@@ -434,7 +419,13 @@ func CreateResource(c *gin.Context) {
 	}
 
 	if resourceReq.Links != nil {
-		resource.Links = resourceReq.Links
+		links, ok := normalizeSubmittedLinks(c, resourceReq.Links)
+		if !ok {
+			return
+		}
+		// 去掉同一次提交中重复的链接，在响应中说明
+		links, resource.DuplicateLinks = utils.LinkIndex{}.Dedupe(links, models.LinkSourceSubmission)
+		resource.Links = links.JsonMap()
 	}
 
 	// 插入记录
@@ -457,40 +448,14 @@ func CreateResource(c *gin.Context) {
 	id, _ := result.LastInsertId()
 	resource.ID = int(id)
 
-	c.JSON(http.StatusCreated, resource)
-}
-
-// 添加一个辅助函数，用于转换资源的所有图片为WebP格式
-func convertResourceImagesToWebP(images []string, resourceID int) ([]string, error) {
-	if len(images) == 0 {
-		return images, nil
-	}
-	
-	webpImages := make([]string, 0, len(images))
-	
-	for _, imgPath := range images {
-		// 只处理JPG和PNG图片
-		ext := strings.ToLower(filepath.Ext(imgPath))
-		if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
-			// 不是支持的格式，保持原样
-			webpImages = append(webpImages, imgPath)
-			continue
-		}
-		
-		// 转换为WebP，保持宽高比，最大尺寸600x900
-		webpPath, err := utils.ConvertToWebPWithRatio(imgPath[7:], 600, 900, true)  // 去掉"/assets"前缀
-		if err != nil {
-			log.Printf("将图片 %s 转换为WebP失败: %v", imgPath, err)
-			// 如果转换失败，保留原始图片
-			webpImages = append(webpImages, imgPath)
-		} else {
-			// 转换成功，使用WebP图片路径
-			webpImages = append(webpImages, "/assets/"+webpPath)
-			log.Printf("成功将图片 %s 转换为WebP: %s", imgPath, "/assets/"+webpPath)
-		}
+	// 提交者凭跟踪令牌查询审批结果，生成失败不影响提交
+	if token, errToken := models.CreateSubmission(resource.ID, nil); errToken != nil {
+		log.Printf("%v", errToken)
+	} else {
+		resource.TrackingToken = token
 	}
-	
-	return webpImages, nil
+
+	c.JSON(http.StatusCreated, resource)
 }
 
 // UpdateResource 更新资源 - 仅管理员可访问
@@ -532,6 +497,8 @@ func UpdateResource(c *gin.Context) {
 
 	// 更新资源字段
 	updated := false
+	// 需要转换WebP的图片，资源更新后再创建任务
+	var convertPaths []string
 
 	if resourceUpdate.Title != nil {
 		resource.Title = *resourceUpdate.Title
@@ -615,53 +582,17 @@ func UpdateResource(c *gin.Context) {
 			// 添加移动后的图片路径
 			finalImages = append(finalImages, newImagePaths...)
 			
-			// 尝试将图片转换为WebP格式
-			webpImages, err := convertResourceImagesToWebP(finalImages, resourceID)
-			if err != nil {
-				log.Printf("转换图片为WebP格式时出错: %v", err)
-				// 发生错误时继续使用原始图片
-			} else {
-				finalImages = webpImages
-				
-				// 如果海报图片被转换为WebP，也需要更新海报路径
-				if posterImageInUpload && resource.PosterImage != nil {
-					originalPosterPath := *resource.PosterImage
-					for i, oldPath := range finalImages {
-						if oldPath == originalPosterPath && i < len(webpImages) {
-							webpPosterPath := webpImages[i]
-							log.Printf("更新海报图片WebP路径: %s -> %s", originalPosterPath, webpPosterPath)
-							resource.PosterImage = &webpPosterPath
-							break
-						}
-					}
-				}
-			}
-			
 			resource.Images = finalImages
 			updated = true
 
-			// 异步调用WebP转换工具处理所有图片，确保转换完成
-			go func(paths []string) {
-				log.Printf("[INFO] 开始异步转换资源编辑时上传的图片为WebP格式，图片数量: %d", len(paths))
-				convertImagesToWebP(paths)
-			}(finalImages)
+			// 资源更新后创建后台任务，将所有图片转换为WebP格式
+			convertPaths = append(convertPaths, finalImages...)
 		} else {
-			// 如果没有需要移动的图片，直接尝试转换为WebP
-			webpImages, err := convertResourceImagesToWebP(resourceUpdate.Images, resourceID)
-			if err != nil {
-				log.Printf("转换图片为WebP格式时出错: %v", err)
-				// 发生错误时继续使用原始图片
-				resource.Images = resourceUpdate.Images
-			} else {
-				resource.Images = webpImages
-			}
+			resource.Images = resourceUpdate.Images
 			updated = true
 
-			// 异步调用WebP转换工具处理所有图片，确保转换完成
-			go func(paths []string) {
-				log.Printf("[INFO] 开始异步转换资源编辑时的图片为WebP格式，图片数量: %d", len(paths))
-				convertImagesToWebP(paths)
-			}(resource.Images)
+			// 资源更新后创建后台任务，将所有图片转换为WebP格式
+			convertPaths = append(convertPaths, resource.Images...)
 		}
 	}
 
@@ -685,6 +616,11 @@ func UpdateResource(c *gin.Context) {
 		updated = true
 	}
 
+	if resourceUpdate.NeedsLinkReplacement != nil {
+		resource.NeedsLinkReplacement = *resourceUpdate.NeedsLinkReplacement
+		updated = true
+	}
+
 	if resourceUpdate.TmdbID != nil {
 		// 处理前端清空 TMDB ID 的情况
 		// 如果前端传入的是 0 或 null，则将 TmdbID 设为 nil
@@ -748,51 +684,14 @@ func UpdateResource(c *gin.Context) {
 			}
 		}
 
-		// 尝试将贴纸图片转换为WebP格式
-		// 收集所有贴纸图片URL以进行WebP转换
-		allStickerImages := make([]string, 0)
-		stickerPathMap := make(map[string]string) // 存储原始路径和对应的键
-		
-		for key, stickerValue := range resourceUpdate.Stickers {
+		// 收集所有本站贴纸图片，资源更新后由后台任务转换为WebP格式
+		for _, stickerValue := range resourceUpdate.Stickers {
 			if stickerMap, ok := stickerValue.(map[string]interface{}); ok {
-				if urlValue, exists := stickerMap["url"]; exists {
-					if url, isString := urlValue.(string); isString && strings.HasPrefix(url, "/assets/") {
-						allStickerImages = append(allStickerImages, url)
-						stickerPathMap[url] = key
-					}
+				if url, isString := stickerMap["url"].(string); isString && strings.HasPrefix(url, "/assets/") {
+					convertPaths = append(convertPaths, url)
 				}
 			}
 		}
-		
-		// 如果有贴纸图片，进行WebP转换
-		if len(allStickerImages) > 0 {
-			log.Printf("开始将 %d 张贴纸图片转换为WebP格式", len(allStickerImages))
-			webpImages, err := convertResourceImagesToWebP(allStickerImages, resourceID)
-			
-			if err != nil {
-				log.Printf("转换贴纸图片为WebP格式时出错: %v", err)
-				// 发生错误时继续使用原始图片
-			} else {
-				// 更新贴纸中的图片URL为WebP路径
-				for i, oldPath := range allStickerImages {
-					if i < len(webpImages) && oldPath != webpImages[i] {
-						key := stickerPathMap[oldPath]
-						
-						if stickerMap, ok := resourceUpdate.Stickers[key].(map[string]interface{}); ok {
-							stickerMap["url"] = webpImages[i]
-							resourceUpdate.Stickers[key] = stickerMap
-							log.Printf("更新贴纸图片WebP路径: %s -> %s", oldPath, webpImages[i])
-						}
-					}
-				}
-				
-				// 异步调用WebP转换工具处理所有贴纸图片，确保转换完成
-				go func(paths []string) {
-					log.Printf("[INFO] 开始异步转换贴纸图片为WebP格式，图片数量: %d", len(paths))
-					convertImagesToWebP(paths)
-				}(webpImages)
-			}
-		}
 
 		resource.Stickers = resourceUpdate.Stickers
 		updated = true
@@ -817,6 +716,10 @@ func UpdateResource(c *gin.Context) {
 		return
 	}
 
+	// 转换任务完成后按 resources.images 刷新响应式图片，必须在资源更新之后创建
+	enqueueImageConversion(resourceID, convertPaths)
+	syncLinkHealth(resourceID)
+
 	log.Printf("资源更新成功: ID=%d", resourceID)
 	c.JSON(http.StatusOK, resource)
 }
@@ -844,6 +747,7 @@ func DeleteResource(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除资源失败"})
 		return
 	}
+	syncLinkHealth(resourceID)
 
 	c.Status(http.StatusNoContent)
 }
@@ -880,6 +784,8 @@ func UpdateResourceStickers(c *gin.Context) {
 	
 	// 检查贴纸中是否有需要移动的图片（从临时uploads目录到永久目录）
 	stickerMapModified := false
+	// 需要转换WebP的贴纸图片，资源更新后再创建任务
+	var convertPaths []string
 	
 	if stickerUpdate.Stickers != nil {
 		// 存储需要移动的贴纸图片
@@ -924,53 +830,14 @@ func UpdateResourceStickers(c *gin.Context) {
 			}
 		}
 		
-		// 尝试将贴纸图片转换为WebP格式
-		// 收集所有贴纸图片URL以进行WebP转换
-		allStickerImages := make([]string, 0)
-		stickerPathMap := make(map[string]string) // 存储原始路径和对应的键
-		
-		for key, sticker := range stickerUpdate.Stickers {
+		// 收集所有本站贴纸图片，更新后由后台任务转换为WebP格式
+		for _, sticker := range stickerUpdate.Stickers {
 			if stickerMap, ok := sticker.(map[string]interface{}); ok {
-				if url, exists := stickerMap["url"].(string); exists {
-					// 只处理本地图片
-					if strings.HasPrefix(url, "/assets/") {
-						allStickerImages = append(allStickerImages, url)
-						stickerPathMap[url] = key
-					}
+				if url, exists := stickerMap["url"].(string); exists && strings.HasPrefix(url, "/assets/") {
+					convertPaths = append(convertPaths, url)
 				}
 			}
 		}
-		
-		// 如果有贴纸图片，进行WebP转换
-		if len(allStickerImages) > 0 {
-			log.Printf("开始将 %d 张贴纸图片转换为WebP格式", len(allStickerImages))
-			webpImages, err := convertResourceImagesToWebP(allStickerImages, resourceID)
-			
-			if err != nil {
-				log.Printf("转换贴纸图片为WebP格式时出错: %v", err)
-				// 发生错误时继续使用原始图片
-			} else {
-				// 更新贴纸中的图片URL为WebP路径
-				for i, oldPath := range allStickerImages {
-					if i < len(webpImages) && oldPath != webpImages[i] {
-						key := stickerPathMap[oldPath]
-						
-						if stickerMap, ok := stickerUpdate.Stickers[key].(map[string]interface{}); ok {
-							stickerMap["url"] = webpImages[i]
-							stickerUpdate.Stickers[key] = stickerMap
-							log.Printf("更新贴纸图片WebP路径: %s -> %s", oldPath, webpImages[i])
-							stickerMapModified = true
-						}
-					}
-				}
-				
-				// 异步调用WebP转换工具处理所有贴纸图片，确保转换完成
-				go func(paths []string) {
-					log.Printf("[INFO] 开始异步转换贴纸图片为WebP格式，图片数量: %d", len(paths))
-					convertImagesToWebP(paths)
-				}(webpImages)
-			}
-		}
 	}
 
 	// 更新贴纸数据
@@ -988,6 +855,9 @@ func UpdateResourceStickers(c *gin.Context) {
 		return
 	}
 
+	// 转换任务必须在资源更新之后创建
+	enqueueImageConversion(resourceID, convertPaths)
+
 	log.Printf("贴纸更新成功，资源ID: %d", resourceID)
 	c.JSON(http.StatusOK, gin.H{"message": "贴纸更新成功", "resource": resource})
 }
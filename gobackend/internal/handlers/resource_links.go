@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/auth"
+	"dongman/internal/models"
+	"dongman/internal/utils"
+)
+
+// anonymousSubmitter 未登录用户提交的链接的 added_by
+const anonymousSubmitter = "anonymous"
+
+// normalizeSubmittedLinks 校验并规范化请求中的链接，校验失败时已写入400响应并返回false
+func normalizeSubmittedLinks(c *gin.Context, raw models.JsonMap) (models.LinkMap, bool) {
+	links, err := utils.NormalizeLinks(raw, submitterName(c), time.Now())
+	if err != nil {
+		var fieldErrs utils.LinkFieldErrors
+		if errors.As(err, &fieldErrs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "链接校验失败", "fields": fieldErrs})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	attachTorrentFiles(links)
+	return links, true
+}
+
+// attachTorrentFiles 为上传过种子文件的磁力链接补全文件列表，以及磁力链接中没有的名称和大小
+func attachTorrentFiles(links models.LinkMap) {
+	for i := range links[models.LinkCategoryMagnet] {
+		link := &links[models.LinkCategoryMagnet][i]
+		if link.Meta == nil {
+			continue
+		}
+		torrent, err := models.GetTorrentMeta(utils.LinkKey(link.URL))
+		if err != nil {
+			log.Printf("%v", err)
+			continue
+		}
+		if torrent == nil {
+			continue
+		}
+		link.Meta.Files = torrent.Files
+		if link.Meta.Name == "" {
+			link.Meta.Name = torrent.Name
+		}
+		if link.Meta.Size == 0 {
+			link.Meta.Size = torrent.Size
+		}
+		if link.Title == "" {
+			link.Title = link.Meta.Name
+		}
+		if link.Size == 0 {
+			link.Size = link.Meta.Size
+		}
+	}
+}
+
+// submitterName 返回提交者的用户名，公开接口上未登录或令牌无效时返回 anonymous
+func submitterName(c *gin.Context) string {
+	if username := c.GetString("username"); username != "" {
+		return username
+	}
+	parts := strings.Split(c.GetHeader("Authorization"), " ")
+	if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+		if claims, err := auth.VerifyToken(parts[1]); err == nil {
+			return claims.Username
+		}
+	}
+	return anonymousSubmitter
+}
+
+// groupApprovedLinks 把审批请求中的链接按 category 分组并去掉 category 字段
+// 缺少分类或分类未知的链接按URL推断分类，无法识别的归入 others
+func groupApprovedLinks(links []map[string]interface{}) map[string][]map[string]interface{} {
+	grouped := make(map[string][]map[string]interface{})
+	for _, link := range links {
+		category, _ := link["category"].(string)
+		if !models.IsLinkCategory(category) {
+			url, _ := link["url"].(string)
+			category = utils.DetectLinkCategory(url)
+		}
+		linkData := make(map[string]interface{}, len(link))
+		for k, v := range link {
+			if k != "category" {
+				linkData[k] = v
+			}
+		}
+		grouped[category] = append(grouped[category], linkData)
+	}
+	return grouped
+}
+
+// dedupeApprovedLinks 去掉与资源已有链接重复或彼此重复的已批准链接，返回保留的链接和重复项
+func dedupeApprovedLinks(existing models.JsonMap, grouped map[string][]map[string]interface{}) (map[string][]map[string]interface{}, []models.LinkDuplicate) {
+	idx := utils.LinkIndex{}
+	idx.Add(models.ParseLinkMap(existing), models.LinkSourceResource)
+
+	kept := make(map[string][]map[string]interface{})
+	var duplicates []models.LinkDuplicate
+	for _, category := range models.LinkCategories {
+		for _, link := range grouped[category] {
+			url, _ := link["url"].(string)
+			key := utils.LinkKey(url)
+			if dup, ok := idx[key]; ok {
+				dup.Category = category
+				dup.URL = url
+				duplicates = append(duplicates, dup)
+				continue
+			}
+			idx[key] = models.LinkDuplicate{Key: key, ExistingCategory: category, ExistingURL: url, Existing: models.LinkSourceSubmission}
+			kept[category] = append(kept[category], link)
+		}
+	}
+	return kept, duplicates
+}
+
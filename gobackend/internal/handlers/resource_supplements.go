@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/models"
+)
+
+// contributorTokenHeader 匿名贡献者令牌的请求/响应头
+// 首次匿名提交补充内容时由服务端生成并返回，之后带上同一令牌提交的补充内容归为同一贡献者
+const contributorTokenHeader = "X-Contributor-Token"
+
+var contributorTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{16,64}$`)
+
+var (
+	errSupplementNotFound   = errors.New("补充内容不存在")
+	errSupplementNotPending = errors.New("补充内容不是待审批状态")
+)
+
+// supplementContributor 返回提交补充内容的登录用户ID，未登录时返回请求中的匿名令牌（没有或格式无效时生成新令牌）
+func supplementContributor(c *gin.Context) (*int, *string, error) {
+	if name := submitterName(c); name != anonymousSubmitter {
+		var userID int
+		err := models.DB.Get(&userID, `SELECT id FROM users WHERE username = ?`, name)
+		if err == nil {
+			return &userID, nil, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, err
+		}
+		// 令牌有效但用户已被删除时按匿名处理
+	}
+
+	token := c.GetHeader(contributorTokenHeader)
+	if !contributorTokenPattern.MatchString(token) {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		token = hex.EncodeToString(buf)
+	}
+	return nil, &token, nil
+}
+
+// pendingSupplementFor 返回资源要审批的补充内容：指定ID时为该补充内容，否则为已审批资源最早提交的待审批补充内容
+// 没有待审批的补充内容时返回nil；指定的补充内容已被审批时同时返回该补充内容和 errSupplementNotPending
+func pendingSupplementFor(resource models.Resource, supplementID *int) (*models.Supplement, error) {
+	if supplementID != nil {
+		supplement, err := models.GetSupplement(*supplementID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && supplement.ResourceID != resource.ID) {
+			return nil, errSupplementNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if supplement.Status != models.ResourceStatusPending {
+			return supplement, errSupplementNotPending
+		}
+		return supplement, nil
+	}
+
+	if resource.Status == models.ResourceStatusPending {
+		return nil, nil
+	}
+	pending, err := models.ListSupplements(resource.ID, models.ResourceStatusPending)
+	if err != nil || len(pending) == 0 {
+		return nil, err
+	}
+	return &pending[0], nil
+}
+
+// respondSupplementError 按 pendingSupplementFor 的错误类型返回响应
+func respondSupplementError(c *gin.Context, resourceID int, err error) {
+	switch {
+	case errors.Is(err, errSupplementNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, errSupplementNotPending):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("查询资源 %d 的补充内容失败: %v", resourceID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询补充内容失败"})
+	}
+}
+
+// attachPendingSupplements 为资源附加待审批补充内容的份数，supplement 字段设为最早提交的一份
+// 返回资源ID -> 最早提交的待审批补充内容
+func attachPendingSupplements(resources []models.Resource) (map[int]*models.Supplement, error) {
+	pending, err := models.ListPendingSupplements()
+	if err != nil {
+		return nil, err
+	}
+	oldest := make(map[int]*models.Supplement)
+	counts := make(map[int]int)
+	for i := range pending {
+		if _, ok := oldest[pending[i].ResourceID]; !ok {
+			oldest[pending[i].ResourceID] = &pending[i]
+		}
+		counts[pending[i].ResourceID]++
+	}
+	for i := range resources {
+		if s, ok := oldest[resources[i].ID]; ok {
+			resources[i].Supplement = s.AsJsonMap()
+			resources[i].HasPendingSupplement = true
+			resources[i].PendingSupplements = counts[resources[i].ID]
+		}
+	}
+	return oldest, nil
+}
+
+// GetResourceSupplements 获取资源的补充内容列表 - 仅管理员可访问
+// status 默认 PENDING，all 返回全部状态
+func GetResourceSupplements(c *gin.Context) {
+	resourceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的资源ID"})
+		return
+	}
+
+	status := models.ResourceStatus(strings.ToUpper(c.DefaultQuery("status", string(models.ResourceStatusPending))))
+	switch status {
+	case "ALL":
+		status = ""
+	case models.ResourceStatusPending, models.ResourceStatusApproved, models.ResourceStatusRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的状态"})
+		return
+	}
+
+	var count int
+	if err := models.DB.Get(&count, `SELECT COUNT(*) FROM resources WHERE id = ?`, resourceID); err != nil || count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "资源未找到"})
+		return
+	}
+
+	supplements, err := models.ListSupplements(resourceID, status)
+	if err != nil {
+		log.Printf("%v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询补充内容失败"})
+		return
+	}
+
+	// 为待审批的补充图片附加近似重复图片
+	if idx, err := newDuplicateIndex(); err != nil {
+		log.Printf("构建图片感知哈希索引失败: %v", err)
+	} else {
+		for i := range supplements {
+			if supplements[i].Status == models.ResourceStatusPending {
+				supplements[i].NearDuplicates = idx.find(resourceID, supplementImagePaths(supplements[i].Images))
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, supplements)
+}
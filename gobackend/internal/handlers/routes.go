@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"github.com/gin-gonic/gin"
-	"dongman/internal/config"
 )
 
 // SetupRoutes 设置API路由
@@ -57,13 +56,60 @@ func SetupRoutes(router *gin.Engine) {
 		admin.POST("/users", CreateUser)
 		admin.PUT("/users/:id", UpdateUser)
 		admin.DELETE("/users/:id", DeleteUser)
+
+		// 图片缩放签名
+		admin.GET("/img/sign", SignImageURLHandler)
+
+		// 补算已有图片的感知哈希
+		admin.POST("/images/hashes/rebuild", RebuildImageHashes)
+
+		// 图片引用完整性检查和修复
+		admin.GET("/assets/check", CheckAssets)
+		admin.POST("/assets/repair", RepairAssets)
+
+		// 后台任务管理
+		admin.GET("/jobs", GetJobs)
+		admin.POST("/jobs/retry-failed", RetryFailedJobs)
+		admin.POST("/jobs/:id/retry", RetryJob)
+
+		// 网盘分享链接有效性检查
+		admin.GET("/links/broken", GetBrokenLinks)
+		admin.POST("/links/:id/recheck", RecheckLink)
+
+		// 链接举报审核队列
+		admin.GET("/link-reports", GetLinkReports)
+		admin.POST("/link-reports/:id/resolve", ResolveLinkReport)
+
+		// 驳回原因目录
+		admin.GET("/rejection-reasons", GetRejectionReasons)
+		admin.POST("/rejection-reasons", CreateRejectionReason)
+		admin.PUT("/rejection-reasons/:id", UpdateRejectionReason)
+		admin.DELETE("/rejection-reasons/:id", DeleteRejectionReason)
 	}
 
+	// 提交者凭跟踪令牌查询审批状态 - 公开接口
+	api.GET("/submissions/:token", GetSubmissionStatus)
+
+	// 图片按需缩放 - 公开接口，预设尺寸无需签名
+	api.GET("/img/*path", ResizeImageHandler)
+
 	// 图像处理工具路由
 	imgtools := api.Group("/imgtools")
 	{
-		// 图像超分辨率API - 公开接口
+		// 图像超分辨率API - 公开接口，异步执行
 		imgtools.POST("/enhance", EnhanceImageHandler)
+		imgtools.GET("/jobs/:id", GetEnhanceJob)
+		imgtools.GET("/jobs/:id/events", StreamEnhanceJob)
+
+		// 裁剪、缩放、旋转、水印和格式转换 - 公开接口，同步返回处理后的图片
+		imgtools.POST("/process", ProcessImageHandler)
+	}
+	
+	// 链接解析路由 - 公开接口
+	links := api.Group("/links")
+	{
+		links.POST("/parse", ParseLinkMeta)
+		links.POST("/torrent", UploadTorrent)
 	}
 	
 	// TMDB API路由
@@ -106,6 +152,7 @@ func SetupRoutes(router *gin.Engine) {
 		resources.POST("/:id/like", LikeResource)
 		resources.POST("/:id/unlike", UnlikeResource)
 		resources.PUT("/:id/supplement", SupplementResource)
+		resources.POST("/:id/links/report", ReportLink)
 		resources.PUT("/:id/stickers", UpdateResourceStickers)
 		resources.POST("/:id/update-tmdb", UpdateResourceTMDBInfo)
 
@@ -134,6 +181,8 @@ func SetupRoutes(router *gin.Engine) {
 			adminResources.GET("/pending", GetPendingResources)
 			adminResources.GET("/pending-supplements", GetPendingSupplementResources)
 			adminResources.GET("/:id/supplement", GetResourceSupplement)
+			adminResources.GET("/:id/supplements", GetResourceSupplements)
+			adminResources.GET("/:id/diff", GetResourceDiff)
 			adminResources.PUT("/:id", UpdateResource)
 			adminResources.PUT("/:id/approve", ApproveResource)
 			adminResources.DELETE("/:id", DeleteResource)
@@ -141,6 +190,7 @@ func SetupRoutes(router *gin.Engine) {
 			adminResources.DELETE("/batch-delete-records", DeleteApprovalRecords)
 			adminResources.GET("/approval-records", GetApprovalRecords)
 			adminResources.GET("/:id/approval-records", GetResourceApprovalRecords)
+			adminResources.POST("/:id/approval-records/:rid/revert", RevertApprovalRecord)
 		}
 	}
 	
@@ -165,5 +215,5 @@ func SetupRoutes(router *gin.Engine) {
 	}
 	
 	// 静态资源路由 - 用于访问上传的文件
-	api.Static("/assets", config.AssetPath)
+	MountAssets(api, "/assets", "")
 } 
\ No newline at end of file
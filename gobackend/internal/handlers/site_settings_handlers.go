@@ -9,8 +9,7 @@ import (
 	"io"
 	"bytes"
 	"os"
-	"path/filepath"
-	"dongman/internal/config"
+	"dongman/internal/storage"
 	"dongman/internal/utils"
 	"encoding/json"
 	"fmt"
@@ -182,24 +181,24 @@ func UploadFavicon(c *gin.Context) {
 		return
 	}
 
-	// 确保目录存在
-	publicDir := filepath.Join(config.AssetPath, "public")
-	if err := os.MkdirAll(publicDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建目录失败"})
+	// 打开上传的文件
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的文件上传"})
 		return
 	}
+	defer src.Close()
 
-	// 保存favicon.ico
-	faviconPath := filepath.Join(publicDir, "favicon.ico")
-	
-	// 保存上传的文件
-	if err := c.SaveUploadedFile(file, faviconPath); err != nil {
+	// 保存favicon.ico到存储后端
+	faviconKey := "public/favicon.ico"
+	if err := storage.Default().Put(faviconKey, src, file.Size, "image/x-icon"); err != nil {
+		log.Printf("保存网站图标失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
 		return
 	}
 
 	// 增加日志输出
-	log.Printf("网站图标已更新，保存路径: %s", faviconPath)
+	log.Printf("网站图标已更新，保存路径: %s", faviconKey)
 	
 	// 确保返回正确的路径
 	c.JSON(http.StatusOK, gin.H{
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/models"
+)
+
+// GetSubmissionStatus 凭跟踪令牌查询提交的审批状态和驳回原因 - 公开接口
+// 不返回审批人和审批备注
+func GetSubmissionStatus(c *gin.Context) {
+	submission, err := models.GetSubmission(c.Param("token"))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "提交记录不存在"})
+		return
+	}
+	if err != nil {
+		log.Printf("查询提交记录失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询提交记录失败"})
+		return
+	}
+
+	kind := "resource"
+	if submission.SupplementID != nil {
+		kind = "supplement"
+	}
+	reasons := submission.RejectionReasons
+	if reasons == nil {
+		reasons = models.AppliedRejectionReasons{}
+	}
+	response := gin.H{
+		"kind":              kind,
+		"resource_id":       submission.ResourceID,
+		"supplement_id":     submission.SupplementID,
+		"status":            submission.Status,
+		"rejection_reasons": reasons,
+		"submitted_at":      submission.CreatedAt,
+		"reviewed_at":       submission.ReviewedAt,
+	}
+
+	// 资源仍存在时附带标题；没有批准任何内容的新资源审批后会被删除
+	var title string
+	err = models.DB.Get(&title, `SELECT title FROM resources WHERE id = ?`, submission.ResourceID)
+	if err == nil {
+		response["title"] = title
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("查询资源 %d 失败: %v", submission.ResourceID, err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
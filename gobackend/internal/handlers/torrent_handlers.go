@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"dongman/internal/models"
+	"dongman/internal/utils"
+)
+
+// ParseLinkMeta 解析磁力链接或ed2k链接的元数据 - 公开接口，供提交前预览
+// 磁力链接对应的种子文件上传过时附带文件列表
+func ParseLinkMeta(c *gin.Context) {
+	var req struct {
+		URL string `json:"url" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	var (
+		meta *models.LinkMeta
+		err  error
+	)
+	category := utils.DetectLinkCategory(req.URL)
+	switch category {
+	case models.LinkCategoryMagnet:
+		meta, err = utils.ParseMagnet(req.URL)
+	case models.LinkCategoryEd2k:
+		meta, err = utils.ParseEd2k(req.URL)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只支持解析磁力链接和ed2k链接"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := utils.LinkKey(req.URL)
+	if category == models.LinkCategoryMagnet {
+		links := models.LinkMap{category: {{URL: strings.TrimSpace(req.URL), Meta: meta}}}
+		attachTorrentFiles(links)
+		meta = links[category][0].Meta
+	}
+	c.JSON(http.StatusOK, gin.H{"category": category, "key": key, "meta": meta})
+}
+
+// UploadTorrent 上传种子文件，返回生成的磁力链接和文件列表 - 公开接口
+// 解析结果按信息哈希保存，之后提交该磁力链接时自动附带文件列表，种子文件本身不保存
+func UploadTorrent(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, utils.MaxTorrentBytes+(1<<20))
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("文件过大，最大支持 %dMB", utils.MaxTorrentBytes>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的文件上传"})
+		return
+	}
+	defer file.Close()
+
+	if !strings.HasSuffix(strings.ToLower(header.Filename), ".torrent") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只支持 .torrent 文件"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, utils.MaxTorrentBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取文件失败"})
+		return
+	}
+	meta, err := utils.ParseTorrent(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	magnet := utils.BuildMagnet(meta)
+	// 混合种子分别按 v1 和 v2 哈希保存，只带其中一个哈希的磁力链接也能找到文件列表
+	var keys []string
+	if meta.InfoHash != "" {
+		keys = append(keys, "btih:"+meta.InfoHash)
+	}
+	if meta.InfoHashV2 != "" {
+		keys = append(keys, "btmh:1220"+meta.InfoHashV2)
+	}
+	for _, key := range keys {
+		if err := models.SaveTorrentMeta(key, meta); err != nil {
+			log.Printf("%v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存种子信息失败"})
+			return
+		}
+	}
+	log.Printf("已解析种子 %s: %s，%d 个文件", keys[0], meta.Name, len(meta.Files))
+
+	c.JSON(http.StatusOK, gin.H{
+		"key":  utils.LinkKey(magnet),
+		"link": models.Link{URL: magnet, Title: meta.Name, Size: meta.Size, Meta: meta},
+	})
+}
@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
-	"path/filepath"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
@@ -16,30 +18,34 @@ import (
 
 // UploadImage 处理单个图片上传
 func UploadImage(c *gin.Context) {
+	// 限制请求体大小，预留multipart表单开销
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, utils.MaxUploadImageBytes+(1<<20))
+
 	// 获取上传的文件
 	file, header, err := c.Request.FormFile("file")
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("文件过大，最大支持 %dMB", utils.MaxUploadImageBytes>>20),
+				"code":  utils.UploadErrFileTooLarge,
+			})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的文件上传"})
 		return
 	}
 	defer file.Close()
 
-	// 检查文件类型
-	ext := filepath.Ext(header.Filename)
-	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".gif" && ext != ".webp" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的文件类型，仅支持jpg、jpeg、png、gif和webp"})
-		return
-	}
-
-	// 为了计算哈希值，我们需要完整地读取文件内容
-	fileBytes, err := io.ReadAll(file)
+	// 校验文件内容并去除元数据
+	sanitized, err := readAndSanitizeImage(file, header)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("读取文件内容失败: %v", err)})
+		respondUploadError(c, err)
 		return
 	}
 
 	// 创建一个字节读取器，这样可以多次读取文件内容
-	fileReader := bytes.NewReader(fileBytes)
+	fileReader := bytes.NewReader(sanitized.Data)
 	
 	// 计算文件哈希值
 	fileHash, err := utils.CalculateFileHash(fileReader)
@@ -51,12 +57,13 @@ func UploadImage(c *gin.Context) {
 	// 重新创建一个新的字节读取器用于保存文件
 	fileReader.Seek(0, io.SeekStart) // 确保重置到开头
 	
-	// 保存文件
-	savedPath, err := utils.SaveUploadedFile(fileReader, header.Filename)
+	// 保存文件，扩展名以检测到的真实类型为准
+	savedPath, err := utils.SaveUploadedFile(fileReader, sanitized.Filename)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("保存文件失败: %v", err)})
 		return
 	}
+	recordUploadedImageHash(savedPath, sanitized.Data)
 
 	// 返回文件信息
 	c.JSON(http.StatusOK, gin.H{
@@ -64,16 +71,32 @@ func UploadImage(c *gin.Context) {
 		"url":     savedPath,    // 保留url字段以兼容可能的前端代码
 		"hash":    fileHash,
 		"name":    header.Filename,
-		"size":    header.Size,
-		"type":    filepath.Ext(header.Filename),
+		"size":    len(sanitized.Data),
+		"type":    sanitized.Ext,
+		"width":   sanitized.Width,
+		"height":  sanitized.Height,
 	})
 }
 
+// maxUploadMultipleFiles 批量上传一次最多的文件数
+const maxUploadMultipleFiles = 10
+
 // UploadMultipleImages 处理多个图片上传（批量上传）
 func UploadMultipleImages(c *gin.Context) {
+	// 限制请求体大小，预留multipart表单开销
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadMultipleFiles*utils.MaxUploadImageBytes+(1<<20))
+
 	// 获取上传的文件
 	form, err := c.MultipartForm()
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("上传内容过大，一次最多 %d 个文件，每个最大 %dMB", maxUploadMultipleFiles, utils.MaxUploadImageBytes>>20),
+				"code":  utils.UploadErrFileTooLarge,
+			})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的文件上传"})
 		return
 	}
@@ -83,6 +106,10 @@ func UploadMultipleImages(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "没有文件上传"})
 		return
 	}
+	if len(files) > maxUploadMultipleFiles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("一次最多上传 %d 个文件", maxUploadMultipleFiles)})
+		return
+	}
 
 	results := make([]gin.H, 0, len(files))
 
@@ -96,30 +123,25 @@ func UploadMultipleImages(c *gin.Context) {
 			})
 			continue
 		}
-		defer file.Close()
 
-		// 检查文件类型
-		ext := filepath.Ext(fileHeader.Filename)
-		if ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".gif" && ext != ".webp" {
-			results = append(results, gin.H{
-				"name":  fileHeader.Filename,
-				"error": "不支持的文件类型，仅支持jpg、jpeg、png、gif和webp",
-			})
-			continue
-		}
-
-		// 为了计算哈希值，我们需要完整地读取文件内容
-		fileBytes, err := io.ReadAll(file)
+		// 校验文件内容并去除元数据
+		sanitized, err := readAndSanitizeImage(file, fileHeader)
+		file.Close()
 		if err != nil {
-			results = append(results, gin.H{
+			result := gin.H{
 				"name":  fileHeader.Filename,
-				"error": fmt.Sprintf("读取文件内容失败: %v", err),
-			})
+				"error": err.Error(),
+			}
+			var uploadErr *utils.UploadError
+			if errors.As(err, &uploadErr) {
+				result["code"] = uploadErr.Code
+			}
+			results = append(results, result)
 			continue
 		}
 
 		// 创建一个字节读取器，这样可以多次读取文件内容
-		fileReader := bytes.NewReader(fileBytes)
+		fileReader := bytes.NewReader(sanitized.Data)
 		
 		// 计算文件哈希值
 		fileHash, err := utils.CalculateFileHash(fileReader)
@@ -135,7 +157,7 @@ func UploadMultipleImages(c *gin.Context) {
 		fileReader.Seek(0, io.SeekStart)
 		
 		// 保存文件
-		savedPath, err := utils.SaveUploadedFile(fileReader, fileHeader.Filename)
+		savedPath, err := utils.SaveUploadedFile(fileReader, sanitized.Filename)
 		if err != nil {
 			results = append(results, gin.H{
 				"name":  fileHeader.Filename,
@@ -143,6 +165,7 @@ func UploadMultipleImages(c *gin.Context) {
 			})
 			continue
 		}
+		recordUploadedImageHash(savedPath, sanitized.Data)
 
 		// 添加结果
 		results = append(results, gin.H{
@@ -150,14 +173,68 @@ func UploadMultipleImages(c *gin.Context) {
 			"url":     savedPath,     // 保留url字段以兼容可能的前端代码
 			"hash":    fileHash,
 			"name":    fileHeader.Filename,
-			"size":    fileHeader.Size,
-			"type":    filepath.Ext(fileHeader.Filename),
+			"size":    len(sanitized.Data),
+			"type":    sanitized.Ext,
+			"width":   sanitized.Width,
+			"height":  sanitized.Height,
 		})
 	}
 
 	c.JSON(http.StatusOK, results)
 }
 
+// readAndSanitizeImage 读取上传文件并进行内容校验
+func readAndSanitizeImage(file multipart.File, header *multipart.FileHeader) (*utils.SanitizedImage, error) {
+	// 先按扩展名快速拒绝（不区分大小写），避免读取无关文件
+	if !utils.IsAllowedImageExt(header.Filename) {
+		return nil, &utils.UploadError{
+			Code:    utils.UploadErrUnsupportedExt,
+			Message: "不支持的文件类型，仅支持jpg、jpeg、png、gif和webp",
+		}
+	}
+	if header.Size > utils.MaxUploadImageBytes {
+		return nil, &utils.UploadError{
+			Code:    utils.UploadErrFileTooLarge,
+			Message: fmt.Sprintf("文件过大，最大支持 %dMB", utils.MaxUploadImageBytes>>20),
+		}
+	}
+
+	// 多读一个字节用于判断是否超过大小限制
+	fileBytes, err := io.ReadAll(io.LimitReader(file, utils.MaxUploadImageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取文件内容失败: %w", err)
+	}
+	return utils.SanitizeUploadedImage(fileBytes, header.Filename)
+}
+
+// recordUploadedImageHash 记录上传图片的感知哈希，供审批时检测近似重复；计算失败时交给后台任务重试
+func recordUploadedImageHash(savedPath string, data []byte) {
+	if err := utils.RecordImageHash(savedPath, data); err != nil {
+		log.Printf("计算图片感知哈希失败 %s: %v", savedPath, err)
+		enqueueImageHash([]string{savedPath})
+	}
+}
+
+// respondUploadError 返回上传校验错误，包含错误码
+func respondUploadError(c *gin.Context, err error) {
+	var uploadErr *utils.UploadError
+	if !errors.As(err, &uploadErr) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	status := http.StatusBadRequest
+	switch uploadErr.Code {
+	case utils.UploadErrFileTooLarge:
+		status = http.StatusRequestEntityTooLarge
+	case utils.UploadErrUnsupportedExt, utils.UploadErrUnsupportedType:
+		status = http.StatusUnsupportedMediaType
+	case utils.UploadErrProcessFailed:
+		status = http.StatusInternalServerError
+	}
+	c.JSON(status, gin.H{"error": uploadErr.Message, "code": uploadErr.Code})
+}
+
 // LikeResource 增加资源喜欢计数
 func LikeResource(c *gin.Context) {
 	// 获取路径参数
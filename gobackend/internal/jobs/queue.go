@@ -0,0 +1,253 @@
+// Package jobs 基于SQLite的持久化后台任务队列
+// 任务先写入 jobs 表再由worker池领取执行，进程重启后未完成的任务会继续执行，
+// 失败的任务按指数退避重试，超过最大次数后标记为失败，可由管理员重新排队。
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"dongman/internal/models"
+)
+
+const (
+	// DefaultMaxAttempts 任务默认最大执行次数
+	DefaultMaxAttempts = 5
+
+	pollInterval   = 2 * time.Second
+	baseRetryDelay = 30 * time.Second
+	maxRetryDelay  = 30 * time.Minute
+	// 成功任务保留时间，超过后定期清理
+	succeededRetention = 7 * 24 * time.Hour
+)
+
+// Handler 任务处理函数，返回错误时按退避策略重试
+type Handler func(payload json.RawMessage) error
+
+// permanentError 不需要重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 包装不需要重试的错误（如源文件已不存在），任务将直接标记为失败
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = make(map[string]Handler)
+
+	notify   = make(chan struct{}, 1)
+	stopOnce sync.Once
+	stop     = make(chan struct{})
+	wg       sync.WaitGroup
+)
+
+// Register 注册任务类型的处理函数，需在 Start 之前调用
+func Register(jobType string, handler Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[jobType] = handler
+}
+
+// Enqueue 将任务写入队列，payload 会被序列化为JSON
+func Enqueue(jobType string, payload interface{}) (int64, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("序列化任务参数失败: %w", err)
+	}
+	id, err := models.CreateJob(jobType, string(data), DefaultMaxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	Wake()
+	return id, nil
+}
+
+// EnqueueTx 在事务中写入任务，事务回滚时任务一并撤销；事务提交后需调用 Wake 通知worker
+func EnqueueTx(tx *sqlx.Tx, jobType string, payload interface{}) (int64, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("序列化任务参数失败: %w", err)
+	}
+	return models.CreateJobTx(tx, jobType, string(data), DefaultMaxAttempts)
+}
+
+// Wake 通知空闲的worker立即检查队列
+func Wake() {
+	select {
+	case notify <- struct{}{}:
+	default:
+	}
+}
+
+// Start 恢复上次中断的任务并启动指定数量的worker
+func Start(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	if n, err := models.ResetRunningJobs(); err != nil {
+		log.Printf("%v", err)
+	} else if n > 0 {
+		log.Printf("已恢复 %d 个中断的后台任务", n)
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go worker()
+	}
+
+	wg.Add(1)
+	go cleanupLoop()
+	log.Printf("后台任务队列已启动，worker数量: %d", workers)
+}
+
+// Shutdown 停止领取新任务并等待正在执行的任务完成，超时后返回 ctx 的错误
+// 超时未完成的任务保持执行中状态，下次启动时会重新排队
+func Shutdown(ctx context.Context) error {
+	stopOnce.Do(func() { close(stop) })
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// worker 循环领取并执行任务，队列为空时等待通知或定时轮询
+func worker() {
+	defer wg.Done()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		job, err := models.ClaimJob()
+		if err != nil {
+			log.Printf("%v", err)
+		}
+		if job != nil {
+			runJob(job)
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(pollInterval)
+		select {
+		case <-stop:
+			return
+		case <-notify:
+		case <-timer.C:
+		}
+	}
+}
+
+// runJob 执行单个任务并记录结果
+func runJob(job *models.Job) {
+	startTime := time.Now()
+	err := execute(job)
+	if err == nil {
+		if errComplete := models.CompleteJob(job.ID); errComplete != nil {
+			log.Printf("%v", errComplete)
+		}
+		log.Printf("[INFO] 后台任务 #%d (%s) 执行成功，耗时: %v", job.ID, job.JobType, time.Since(startTime))
+		return
+	}
+
+	var retryAt *time.Time
+	var permanent *permanentError
+	if !errors.As(err, &permanent) && job.Attempts < job.MaxAttempts {
+		next := time.Now().Add(retryDelay(job.Attempts))
+		retryAt = &next
+	}
+	if errFail := models.FailJob(job.ID, err.Error(), retryAt); errFail != nil {
+		log.Printf("%v", errFail)
+	}
+	if retryAt != nil {
+		log.Printf("[WARN] 后台任务 #%d (%s) 第 %d 次执行失败，将于 %s 重试: %v",
+			job.ID, job.JobType, job.Attempts, retryAt.Format(time.RFC3339), err)
+	} else {
+		log.Printf("[ERROR] 后台任务 #%d (%s) 执行失败，不再重试: %v", job.ID, job.JobType, err)
+	}
+}
+
+// execute 调用任务处理函数，处理函数panic时视为执行失败
+func execute(job *models.Job) (err error) {
+	handlersMu.RLock()
+	handler, ok := handlers[job.JobType]
+	handlersMu.RUnlock()
+	if !ok {
+		return Permanent(fmt.Errorf("未知的任务类型: %s", job.JobType))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务执行时发生严重错误: %v", r)
+		}
+	}()
+	return handler(json.RawMessage(job.Payload))
+}
+
+// retryDelay 第 attempts 次失败后的重试间隔，按指数增长
+func retryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// cleanupLoop 定期清理过期的成功任务
+func cleanupLoop() {
+	defer wg.Done()
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			n, err := models.DeleteFinishedJobs(time.Now().Add(-succeededRetention))
+			if err != nil {
+				log.Printf("%v", err)
+			} else if n > 0 {
+				log.Printf("已清理 %d 个过期的后台任务", n)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package linkcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"dongman/internal/models"
+)
+
+// AliyunChecker 调用阿里云盘的匿名分享信息接口判断分享是否有效：
+// 有效的分享返回200和分享信息，失效的分享返回4xx和 ShareLink.* 错误码（如 ShareLink.Cancelled、ShareLink.Expired）
+type AliyunChecker struct {
+	BaseURL string // 默认 https://api.aliyundrive.com，测试时可指向本地服务
+	Client  *http.Client
+}
+
+// NewAliyunChecker 创建阿里云盘检查器，baseURL 和 client 为空时使用默认值
+func NewAliyunChecker(baseURL string, client *http.Client) *AliyunChecker {
+	if baseURL == "" {
+		baseURL = "https://api.aliyundrive.com"
+	}
+	if client == nil {
+		client = defaultClient()
+	}
+	return &AliyunChecker{BaseURL: strings.TrimRight(baseURL, "/"), Client: client}
+}
+
+func (c *AliyunChecker) Category() string { return models.LinkCategoryAliyun }
+
+func (c *AliyunChecker) Check(ctx context.Context, shareID, password string) (Verdict, string, error) {
+	endpoint := c.BaseURL + "/adrive/v3/share_link/get_share_by_anonymous?share_id=" + url.QueryEscape(shareID)
+	status, body, err := postJSON(ctx, c.Client, endpoint, map[string]string{"share_id": shareID})
+	if err != nil {
+		return "", "", fmt.Errorf("请求阿里云盘失败: %w", err)
+	}
+
+	var resp struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		ShareName string `json:"share_name"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", "", fmt.Errorf("阿里云盘返回无法解析的响应 (%d)", status)
+	}
+
+	switch {
+	case status == http.StatusOK:
+		return VerdictAlive, resp.ShareName, nil
+	case strings.HasPrefix(resp.Code, "ShareLink.") || strings.HasPrefix(resp.Code, "NotFound."):
+		return VerdictDead, strings.TrimSpace(resp.Code + " " + resp.Message), nil
+	default:
+		return "", "", fmt.Errorf("阿里云盘返回 %d: %s %s", status, resp.Code, resp.Message)
+	}
+}
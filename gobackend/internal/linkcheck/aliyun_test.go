@@ -0,0 +1,84 @@
+package linkcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAliyunCheckerVerdicts(t *testing.T) {
+	// 按 share_id 返回不同的接口响应，模拟阿里云盘的匿名分享信息接口
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/adrive/v3/share_link/get_share_by_anonymous" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			ShareID string `json:"share_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ShareID != r.URL.Query().Get("share_id") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch req.ShareID {
+		case "alive":
+			w.Write([]byte(`{"share_name":"第一季","file_count":12}`))
+		case "cancelled":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"ShareLink.Cancelled","message":"share link is cancelled"}`))
+		case "expired":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"ShareLink.Expired","message":"share link is expired"}`))
+		case "missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":"NotFound.ShareLink","message":"not found"}`))
+		case "limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"code":"TooManyRequests","message":"too many requests"}`))
+		case "broken":
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`<html>bad gateway</html>`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"code":"InternalError","message":"internal error"}`))
+		}
+	}))
+	defer server.Close()
+
+	checker := NewAliyunChecker(server.URL, nil)
+	tests := []struct {
+		shareID string
+		verdict Verdict
+		detail  string
+		unknown bool // 无法判断，应返回错误
+	}{
+		{shareID: "alive", verdict: VerdictAlive, detail: "第一季"},
+		{shareID: "cancelled", verdict: VerdictDead, detail: "ShareLink.Cancelled share link is cancelled"},
+		{shareID: "expired", verdict: VerdictDead, detail: "ShareLink.Expired share link is expired"},
+		{shareID: "missing", verdict: VerdictDead, detail: "NotFound.ShareLink not found"},
+		{shareID: "limited", unknown: true},
+		{shareID: "broken", unknown: true},
+		{shareID: "other", unknown: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shareID, func(t *testing.T) {
+			verdict, detail, err := checker.Check(context.Background(), tt.shareID, "")
+			if tt.unknown {
+				if err == nil {
+					t.Fatalf("期望无法判断，得到 %s (%s)", verdict, detail)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("检查失败: %v", err)
+			}
+			if verdict != tt.verdict || detail != tt.detail {
+				t.Errorf("结论 = %s (%s)，期望 %s (%s)", verdict, detail, tt.verdict, tt.detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package linkcheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"dongman/internal/models"
+)
+
+// baiduDeadMarkers 百度网盘分享失效页面中的提示
+var baiduDeadMarkers = []string{
+	"你来晚了", "分享的文件已经被删除", "分享的文件已经被取消", "分享已过期",
+	"链接不存在", "涉及侵权", "分享内容可能因为",
+}
+
+// BaiduChecker 访问百度网盘分享页 /s/1<分享ID> 判断分享是否有效：
+// 需要提取码的分享会跳转到 /share/init，失效的分享返回带有失效提示的页面或跳转到错误页
+type BaiduChecker struct {
+	BaseURL string // 默认 https://pan.baidu.com，测试时可指向本地服务
+	Client  *http.Client
+}
+
+// NewBaiduChecker 创建百度网盘检查器，baseURL 和 client 为空时使用默认值
+func NewBaiduChecker(baseURL string, client *http.Client) *BaiduChecker {
+	if baseURL == "" {
+		baseURL = "https://pan.baidu.com"
+	}
+	if client == nil {
+		client = defaultClient()
+	}
+	return &BaiduChecker{BaseURL: strings.TrimRight(baseURL, "/"), Client: client}
+}
+
+func (c *BaiduChecker) Category() string { return models.LinkCategoryBaidu }
+
+func (c *BaiduChecker) Check(ctx context.Context, shareID, password string) (Verdict, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/s/1"+url.PathEscape(shareID), nil)
+	if err != nil {
+		return "", "", fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("User-Agent", defaultUserAgent)
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("请求百度网盘失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return VerdictDead, "分享页不存在", nil
+	case resp.StatusCode >= 300 && resp.StatusCode < 400:
+		location := resp.Header.Get("Location")
+		if strings.Contains(location, "/share/init") {
+			return VerdictAlive, "需要提取码", nil
+		}
+		if strings.Contains(location, "error") {
+			return VerdictDead, "跳转到错误页", nil
+		}
+		return "", "", fmt.Errorf("未知的跳转: %s", location)
+	case resp.StatusCode != http.StatusOK:
+		return "", "", fmt.Errorf("百度网盘返回状态码 %d", resp.StatusCode)
+	}
+
+	body, err := readBody(resp)
+	if err != nil {
+		return "", "", err
+	}
+	if marker, ok := containsAny(string(body), baiduDeadMarkers); ok {
+		return VerdictDead, marker, nil
+	}
+	return VerdictAlive, "", nil
+}
@@ -0,0 +1,63 @@
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBaiduCheckerVerdicts(t *testing.T) {
+	// 按分享ID返回不同的分享页，模拟百度网盘的各种响应
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/s/1alive":
+			w.Write([]byte("<html><title>百度网盘 请输入提取码</title>分享文件列表</html>"))
+		case "/s/1locked":
+			http.Redirect(w, r, "/share/init?surl=locked", http.StatusFound)
+		case "/s/1deleted":
+			w.Write([]byte("<html>啊哦，你来晚了，分享的文件已经被删除了</html>"))
+		case "/s/1missing":
+			http.NotFound(w, r)
+		case "/s/1errorpage":
+			http.Redirect(w, r, "/error/404.html", http.StatusFound)
+		case "/s/1captcha":
+			http.Redirect(w, r, "/wap/verify", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	checker := NewBaiduChecker(server.URL, nil)
+	tests := []struct {
+		shareID string
+		verdict Verdict
+		unknown bool // 无法判断，应返回错误
+	}{
+		{shareID: "alive", verdict: VerdictAlive},
+		{shareID: "locked", verdict: VerdictAlive},
+		{shareID: "deleted", verdict: VerdictDead},
+		{shareID: "missing", verdict: VerdictDead},
+		{shareID: "errorpage", verdict: VerdictDead},
+		{shareID: "captcha", unknown: true},
+		{shareID: "overloaded", unknown: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shareID, func(t *testing.T) {
+			verdict, detail, err := checker.Check(context.Background(), tt.shareID, "")
+			if tt.unknown {
+				if err == nil {
+					t.Fatalf("期望无法判断，得到 %s (%s)", verdict, detail)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("检查失败: %v", err)
+			}
+			if verdict != tt.verdict {
+				t.Errorf("结论 = %s (%s)，期望 %s", verdict, detail, tt.verdict)
+			}
+		})
+	}
+}
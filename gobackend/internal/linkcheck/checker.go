@@ -0,0 +1,130 @@
+// Package linkcheck 定期检查网盘分享链接是否失效
+// 每个网盘一个 Checker，访问分享页或接口判断分享是否存在；调度器按限速逐个检查到期的链接，
+// 结果保存在 link_health 表中，连续多次检查到失效后标记为 broken，出现在管理员的失效链接列表中。
+package linkcheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Verdict 检查结论
+type Verdict string
+
+// 检查结论常量，无法判断时 Checker 返回错误而不是结论
+const (
+	VerdictAlive Verdict = "alive"
+	VerdictDead  Verdict = "dead"
+)
+
+// Checker 检查一个网盘的分享链接
+type Checker interface {
+	// Category 对应的链接分类，如 baidu
+	Category() string
+	// Check 检查分享是否有效，shareID 由 utils.ParseShareLink 解析得到；
+	// 返回结论和说明（如网盘返回的提示），无法判断（网络错误、风控、未知响应）时返回错误
+	Check(ctx context.Context, shareID, password string) (Verdict, string, error)
+}
+
+// defaultUserAgent 模拟浏览器访问，部分网盘会拒绝没有UA的请求
+const defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36"
+
+// maxResponseBytes 读取分享页或接口响应的上限
+const maxResponseBytes = 2 << 20
+
+var (
+	checkersMu sync.RWMutex
+	checkers   = map[string]Checker{}
+)
+
+func init() {
+	Register(NewBaiduChecker("", nil))
+	Register(NewAliyunChecker("", nil))
+	Register(NewQuarkChecker("", nil))
+}
+
+// Register 注册或替换某个分类的检查器
+func Register(checker Checker) {
+	checkersMu.Lock()
+	defer checkersMu.Unlock()
+	checkers[checker.Category()] = checker
+}
+
+// Get 返回分类的检查器
+func Get(category string) (Checker, bool) {
+	checkersMu.RLock()
+	defer checkersMu.RUnlock()
+	checker, ok := checkers[category]
+	return checker, ok
+}
+
+// Categories 返回支持检查的分类
+func Categories() []string {
+	checkersMu.RLock()
+	defer checkersMu.RUnlock()
+	categories := make([]string, 0, len(checkers))
+	for category := range checkers {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// defaultClient 检查器未指定 http.Client 时使用，不自动跟随重定向，由检查器判断跳转目标
+func defaultClient() *http.Client {
+	return &http.Client{
+		Timeout: 20 * time.Second,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// readBody 读取响应体，超过上限的部分被丢弃
+func readBody(resp *http.Response) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	return data, nil
+}
+
+// postJSON 发送JSON请求，返回状态码和响应体
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}) (int, []byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return 0, nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", defaultUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := readBody(resp)
+	return resp.StatusCode, respBody, err
+}
+
+// containsAny 判断文本是否包含任一关键词
+func containsAny(text string, keywords []string) (string, bool) {
+	for _, keyword := range keywords {
+		if strings.Contains(text, keyword) {
+			return keyword, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,60 @@
+package linkcheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"dongman/internal/models"
+)
+
+// quarkDeadMarkers 夸克网盘分享失效时接口返回的提示
+var quarkDeadMarkers = []string{"失效", "删除", "不存在", "过期", "违规", "取消", "提取码", "密码"}
+
+// QuarkChecker 调用夸克网盘获取分享令牌的接口判断分享是否有效：
+// 有效的分享返回 code 0，失效、被删除或提取码错误时返回非0的 code 和提示
+type QuarkChecker struct {
+	BaseURL string // 默认 https://drive-h.quark.cn，测试时可指向本地服务
+	Client  *http.Client
+}
+
+// NewQuarkChecker 创建夸克网盘检查器，baseURL 和 client 为空时使用默认值
+func NewQuarkChecker(baseURL string, client *http.Client) *QuarkChecker {
+	if baseURL == "" {
+		baseURL = "https://drive-h.quark.cn"
+	}
+	if client == nil {
+		client = defaultClient()
+	}
+	return &QuarkChecker{BaseURL: strings.TrimRight(baseURL, "/"), Client: client}
+}
+
+func (c *QuarkChecker) Category() string { return models.LinkCategoryQuark }
+
+func (c *QuarkChecker) Check(ctx context.Context, shareID, password string) (Verdict, string, error) {
+	endpoint := c.BaseURL + "/1/clouddrive/share/sharepage/token?pr=ucpro&fr=pc"
+	status, body, err := postJSON(ctx, c.Client, endpoint, map[string]string{"pwd_id": shareID, "passcode": password})
+	if err != nil {
+		return "", "", fmt.Errorf("请求夸克网盘失败: %w", err)
+	}
+
+	var resp struct {
+		Status  int    `json:"status"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", "", fmt.Errorf("夸克网盘返回无法解析的响应 (%d)", status)
+	}
+
+	if status == http.StatusOK && resp.Code == 0 {
+		return VerdictAlive, "", nil
+	}
+	// 提取码错误时分享本身存在，但保存的提取码已无法使用，同样视为失效
+	if _, ok := containsAny(resp.Message, quarkDeadMarkers); ok && status < 500 {
+		return VerdictDead, fmt.Sprintf("%d %s", resp.Code, resp.Message), nil
+	}
+	return "", "", fmt.Errorf("夸克网盘返回 %d: %d %s", status, resp.Code, resp.Message)
+}
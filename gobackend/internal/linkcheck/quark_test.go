@@ -0,0 +1,88 @@
+package linkcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQuarkCheckerVerdicts(t *testing.T) {
+	// 按 pwd_id 返回不同的接口响应，模拟夸克网盘获取分享令牌的接口
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/1/clouddrive/share/sharepage/token" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			PwdID    string `json:"pwd_id"`
+			Passcode string `json:"passcode"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch req.PwdID {
+		case "alive":
+			w.Write([]byte(`{"status":200,"code":0,"message":"ok","data":{"stoken":"x"}}`))
+		case "expired":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":400,"code":41004,"message":"分享地址已失效"}`))
+		case "locked":
+			if req.Passcode == "abcd" {
+				w.Write([]byte(`{"status":200,"code":0,"message":"ok"}`))
+				return
+			}
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":400,"code":41008,"message":"提取码错误"}`))
+		case "limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"status":429,"code":32003,"message":"请求过于频繁"}`))
+		case "broken":
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`<html>bad gateway</html>`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"status":500,"code":50000,"message":"分享不存在"}`))
+		}
+	}))
+	defer server.Close()
+
+	checker := NewQuarkChecker(server.URL, nil)
+	tests := []struct {
+		name     string
+		shareID  string
+		password string
+		verdict  Verdict
+		unknown  bool // 无法判断，应返回错误
+	}{
+		{name: "alive", shareID: "alive", verdict: VerdictAlive},
+		{name: "expired", shareID: "expired", verdict: VerdictDead},
+		{name: "correct passcode", shareID: "locked", password: "abcd", verdict: VerdictAlive},
+		{name: "wrong passcode", shareID: "locked", password: "zzzz", verdict: VerdictDead},
+		{name: "rate limited", shareID: "limited", unknown: true},
+		{name: "not json", shareID: "broken", unknown: true},
+		// 服务端错误时即使提示像失效也不能下结论
+		{name: "server error", shareID: "other", unknown: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, detail, err := checker.Check(context.Background(), tt.shareID, tt.password)
+			if tt.unknown {
+				if err == nil {
+					t.Fatalf("期望无法判断，得到 %s (%s)", verdict, detail)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("检查失败: %v", err)
+			}
+			if verdict != tt.verdict {
+				t.Errorf("结论 = %s (%s)，期望 %s", verdict, detail, tt.verdict)
+			}
+		})
+	}
+}
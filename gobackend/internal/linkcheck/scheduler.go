@@ -0,0 +1,194 @@
+package linkcheck
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"dongman/internal/config"
+	"dongman/internal/models"
+	"dongman/internal/utils"
+)
+
+const (
+	// BrokenThreshold 连续检查到失效多少次后标记为 broken，避免网盘偶发错误误判
+	BrokenThreshold = 2
+
+	suspectRetryDelay = time.Hour        // 首次检查到失效后的复查间隔
+	errorRetryDelay   = time.Hour        // 无法判断时的重试间隔
+	syncInterval      = 10 * time.Minute // 从资源同步检查列表的间隔
+	checkBatchSize    = 20
+	checkTimeout      = 30 * time.Second
+)
+
+var (
+	startOnce sync.Once
+	wake      = make(chan struct{}, 1)
+)
+
+// Start 启动链接检查调度，LINK_CHECK_ENABLED=false 时不启动
+func Start() {
+	if !config.LinkCheckEnabled {
+		log.Printf("链接有效性检查已关闭")
+		return
+	}
+	startOnce.Do(func() {
+		go run()
+		log.Printf("链接有效性检查已启动，支持: %v，每分钟最多检查 %d 个链接", Categories(), config.LinkCheckRate)
+	})
+}
+
+// Wake 通知调度器立即检查到期的链接
+func Wake() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// run 调度循环：定期同步检查列表，按限速逐个检查到期的链接，没有到期的链接时等到下一个计划时间
+func run() {
+	pace := time.Minute / time.Duration(config.LinkCheckRate)
+	var lastSync time.Time
+	for {
+		if time.Since(lastSync) >= syncInterval {
+			if err := SyncAll(); err != nil {
+				log.Printf("同步链接检查列表失败: %v", err)
+			}
+			lastSync = time.Now()
+		}
+
+		due, err := models.DueLinkHealth(time.Now(), checkBatchSize)
+		if err != nil {
+			log.Printf("%v", err)
+		}
+		for _, h := range due {
+			checkLink(h)
+			time.Sleep(pace)
+		}
+		if len(due) == checkBatchSize {
+			continue
+		}
+
+		wait := syncInterval - time.Since(lastSync)
+		if next, err := models.NextLinkCheckAt(); err == nil && next != nil {
+			if d := time.Until(*next); d < wait {
+				wait = d
+			}
+		}
+		if wait < pace {
+			wait = pace
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// SyncAll 按已审批资源当前的链接更新检查列表，并删除其他资源的检查记录
+func SyncAll() error {
+	var resources []struct {
+		ID    int            `db:"id"`
+		Links models.JsonMap `db:"links"`
+	}
+	if err := models.DB.Select(&resources, `SELECT id, links FROM resources WHERE status = ?`, models.ResourceStatusApproved); err != nil {
+		return err
+	}
+	for _, r := range resources {
+		if err := models.SyncResourceLinkHealth(r.ID, entriesFor(r.Links)); err != nil {
+			log.Printf("同步资源 %d 的链接检查列表失败: %v", r.ID, err)
+		}
+	}
+	if n, err := models.PruneLinkHealth(); err != nil {
+		return err
+	} else if n > 0 {
+		log.Printf("已删除 %d 条不再需要的链接检查记录", n)
+	}
+	return nil
+}
+
+// SyncResource 按资源当前的链接更新检查列表，links 为空时删除资源的全部检查记录。
+// 审批、编辑、撤销、删除资源和处理举报后调用，避免已移除的链接继续出现在失效列表中、新链接要等到下次 SyncAll 才检查
+func SyncResource(resourceID int, links models.JsonMap) error {
+	return models.SyncResourceLinkHealth(resourceID, entriesFor(links))
+}
+
+// entriesFor 选出资源中有检查器支持的分享链接，分类按域名判断，同一分享只保留一次
+func entriesFor(links models.JsonMap) []models.LinkHealthEntry {
+	var entries []models.LinkHealthEntry
+	seen := make(map[string]bool)
+	for _, items := range models.ParseLinkMap(links) {
+		for _, link := range items {
+			category, _, ok := utils.ParseShareLink(link.URL)
+			if !ok {
+				continue
+			}
+			if _, supported := Get(category); !supported {
+				continue
+			}
+			key := utils.LinkKey(link.URL)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			entries = append(entries, models.LinkHealthEntry{Category: category, LinkKey: key, URL: link.URL, Password: link.Password})
+		}
+	}
+	return entries
+}
+
+// checkLink 检查一个链接并保存结果
+func checkLink(h models.LinkHealth) {
+	now := time.Now().UTC()
+	h.LastCheckedAt = &now
+
+	checker, ok := Get(h.Category)
+	_, shareID, okID := utils.ParseShareLink(h.URL)
+	if !ok || !okID {
+		msg := "不支持检查该链接"
+		h.Status = models.LinkHealthUnknown
+		h.LastError = &msg
+		h.NextCheckAt = now.Add(config.LinkCheckInterval)
+		if err := models.UpdateLinkHealth(&h); err != nil {
+			log.Printf("%v", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	verdict, detail, err := checker.Check(ctx, shareID, h.Password)
+	cancel()
+
+	switch {
+	case err != nil:
+		// 无法判断时保持原状态，稍后重试
+		msg := err.Error()
+		h.LastError = &msg
+		h.NextCheckAt = now.Add(errorRetryDelay)
+	case verdict == VerdictAlive:
+		h.Status = models.LinkHealthOK
+		h.FailureCount = 0
+		h.LastError = nil
+		h.NextCheckAt = now.Add(config.LinkCheckInterval)
+	default:
+		h.FailureCount++
+		h.LastError = &detail
+		if h.FailureCount >= BrokenThreshold {
+			if h.Status != models.LinkHealthBroken {
+				log.Printf("资源 %d 的链接已失效: %s (%s)", h.ResourceID, h.URL, detail)
+			}
+			h.Status = models.LinkHealthBroken
+			h.NextCheckAt = now.Add(config.LinkCheckInterval)
+		} else {
+			h.Status = models.LinkHealthSuspect
+			h.NextCheckAt = now.Add(suspectRetryDelay)
+		}
+	}
+	if err := models.UpdateLinkHealth(&h); err != nil {
+		log.Printf("%v", err)
+	}
+}
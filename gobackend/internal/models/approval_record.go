@@ -0,0 +1,58 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// InsertApprovalRecord 在事务中插入审批记录
+func InsertApprovalRecord(tx *sqlx.Tx, r *ApprovalRecord) (int64, error) {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now()
+	}
+	result, err := tx.Exec(
+		`INSERT INTO approval_records (
+			resource_id, status, field_approvals, field_rejections,
+			approved_images, rejected_images, poster_image, notes,
+			approved_links, rejected_links, is_supplement_approval, request_hash, rejection_reasons,
+			snapshot, reverts_record_id, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ResourceID, r.Status, r.FieldApprovals, r.FieldRejections,
+		r.ApprovedImages, r.RejectedImages, r.PosterImage, r.Notes,
+		r.ApprovedLinks, r.RejectedLinks, r.IsSupplementApproval, r.RequestHash, r.RejectionReasons,
+		r.Snapshot, r.RevertsRecordID, r.CreatedAt,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("创建审批记录失败: %w", err)
+	}
+	id, _ := result.LastInsertId()
+	r.ID = int(id)
+	return id, nil
+}
+
+// FindRecentApprovalRecord 查找 since 之后相同审批请求写入且未被撤销的审批记录，没有时返回nil
+func FindRecentApprovalRecord(resourceID int, requestHash string, since time.Time) (*ApprovalRecord, error) {
+	var record ApprovalRecord
+	err := DB.Get(&record,
+		`SELECT * FROM approval_records WHERE resource_id = ? AND request_hash = ? AND created_at >= ? AND reverted_at IS NULL ORDER BY id DESC LIMIT 1`,
+		resourceID, requestHash, since)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询审批记录失败: %w", err)
+	}
+	return &record, nil
+}
+
+// SetApprovalSnapshot 在审批事务中保存审批记录的修改前后快照
+func SetApprovalSnapshot(tx *sqlx.Tx, recordID int64, snapshot *ApprovalSnapshot) error {
+	if _, err := tx.Exec(`UPDATE approval_records SET snapshot = ? WHERE id = ?`, snapshot, recordID); err != nil {
+		return fmt.Errorf("保存审批记录 %d 的快照失败: %w", recordID, err)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// ResourceState 审批可能修改的资源字段
+type ResourceState struct {
+	Status               ResourceStatus `json:"status"`
+	Title                string         `json:"title"`
+	TitleEn              string         `json:"title_en"`
+	Description          string         `json:"description"`
+	Images               JsonList       `json:"images"`
+	PosterImage          *string        `json:"poster_image"`
+	Links                JsonMap        `json:"links"`
+	NeedsLinkReplacement bool           `json:"needs_link_replacement"`
+}
+
+// CaptureResourceState 复制资源当前的审批相关字段，链接为深拷贝，之后修改资源不影响返回值
+func CaptureResourceState(r *Resource) ResourceState {
+	state := ResourceState{
+		Status:               r.Status,
+		Title:                r.Title,
+		TitleEn:              r.TitleEn,
+		Description:          r.Description,
+		Images:               append(JsonList(nil), r.Images...),
+		NeedsLinkReplacement: r.NeedsLinkReplacement,
+	}
+	if r.PosterImage != nil {
+		poster := *r.PosterImage
+		state.PosterImage = &poster
+	}
+	if r.Links != nil {
+		data, _ := json.Marshal(r.Links)
+		json.Unmarshal(data, &state.Links)
+	}
+	return state
+}
+
+// Apply 把状态写回资源
+func (s ResourceState) Apply(r *Resource) {
+	r.Status = s.Status
+	r.Title = s.Title
+	r.TitleEn = s.TitleEn
+	r.Description = s.Description
+	r.Images = s.Images
+	r.PosterImage = s.PosterImage
+	r.Links = s.Links
+	r.NeedsLinkReplacement = s.NeedsLinkReplacement
+}
+
+// ApprovalSnapshot 审批记录中保存的修改前后的资源字段，只包含被修改的字段，用于撤销审批
+type ApprovalSnapshot struct {
+	Before  JsonMap           `json:"before"`
+	After   JsonMap           `json:"after"`
+	Renamed map[string]string `json:"renamed,omitempty"` // 审批时移入资源目录的图片：原路径 -> 新路径
+}
+
+// NewApprovalSnapshot 比较审批前后的资源字段生成快照
+// 审批前的图片路径按 renamed 换成移动后的路径，文件已经移动，撤销时不会恢复到原位置
+func NewApprovalSnapshot(before, after ResourceState, renamed map[string]string) *ApprovalSnapshot {
+	if len(renamed) > 0 {
+		images := make(JsonList, len(before.Images))
+		for i, img := range before.Images {
+			if newPath, ok := renamed[img]; ok {
+				img = newPath
+			}
+			images[i] = img
+		}
+		before.Images = images
+		if before.PosterImage != nil {
+			if newPath, ok := renamed[*before.PosterImage]; ok {
+				before.PosterImage = &newPath
+			}
+		}
+	}
+
+	beforeFields, afterFields := before.fields(), after.fields()
+	snapshot := &ApprovalSnapshot{Before: JsonMap{}, After: JsonMap{}, Renamed: renamed}
+	for key, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[key]) {
+			snapshot.Before[key] = value
+			snapshot.After[key] = afterFields[key]
+		}
+	}
+	return snapshot
+}
+
+// fields 转换为 字段名 -> JSON解码后的值，便于逐字段比较
+func (s ResourceState) fields() JsonMap {
+	fields := JsonMap{}
+	data, _ := json.Marshal(s)
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// Value 实现database/sql/driver.Valuer接口
+func (s *ApprovalSnapshot) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	bytes, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(bytes), nil
+}
+
+// Scan 实现sql.Scanner接口
+func (s *ApprovalSnapshot) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.New("无法将值扫描为ApprovalSnapshot：不支持的类型")
+	}
+	return json.Unmarshal(b, s)
+}
+
+// Revert 撤销快照中的修改，返回撤销后的状态和无法撤销的字段
+// 图片和链接按增删撤销：去掉审批新增的、加回审批移除的，之后的其他修改保持不变；链接按 linkKey 判断是否相同。
+// 其他字段只有当前值仍等于审批后的值时才恢复，否则列入无法撤销的字段
+func (s *ApprovalSnapshot) Revert(current ResourceState, linkKey func(url string) string) (ResourceState, []string) {
+	fields := current.fields()
+	var conflicts []string
+	for key, before := range s.Before {
+		after := s.After[key]
+		switch key {
+		case "images":
+			fields[key] = revertList(fields[key], before, after, func(item interface{}) string {
+				path, _ := item.(string)
+				return path
+			})
+		case "links":
+			fields[key] = revertLinks(fields[key], before, after, linkKey)
+		default:
+			if !reflect.DeepEqual(fields[key], after) {
+				conflicts = append(conflicts, key)
+				continue
+			}
+			fields[key] = before
+		}
+	}
+
+	var restored ResourceState
+	data, _ := json.Marshal(fields)
+	json.Unmarshal(data, &restored)
+	return restored, conflicts
+}
+
+// revertLinks 按分类撤销链接的增删
+func revertLinks(current, before, after interface{}, linkKey func(url string) string) interface{} {
+	currentMap, _ := current.(map[string]interface{})
+	beforeMap, _ := before.(map[string]interface{})
+	afterMap, _ := after.(map[string]interface{})
+	key := func(item interface{}) string {
+		link, err := LinkFromValue(item)
+		if err != nil {
+			data, _ := json.Marshal(item)
+			return string(data)
+		}
+		return linkKey(link.URL)
+	}
+
+	result := map[string]interface{}{}
+	for category, links := range currentMap {
+		result[category] = links
+	}
+	categories := map[string]bool{}
+	for _, m := range []map[string]interface{}{currentMap, beforeMap, afterMap} {
+		for category := range m {
+			categories[category] = true
+		}
+	}
+	for category := range categories {
+		links := revertList(currentMap[category], beforeMap[category], afterMap[category], key)
+		if _, inBefore := beforeMap[category]; len(links) == 0 && !inBefore {
+			// 审批新增的分类撤销后为空时去掉该分类
+			delete(result, category)
+			continue
+		}
+		result[category] = links
+	}
+	return result
+}
+
+// revertList 从当前列表中去掉 before 到 after 新增的条目，并加回被移除的条目
+func revertList(current, before, after interface{}, key func(interface{}) string) []interface{} {
+	currentItems, _ := current.([]interface{})
+	beforeItems, _ := before.([]interface{})
+	afterItems, _ := after.([]interface{})
+
+	inBefore := make(map[string]bool, len(beforeItems))
+	for _, item := range beforeItems {
+		inBefore[key(item)] = true
+	}
+	inAfter := make(map[string]bool, len(afterItems))
+	for _, item := range afterItems {
+		inAfter[key(item)] = true
+	}
+
+	result := []interface{}{}
+	present := map[string]bool{}
+	for _, item := range currentItems {
+		k := key(item)
+		if inAfter[k] && !inBefore[k] {
+			continue
+		}
+		result = append(result, item)
+		present[k] = true
+	}
+	for _, item := range beforeItems {
+		k := key(item)
+		if !inAfter[k] && !present[k] {
+			result = append(result, item)
+			present[k] = true
+		}
+	}
+	return result
+}
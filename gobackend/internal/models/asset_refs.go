@@ -0,0 +1,227 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+
+	"dongman/internal/storage"
+)
+
+// assetRefPattern 匹配文本中的 /assets/... 路径，可以出现在JSON字符串、Markdown链接或完整URL中
+var assetRefPattern = regexp.MustCompile(`/assets/[^\s"'()<>\[\]{},\\?#]+`)
+
+// assetRefColumns 可能引用 /assets/... 图片的数据库列
+// index 为 true 的是以图片路径为主键的索引表，改写路径时一并更新，但不算作内容引用
+var assetRefColumns = []struct {
+	table  string
+	column string
+	index  bool
+}{
+	{"resources", "images", false},
+	{"resources", "poster_image", false},
+	{"resources", "stickers", false},
+	{"resources", "supplement", false},
+	{"resources", "approval_history", false},
+	{"resources", "image_variants", false},
+	{"supplements", "images", false},
+	{"approval_records", "approved_images", false},
+	{"approval_records", "rejected_images", false},
+	{"approval_records", "poster_image", false},
+	{"approval_records", "snapshot", false},
+	{"site_settings", "setting_value", false},
+	{"image_hashes", "image_path", true},
+	{"image_variants", "image_path", true},
+}
+
+// assetRefExtractors 按列的结构取出引用的列，其他列取文本中所有的 /assets/... 路径
+var assetRefExtractors = map[string]func(value string) []string{
+	"approval_records.snapshot": approvalSnapshotAssetRefs,
+}
+
+// approvalSnapshotAssetRefs 取出审批快照引用的图片：修改前后的字段和移动后的路径
+// Renamed 的原路径在审批时已经移走，不算作引用；改写时按文本替换，原路径不会再匹配到映射
+func approvalSnapshotAssetRefs(value string) []string {
+	var snapshot ApprovalSnapshot
+	if err := snapshot.Scan(value); err != nil {
+		return assetRefPattern.FindAllString(value, -1)
+	}
+	var refs []string
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		switch v := v.(type) {
+		case string:
+			refs = append(refs, assetRefPattern.FindAllString(v, -1)...)
+		case []interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+		case map[string]interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+		}
+	}
+	collect(map[string]interface{}(snapshot.Before))
+	collect(map[string]interface{}(snapshot.After))
+	for _, newPath := range snapshot.Renamed {
+		collect(newPath)
+	}
+	return refs
+}
+
+// AssetRef 一处对 /assets/... 路径的引用
+type AssetRef struct {
+	Source string `json:"source"` // 引用位置，如 resources.images#12 或 posts/hello.md
+	Path   string `json:"path"`
+}
+
+// AssetRewriteResult 路径改写的统计
+type AssetRewriteResult struct {
+	Rows  map[string]int `json:"rows"`  // 表.列 -> 改写的行数
+	Posts []string       `json:"posts"` // 改写的文章，如 posts/hello.md
+}
+
+// ReplaceAssetRefs 将文本中的 /assets/... 路径按映射替换，只替换完整匹配的路径，返回替换后的文本和替换次数
+// 如映射 a.jpg -> a.webp 时 a.jpg.webp 不受影响
+func ReplaceAssetRefs(text string, mapping map[string]string) (string, int) {
+	replaced := 0
+	result := assetRefPattern.ReplaceAllStringFunc(text, func(ref string) string {
+		if newPath, ok := mapping[ref]; ok {
+			replaced++
+			return newPath
+		}
+		return ref
+	})
+	return result, replaced
+}
+
+// RewriteAssetRefs 在一个事务中将数据库和存储后端 posts/ 下文章中对旧路径的引用改写为新路径
+// 文章的新内容先在内存中准备好，数据库提交成功后再写回存储后端，之前任何一步失败都会回滚数据库。
+// dryRun 为 true 时只统计将要改写的位置，最后回滚事务
+func RewriteAssetRefs(mapping map[string]string, backend storage.Backend, dryRun bool) (*AssetRewriteResult, error) {
+	result := &AssetRewriteResult{Rows: make(map[string]int)}
+	if len(mapping) == 0 {
+		return result, nil
+	}
+
+	tx, err := DB.Beginx()
+	if err != nil {
+		return nil, fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, c := range assetRefColumns {
+		rows, err := selectAssetRefRows(tx, c.table, c.column)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			newValue, n := ReplaceAssetRefs(row.Value, mapping)
+			if n == 0 {
+				continue
+			}
+			// 路径作为主键时目标路径可能已有记录，以改写后的记录为准
+			update := fmt.Sprintf(`UPDATE OR REPLACE %s SET %s = ? WHERE rowid = ?`, c.table, c.column)
+			if _, err := tx.Exec(update, newValue, row.RowID); err != nil {
+				return nil, fmt.Errorf("改写 %s.%s#%d 失败: %w", c.table, c.column, row.RowID, err)
+			}
+			result.Rows[c.table+"."+c.column]++
+		}
+	}
+
+	// 准备文章的改写内容
+	type pendingPost struct {
+		key     string
+		content string
+	}
+	var pending []pendingPost
+	posts, err := listPostObjects(backend)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		key := postsPrefix + post.Name
+		content, err := readPostObject(backend, post.Name)
+		if err != nil {
+			return nil, fmt.Errorf("读取文章 %s 失败: %w", key, err)
+		}
+		newContent, n := ReplaceAssetRefs(string(content), mapping)
+		if n == 0 {
+			continue
+		}
+		result.Posts = append(result.Posts, key)
+		pending = append(pending, pendingPost{key: key, content: newContent})
+	}
+
+	if dryRun {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
+	for _, p := range pending {
+		if err := backend.Put(p.key, strings.NewReader(p.content), int64(len(p.content)), "text/markdown; charset=utf-8"); err != nil {
+			return result, fmt.Errorf("写回文章 %s 失败，数据库已更新: %w", p.key, err)
+		}
+	}
+	return result, nil
+}
+
+// assetRefRow 包含 /assets/ 的一行数据
+type assetRefRow struct {
+	RowID int64  `db:"row_id"`
+	Value string `db:"value"`
+}
+
+// selectAssetRefRows 查询指定列中包含 /assets/ 的行
+func selectAssetRefRows(q sqlx.Queryer, table, column string) ([]assetRefRow, error) {
+	var rows []assetRefRow
+	query := fmt.Sprintf(`SELECT rowid AS row_id, CAST(%s AS TEXT) AS value FROM %s WHERE %s LIKE '%%/assets/%%'`, column, table, column)
+	if err := sqlx.Select(q, &rows, query); err != nil {
+		return nil, fmt.Errorf("查询 %s.%s 失败: %w", table, column, err)
+	}
+	return rows, nil
+}
+
+// ListAssetRefs 列出数据库和存储后端 posts/ 下文章中所有对 /assets/... 路径的引用，不包括感知哈希等索引表
+func ListAssetRefs(backend storage.Backend) ([]AssetRef, error) {
+	var refs []AssetRef
+	for _, c := range assetRefColumns {
+		if c.index {
+			continue
+		}
+		rows, err := selectAssetRefRows(DB, c.table, c.column)
+		if err != nil {
+			return nil, err
+		}
+		extract, ok := assetRefExtractors[c.table+"."+c.column]
+		if !ok {
+			extract = func(value string) []string { return assetRefPattern.FindAllString(value, -1) }
+		}
+		for _, row := range rows {
+			source := fmt.Sprintf("%s.%s#%d", c.table, c.column, row.RowID)
+			for _, p := range extract(row.Value) {
+				refs = append(refs, AssetRef{Source: source, Path: p})
+			}
+		}
+	}
+
+	posts, err := listPostObjects(backend)
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		source := postsPrefix + post.Name
+		content, err := readPostObject(backend, post.Name)
+		if err != nil {
+			return nil, fmt.Errorf("读取文章 %s 失败: %w", source, err)
+		}
+		for _, p := range assetRefPattern.FindAllString(string(content), -1) {
+			refs = append(refs, AssetRef{Source: source, Path: p})
+		}
+	}
+	return refs, nil
+}
@@ -39,6 +39,8 @@ CREATE TABLE IF NOT EXISTS resources (
 	tmdb_id INTEGER,
 	stickers TEXT DEFAULT '{}' NOT NULL,
 	media_type VARCHAR,
+	image_variants JSON,
+	needs_link_replacement BOOLEAN NOT NULL DEFAULT 0,
 	PRIMARY KEY (id)
 );
 
@@ -61,6 +63,12 @@ CREATE TABLE IF NOT EXISTS approval_records (
 	approved_links JSON,
 	rejected_links JSON,
 	is_supplement_approval BOOLEAN DEFAULT 'False',
+	request_hash TEXT NOT NULL DEFAULT '',
+	rejection_reasons JSON NOT NULL DEFAULT '[]',
+	snapshot JSON,
+	reverts_record_id INTEGER,
+	reverted_by_record_id INTEGER,
+	reverted_at DATETIME,
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	FOREIGN KEY (resource_id) REFERENCES resources(id) ON DELETE CASCADE
 );
@@ -86,8 +94,144 @@ CREATE TABLE IF NOT EXISTS site_settings (
 );
 
 CREATE INDEX IF NOT EXISTS idx_site_settings_key ON site_settings(setting_key);
+
+CREATE TABLE IF NOT EXISTS image_hashes (
+    image_path TEXT PRIMARY KEY,
+    hash TEXT NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS image_variants (
+    image_path TEXT PRIMARY KEY,
+    variant JSON NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS torrent_meta (
+    link_key TEXT PRIMARY KEY,
+    meta JSON NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_type TEXT NOT NULL,
+    payload JSON,
+    status VARCHAR(16) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    last_error TEXT,
+    run_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_status_run_at ON jobs(status, run_at);
+
+CREATE TABLE IF NOT EXISTS link_health (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    resource_id INTEGER NOT NULL,
+    category VARCHAR(16) NOT NULL,
+    link_key TEXT NOT NULL,
+    url TEXT NOT NULL,
+    password TEXT NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL,
+    failure_count INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    last_checked_at DATETIME,
+    next_check_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE (resource_id, link_key),
+    FOREIGN KEY (resource_id) REFERENCES resources(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_health_next_check_at ON link_health(next_check_at);
+CREATE INDEX IF NOT EXISTS idx_link_health_status ON link_health(status);
+
+CREATE TABLE IF NOT EXISTS link_reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    resource_id INTEGER NOT NULL,
+    category VARCHAR(16) NOT NULL,
+    link_key TEXT NOT NULL,
+    url TEXT NOT NULL,
+    reason VARCHAR(16) NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    reporter TEXT NOT NULL,
+    client_key TEXT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    approval_record_id INTEGER,
+    resolved_by TEXT,
+    resolved_at DATETIME,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (resource_id) REFERENCES resources(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_reports_status ON link_reports(status, resource_id, link_key);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_link_reports_open_client ON link_reports(resource_id, link_key, client_key) WHERE status = 'open';
+
+CREATE TABLE IF NOT EXISTS supplements (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    resource_id INTEGER NOT NULL,
+    contributor_id INTEGER,
+    contributor_token TEXT,
+    images JSON,
+    links JSON,
+    status VARCHAR(8) NOT NULL,
+    notes TEXT NOT NULL DEFAULT '',
+    approval_record_id INTEGER,
+    reviewed_by TEXT,
+    reviewed_at DATETIME,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    FOREIGN KEY (resource_id) REFERENCES resources(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_supplements_resource_status ON supplements(resource_id, status);
+CREATE INDEX IF NOT EXISTS idx_supplements_status ON supplements(status, created_at);
+
+CREATE TABLE IF NOT EXISTS rejection_reasons (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL UNIQUE,
+    label TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    sort_order INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS submissions (
+    token TEXT PRIMARY KEY,
+    resource_id INTEGER NOT NULL,
+    supplement_id INTEGER,
+    status VARCHAR(8) NOT NULL,
+    rejection_reasons JSON NOT NULL DEFAULT '[]',
+    reviewed_at DATETIME,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_submissions_resource ON submissions(resource_id, supplement_id);
 `
 
+// 旧数据库升级时需要补充的列
+var migrateColumns = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"resources", "image_variants", "JSON"},
+	{"resources", "needs_link_replacement", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"approval_records", "request_hash", "TEXT NOT NULL DEFAULT ''"},
+	{"approval_records", "rejection_reasons", "JSON NOT NULL DEFAULT '[]'"},
+	{"approval_records", "snapshot", "JSON"},
+	{"approval_records", "reverts_record_id", "INTEGER"},
+	{"approval_records", "reverted_by_record_id", "INTEGER"},
+	{"approval_records", "reverted_at", "DATETIME"},
+}
+
 // InitDB 初始化数据库连接
 func InitDB() (*sqlx.DB, error) {
 	// 从utils包获取数据库路径
@@ -117,6 +261,21 @@ func InitDB() (*sqlx.DB, error) {
 		return nil, fmt.Errorf("创建数据库表失败: %w", err)
 	}
 
+	// 为旧数据库补充新增的列
+	if err := migrateSchema(db); err != nil {
+		return nil, err
+	}
+
+	// 把旧的 resources.supplement 列迁移到 supplements 表
+	if err := migrateLegacySupplements(db); err != nil {
+		return nil, err
+	}
+
+	// 新数据库写入预置的驳回原因
+	if err := seedRejectionReasons(db); err != nil {
+		return nil, err
+	}
+
 	// 设置自定义类型映射
 	db.MapperFunc(func(s string) string { return s })
 
@@ -131,6 +290,25 @@ func InitDB() (*sqlx.DB, error) {
 
 
 
+// migrateSchema 为已存在的表补充缺失的列
+func migrateSchema(db *sqlx.DB) error {
+	for _, m := range migrateColumns {
+		var count int
+		err := db.Get(&count, `SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, m.table, m.column)
+		if err != nil {
+			return fmt.Errorf("检查表结构失败: %w", err)
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, m.table, m.column, m.definition)); err != nil {
+			return fmt.Errorf("添加列 %s.%s 失败: %w", m.table, m.column, err)
+		}
+		log.Printf("已为表 %s 添加列 %s", m.table, m.column)
+	}
+	return nil
+}
+
 // GetDB 获取数据库连接
 func GetDB() *sqlx.DB {
 	return DB
@@ -449,11 +627,11 @@ func UpdateResourceWithStickers(resource *Resource) error {
 		`UPDATE resources SET 
 			title = ?, title_en = ?, description = ?, resource_type = ?,
 			images = ?, poster_image = ?, links = ?, updated_at = ?, 
-			tmdb_id = ?, media_type = ?, stickers = ?
+			tmdb_id = ?, media_type = ?, stickers = ?, needs_link_replacement = ?
 		WHERE id = ?`,
 		resource.Title, resource.TitleEn, resource.Description, resource.ResourceType,
 		resource.Images, resource.PosterImage, resource.Links, resource.UpdatedAt, 
-		resource.TmdbID, resource.MediaType, resource.Stickers, resource.ID,
+		resource.TmdbID, resource.MediaType, resource.Stickers, resource.NeedsLinkReplacement, resource.ID,
 	)
 
 	if err != nil {
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ImageHash 图片感知哈希记录
+type ImageHash struct {
+	ImagePath string    `db:"image_path" json:"image_path"`
+	Hash      string    `db:"hash" json:"hash"` // 64位dHash的十六进制表示
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// ImageDuplicate 近似重复图片
+type ImageDuplicate struct {
+	Image         string `json:"image"`          // 待审批的图片
+	Match         string `json:"match"`          // 与之近似的已有图片
+	Distance      int    `json:"distance"`       // 汉明距离，0 表示感知上完全相同
+	ResourceID    int    `json:"resource_id"`    // 已有图片所属资源
+	ResourceTitle string `json:"resource_title"` // 已有图片所属资源标题
+	SameResource  bool   `json:"same_resource"`  // 是否属于同一资源
+}
+
+// SaveImageHash 保存图片的感知哈希
+func SaveImageHash(imagePath, hash string) error {
+	if DB == nil {
+		return errors.New("数据库未初始化")
+	}
+	_, err := DB.Exec(
+		`INSERT INTO image_hashes (image_path, hash, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(image_path) DO UPDATE SET hash = excluded.hash, updated_at = excluded.updated_at`,
+		imagePath, hash, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("保存图片哈希失败: %w", err)
+	}
+	return nil
+}
+
+// RenameImageHash 图片移动后更新哈希记录的路径
+func RenameImageHash(oldPath, newPath string) error {
+	if DB == nil {
+		return nil
+	}
+	return renameImageHash(DB, oldPath, newPath)
+}
+
+// RenameImageHashTx 在事务中更新哈希记录的路径，用于图片移动与数据库修改一起提交的场景
+func RenameImageHashTx(tx *sqlx.Tx, oldPath, newPath string) error {
+	return renameImageHash(tx, oldPath, newPath)
+}
+
+func renameImageHash(e sqlx.Execer, oldPath, newPath string) error {
+	if oldPath == newPath {
+		return nil
+	}
+	// 目标路径已有记录时以源记录为准
+	if _, err := e.Exec(`DELETE FROM image_hashes WHERE image_path = ? AND EXISTS (SELECT 1 FROM image_hashes WHERE image_path = ?)`, newPath, oldPath); err != nil {
+		return fmt.Errorf("更新图片哈希失败: %w", err)
+	}
+	if _, err := e.Exec(`UPDATE image_hashes SET image_path = ? WHERE image_path = ?`, newPath, oldPath); err != nil {
+		return fmt.Errorf("更新图片哈希失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteImageHash 删除图片的感知哈希记录
+func DeleteImageHash(imagePath string) error {
+	if DB == nil {
+		return nil
+	}
+	if _, err := DB.Exec(`DELETE FROM image_hashes WHERE image_path = ?`, imagePath); err != nil {
+		return fmt.Errorf("删除图片哈希失败: %w", err)
+	}
+	return nil
+}
+
+// GetImageHashes 批量获取图片的感知哈希，返回 路径->哈希
+func GetImageHashes(imagePaths []string) (map[string]string, error) {
+	result := make(map[string]string)
+	if len(imagePaths) == 0 {
+		return result, nil
+	}
+
+	query, args, err := sqlx.In(`SELECT * FROM image_hashes WHERE image_path IN (?)`, imagePaths)
+	if err != nil {
+		return nil, fmt.Errorf("构造查询失败: %w", err)
+	}
+	var hashes []ImageHash
+	if err := DB.Select(&hashes, DB.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("查询图片哈希失败: %w", err)
+	}
+	for _, h := range hashes {
+		result[h.ImagePath] = h.Hash
+	}
+	return result, nil
+}
+
+// GetAllImageHashes 获取全部图片的感知哈希
+func GetAllImageHashes() ([]ImageHash, error) {
+	var hashes []ImageHash
+	if err := DB.Select(&hashes, `SELECT * FROM image_hashes`); err != nil {
+		return nil, fmt.Errorf("查询图片哈希失败: %w", err)
+	}
+	return hashes, nil
+}
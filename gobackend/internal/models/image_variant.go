@@ -0,0 +1,155 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// ImageVariant 单张图片的响应式尺寸和占位图信息
+type ImageVariant struct {
+	Width    int            `json:"width"`    // 原图宽度
+	Height   int            `json:"height"`   // 原图高度
+	BlurHash string         `json:"blurhash"` // 加载前显示的模糊占位图
+	Sources  map[int]string `json:"sources"`  // 宽度 -> 图片路径，可直接用于srcset
+}
+
+// ImageVariantMap 图片路径 -> 响应式尺寸信息
+type ImageVariantMap map[string]ImageVariant
+
+// Value 实现database/sql/driver.Valuer接口
+func (m ImageVariantMap) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	bytes, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(bytes), nil
+}
+
+// Scan 实现sql.Scanner接口
+func (m *ImageVariantMap) Scan(value interface{}) error {
+	if value == nil {
+		*m = nil
+		return nil
+	}
+
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.New("无法将值扫描为ImageVariantMap：不支持的类型")
+	}
+
+	if len(b) == 0 || string(b) == "null" {
+		*m = nil
+		return nil
+	}
+
+	var result map[string]ImageVariant
+	if err := json.Unmarshal(b, &result); err != nil {
+		log.Printf("解析ImageVariantMap失败: %v, 原始数据: %s", err, string(b))
+		*m = nil
+		return nil
+	}
+	*m = result
+	return nil
+}
+
+// SaveImageVariant 保存单张图片的响应式尺寸信息
+func SaveImageVariant(imagePath string, variant ImageVariant) error {
+	if DB == nil {
+		return errors.New("数据库未初始化")
+	}
+	data, err := json.Marshal(variant)
+	if err != nil {
+		return fmt.Errorf("序列化图片尺寸信息失败: %w", err)
+	}
+	_, err = DB.Exec(
+		`INSERT INTO image_variants (image_path, variant, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(image_path) DO UPDATE SET variant = excluded.variant, updated_at = excluded.updated_at`,
+		imagePath, string(data), time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("保存图片尺寸信息失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteImageVariant 删除单张图片的响应式尺寸信息
+func DeleteImageVariant(imagePath string) error {
+	if DB == nil {
+		return nil
+	}
+	if _, err := DB.Exec(`DELETE FROM image_variants WHERE image_path = ?`, imagePath); err != nil {
+		return fmt.Errorf("删除图片尺寸信息失败: %w", err)
+	}
+	return nil
+}
+
+// GetImageVariants 批量获取图片的响应式尺寸信息
+func GetImageVariants(imagePaths []string) (ImageVariantMap, error) {
+	result := ImageVariantMap{}
+	if len(imagePaths) == 0 {
+		return result, nil
+	}
+
+	query, args, err := sqlx.In(`SELECT image_path, variant FROM image_variants WHERE image_path IN (?)`, imagePaths)
+	if err != nil {
+		return nil, fmt.Errorf("构造查询失败: %w", err)
+	}
+	var rows []struct {
+		ImagePath string `db:"image_path"`
+		Variant   string `db:"variant"`
+	}
+	if err := DB.Select(&rows, DB.Rebind(query), args...); err != nil {
+		return nil, fmt.Errorf("查询图片尺寸信息失败: %w", err)
+	}
+	for _, row := range rows {
+		var variant ImageVariant
+		if err := json.Unmarshal([]byte(row.Variant), &variant); err != nil {
+			log.Printf("解析图片尺寸信息失败 %s: %v", row.ImagePath, err)
+			continue
+		}
+		result[row.ImagePath] = variant
+	}
+	return result, nil
+}
+
+// SyncResourceImageVariants 根据资源当前的图片和海报刷新 image_variants 字段
+func SyncResourceImageVariants(resourceID int) error {
+	var resource Resource
+	if err := DB.Get(&resource, `SELECT * FROM resources WHERE id = ?`, resourceID); err != nil {
+		return fmt.Errorf("查询资源失败: %w", err)
+	}
+
+	paths := make([]string, 0, len(resource.Images)+1)
+	for _, img := range resource.Images {
+		if strings.HasPrefix(img, "/assets/") {
+			paths = append(paths, img)
+		}
+	}
+	if resource.PosterImage != nil && strings.HasPrefix(*resource.PosterImage, "/assets/") {
+		paths = append(paths, *resource.PosterImage)
+	}
+
+	variants, err := GetImageVariants(paths)
+	if err != nil {
+		return err
+	}
+	if _, err := DB.Exec(`UPDATE resources SET image_variants = ? WHERE id = ?`, variants, resourceID); err != nil {
+		return fmt.Errorf("更新资源图片尺寸信息失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// JobStatus 后台任务状态
+type JobStatus string
+
+// 后台任务状态常量
+const (
+	JobStatusPending   JobStatus = "PENDING"
+	JobStatusRunning   JobStatus = "RUNNING"
+	JobStatusSucceeded JobStatus = "SUCCEEDED"
+	JobStatusFailed    JobStatus = "FAILED"
+)
+
+// Job 后台任务
+type Job struct {
+	ID          int64      `db:"id" json:"id"`
+	JobType     string     `db:"job_type" json:"job_type"`
+	Payload     string     `db:"payload" json:"payload"`
+	Status      JobStatus  `db:"status" json:"status"`
+	Attempts    int        `db:"attempts" json:"attempts"`
+	MaxAttempts int        `db:"max_attempts" json:"max_attempts"`
+	LastError   *string    `db:"last_error" json:"last_error"`
+	RunAt       time.Time  `db:"run_at" json:"run_at"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+	FinishedAt  *time.Time `db:"finished_at" json:"finished_at"`
+}
+
+// CreateJob 创建待执行的后台任务
+func CreateJob(jobType, payload string, maxAttempts int) (int64, error) {
+	return createJob(DB, jobType, payload, maxAttempts)
+}
+
+// CreateJobTx 在事务中创建后台任务，事务回滚时任务一并撤销
+func CreateJobTx(tx *sqlx.Tx, jobType, payload string, maxAttempts int) (int64, error) {
+	return createJob(tx, jobType, payload, maxAttempts)
+}
+
+func createJob(e sqlx.Execer, jobType, payload string, maxAttempts int) (int64, error) {
+	now := time.Now().UTC()
+	result, err := e.Exec(
+		`INSERT INTO jobs (job_type, payload, status, attempts, max_attempts, run_at, created_at, updated_at)
+		VALUES (?, ?, ?, 0, ?, ?, ?, ?)`,
+		jobType, payload, JobStatusPending, maxAttempts, now, now, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("创建后台任务失败: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// ClaimJob 领取一个到期的待执行任务并标记为执行中，没有任务时返回 nil
+func ClaimJob() (*Job, error) {
+	// 多个worker可能同时选中同一任务，以状态条件更新保证只有一个领取成功
+	for i := 0; i < 3; i++ {
+		now := time.Now().UTC()
+		var job Job
+		err := DB.Get(&job,
+			`SELECT * FROM jobs WHERE status = ? AND run_at <= ? ORDER BY run_at, id LIMIT 1`,
+			JobStatusPending, now)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("查询后台任务失败: %w", err)
+		}
+
+		result, err := DB.Exec(
+			`UPDATE jobs SET status = ?, attempts = attempts + 1, updated_at = ? WHERE id = ? AND status = ?`,
+			JobStatusRunning, now, job.ID, JobStatusPending)
+		if err != nil {
+			return nil, fmt.Errorf("领取后台任务失败: %w", err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 1 {
+			job.Status = JobStatusRunning
+			job.Attempts++
+			return &job, nil
+		}
+	}
+	return nil, nil
+}
+
+// CompleteJob 标记任务执行成功
+func CompleteJob(id int64) error {
+	now := time.Now().UTC()
+	_, err := DB.Exec(
+		`UPDATE jobs SET status = ?, last_error = NULL, updated_at = ?, finished_at = ? WHERE id = ?`,
+		JobStatusSucceeded, now, now, id)
+	if err != nil {
+		return fmt.Errorf("更新后台任务状态失败: %w", err)
+	}
+	return nil
+}
+
+// FailJob 记录任务失败，retryAt 不为空时重新排队，否则标记为最终失败
+func FailJob(id int64, errMsg string, retryAt *time.Time) error {
+	now := time.Now().UTC()
+	var err error
+	if retryAt != nil {
+		_, err = DB.Exec(
+			`UPDATE jobs SET status = ?, last_error = ?, run_at = ?, updated_at = ? WHERE id = ?`,
+			JobStatusPending, errMsg, retryAt.UTC(), now, id)
+	} else {
+		_, err = DB.Exec(
+			`UPDATE jobs SET status = ?, last_error = ?, updated_at = ?, finished_at = ? WHERE id = ?`,
+			JobStatusFailed, errMsg, now, now, id)
+	}
+	if err != nil {
+		return fmt.Errorf("更新后台任务状态失败: %w", err)
+	}
+	return nil
+}
+
+// ResetRunningJobs 将执行中的任务重新排队，用于进程重启后恢复被中断的任务
+func ResetRunningJobs() (int64, error) {
+	result, err := DB.Exec(
+		`UPDATE jobs SET status = ?, updated_at = ? WHERE status = ?`,
+		JobStatusPending, time.Now().UTC(), JobStatusRunning)
+	if err != nil {
+		return 0, fmt.Errorf("恢复中断的后台任务失败: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ListJobs 按状态分页查询任务，status 为空时查询全部
+func ListJobs(status JobStatus, limit, offset int) ([]Job, error) {
+	jobs := []Job{}
+	var err error
+	if status == "" {
+		err = DB.Select(&jobs, `SELECT * FROM jobs ORDER BY id DESC LIMIT ? OFFSET ?`, limit, offset)
+	} else {
+		err = DB.Select(&jobs, `SELECT * FROM jobs WHERE status = ? ORDER BY id DESC LIMIT ? OFFSET ?`, status, limit, offset)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询后台任务失败: %w", err)
+	}
+	return jobs, nil
+}
+
+// RetryJob 将失败的任务重新排队，返回是否有任务被重新排队
+func RetryJob(id int64) (bool, error) {
+	now := time.Now().UTC()
+	result, err := DB.Exec(
+		`UPDATE jobs SET status = ?, attempts = 0, run_at = ?, updated_at = ?, finished_at = NULL WHERE id = ? AND status = ?`,
+		JobStatusPending, now, now, id, JobStatusFailed)
+	if err != nil {
+		return false, fmt.Errorf("重新排队后台任务失败: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// RetryFailedJobs 将所有失败的任务重新排队
+func RetryFailedJobs() (int64, error) {
+	now := time.Now().UTC()
+	result, err := DB.Exec(
+		`UPDATE jobs SET status = ?, attempts = 0, run_at = ?, updated_at = ?, finished_at = NULL WHERE status = ?`,
+		JobStatusPending, now, now, JobStatusFailed)
+	if err != nil {
+		return 0, fmt.Errorf("重新排队后台任务失败: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteFinishedJobs 删除早于指定时间完成的成功任务
+func DeleteFinishedJobs(before time.Time) (int64, error) {
+	result, err := DB.Exec(
+		`DELETE FROM jobs WHERE status = ? AND finished_at < ?`,
+		JobStatusSucceeded, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("清理后台任务失败: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,235 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 链接分类，与前端的分类标签一致
+const (
+	LinkCategoryMagnet = "magnet"
+	LinkCategoryEd2k   = "ed2k"
+	LinkCategoryUC     = "uc"
+	LinkCategoryMobile = "mobile"
+	LinkCategoryTianyi = "tianyi"
+	LinkCategoryQuark  = "quark"
+	LinkCategory115    = "115"
+	LinkCategoryAliyun = "aliyun"
+	LinkCategoryPikPak = "pikpak"
+	LinkCategoryBaidu  = "baidu"
+	LinkCategory123    = "123"
+	LinkCategoryXunlei = "xunlei"
+	LinkCategoryOnline = "online"
+	LinkCategoryOthers = "others"
+)
+
+// LinkCategories 所有链接分类，顺序与前端展示一致
+var LinkCategories = []string{
+	LinkCategoryMagnet, LinkCategoryEd2k, LinkCategoryUC, LinkCategoryMobile,
+	LinkCategoryTianyi, LinkCategoryQuark, LinkCategory115, LinkCategoryAliyun,
+	LinkCategoryPikPak, LinkCategoryBaidu, LinkCategory123, LinkCategoryXunlei,
+	LinkCategoryOnline, LinkCategoryOthers,
+}
+
+// IsLinkCategory 判断是否为已知的链接分类
+func IsLinkCategory(category string) bool {
+	for _, c := range LinkCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Link 资源的一个下载/播放链接，存储在 resources.links 的分类数组中
+type Link struct {
+	URL      string     `json:"url"`
+	Password string     `json:"password,omitempty"` // 提取码/访问码
+	Title    string     `json:"title,omitempty"`
+	Size     int64      `json:"size,omitempty"` // 文件大小（字节），未知时为0
+	Note     string     `json:"note,omitempty"`
+	AddedBy  string     `json:"added_by,omitempty"`
+	AddedAt  *time.Time `json:"added_at,omitempty"`
+	Meta     *LinkMeta  `json:"meta,omitempty"` // 磁力和ed2k链接解析出的元数据，由服务端生成
+}
+
+// LinkMeta 从磁力链接、ed2k链接或种子文件解析出的元数据
+type LinkMeta struct {
+	InfoHash   string        `json:"info_hash,omitempty"`    // BitTorrent v1 信息哈希（40位小写十六进制）
+	InfoHashV2 string        `json:"info_hash_v2,omitempty"` // BitTorrent v2 信息哈希（64位小写十六进制，不含多重哈希前缀）
+	Hash       string        `json:"hash,omitempty"`         // ed2k 文件哈希（32位小写十六进制）
+	Name       string        `json:"name,omitempty"`
+	Size       int64         `json:"size,omitempty"` // 总大小（字节），未知时为0
+	Trackers   []string      `json:"trackers,omitempty"`
+	Files      []TorrentFile `json:"files,omitempty"` // 种子中的文件列表，只有上传过种子文件的磁力链接才有
+}
+
+// TorrentFile 种子中的一个文件
+type TorrentFile struct {
+	Path string `json:"path"` // 以 / 分隔的相对路径，不含种子名称目录
+	Size int64  `json:"size"`
+}
+
+// 重复链接已存在的位置
+const (
+	LinkSourceResource   = "resource"   // 资源已有的链接
+	LinkSourceSupplement = "supplement" // 待审批的补充内容
+	LinkSourceSubmission = "submission" // 同一次提交中前面的链接
+)
+
+// LinkDuplicate 提交的链接与已有链接重复，按规范键判断（见 utils.LinkKey）
+type LinkDuplicate struct {
+	Category         string `json:"category"`
+	URL              string `json:"url"`
+	Key              string `json:"key"`
+	Existing         string `json:"existing"` // resource、supplement 或 submission
+	ExistingCategory string `json:"existing_category"`
+	ExistingURL      string `json:"existing_url"`
+}
+
+// LinkMap 分类 -> 链接列表
+type LinkMap map[string][]Link
+
+// JsonMap 转换为存储用的 JsonMap，结构与从数据库读出的一致（数组为[]interface{}）
+func (m LinkMap) JsonMap() JsonMap {
+	result := JsonMap{}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return result
+	}
+	json.Unmarshal(data, &result)
+	return result
+}
+
+// Count 链接总数
+func (m LinkMap) Count() int {
+	n := 0
+	for _, links := range m {
+		n += len(links)
+	}
+	return n
+}
+
+// ParseLinkMap 解析 resources.links 等字段中的链接，兼容旧数据的字符串和对象格式，无法识别的条目被跳过
+func ParseLinkMap(j JsonMap) LinkMap {
+	result := LinkMap{}
+	for category, value := range j {
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		for _, item := range items {
+			link, err := LinkFromValue(item)
+			if err != nil || link.URL == "" {
+				continue
+			}
+			result[category] = append(result[category], link)
+		}
+	}
+	return result
+}
+
+// LinkFromValue 把JSON解码后的链接条目转换为 Link
+// 条目可以是URL字符串，也可以是对象；提取码兼容 password/pwd/code 几种写法
+func LinkFromValue(value interface{}) (Link, error) {
+	switch v := value.(type) {
+	case string:
+		return Link{URL: v}, nil
+	case map[string]interface{}:
+		var link Link
+		var err error
+		if link.URL, err = stringField(v, "url"); err != nil {
+			return link, err
+		}
+		for _, key := range []string{"password", "pwd", "code"} {
+			if link.Password, err = stringField(v, key); err != nil {
+				return link, err
+			}
+			if link.Password != "" {
+				break
+			}
+		}
+		if link.Title, err = stringField(v, "title"); err != nil {
+			return link, err
+		}
+		if link.Note, err = stringField(v, "note"); err != nil {
+			return link, err
+		}
+		if link.AddedBy, err = stringField(v, "added_by"); err != nil {
+			return link, err
+		}
+		switch size := v["size"].(type) {
+		case nil:
+		case float64:
+			link.Size = int64(size)
+		case string:
+			if size != "" {
+				if link.Size, err = strconv.ParseInt(size, 10, 64); err != nil {
+					return link, errors.New("size 必须是字节数")
+				}
+			}
+		default:
+			return link, errors.New("size 必须是字节数")
+		}
+		if link.Size < 0 {
+			return link, errors.New("size 不能为负数")
+		}
+		if addedAt, ok := v["added_at"].(string); ok && addedAt != "" {
+			if t, err := time.Parse(time.RFC3339, addedAt); err == nil {
+				link.AddedAt = &t
+			}
+		}
+		if meta, ok := v["meta"].(map[string]interface{}); ok {
+			// 元数据由服务端生成，格式不对时直接忽略
+			if data, err := json.Marshal(meta); err == nil {
+				var m LinkMeta
+				if json.Unmarshal(data, &m) == nil {
+					link.Meta = &m
+				}
+			}
+		}
+		return link, nil
+	default:
+		return Link{}, errors.New("链接必须是字符串或对象")
+	}
+}
+
+// stringField 读取对象中的字符串字段，字段不存在时返回空字符串
+func stringField(m map[string]interface{}, key string) (string, error) {
+	switch v := m[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.TrimSpace(v), nil
+	default:
+		return "", fmt.Errorf("%s 必须是字符串", key)
+	}
+}
+
+// RemoveLinkByKey 从资源链接中移除规范键（由 linkKey 计算）为 key 的全部链接，返回移除后的链接和被移除的链接（按分类）
+func RemoveLinkByKey(links JsonMap, key string, linkKey func(url string) string) (JsonMap, map[string][]interface{}) {
+	kept := make(JsonMap, len(links))
+	removed := make(map[string][]interface{})
+	for category, value := range links {
+		items, ok := value.([]interface{})
+		if !ok {
+			items = []interface{}{value}
+		}
+		remaining := make([]interface{}, 0, len(items))
+		for _, item := range items {
+			if link, err := LinkFromValue(item); err == nil && linkKey(link.URL) == key {
+				removed[category] = append(removed[category], item)
+				continue
+			}
+			remaining = append(remaining, item)
+		}
+		if len(remaining) > 0 {
+			kept[category] = remaining
+		}
+	}
+	return kept, removed
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// LinkHealthStatus 链接的有效性状态
+type LinkHealthStatus string
+
+// 链接有效性状态常量
+const (
+	LinkHealthUnknown LinkHealthStatus = "unknown" // 尚未检查或无法判断
+	LinkHealthOK      LinkHealthStatus = "ok"
+	LinkHealthSuspect LinkHealthStatus = "suspect" // 检查到失效，但连续次数未达到阈值
+	LinkHealthBroken  LinkHealthStatus = "broken"
+)
+
+// LinkHealth 资源中一个链接的检查状态，按 (resource_id, link_key) 唯一
+type LinkHealth struct {
+	ID            int64            `db:"id" json:"id"`
+	ResourceID    int              `db:"resource_id" json:"resource_id"`
+	Category      string           `db:"category" json:"category"`
+	LinkKey       string           `db:"link_key" json:"link_key"`
+	URL           string           `db:"url" json:"url"`
+	Password      string           `db:"password" json:"-"`
+	Status        LinkHealthStatus `db:"status" json:"status"`
+	FailureCount  int              `db:"failure_count" json:"failure_count"` // 连续检查到失效的次数
+	LastError     *string          `db:"last_error" json:"last_error"`
+	LastCheckedAt *time.Time       `db:"last_checked_at" json:"last_checked_at"`
+	NextCheckAt   time.Time        `db:"next_check_at" json:"next_check_at"`
+	CreatedAt     time.Time        `db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time        `db:"updated_at" json:"updated_at"`
+	ResourceTitle string           `db:"resource_title" json:"resource_title,omitempty"` // 仅列表查询时填充
+}
+
+// LinkHealthSummary 公开接口返回的链接状态
+type LinkHealthSummary struct {
+	Status        LinkHealthStatus `json:"status"`
+	LastCheckedAt *time.Time       `json:"last_checked_at"`
+}
+
+// LinkHealthEntry 需要检查的链接
+type LinkHealthEntry struct {
+	Category string
+	LinkKey  string
+	URL      string
+	Password string
+}
+
+// SyncResourceLinkHealth 使资源的链接检查记录与 entries 一致：新增缺少的链接（立即检查），
+// 更新已有链接的URL和提取码，删除资源中已不存在的链接
+func SyncResourceLinkHealth(resourceID int, entries []LinkHealthEntry) error {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	keys := make([]string, 0, len(entries))
+	for _, e := range entries {
+		_, err := tx.Exec(
+			`INSERT INTO link_health (resource_id, category, link_key, url, password, status, failure_count, next_check_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?, ?)
+			ON CONFLICT(resource_id, link_key) DO UPDATE SET
+				category = excluded.category, url = excluded.url, password = excluded.password, updated_at = excluded.updated_at
+			WHERE link_health.url != excluded.url OR link_health.password != excluded.password OR link_health.category != excluded.category`,
+			resourceID, e.Category, e.LinkKey, e.URL, e.Password, LinkHealthUnknown, now, now, now,
+		)
+		if err != nil {
+			return fmt.Errorf("保存链接检查记录失败: %w", err)
+		}
+		keys = append(keys, e.LinkKey)
+	}
+
+	if len(keys) == 0 {
+		_, err = tx.Exec(`DELETE FROM link_health WHERE resource_id = ?`, resourceID)
+	} else {
+		var query string
+		var args []interface{}
+		query, args, err = sqlx.In(`DELETE FROM link_health WHERE resource_id = ? AND link_key NOT IN (?)`, resourceID, keys)
+		if err == nil {
+			_, err = tx.Exec(tx.Rebind(query), args...)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("删除过期的链接检查记录失败: %w", err)
+	}
+	return tx.Commit()
+}
+
+// PruneLinkHealth 删除已删除或未通过审批的资源的链接检查记录
+func PruneLinkHealth() (int64, error) {
+	result, err := DB.Exec(
+		`DELETE FROM link_health WHERE resource_id NOT IN (SELECT id FROM resources WHERE status = ?)`,
+		ResourceStatusApproved)
+	if err != nil {
+		return 0, fmt.Errorf("清理链接检查记录失败: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DueLinkHealth 返回到期需要检查的链接，按计划时间排序
+func DueLinkHealth(now time.Time, limit int) ([]LinkHealth, error) {
+	var items []LinkHealth
+	err := DB.Select(&items,
+		`SELECT * FROM link_health WHERE next_check_at <= ? ORDER BY next_check_at, id LIMIT ?`,
+		now.UTC(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询待检查的链接失败: %w", err)
+	}
+	return items, nil
+}
+
+// NextLinkCheckAt 返回最近一次计划检查的时间，没有记录时返回nil
+func NextLinkCheckAt() (*time.Time, error) {
+	var next time.Time
+	err := DB.Get(&next, `SELECT next_check_at FROM link_health ORDER BY next_check_at LIMIT 1`)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询链接检查计划失败: %w", err)
+	}
+	return &next, nil
+}
+
+// UpdateLinkHealth 保存一次检查的结果
+func UpdateLinkHealth(h *LinkHealth) error {
+	h.UpdatedAt = time.Now().UTC()
+	_, err := DB.Exec(
+		`UPDATE link_health SET status = ?, failure_count = ?, last_error = ?, last_checked_at = ?, next_check_at = ?, updated_at = ?
+		WHERE id = ?`,
+		h.Status, h.FailureCount, h.LastError, h.LastCheckedAt, h.NextCheckAt.UTC(), h.UpdatedAt, h.ID)
+	if err != nil {
+		return fmt.Errorf("保存链接检查结果失败: %w", err)
+	}
+	return nil
+}
+
+// ScheduleLinkCheck 把链接的下次检查时间设为现在，记录不存在时返回 sql.ErrNoRows
+func ScheduleLinkCheck(id int64) error {
+	now := time.Now().UTC()
+	result, err := DB.Exec(`UPDATE link_health SET next_check_at = ?, updated_at = ? WHERE id = ?`, now, now, id)
+	if err != nil {
+		return fmt.Errorf("安排链接检查失败: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListLinkHealth 按状态分页查询链接检查记录（附带资源标题），statuses 为空时返回全部
+func ListLinkHealth(statuses []LinkHealthStatus, skip, limit int) ([]LinkHealth, int, error) {
+	where := ""
+	var args []interface{}
+	if len(statuses) > 0 {
+		query, inArgs, err := sqlx.In(`WHERE h.status IN (?)`, statuses)
+		if err != nil {
+			return nil, 0, err
+		}
+		where, args = query, inArgs
+	}
+
+	var total int
+	if err := DB.Get(&total, `SELECT COUNT(*) FROM link_health h `+where, args...); err != nil {
+		return nil, 0, fmt.Errorf("统计链接检查记录失败: %w", err)
+	}
+
+	items := []LinkHealth{}
+	err := DB.Select(&items,
+		`SELECT h.*, r.title AS resource_title FROM link_health h JOIN resources r ON r.id = h.resource_id `+where+`
+		ORDER BY h.failure_count DESC, h.last_checked_at DESC, h.id LIMIT ? OFFSET ?`,
+		append(args, limit, skip)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询链接检查记录失败: %w", err)
+	}
+	return items, total, nil
+}
+
+// GetResourceLinkHealth 返回资源所有链接的检查状态，按URL索引
+func GetResourceLinkHealth(resourceID int) (map[string]LinkHealthSummary, error) {
+	var items []LinkHealth
+	if err := DB.Select(&items, `SELECT * FROM link_health WHERE resource_id = ?`, resourceID); err != nil {
+		return nil, fmt.Errorf("查询链接检查状态失败: %w", err)
+	}
+	result := make(map[string]LinkHealthSummary, len(items))
+	for _, item := range items {
+		result[item.URL] = LinkHealthSummary{Status: item.Status, LastCheckedAt: item.LastCheckedAt}
+	}
+	return result, nil
+}
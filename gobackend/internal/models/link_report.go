@@ -0,0 +1,262 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// LinkReportReason 举报原因
+type LinkReportReason string
+
+// 举报原因常量
+const (
+	LinkReportDead     LinkReportReason = "dead"     // 链接失效
+	LinkReportPassword LinkReportReason = "password" // 提取码错误
+	LinkReportWrong    LinkReportReason = "wrong"    // 内容与资源不符
+	LinkReportMalware  LinkReportReason = "malware"  // 含有病毒、广告或诈骗内容
+	LinkReportOther    LinkReportReason = "other"
+)
+
+// IsLinkReportReason 判断是否为支持的举报原因
+func IsLinkReportReason(reason string) bool {
+	switch LinkReportReason(reason) {
+	case LinkReportDead, LinkReportPassword, LinkReportWrong, LinkReportMalware, LinkReportOther:
+		return true
+	}
+	return false
+}
+
+// LinkReportStatus 举报的处理状态，除 open 外的状态与处理动作同名
+type LinkReportStatus string
+
+// 举报处理状态常量
+const (
+	LinkReportOpen             LinkReportStatus = "open"
+	LinkReportDismissed        LinkReportStatus = "dismissed"         // 举报不成立
+	LinkReportRemoved          LinkReportStatus = "removed"           // 链接已从资源中移除
+	LinkReportNeedsReplacement LinkReportStatus = "needs_replacement" // 资源已标记为需要补充新链接
+)
+
+// LinkReport 访客对资源中一个链接的举报
+type LinkReport struct {
+	ID               int64            `db:"id" json:"id"`
+	ResourceID       int              `db:"resource_id" json:"resource_id"`
+	Category         string           `db:"category" json:"category"`
+	LinkKey          string           `db:"link_key" json:"link_key"`
+	URL              string           `db:"url" json:"url"`
+	Reason           LinkReportReason `db:"reason" json:"reason"`
+	Note             string           `db:"note" json:"note"`
+	Reporter         string           `db:"reporter" json:"reporter"`
+	ClientKey        string           `db:"client_key" json:"-"` // 客户端IP的哈希，用于同一客户端重复举报去重
+	Status           LinkReportStatus `db:"status" json:"status"`
+	ApprovalRecordID *int64           `db:"approval_record_id" json:"approval_record_id"`
+	ResolvedBy       *string          `db:"resolved_by" json:"resolved_by"`
+	ResolvedAt       *time.Time       `db:"resolved_at" json:"resolved_at"`
+	CreatedAt        time.Time        `db:"created_at" json:"created_at"`
+}
+
+// LinkReportCreate 举报链接请求，link_key 和 url 二选一
+type LinkReportCreate struct {
+	Category string `json:"category"`
+	LinkKey  string `json:"link_key"`
+	URL      string `json:"url"`
+	Reason   string `json:"reason" binding:"required"`
+	Note     string `json:"note"`
+}
+
+// LinkReportResolve 处理举报请求，action 为 dismiss、remove 或 needs_replacement
+type LinkReportResolve struct {
+	Action string `json:"action" binding:"required"`
+	Notes  string `json:"notes"`
+}
+
+// LinkReportGroup 审核队列中的一项：同一资源同一链接的全部待处理举报
+type LinkReportGroup struct {
+	ID              int64                    `json:"id"` // 最新一条举报的ID，处理时使用
+	ResourceID      int                      `json:"resource_id"`
+	ResourceTitle   string                   `json:"resource_title"`
+	Category        string                   `json:"category"`
+	LinkKey         string                   `json:"link_key"`
+	URL             string                   `json:"url"`
+	ReportCount     int                      `json:"report_count"`
+	Reasons         map[LinkReportReason]int `json:"reasons"`
+	Reports         []LinkReport             `json:"reports"`
+	FirstReportedAt time.Time                `json:"first_reported_at"`
+	LastReportedAt  time.Time                `json:"last_reported_at"`
+}
+
+// CreateLinkReport 保存一条举报，同一客户端对同一链接已有待处理的举报时不重复保存，返回是否新增
+func CreateLinkReport(r *LinkReport) (bool, error) {
+	r.Status = LinkReportOpen
+	r.CreatedAt = time.Now().UTC()
+	result, err := DB.Exec(
+		`INSERT INTO link_reports (resource_id, category, link_key, url, reason, note, reporter, client_key, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		r.ResourceID, r.Category, r.LinkKey, r.URL, r.Reason, r.Note, r.Reporter, r.ClientKey, r.Status, r.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("保存链接举报失败: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return false, nil
+	}
+	r.ID, _ = result.LastInsertId()
+	return true, nil
+}
+
+// CountOpenLinkReports 返回链接待处理的举报数
+func CountOpenLinkReports(resourceID int, linkKey string) (int, error) {
+	var count int
+	err := DB.Get(&count, `SELECT COUNT(*) FROM link_reports WHERE resource_id = ? AND link_key = ? AND status = ?`,
+		resourceID, linkKey, LinkReportOpen)
+	if err != nil {
+		return 0, fmt.Errorf("统计链接举报失败: %w", err)
+	}
+	return count, nil
+}
+
+// GetLinkReport 按ID查询举报
+func GetLinkReport(id int64) (*LinkReport, error) {
+	var report LinkReport
+	if err := DB.Get(&report, `SELECT * FROM link_reports WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ListOpenLinkReports 分页返回按链接聚合的待处理举报，举报多的排在前面
+func ListOpenLinkReports(skip, limit int) ([]LinkReportGroup, int, error) {
+	var total int
+	err := DB.Get(&total,
+		`SELECT COUNT(*) FROM (SELECT 1 FROM link_reports WHERE status = ? GROUP BY resource_id, link_key)`, LinkReportOpen)
+	if err != nil {
+		return nil, 0, fmt.Errorf("统计链接举报失败: %w", err)
+	}
+
+	var keys []struct {
+		ResourceID    int    `db:"resource_id"`
+		LinkKey       string `db:"link_key"`
+		ResourceTitle string `db:"resource_title"`
+	}
+	err = DB.Select(&keys,
+		`SELECT l.resource_id, l.link_key, COALESCE(r.title, '') AS resource_title
+		FROM link_reports l JOIN resources r ON r.id = l.resource_id
+		WHERE l.status = ?
+		GROUP BY l.resource_id, l.link_key
+		ORDER BY COUNT(*) DESC, MAX(l.id) DESC LIMIT ? OFFSET ?`,
+		LinkReportOpen, limit, skip)
+	if err != nil {
+		return nil, 0, fmt.Errorf("查询链接举报失败: %w", err)
+	}
+
+	groups := make([]LinkReportGroup, 0, len(keys))
+	for _, k := range keys {
+		var reports []LinkReport
+		err := DB.Select(&reports,
+			`SELECT * FROM link_reports WHERE resource_id = ? AND link_key = ? AND status = ? ORDER BY id`,
+			k.ResourceID, k.LinkKey, LinkReportOpen)
+		if err != nil {
+			return nil, 0, fmt.Errorf("查询链接举报失败: %w", err)
+		}
+		if len(reports) == 0 {
+			continue
+		}
+		last := reports[len(reports)-1]
+		group := LinkReportGroup{
+			ID:              last.ID,
+			ResourceID:      k.ResourceID,
+			ResourceTitle:   k.ResourceTitle,
+			Category:        last.Category,
+			LinkKey:         k.LinkKey,
+			URL:             last.URL,
+			ReportCount:     len(reports),
+			Reasons:         make(map[LinkReportReason]int),
+			Reports:         reports,
+			FirstReportedAt: reports[0].CreatedAt,
+			LastReportedAt:  last.CreatedAt,
+		}
+		for _, r := range reports {
+			group.Reasons[r.Reason]++
+		}
+		groups = append(groups, group)
+	}
+	return groups, total, nil
+}
+
+// LinkReportResolution 处理一个链接的全部待处理举报
+type LinkReportResolution struct {
+	ResourceID       int
+	LinkKey          string
+	Status           LinkReportStatus // 处理结果，不能为 open
+	ResolvedBy       string
+	RemoveLink       bool    // 为true时从资源中移除规范键为 LinkKey 的链接
+	NeedsReplacement bool    // 为true时把资源标记为需要补充新链接
+	Record           ApprovalRecord
+}
+
+// ResolveLinkReports 在一个事务中更新资源、写入审批记录并关闭举报，返回审批记录ID和关闭的举报数；
+// 移除链接时按事务中读取的资源链接计算，链接按 linkKey 判断是否为被举报的链接，被移除的链接写入审批记录的 RejectedLinks。
+// 举报已被处理时返回 sql.ErrNoRows，不做任何修改
+func ResolveLinkReports(res LinkReportResolution, linkKey func(url string) string) (int64, int64, error) {
+	tx, err := DB.Beginx()
+	if err != nil {
+		return 0, 0, fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 先写入以持有写锁，之后读取的资源不会再被其他请求修改
+	now := time.Now().UTC()
+	if _, err := tx.Exec(`UPDATE resources SET updated_at = ? WHERE id = ?`, now, res.ResourceID); err != nil {
+		return 0, 0, fmt.Errorf("更新资源失败: %w", err)
+	}
+	var resource Resource
+	if err := tx.Get(&resource, `SELECT * FROM resources WHERE id = ?`, res.ResourceID); err != nil {
+		return 0, 0, fmt.Errorf("查询资源失败: %w", err)
+	}
+	before := CaptureResourceState(&resource)
+
+	if res.RemoveLink {
+		links, removed := RemoveLinkByKey(resource.Links, res.LinkKey, linkKey)
+		if _, err := tx.Exec(`UPDATE resources SET links = ?, updated_at = ? WHERE id = ?`, links, now, res.ResourceID); err != nil {
+			return 0, 0, fmt.Errorf("更新资源链接失败: %w", err)
+		}
+		resource.Links = links
+		if res.Record.RejectedLinks == nil {
+			res.Record.RejectedLinks = JsonMap{}
+		}
+		for category, items := range removed {
+			res.Record.RejectedLinks[category] = items
+		}
+	}
+	if res.NeedsReplacement {
+		if _, err := tx.Exec(`UPDATE resources SET needs_link_replacement = 1, updated_at = ? WHERE id = ?`, now, res.ResourceID); err != nil {
+			return 0, 0, fmt.Errorf("标记资源失败: %w", err)
+		}
+		resource.NeedsLinkReplacement = true
+	}
+	res.Record.Snapshot = NewApprovalSnapshot(before, CaptureResourceState(&resource), nil)
+
+	recordID, err := InsertApprovalRecord(tx, &res.Record)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	result, err := tx.Exec(
+		`UPDATE link_reports SET status = ?, approval_record_id = ?, resolved_by = ?, resolved_at = ?
+		WHERE resource_id = ? AND link_key = ? AND status = ?`,
+		res.Status, recordID, res.ResolvedBy, now, res.ResourceID, res.LinkKey, LinkReportOpen)
+	if err != nil {
+		return 0, 0, fmt.Errorf("更新链接举报失败: %w", err)
+	}
+	closed, _ := result.RowsAffected()
+	if closed == 0 {
+		// 举报已被其他请求处理，放弃本次修改
+		return 0, 0, sql.ErrNoRows
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("提交事务失败: %w", err)
+	}
+	return recordID, closed, nil
+}
@@ -189,17 +189,24 @@ type Resource struct {
 	HiddenFromAdmin    *bool          `db:"hidden_from_admin" json:"hidden_from_admin"`
 	Links              JsonMap        `db:"links" json:"links"`
 	OriginalResourceID *int           `db:"original_resource_id" json:"original_resource_id"`
-	Supplement         JsonMap        `db:"supplement" json:"supplement"`
+	Supplement         JsonMap        `db:"supplement" json:"supplement"` // 旧的补充内容列，已迁移到 supplements 表；待审批列表中为最早提交的待审批补充内容
 	ApprovalHistory    JsonMap        `db:"approval_history" json:"approval_history"`
 	IsSupplementApproval bool         `db:"is_supplement_approval" json:"is_supplement_approval"`
 	LikesCount         int            `db:"likes_count" json:"likes_count"`
 	TmdbID             *int           `db:"tmdb_id" json:"tmdb_id"`
 	MediaType          *string        `db:"media_type" json:"media_type"`
 	Stickers           JsonMap        `db:"stickers" json:"stickers"`
+	ImageVariants      ImageVariantMap `db:"image_variants" json:"image_variants"` // 图片路径 -> 响应式尺寸和BlurHash
+	NeedsLinkReplacement bool         `db:"needs_link_replacement" json:"needs_link_replacement"` // 链接被举报失效，等待补充新链接
 	CreatedAt          time.Time      `db:"created_at" json:"created_at"`
 	UpdatedAt          time.Time      `db:"updated_at" json:"updated_at"`
 	TotalCount         *int           `db:"-" json:"total_count,omitempty"` // 不存储在数据库中，用于分页
 	HasPendingSupplement bool         `db:"-" json:"has_pending_supplement,omitempty"` // 不存储在数据库中
+	PendingSupplements int            `db:"-" json:"pending_supplements,omitempty"` // 不存储在数据库中，待审批补充内容的份数
+	NearDuplicates     []ImageDuplicate `db:"-" json:"near_duplicates,omitempty"` // 不存储在数据库中，待审批图片的近似重复图片
+	DuplicateLinks     []LinkDuplicate `db:"-" json:"duplicate_links,omitempty"` // 不存储在数据库中，提交时被去掉的重复链接
+	LinkHealth         map[string]LinkHealthSummary `db:"-" json:"link_health,omitempty"` // 不存储在数据库中，链接URL -> 有效性检查结果
+	TrackingToken      string         `db:"-" json:"tracking_token,omitempty"` // 不存储在资源表中，仅在提交时返回给提交者
 }
 
 // User 用户模型
@@ -234,6 +241,7 @@ type ResourceUpdate struct {
 	TmdbID       *int      `json:"tmdb_id"`
 	MediaType    *string   `json:"media_type"`
 	Stickers     JsonMap   `json:"stickers"`
+	NeedsLinkReplacement *bool `json:"needs_link_replacement"`
 }
 
 // ResourceApproval 资源审批请求
@@ -247,6 +255,9 @@ type ResourceApproval struct {
 	Notes           string                 `json:"notes"`
 	ApprovedLinks   []map[string]interface{} `json:"approved_links"`
 	RejectedLinks   []map[string]interface{} `json:"rejected_links"`
+	ApprovedEntries []string                `json:"approved_entries"` // 批准的差异条目ID，非空时代替上面的图片、链接和字段列表，未列出的条目视为拒绝
+	SupplementID    *int                    `json:"supplement_id"`    // 审批的补充内容ID，为空时审批最早提交的待审批补充内容
+	RejectionReasons []AppliedRejectionReason `json:"rejection_reasons"` // 附加到整个提交或字段、图片、链接上的驳回原因，提交者可凭跟踪令牌查看
 }
 
 // ApprovalRecord 审批记录模型
@@ -263,6 +274,12 @@ type ApprovalRecord struct {
 	ApprovedLinks    JsonMap        `db:"approved_links" json:"approved_links"`
 	RejectedLinks    JsonMap        `db:"rejected_links" json:"rejected_links"`
 	IsSupplementApproval bool       `db:"is_supplement_approval" json:"is_supplement_approval"`
+	RequestHash      string         `db:"request_hash" json:"-"` // 审批请求的哈希，用于识别重复提交
+	RejectionReasons AppliedRejectionReasons `db:"rejection_reasons" json:"rejection_reasons"`
+	Snapshot         *ApprovalSnapshot `db:"snapshot" json:"snapshot,omitempty"` // 修改前后的资源字段，用于撤销；旧记录为空
+	RevertsRecordID  *int           `db:"reverts_record_id" json:"reverts_record_id,omitempty"` // 撤销记录对应的原审批记录
+	RevertedByRecordID *int         `db:"reverted_by_record_id" json:"reverted_by_record_id,omitempty"` // 已被撤销时为撤销记录ID
+	RevertedAt       *time.Time     `db:"reverted_at" json:"reverted_at,omitempty"`
 	CreatedAt        time.Time      `db:"created_at" json:"created_at"`
 }
 
@@ -282,6 +299,7 @@ type PasswordUpdate struct {
 type SupplementCreate struct {
 	Images []string  `json:"images"`
 	Links  JsonMap   `json:"links"`
+	Notes  string    `json:"notes"`
 }
 
 // TokenResponse 令牌响应
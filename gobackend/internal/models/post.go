@@ -4,12 +4,16 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
-	"io/ioutil"
-	"os"
+	"io"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
+
+	"dongman/internal/config"
+	"dongman/internal/storage"
 )
 
 // Post 表示一篇完整的文章
@@ -64,15 +68,58 @@ func GenerateSlug(title string) string {
 	return slug
 }
 
-// SavePost 保存文章（创建或更新）
-func SavePost(post *Post, basePath string) error {
-	postsDir := filepath.Join(basePath, "posts")
-	
-	// 确保目录存在
-	if err := os.MkdirAll(postsDir, 0755); err != nil {
-		return err
+// postsPrefix 文章在存储后端中的目录
+const postsPrefix = "posts/"
+
+// postObject 存储后端中的一篇文章
+type postObject struct {
+	Name    string // 文件名，如 hello.md
+	ModTime time.Time
+}
+
+// isPostFile 判断文件名是否为Markdown文章
+func isPostFile(name string) bool {
+	return strings.HasSuffix(name, ".md") || strings.HasSuffix(name, ".markdown")
+}
+
+// listPostObjects 列出文章目录下的Markdown文件（不含 posts/imgs 等子目录），按文件名排序
+func listPostObjects(backend storage.Backend) ([]postObject, error) {
+	var posts []postObject
+	err := storage.List(backend, postsPrefix, func(info storage.ObjectInfo) error {
+		name := strings.TrimPrefix(info.Key, postsPrefix)
+		if strings.Contains(name, "/") || !isPostFile(name) {
+			return nil
+		}
+		posts = append(posts, postObject{Name: name, ModTime: info.LastModified})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取文章目录失败: %w", err)
 	}
-	
+	sort.Slice(posts, func(i, j int) bool { return posts[i].Name < posts[j].Name })
+	return posts, nil
+}
+
+// readPostObject 读取文章内容
+func readPostObject(backend storage.Backend, name string) ([]byte, error) {
+	reader, err := backend.Get(postsPrefix + name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// postID 根据文件名生成稳定的文章ID
+// 沿用按本地文件路径计算的方式，切换存储后端前后已有文章的ID保持不变
+func postID(name string) string {
+	hasher := md5.New()
+	hasher.Write([]byte(filepath.Join(config.AssetPath, "posts", name)))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// SavePost 保存文章（创建或更新），经由存储后端写入 posts/ 目录
+func SavePost(post *Post, backend storage.Backend) error {
 	// 确保有标题和slug
 	if post.Title == "" {
 		post.Title = fmt.Sprintf("新文章 - %s", time.Now().Format("2006-01-02"))
@@ -87,7 +134,7 @@ func SavePost(post *Post, basePath string) error {
 	var fileName string
 	
 	// 检查是否已有同名文件
-	files, err := ioutil.ReadDir(postsDir)
+	files, err := listPostObjects(backend)
 	if err != nil {
 		return err
 	}
@@ -102,14 +149,12 @@ func SavePost(post *Post, basePath string) error {
 		// 尝试查找现有文件
 		fileFound := false
 		for _, file := range files {
-			if !file.IsDir() && (strings.HasSuffix(file.Name(), ".md") || strings.HasSuffix(file.Name(), ".markdown")) {
-				fileNameWithoutExt := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
-				// 检查文件名是否包含slug或者是日期格式+slug
-				if fileNameWithoutExt == post.Slug || strings.HasSuffix(fileNameWithoutExt, "-"+post.Slug) {
-					fileName = file.Name()
-					fileFound = true
-					break
-				}
+			fileNameWithoutExt := strings.TrimSuffix(file.Name, path.Ext(file.Name))
+			// 检查文件名是否包含slug或者是日期格式+slug
+			if fileNameWithoutExt == post.Slug || strings.HasSuffix(fileNameWithoutExt, "-"+post.Slug) {
+				fileName = file.Name
+				fileFound = true
+				break
 			}
 		}
 		
@@ -123,13 +168,8 @@ func SavePost(post *Post, basePath string) error {
 		post.UpdatedAt = time.Now()
 	}
 	
-	// 构建完整的文件路径
-	filePath := filepath.Join(postsDir, fileName)
-	
-	// 生成稳定的ID，基于文件路径
-	hasher := md5.New()
-	hasher.Write([]byte(filePath))
-	post.ID = hex.EncodeToString(hasher.Sum(nil))
+	// 生成稳定的ID，基于文件名
+	post.ID = postID(fileName)
 	
 	// 构建Markdown内容，包括元数据
 	var contentBuilder strings.Builder
@@ -170,49 +210,159 @@ func SavePost(post *Post, basePath string) error {
 	
 	contentBuilder.WriteString(content)
 	
-	// 写入文件
-	return ioutil.WriteFile(filePath, []byte(contentBuilder.String()), 0644)
+	// 写入存储后端
+	data := contentBuilder.String()
+	if err := backend.Put(postsPrefix+fileName, strings.NewReader(data), int64(len(data)), "text/markdown; charset=utf-8"); err != nil {
+		return fmt.Errorf("保存文章失败: %w", err)
+	}
+	return nil
 }
 
 // GetAllPosts 获取所有文章的摘要信息
-func GetAllPosts(basePath string) ([]PostSummary, error) {
-	postsDir := filepath.Join(basePath, "posts")
-	
+func GetAllPosts(backend storage.Backend) ([]PostSummary, error) {
 	// 初始化一个空数组，确保即使没有文章也返回空数组而不是null
 	var posts []PostSummary = []PostSummary{}
 	
-	// 检查目录是否存在
-	if _, err := os.Stat(postsDir); os.IsNotExist(err) {
-		// 如果目录不存在，创建它
-		if err := os.MkdirAll(postsDir, 0755); err != nil {
-			return posts, err
+	// 读取所有markdown文件，目录不存在时为空
+	files, err := listPostObjects(backend)
+	if err != nil {
+		return posts, err
+	}
+	
+	for _, file := range files {
+		fileName := file.Name
+		fileNameWithoutExt := strings.TrimSuffix(fileName, path.Ext(fileName))
+		
+		// 读取文件内容
+		content, err := readPostObject(backend, fileName)
+		if err != nil {
+			continue
 		}
-		return posts, nil
+		
+		// 默认使用文件名作为标题和slug
+		title := fileNameWithoutExt
+		slug := fileNameWithoutExt // 直接使用文件名（不包含扩展名）作为slug
+		createdAt := file.ModTime
+		
+		// 尝试从文件名提取日期和标题（如果是日期-标题格式）
+		datePattern := regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+		matches := datePattern.FindStringSubmatch(fileNameWithoutExt)
+		if len(matches) == 3 {
+			// 如果文件名符合日期-标题格式
+			dateStr := matches[1]
+			titleFromFileName := matches[2]
+			parsedDate, err := time.Parse("2006-01-02", dateStr)
+			if err == nil {
+				// 如果成功解析日期
+				createdAt = parsedDate
+				title = titleFromFileName
+				slug = titleFromFileName // 使用标题部分作为slug
+			}
+		}
+		
+		// 尝试从内容中提取H1标题作为文章标题
+		contentLines := strings.Split(string(content), "\n")
+		for _, line := range contentLines[:min(5, len(contentLines))] {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "# ") {
+				// 找到H1标题，使用它作为文章标题
+				extractedTitle := strings.TrimSpace(strings.TrimPrefix(line, "# "))
+				if extractedTitle != "" {
+					title = extractedTitle
+				}
+				break
+			}
+		}
+		
+		// 创建文章摘要对象
+		post := PostSummary{
+			ID:          postID(fileName),
+			Title:       title,
+			Slug:        slug,
+			Author:      "", // 从Markdown内容中提取作者信息（如果有）
+			CreatedAt:   createdAt,
+			UpdatedAt:   file.ModTime,
+			IsMarkdown:  true,
+			Tags:        []string{}, // 从Markdown内容中提取标签信息（如果有）
+			Cover:       "", // 从Markdown内容中提取封面图片（如果有）
+			IsPublished: true,
+		}
+		
+		// 尝试从内容中提取更多元数据（作者、标签等）
+		for _, line := range contentLines[:min(20, len(contentLines))] {
+			line = strings.TrimSpace(line)
+			
+			// 提取作者信息
+			if strings.HasPrefix(line, "作者:") || strings.HasPrefix(line, "Author:") {
+				post.Author = strings.TrimSpace(strings.Split(line, ":")[1])
+			}
+			
+			// 提取标签信息
+			if strings.HasPrefix(line, "标签:") || strings.HasPrefix(line, "Tags:") {
+				tagsPart := strings.TrimSpace(strings.Split(line, ":")[1])
+				tags := strings.Split(tagsPart, ",")
+				for i, tag := range tags {
+					tags[i] = strings.TrimSpace(tag)
+				}
+				post.Tags = tags
+			}
+			
+			// 提取封面图片
+			if strings.HasPrefix(line, "封面:") || strings.HasPrefix(line, "Cover:") {
+				post.Cover = strings.TrimSpace(strings.Split(line, ":")[1])
+			}
+		}
+		
+		posts = append(posts, post)
 	}
 	
-	// 读取所有markdown文件
-	files, err := ioutil.ReadDir(postsDir)
+	return posts, nil
+}
+
+// GetPostByID 通过ID获取文章详情
+func GetPostByID(id string, backend storage.Backend) (*Post, error) {
+	// 由于我们现在完全依赖Markdown文件，而不是JSON文件
+	// 我们需要先获取所有文章，然后根据ID查找
+	posts, err := GetAllPosts(backend)
 	if err != nil {
-		return posts, err
+		return nil, err
 	}
 	
+	// 查找匹配ID的文章
+	for _, postSummary := range posts {
+		if postSummary.ID == id {
+			// 找到匹配的文章，通过slug获取完整内容
+			return GetPostBySlug(postSummary.Slug, backend)
+		}
+	}
+	
+	return nil, fmt.Errorf("post with ID '%s' not found", id)
+}
+
+// GetPostBySlug 通过slug获取文章详情
+func GetPostBySlug(slug string, backend storage.Backend) (*Post, error) {
+	// 读取目录中的所有文件
+	files, err := listPostObjects(backend)
+	if err != nil {
+		return nil, err
+	}
+	
+	// 查找匹配的Markdown文件
 	for _, file := range files {
-		// 只处理markdown文件，忽略JSON文件和其他文件
-		if !file.IsDir() && (strings.HasSuffix(file.Name(), ".md") || strings.HasSuffix(file.Name(), ".markdown")) {
-			fileName := file.Name()
-			fileNameWithoutExt := strings.TrimSuffix(fileName, filepath.Ext(fileName))
-			
-			// 读取文件内容
-			filePath := filepath.Join(postsDir, fileName)
-			content, err := ioutil.ReadFile(filePath)
+		fileName := file.Name
+		fileNameWithoutExt := strings.TrimSuffix(fileName, path.Ext(fileName))
+		
+		// 如果slug匹配文件名（不包含扩展名）或者是文件名的一部分
+		if fileNameWithoutExt == slug || strings.Contains(fileNameWithoutExt, slug) {
+			content, err := readPostObject(backend, fileName)
 			if err != nil {
-				continue
+				return nil, err
 			}
 			
 			// 默认使用文件名作为标题和slug
 			title := fileNameWithoutExt
-			slug := fileNameWithoutExt // 直接使用文件名（不包含扩展名）作为slug
-			createdAt := file.ModTime()
+			fileSlug := fileNameWithoutExt
+			createdAt := file.ModTime
 			
 			// 尝试从文件名提取日期和标题（如果是日期-标题格式）
 			datePattern := regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
@@ -226,7 +376,7 @@ func GetAllPosts(basePath string) ([]PostSummary, error) {
 					// 如果成功解析日期
 					createdAt = parsedDate
 					title = titleFromFileName
-					slug = titleFromFileName // 使用标题部分作为slug
+					fileSlug = titleFromFileName
 				}
 			}
 			
@@ -244,22 +394,18 @@ func GetAllPosts(basePath string) ([]PostSummary, error) {
 				}
 			}
 			
-			// 生成稳定的ID，基于文件路径
-			hasher := md5.New()
-			hasher.Write([]byte(filePath))
-			id := hex.EncodeToString(hasher.Sum(nil))
-			
-			// 创建文章摘要对象
-			post := PostSummary{
-				ID:          id,
+			// 创建文章对象
+			post := &Post{
+				ID:          postID(fileName),
 				Title:       title,
-				Slug:        slug,
-				Author:      "", // 从Markdown内容中提取作者信息（如果有）
+				Slug:        fileSlug,
+				Content:     string(content),
+				Author:      "", // 从内容中提取
 				CreatedAt:   createdAt,
-				UpdatedAt:   file.ModTime(),
+				UpdatedAt:   file.ModTime,
 				IsMarkdown:  true,
-				Tags:        []string{}, // 从Markdown内容中提取标签信息（如果有）
-				Cover:       "", // 从Markdown内容中提取封面图片（如果有）
+				Tags:        []string{}, // 从内容中提取
+				Cover:       "", // 从内容中提取
 				IsPublished: true,
 			}
 			
@@ -288,139 +434,7 @@ func GetAllPosts(basePath string) ([]PostSummary, error) {
 				}
 			}
 			
-			posts = append(posts, post)
-		}
-	}
-	
-	return posts, nil
-}
-
-// GetPostByID 通过ID获取文章详情
-func GetPostByID(id string, basePath string) (*Post, error) {
-	// 由于我们现在完全依赖Markdown文件，而不是JSON文件
-	// 我们需要先获取所有文章，然后根据ID查找
-	posts, err := GetAllPosts(basePath)
-	if err != nil {
-		return nil, err
-	}
-	
-	// 查找匹配ID的文章
-	for _, postSummary := range posts {
-		if postSummary.ID == id {
-			// 找到匹配的文章，通过slug获取完整内容
-			return GetPostBySlug(postSummary.Slug, basePath)
-		}
-	}
-	
-	return nil, fmt.Errorf("post with ID '%s' not found", id)
-}
-
-// GetPostBySlug 通过slug获取文章详情
-func GetPostBySlug(slug string, basePath string) (*Post, error) {
-	postsDir := filepath.Join(basePath, "posts")
-	
-	// 读取目录中的所有文件
-	files, err := ioutil.ReadDir(postsDir)
-	if err != nil {
-		return nil, err
-	}
-	
-	// 查找匹配的Markdown文件
-	for _, file := range files {
-		if !file.IsDir() && (strings.HasSuffix(file.Name(), ".md") || strings.HasSuffix(file.Name(), ".markdown")) {
-			fileName := file.Name()
-			fileNameWithoutExt := strings.TrimSuffix(fileName, filepath.Ext(fileName))
-			
-			// 如果slug匹配文件名（不包含扩展名）或者是文件名的一部分
-			if fileNameWithoutExt == slug || strings.Contains(fileNameWithoutExt, slug) {
-				filePath := filepath.Join(postsDir, fileName)
-				content, err := ioutil.ReadFile(filePath)
-				if err != nil {
-					return nil, err
-				}
-				
-				// 默认使用文件名作为标题和slug
-				title := fileNameWithoutExt
-				fileSlug := fileNameWithoutExt
-				createdAt := file.ModTime()
-				
-				// 尝试从文件名提取日期和标题（如果是日期-标题格式）
-				datePattern := regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
-				matches := datePattern.FindStringSubmatch(fileNameWithoutExt)
-				if len(matches) == 3 {
-					// 如果文件名符合日期-标题格式
-					dateStr := matches[1]
-					titleFromFileName := matches[2]
-					parsedDate, err := time.Parse("2006-01-02", dateStr)
-					if err == nil {
-						// 如果成功解析日期
-						createdAt = parsedDate
-						title = titleFromFileName
-						fileSlug = titleFromFileName
-					}
-				}
-				
-				// 尝试从内容中提取H1标题作为文章标题
-				contentLines := strings.Split(string(content), "\n")
-				for _, line := range contentLines[:min(5, len(contentLines))] {
-					line = strings.TrimSpace(line)
-					if strings.HasPrefix(line, "# ") {
-						// 找到H1标题，使用它作为文章标题
-						extractedTitle := strings.TrimSpace(strings.TrimPrefix(line, "# "))
-						if extractedTitle != "" {
-							title = extractedTitle
-						}
-						break
-					}
-				}
-				
-				// 生成稳定的ID，基于文件路径
-				hasher := md5.New()
-				hasher.Write([]byte(filePath))
-				id := hex.EncodeToString(hasher.Sum(nil))
-				
-				// 创建文章对象
-				post := &Post{
-					ID:          id,
-					Title:       title,
-					Slug:        fileSlug,
-					Content:     string(content),
-					Author:      "", // 从内容中提取
-					CreatedAt:   createdAt,
-					UpdatedAt:   file.ModTime(),
-					IsMarkdown:  true,
-					Tags:        []string{}, // 从内容中提取
-					Cover:       "", // 从内容中提取
-					IsPublished: true,
-				}
-				
-				// 尝试从内容中提取更多元数据（作者、标签等）
-				for _, line := range contentLines[:min(20, len(contentLines))] {
-					line = strings.TrimSpace(line)
-					
-					// 提取作者信息
-					if strings.HasPrefix(line, "作者:") || strings.HasPrefix(line, "Author:") {
-						post.Author = strings.TrimSpace(strings.Split(line, ":")[1])
-					}
-					
-					// 提取标签信息
-					if strings.HasPrefix(line, "标签:") || strings.HasPrefix(line, "Tags:") {
-						tagsPart := strings.TrimSpace(strings.Split(line, ":")[1])
-						tags := strings.Split(tagsPart, ",")
-						for i, tag := range tags {
-							tags[i] = strings.TrimSpace(tag)
-						}
-						post.Tags = tags
-					}
-					
-					// 提取封面图片
-					if strings.HasPrefix(line, "封面:") || strings.HasPrefix(line, "Cover:") {
-						post.Cover = strings.TrimSpace(strings.Split(line, ":")[1])
-					}
-				}
-				
-				return post, nil
-			}
+			return post, nil
 		}
 	}
 	
@@ -428,9 +442,9 @@ func GetPostBySlug(slug string, basePath string) (*Post, error) {
 }
 
 // DeletePost 删除文章
-func DeletePost(id string, basePath string) error {
+func DeletePost(id string, backend storage.Backend) error {
 	// 获取所有文章
-	posts, err := GetAllPosts(basePath)
+	posts, err := GetAllPosts(backend)
 	if err != nil {
 		return err
 	}
@@ -449,23 +463,16 @@ func DeletePost(id string, basePath string) error {
 	}
 	
 	// 查找并删除对应的Markdown文件
-	postsDir := filepath.Join(basePath, "posts")
-	files, err := ioutil.ReadDir(postsDir)
+	files, err := listPostObjects(backend)
 	if err != nil {
 		return err
 	}
 	
 	for _, file := range files {
-		if !file.IsDir() && (strings.HasSuffix(file.Name(), ".md") || strings.HasSuffix(file.Name(), ".markdown")) {
-			fileNameWithoutExt := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
-			if strings.Contains(fileNameWithoutExt, targetSlug) {
-				// 删除Markdown文件
-				err := os.Remove(filepath.Join(postsDir, file.Name()))
-				if err != nil {
-					return err
-				}
-				return nil
-			}
+		fileNameWithoutExt := strings.TrimSuffix(file.Name, path.Ext(file.Name))
+		if strings.Contains(fileNameWithoutExt, targetSlug) {
+			// 删除Markdown文件
+			return backend.Delete(postsPrefix + file.Name)
 		}
 	}
 	
@@ -473,12 +480,12 @@ func DeletePost(id string, basePath string) error {
 }
 
 // SearchPosts 搜索文章
-func SearchPosts(query string, basePath string) ([]PostSummary, error) {
+func SearchPosts(query string, backend storage.Backend) ([]PostSummary, error) {
 	// 初始化一个空数组，确保即使没有匹配的文章也返回空数组而不是null
 	var results []PostSummary = []PostSummary{}
 	
 	// 获取所有文章
-	posts, err := GetAllPosts(basePath)
+	posts, err := GetAllPosts(backend)
 	if err != nil {
 		return results, err
 	}
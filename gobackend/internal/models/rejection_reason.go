@@ -0,0 +1,202 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// RejectionReason 管理员维护的驳回原因
+type RejectionReason struct {
+	ID          int       `db:"id" json:"id"`
+	Code        string    `db:"code" json:"code"`   // 审批请求中引用的稳定标识，如 duplicate
+	Label       string    `db:"label" json:"label"` // 展示给提交者的名称
+	Description string    `db:"description" json:"description"`
+	Enabled     bool      `db:"enabled" json:"enabled"` // 停用的原因不能再用于新的审批，已有记录不受影响
+	SortOrder   int       `db:"sort_order" json:"sort_order"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// RejectionReasonInput 创建或修改驳回原因的请求
+type RejectionReasonInput struct {
+	Code        string `json:"code"`
+	Label       string `json:"label"`
+	Description string `json:"description"`
+	Enabled     *bool  `json:"enabled"`
+	SortOrder   *int   `json:"sort_order"`
+}
+
+// RejectionTarget 驳回原因针对的对象
+type RejectionTarget string
+
+// 驳回对象常量
+const (
+	RejectionTargetResource RejectionTarget = "resource" // 整个提交
+	RejectionTargetField    RejectionTarget = "field"    // 字段，value 为字段名
+	RejectionTargetImage    RejectionTarget = "image"    // 图片，value 为图片路径
+	RejectionTargetLink     RejectionTarget = "link"     // 链接，value 为链接URL
+)
+
+// IsRejectionTarget 判断是否为支持的驳回对象
+func IsRejectionTarget(target string) bool {
+	switch RejectionTarget(target) {
+	case RejectionTargetResource, RejectionTargetField, RejectionTargetImage, RejectionTargetLink:
+		return true
+	}
+	return false
+}
+
+// AppliedRejectionReason 审批时附加到字段、图片或链接上的驳回原因
+// label 在审批时从目录中复制，之后修改目录不影响已有记录
+type AppliedRejectionReason struct {
+	Target RejectionTarget `json:"target"`
+	Value  string          `json:"value,omitempty"`
+	Code   string          `json:"code"`
+	Label  string          `json:"label"`
+	Note   string          `json:"note,omitempty"` // 给提交者的补充说明
+}
+
+// AppliedRejectionReasons 审批附加的驳回原因列表
+type AppliedRejectionReasons []AppliedRejectionReason
+
+// Value 实现database/sql/driver.Valuer接口
+func (r AppliedRejectionReasons) Value() (driver.Value, error) {
+	if r == nil {
+		return "[]", nil
+	}
+	bytes, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(bytes), nil
+}
+
+// Scan 实现sql.Scanner接口
+func (r *AppliedRejectionReasons) Scan(value interface{}) error {
+	if value == nil {
+		*r = nil
+		return nil
+	}
+
+	var b []byte
+	switch v := value.(type) {
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return errors.New("无法将值扫描为AppliedRejectionReasons：不支持的类型")
+	}
+
+	if len(b) == 0 || string(b) == "null" {
+		*r = nil
+		return nil
+	}
+	return json.Unmarshal(b, r)
+}
+
+// ErrInvalidRejectionReason 审批请求中的驳回原因无效
+var ErrInvalidRejectionReason = errors.New("无效的驳回原因")
+
+var rejectionReasonCodePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// IsRejectionReasonCode 判断驳回原因标识是否合法：小写字母、数字和下划线
+func IsRejectionReasonCode(code string) bool {
+	return rejectionReasonCodePattern.MatchString(code)
+}
+
+// defaultRejectionReasons 新数据库中预置的驳回原因
+var defaultRejectionReasons = []RejectionReason{
+	{Code: "duplicate", Label: "重复提交", Description: "资源、图片或链接已经存在"},
+	{Code: "broken_link", Label: "链接失效", Description: "链接无法访问、已过期或提取码错误"},
+	{Code: "wrong_title", Label: "标题错误", Description: "标题或英文标题与资源不符"},
+	{Code: "wrong_content", Label: "内容不符", Description: "图片或链接的内容与资源不符"},
+	{Code: "low_quality", Label: "图片质量差", Description: "图片模糊、尺寸过小或带有水印"},
+	{Code: "inappropriate", Label: "违规内容", Description: "含有广告、病毒或其他违规内容"},
+	{Code: "other", Label: "其他", Description: "见补充说明"},
+}
+
+// seedRejectionReasons 驳回原因表为空时写入预置原因
+func seedRejectionReasons(db *sqlx.DB) error {
+	var count int
+	if err := db.Get(&count, `SELECT COUNT(*) FROM rejection_reasons`); err != nil {
+		return fmt.Errorf("检查驳回原因失败: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	now := time.Now()
+	for i, r := range defaultRejectionReasons {
+		if _, err := db.Exec(
+			`INSERT INTO rejection_reasons (code, label, description, enabled, sort_order, created_at, updated_at)
+			VALUES (?, ?, ?, 1, ?, ?, ?)`,
+			r.Code, r.Label, r.Description, (i+1)*10, now, now,
+		); err != nil {
+			return fmt.Errorf("写入预置驳回原因失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListRejectionReasons 按排序返回驳回原因，enabledOnly 时只返回启用的原因
+func ListRejectionReasons(enabledOnly bool) ([]RejectionReason, error) {
+	query := `SELECT * FROM rejection_reasons`
+	if enabledOnly {
+		query += ` WHERE enabled = 1`
+	}
+	reasons := []RejectionReason{}
+	if err := DB.Select(&reasons, query+` ORDER BY sort_order, id`); err != nil {
+		return nil, fmt.Errorf("查询驳回原因失败: %w", err)
+	}
+	return reasons, nil
+}
+
+// GetRejectionReason 按ID获取驳回原因
+func GetRejectionReason(id int) (*RejectionReason, error) {
+	var reason RejectionReason
+	if err := DB.Get(&reason, `SELECT * FROM rejection_reasons WHERE id = ?`, id); err != nil {
+		return nil, err
+	}
+	return &reason, nil
+}
+
+// ResolveRejectionReasons 校验审批请求中的驳回原因并从目录中补全名称
+// 原因标识不存在或已停用、驳回对象无效、非整体驳回缺少 value 时返回 ErrInvalidRejectionReason
+func ResolveRejectionReasons(applied []AppliedRejectionReason) (AppliedRejectionReasons, error) {
+	if len(applied) == 0 {
+		return nil, nil
+	}
+	reasons, err := ListRejectionReasons(true)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string, len(reasons))
+	for _, r := range reasons {
+		labels[r.Code] = r.Label
+	}
+
+	resolved := make(AppliedRejectionReasons, 0, len(applied))
+	for _, a := range applied {
+		if !IsRejectionTarget(string(a.Target)) {
+			return nil, fmt.Errorf("%w: 不支持的驳回对象 %q", ErrInvalidRejectionReason, a.Target)
+		}
+		if a.Target == RejectionTargetResource {
+			a.Value = ""
+		} else if a.Value == "" {
+			return nil, fmt.Errorf("%w: 驳回%s时需要指定 value", ErrInvalidRejectionReason, a.Target)
+		}
+		label, ok := labels[a.Code]
+		if !ok {
+			return nil, fmt.Errorf("%w: 驳回原因 %q 不存在或已停用", ErrInvalidRejectionReason, a.Code)
+		}
+		a.Label = label
+		resolved = append(resolved, a)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,47 @@
+package models
+
+// 差异条目类型
+const (
+	DiffEntryField = "field" // 标题、英文标题、简介或海报的修改
+	DiffEntryImage = "image" // 新增的图片
+	DiffEntryLink  = "link"  // 新增的链接
+)
+
+// 差异的来源
+const (
+	DiffKindSupplement = "supplement" // 已审批资源的一份待审批补充内容
+	DiffKindEdit       = "edit"       // 修改已有资源的待审批资源（original_resource_id 指向原资源）
+	DiffKindNew        = "new"        // 新提交的待审批资源，与空资源比较
+)
+
+// ResourceDiffEntry 待审批内容与现有资源的一处差异
+// ID 由条目内容计算，同一待审批内容多次生成的ID相同，审批时可以只提交条目ID
+type ResourceDiffEntry struct {
+	ID             string           `json:"id"`
+	Type           string           `json:"type"`
+	Field          string           `json:"field,omitempty"`    // field 类型的字段名
+	Category       string           `json:"category,omitempty"` // link 类型的链接分类
+	Old            *string          `json:"old,omitempty"`      // field 类型修改前的值
+	New            string           `json:"new,omitempty"`      // field 类型修改后的值，image 类型为图片路径
+	Link           *Link            `json:"link,omitempty"`
+	Duplicate      *LinkDuplicate   `json:"duplicate,omitempty"` // 链接与已有链接重复时的已有链接
+	NearDuplicates []ImageDuplicate `json:"near_duplicates,omitempty"`
+}
+
+// ResourceDiffSummary 差异条目的统计
+type ResourceDiffSummary struct {
+	Fields         int `json:"fields"`
+	Images         int `json:"images"`
+	Links          int `json:"links"`
+	DuplicateLinks int `json:"duplicate_links"`
+}
+
+// ResourceDiff 资源待审批内容与现有资源的结构化差异
+type ResourceDiff struct {
+	ResourceID     int                 `json:"resource_id"`
+	BaseResourceID *int                `json:"base_resource_id"`        // 比较的现有资源，新资源为null
+	SupplementID   *int                `json:"supplement_id,omitempty"` // supplement 类型比较的补充内容
+	Kind           string              `json:"kind"`
+	Entries        []ResourceDiffEntry `json:"entries"`
+	Summary        ResourceDiffSummary `json:"summary"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Submission 提交者凭跟踪令牌查询的一次提交：新资源（supplement_id 为空）或一份补充内容
+// 审批时同步更新状态和驳回原因；不关联外键，资源被删除后提交者仍能查到结果
+type Submission struct {
+	Token            string                  `db:"token" json:"-"`
+	ResourceID       int                     `db:"resource_id" json:"resource_id"`
+	SupplementID     *int                    `db:"supplement_id" json:"supplement_id,omitempty"`
+	Status           ResourceStatus          `db:"status" json:"status"`
+	RejectionReasons AppliedRejectionReasons `db:"rejection_reasons" json:"rejection_reasons"`
+	ReviewedAt       *time.Time              `db:"reviewed_at" json:"reviewed_at"`
+	CreatedAt        time.Time               `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time               `db:"updated_at" json:"updated_at"`
+}
+
+// CreateSubmission 为新提交的资源或补充内容生成跟踪令牌
+func CreateSubmission(resourceID int, supplementID *int) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成跟踪令牌失败: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	now := time.Now().UTC()
+	_, err := DB.Exec(
+		`INSERT INTO submissions (token, resource_id, supplement_id, status, rejection_reasons, created_at, updated_at)
+		VALUES (?, ?, ?, ?, '[]', ?, ?)`,
+		token, resourceID, supplementID, ResourceStatusPending, now, now)
+	if err != nil {
+		return "", fmt.Errorf("保存跟踪令牌失败: %w", err)
+	}
+	return token, nil
+}
+
+// GetSubmission 按跟踪令牌查询提交
+func GetSubmission(token string) (*Submission, error) {
+	var s Submission
+	if err := DB.Get(&s, `SELECT * FROM submissions WHERE token = ?`, token); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ReviewSubmission 审批后更新提交的状态和驳回原因，supplementID 为空时更新新资源的提交
+// 撤销审批时 status 为待审批，同时清空审批时间；审批前提交的资源没有跟踪令牌，此时不更新任何记录
+func ReviewSubmission(e sqlx.Execer, resourceID int, supplementID *int, status ResourceStatus, reasons AppliedRejectionReasons) error {
+	now := time.Now().UTC()
+	var reviewedAt *time.Time
+	if status != ResourceStatusPending {
+		reviewedAt = &now
+	}
+	_, err := e.Exec(
+		`UPDATE submissions SET status = ?, rejection_reasons = ?, reviewed_at = ?, updated_at = ?
+		WHERE resource_id = ? AND supplement_id IS ?`,
+		status, reasons, reviewedAt, now, resourceID, supplementID)
+	if err != nil {
+		return fmt.Errorf("更新资源 %d 的提交状态失败: %w", resourceID, err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Supplement 访客为已有资源提交的一份补充内容（图片和链接），每份补充内容单独审批
+// 登录用户提交时记录 contributor_id，匿名提交时记录 contributor_token
+type Supplement struct {
+	ID               int              `db:"id" json:"id"`
+	ResourceID       int              `db:"resource_id" json:"resource_id"`
+	ContributorID    *int             `db:"contributor_id" json:"contributor_id"`
+	ContributorToken *string          `db:"contributor_token" json:"contributor_token"`
+	ContributorName  *string          `db:"contributor_name" json:"contributor_name"` // 查询时关联 users 表得到，不存储
+	Images           JsonList         `db:"images" json:"images"`
+	Links            JsonMap          `db:"links" json:"links"`
+	Status           ResourceStatus   `db:"status" json:"status"`
+	Notes            string           `db:"notes" json:"notes"`
+	ApprovalRecordID *int64           `db:"approval_record_id" json:"approval_record_id"`
+	ReviewedBy       *string          `db:"reviewed_by" json:"reviewed_by"`
+	ReviewedAt       *time.Time       `db:"reviewed_at" json:"reviewed_at"`
+	CreatedAt        time.Time        `db:"created_at" json:"created_at"`
+	UpdatedAt        time.Time        `db:"updated_at" json:"updated_at"`
+	NearDuplicates   []ImageDuplicate `db:"-" json:"near_duplicates,omitempty"` // 不存储在数据库中，补充图片的近似重复图片
+}
+
+// supplementSelect 查询补充内容并带上登录贡献者的用户名
+const supplementSelect = `SELECT s.*, u.username AS contributor_name FROM supplements s LEFT JOIN users u ON u.id = s.contributor_id`
+
+// AsJsonMap 转换为旧的 resources.supplement 格式，列表接口中作为资源的 supplement 字段返回
+func (s *Supplement) AsJsonMap() JsonMap {
+	images := s.Images
+	if images == nil {
+		images = JsonList{}
+	}
+	links := s.Links
+	if links == nil {
+		links = JsonMap{}
+	}
+	return JsonMap{
+		"id":               s.ID,
+		"images":           images,
+		"links":            links,
+		"status":           string(s.Status),
+		"notes":            s.Notes,
+		"contributor_id":   s.ContributorID,
+		"contributor_name": s.ContributorName,
+		"submission_date":  s.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// CreateSupplement 保存一份待审批的补充内容
+func CreateSupplement(s *Supplement) error {
+	s.Status = ResourceStatusPending
+	s.CreatedAt = time.Now().UTC()
+	s.UpdatedAt = s.CreatedAt
+	result, err := DB.Exec(
+		`INSERT INTO supplements (resource_id, contributor_id, contributor_token, images, links, status, notes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.ResourceID, s.ContributorID, s.ContributorToken, s.Images, s.Links, s.Status, s.Notes, s.CreatedAt, s.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("保存补充内容失败: %w", err)
+	}
+	id, _ := result.LastInsertId()
+	s.ID = int(id)
+	return nil
+}
+
+// GetSupplement 按ID查询补充内容
+func GetSupplement(id int) (*Supplement, error) {
+	var s Supplement
+	if err := DB.Get(&s, supplementSelect+` WHERE s.id = ?`, id); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListSupplements 按提交时间返回资源的补充内容，status 为空时返回全部状态
+func ListSupplements(resourceID int, status ResourceStatus) ([]Supplement, error) {
+	query := supplementSelect + ` WHERE s.resource_id = ?`
+	args := []interface{}{resourceID}
+	if status != "" {
+		query += ` AND s.status = ?`
+		args = append(args, status)
+	}
+	supplements := []Supplement{}
+	if err := DB.Select(&supplements, query+` ORDER BY s.created_at, s.id`, args...); err != nil {
+		return nil, fmt.Errorf("查询资源 %d 的补充内容失败: %w", resourceID, err)
+	}
+	return supplements, nil
+}
+
+// ListPendingSupplements 按提交时间返回全部待审批的补充内容
+func ListPendingSupplements() ([]Supplement, error) {
+	supplements := []Supplement{}
+	err := DB.Select(&supplements, supplementSelect+` WHERE s.status = ? ORDER BY s.created_at, s.id`, ResourceStatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("查询待审批补充内容失败: %w", err)
+	}
+	return supplements, nil
+}
+
+// ReviewSupplement 在审批事务中把待审批的补充内容标记为已通过或已驳回并关联审批记录
+// 补充内容已被审批（如重复提交或并发审批）时返回 sql.ErrNoRows
+func ReviewSupplement(tx *sqlx.Tx, id int, status ResourceStatus, recordID int64, reviewedBy string) error {
+	now := time.Now().UTC()
+	result, err := tx.Exec(
+		`UPDATE supplements SET status = ?, approval_record_id = ?, reviewed_by = ?, reviewed_at = ?, updated_at = ?
+		WHERE id = ? AND status = ?`,
+		status, recordID, reviewedBy, now, now, id, ResourceStatusPending)
+	if err != nil {
+		return fmt.Errorf("更新补充内容 %d 失败: %w", id, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ReopenSupplement 撤销审批后把关联该审批记录的补充内容恢复为待审批，审批时已移动的图片换成新路径
+// 没有关联的补充内容时返回nil
+func ReopenSupplement(tx *sqlx.Tx, recordID int, renamed map[string]string) (*int, error) {
+	var s Supplement
+	err := tx.Get(&s, `SELECT * FROM supplements WHERE approval_record_id = ?`, recordID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询审批记录 %d 的补充内容失败: %w", recordID, err)
+	}
+	for i, img := range s.Images {
+		if newPath, ok := renamed[img]; ok {
+			s.Images[i] = newPath
+		}
+	}
+	_, err = tx.Exec(
+		`UPDATE supplements SET status = ?, images = ?, approval_record_id = NULL, reviewed_by = NULL, reviewed_at = NULL, updated_at = ?
+		WHERE id = ?`,
+		ResourceStatusPending, s.Images, time.Now().UTC(), s.ID)
+	if err != nil {
+		return nil, fmt.Errorf("恢复补充内容 %d 失败: %w", s.ID, err)
+	}
+	return &s.ID, nil
+}
+
+// migrateLegacySupplements 把旧的 resources.supplement 列中的补充内容迁移到 supplements 表并清空该列
+// 旧数据没有记录贡献者；迁移后的列为NULL，重复执行不会重复迁移
+func migrateLegacySupplements(db *sqlx.DB) error {
+	var rows []struct {
+		ID         int     `db:"id"`
+		Supplement JsonMap `db:"supplement"`
+	}
+	if err := db.Select(&rows, `SELECT id, supplement FROM resources WHERE supplement IS NOT NULL`); err != nil {
+		return fmt.Errorf("查询旧补充内容失败: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	migrated := 0
+	for _, row := range rows {
+		if len(row.Supplement) > 0 {
+			if err := insertLegacySupplement(tx, row.ID, row.Supplement); err != nil {
+				return err
+			}
+			migrated++
+		}
+		if _, err := tx.Exec(`UPDATE resources SET supplement = NULL WHERE id = ?`, row.ID); err != nil {
+			return fmt.Errorf("清空资源 %d 的补充内容失败: %w", row.ID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交事务失败: %w", err)
+	}
+	log.Printf("已将 %d 份旧补充内容迁移到 supplements 表", migrated)
+	return nil
+}
+
+// insertLegacySupplement 按旧格式 {"images", "links", "status", "submission_date"} 插入一份补充内容
+func insertLegacySupplement(tx *sqlx.Tx, resourceID int, legacy JsonMap) error {
+	var images JsonList
+	if list, ok := legacy["images"].([]interface{}); ok {
+		for _, item := range list {
+			if img, ok := item.(string); ok && img != "" {
+				images = append(images, img)
+			}
+		}
+	}
+	links := JsonMap{}
+	if m, ok := legacy["links"].(map[string]interface{}); ok {
+		links = JsonMap(m)
+	}
+	status := ResourceStatusPending
+	if s, ok := legacy["status"].(string); ok && s != "" {
+		status = ResourceStatus(strings.ToUpper(s))
+	}
+	createdAt := time.Now().UTC()
+	if s, ok := legacy["submission_date"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			createdAt = t.UTC()
+		}
+	}
+
+	_, err := tx.Exec(
+		`INSERT INTO supplements (resource_id, images, links, status, notes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		resourceID, images, links, status, "", createdAt, createdAt)
+	if err != nil {
+		return fmt.Errorf("迁移资源 %d 的补充内容失败: %w", resourceID, err)
+	}
+	return nil
+}
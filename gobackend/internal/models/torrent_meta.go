@@ -0,0 +1,44 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SaveTorrentMeta 保存上传的种子文件解析出的元数据，key 为磁力链接的规范键（见 utils.LinkKey）
+// 提交同一哈希的磁力链接时据此补全文件列表
+func SaveTorrentMeta(key string, meta *LinkMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("序列化种子信息失败: %w", err)
+	}
+	_, err = DB.Exec(
+		`INSERT INTO torrent_meta (link_key, meta, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(link_key) DO UPDATE SET meta = excluded.meta, updated_at = excluded.updated_at`,
+		key, string(data), time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("保存种子信息失败: %w", err)
+	}
+	return nil
+}
+
+// GetTorrentMeta 按磁力链接的规范键查询种子元数据，没有上传过种子时返回nil
+func GetTorrentMeta(key string) (*LinkMeta, error) {
+	var data string
+	err := DB.Get(&data, `SELECT meta FROM torrent_meta WHERE link_key = ?`, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询种子信息失败: %w", err)
+	}
+	var meta LinkMeta
+	if err := json.Unmarshal([]byte(data), &meta); err != nil {
+		return nil, fmt.Errorf("解析种子信息失败: %w", err)
+	}
+	return &meta, nil
+}
@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalBackend 本地文件系统存储后端
+type LocalBackend struct {
+	root    string
+	baseURL string
+}
+
+// NewLocalBackend 创建本地存储后端
+// root 为资源根目录，baseURL 为对外访问前缀，如 "/assets"
+func NewLocalBackend(root, baseURL string) *LocalBackend {
+	return &LocalBackend{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// LocalPath 返回对象在本地文件系统中的路径
+func (b *LocalBackend) LocalPath(key string) string {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		// 非法key映射到一个不存在的位置，避免越出根目录
+		return filepath.Join(b.root, ".invalid")
+	}
+	return filepath.Join(b.root, filepath.FromSlash(cleaned))
+}
+
+// Put 写入对象，先写临时文件再重命名，保证写入的原子性
+func (b *LocalBackend) Put(key string, r io.Reader, size int64, contentType string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	dstPath := filepath.Join(b.root, filepath.FromSlash(cleaned))
+
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(dstPath), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+
+	if _, err := io.Copy(tmpFile, r); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		log.Printf("警告：设置文件权限失败 %s: %v", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("保存文件失败: %w", err)
+	}
+	return nil
+}
+
+// Get 读取对象
+func (b *LocalBackend) Get(key string) (io.ReadCloser, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filepath.Join(b.root, filepath.FromSlash(cleaned)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+	return file, nil
+}
+
+// Stat 获取对象元信息
+func (b *LocalBackend) Stat(key string) (*ObjectInfo, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	fullPath := filepath.Join(b.root, filepath.FromSlash(cleaned))
+
+	fileInfo, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotExist
+		}
+		return nil, fmt.Errorf("获取文件信息失败: %w", err)
+	}
+	if fileInfo.IsDir() {
+		return nil, ErrNotExist
+	}
+
+	// 读取文件头检测真实类型（图片转换后可能保留原扩展名）
+	head := make([]byte, 512)
+	n := 0
+	if file, err := os.Open(fullPath); err == nil {
+		n, _ = io.ReadFull(file, head)
+		file.Close()
+	}
+
+	return &ObjectInfo{
+		Key:          cleaned,
+		Size:         fileInfo.Size(),
+		ContentType:  detectContentType(cleaned, head[:n]),
+		ETag:         fmt.Sprintf("\"%x-%x\"", fileInfo.ModTime().UnixNano(), fileInfo.Size()),
+		LastModified: fileInfo.ModTime(),
+	}, nil
+}
+
+// List 遍历前缀下的所有文件，跳过写入中的临时文件
+func (b *LocalBackend) List(prefix string, fn func(info ObjectInfo) error) error {
+	dir := b.root
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		cleaned, err := CleanKey(prefix)
+		if err != nil {
+			return err
+		}
+		dir = filepath.Join(b.root, filepath.FromSlash(cleaned))
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") || strings.HasSuffix(d.Name(), ".tmp") {
+			return nil
+		}
+		fileInfo, err := d.Info()
+		if err != nil {
+			return nil // 文件在遍历期间被删除
+		}
+		rel, err := filepath.Rel(b.root, p)
+		if err != nil {
+			return nil
+		}
+		return fn(ObjectInfo{
+			Key:          filepath.ToSlash(rel),
+			Size:         fileInfo.Size(),
+			LastModified: fileInfo.ModTime(),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("遍历目录失败: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除对象
+func (b *LocalBackend) Delete(key string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(b.root, filepath.FromSlash(cleaned))); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除文件失败: %w", err)
+	}
+	return nil
+}
+
+// URL 返回对象的访问URL
+func (b *LocalBackend) URL(key string) string {
+	return b.baseURL + "/" + strings.TrimPrefix(key, "/")
+}
+
+// Move 移动对象，优先使用重命名，跨设备时退化为复制后删除
+func (b *LocalBackend) Move(srcKey, dstKey string) error {
+	srcPath := b.LocalPath(srcKey)
+	dstPath := b.LocalPath(dstKey)
+
+	if _, err := os.Stat(srcPath); os.IsNotExist(err) {
+		return ErrNotExist
+	}
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+
+	if err := os.Rename(srcPath, dstPath); err == nil {
+		return nil
+	}
+
+	// 重命名失败（例如跨设备），复制后删除
+	srcFile, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("打开源文件失败: %w", err)
+	}
+	err = b.Put(dstKey, srcFile, -1, "")
+	srcFile.Close()
+	if err != nil {
+		return fmt.Errorf("复制文件失败: %w", err)
+	}
+
+	if err := os.Remove(srcPath); err != nil {
+		log.Printf("警告：无法删除源文件 %s: %v，将重试", srcPath, err)
+		time.Sleep(100 * time.Millisecond)
+		if err := os.Remove(srcPath); err != nil {
+			log.Printf("警告：第二次尝试删除源文件 %s 失败: %v", srcPath, err)
+		}
+	}
+	return nil
+}
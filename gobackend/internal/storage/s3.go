@@ -0,0 +1,381 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config S3兼容存储配置
+type S3Config struct {
+	Endpoint  string // 服务地址，如 http://127.0.0.1:9000 或 https://s3.amazonaws.com
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PublicURL string // 对外访问对象的基础URL，为空时使用 /assets 由API转发
+	PathStyle bool   // 使用 endpoint/bucket/key 形式访问，MinIO需要开启
+}
+
+// S3Backend S3兼容存储后端（AWS S3、MinIO等），使用SigV4签名
+type S3Backend struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3Backend 创建S3兼容存储后端
+func NewS3Backend(cfg S3Config) (*S3Backend, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("S3_ENDPOINT 和 S3_BUCKET 不能为空")
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("S3_ACCESS_KEY 和 S3_SECRET_KEY 不能为空")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("无效的S3_ENDPOINT: %s", cfg.Endpoint)
+	}
+
+	return &S3Backend{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// Put 上传对象
+func (b *S3Backend) Put(key string, r io.Reader, size int64, contentType string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	// 资源文件通常较小，读入内存以便计算载荷哈希
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("读取上传内容失败: %w", err)
+	}
+	if contentType == "" {
+		head := data
+		if len(head) > 512 {
+			head = head[:512]
+		}
+		contentType = detectContentType(cleaned, head)
+	}
+
+	req, err := b.newRequest(http.MethodPut, cleaned, data)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.ContentLength = int64(len(data))
+
+	resp, err := b.do(req, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return b.responseError("上传对象", cleaned, resp)
+	}
+	return nil
+}
+
+// Get 下载对象
+func (b *S3Backend) Get(key string) (io.ReadCloser, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := b.newRequest(http.MethodGet, cleaned, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.do(req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotExist
+	default:
+		defer resp.Body.Close()
+		return nil, b.responseError("下载对象", cleaned, resp)
+	}
+}
+
+// Stat 获取对象元信息
+func (b *S3Backend) Stat(key string) (*ObjectInfo, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := b.newRequest(http.MethodHead, cleaned, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.do(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotExist
+	default:
+		return nil, b.responseError("获取对象信息", cleaned, resp)
+	}
+
+	info := &ObjectInfo{
+		Key:         cleaned,
+		ContentType: resp.Header.Get("Content-Type"),
+		ETag:        resp.Header.Get("ETag"),
+		Size:        -1,
+	}
+	if size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+		info.Size = size
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.LastModified = modified
+	}
+	return info, nil
+}
+
+// Delete 删除对象
+func (b *S3Backend) Delete(key string) error {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	req, err := b.newRequest(http.MethodDelete, cleaned, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := b.do(req, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return b.responseError("删除对象", cleaned, resp)
+	}
+}
+
+// s3ListResult ListObjectsV2 响应
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		ETag         string    `xml:"ETag"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+// List 使用 ListObjectsV2 分页遍历前缀下的所有对象
+func (b *S3Backend) List(prefix string, fn func(info ObjectInfo) error) error {
+	prefix = strings.TrimPrefix(prefix, "/")
+	token := ""
+	for {
+		req, err := b.newRequest(http.MethodGet, "", nil)
+		if err != nil {
+			return err
+		}
+		// SigV4要求查询参数按名称排序并严格编码
+		query := "list-type=2"
+		if token != "" {
+			query = "continuation-token=" + s3EscapeQuery(token) + "&" + query
+		}
+		if prefix != "" {
+			query += "&prefix=" + s3EscapeQuery(prefix)
+		}
+		req.URL.RawQuery = query
+
+		resp, err := b.do(req, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := b.responseError("列出对象", prefix, resp)
+			resp.Body.Close()
+			return err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("解析对象列表失败: %w", err)
+		}
+
+		for _, obj := range result.Contents {
+			if strings.HasSuffix(obj.Key, "/") {
+				continue
+			}
+			if err := fn(ObjectInfo{Key: obj.Key, Size: obj.Size, ETag: obj.ETag, LastModified: obj.LastModified}); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// URL 返回对象的访问URL
+func (b *S3Backend) URL(key string) string {
+	key = strings.TrimPrefix(key, "/")
+	if b.cfg.PublicURL != "" {
+		return strings.TrimSuffix(b.cfg.PublicURL, "/") + "/" + key
+	}
+	return AssetPath(key)
+}
+
+// newRequest 构造对象请求
+func (b *S3Backend) newRequest(method, key string, body []byte) (*http.Request, error) {
+	u := *b.endpoint
+	escapedKey := s3EscapePath(key)
+	basePath := strings.TrimSuffix(u.Path, "/")
+
+	if b.cfg.PathStyle {
+		u.Path = basePath + "/" + b.cfg.Bucket + "/" + key
+		u.RawPath = basePath + "/" + s3EscapePath(b.cfg.Bucket) + "/" + escapedKey
+	} else {
+		u.Host = b.cfg.Bucket + "." + u.Host
+		u.Path = basePath + "/" + key
+		u.RawPath = basePath + "/" + escapedKey
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, fmt.Errorf("创建S3请求失败: %w", err)
+	}
+	return req, nil
+}
+
+// do 签名并发送请求
+func (b *S3Backend) do(req *http.Request, body []byte) (*http.Response, error) {
+	b.sign(req, body, time.Now().UTC())
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求S3失败: %w", err)
+	}
+	return resp, nil
+}
+
+// sign 使用AWS Signature Version 4对请求签名
+func (b *S3Backend) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	payloadHash := sha256Hex(body)
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := dateStamp + "/" + b.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+b.cfg.SecretKey), dateStamp)
+	signingKey = hmacSHA256(signingKey, b.cfg.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.cfg.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+// responseError 将S3错误响应转换为error
+func (b *S3Backend) responseError(action, key string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("%s %s 失败: HTTP %d %s", action, key, resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// s3EscapePath 按SigV4规则对路径进行URI编码，保留 "/"
+func s3EscapePath(p string) string {
+	var sb strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+// s3EscapeQuery 按SigV4规则对查询参数值进行URI编码，"/" 也需要编码
+func s3EscapeQuery(v string) string {
+	return strings.ReplaceAll(s3EscapePath(v), "/", "%2F")
+}
+
+// sha256Hex 计算SHA-256并返回十六进制字符串
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 计算HMAC-SHA256
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
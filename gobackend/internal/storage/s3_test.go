@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestS3Backend 连接 S3_TEST_ENDPOINT 指定的MinIO，未设置时跳过测试
+func newTestS3Backend(t *testing.T) *S3Backend {
+	t.Helper()
+
+	endpoint := os.Getenv("S3_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("未设置 S3_TEST_ENDPOINT，跳过MinIO集成测试")
+	}
+	cfg := S3Config{
+		Endpoint:  endpoint,
+		Region:    os.Getenv("S3_TEST_REGION"),
+		Bucket:    envOrDefault("S3_TEST_BUCKET", "dongman-test"),
+		AccessKey: envOrDefault("S3_TEST_ACCESS_KEY", "minioadmin"),
+		SecretKey: envOrDefault("S3_TEST_SECRET_KEY", "minioadmin"),
+		PathStyle: true,
+	}
+	backend, err := NewS3Backend(cfg)
+	if err != nil {
+		t.Fatalf("创建S3后端失败: %v", err)
+	}
+
+	// 存储桶不存在时创建，已存在返回409
+	req, err := backend.newRequest(http.MethodPut, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := backend.do(req, nil)
+	if err != nil {
+		t.Fatalf("创建存储桶失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+		t.Fatalf("创建存储桶失败: HTTP %d", resp.StatusCode)
+	}
+	return backend
+}
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func TestS3BackendMinIO(t *testing.T) {
+	backend := newTestS3Backend(t)
+
+	// 每次运行使用独立前缀，避免与其他运行互相影响
+	prefix := fmt.Sprintf("test-%d/", time.Now().UnixNano())
+	key := prefix + "imgs/封面 1.txt"
+	body := []byte("hello minio")
+	t.Cleanup(func() {
+		List(backend, prefix, func(info ObjectInfo) error {
+			return backend.Delete(info.Key)
+		})
+	})
+
+	if err := backend.Put(key, bytes.NewReader(body), int64(len(body)), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	reader, err := backend.Get(key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		t.Fatalf("读取对象失败: %v", err)
+	}
+	if !bytes.Equal(got, body) {
+		t.Fatalf("Get 内容 = %q，期望 %q", got, body)
+	}
+
+	info, err := backend.Stat(key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Size != int64(len(body)) {
+		t.Errorf("Stat 大小 = %d，期望 %d", info.Size, len(body))
+	}
+	if !strings.HasPrefix(info.ContentType, "text/plain") {
+		t.Errorf("Stat 类型 = %q，期望 text/plain", info.ContentType)
+	}
+
+	copied := prefix + "imgs/copy.txt"
+	if err := Copy(backend, key, copied); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	moved := prefix + "other/moved.txt"
+	if err := Move(backend, copied, moved); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if Exists(backend, copied) {
+		t.Errorf("Move 后源对象 %s 仍存在", copied)
+	}
+
+	var keys []string
+	err = List(backend, prefix, func(info ObjectInfo) error {
+		keys = append(keys, info.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	sort.Strings(keys)
+	want := []string{key, moved}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("List = %v，期望 %v", keys, want)
+	}
+
+	if err := backend.Delete(key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := backend.Get(key); !errors.Is(err, ErrNotExist) {
+		t.Errorf("删除后 Get 错误 = %v，期望 ErrNotExist", err)
+	}
+	if _, err := backend.Stat(key); !errors.Is(err, ErrNotExist) {
+		t.Errorf("删除后 Stat 错误 = %v，期望 ErrNotExist", err)
+	}
+	// 删除不存在的对象不报错
+	if err := backend.Delete(key); err != nil {
+		t.Errorf("重复 Delete: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"dongman/internal/config"
+)
+
+// ErrNotExist 对象不存在
+var ErrNotExist = errors.New("对象不存在")
+
+// AssetURLPrefix 数据库中保存的资源路径前缀
+const AssetURLPrefix = "/assets/"
+
+// ObjectInfo 对象元信息
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	ETag         string
+	LastModified time.Time
+}
+
+// Backend 对象存储后端接口
+// key 均为相对于资源根目录的路径，如 "uploads/20250101/xxx.jpg"、"imgs/12/xxx.jpg"
+type Backend interface {
+	// Put 写入对象，size 未知时传 -1，contentType 为空时自动检测
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象，对象不存在时返回 ErrNotExist
+	Get(key string) (io.ReadCloser, error)
+	// Stat 获取对象元信息，对象不存在时返回 ErrNotExist
+	Stat(key string) (*ObjectInfo, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(key string) error
+	// URL 返回对象对外访问的URL
+	URL(key string) string
+}
+
+// LocalPather 由可以直接访问本地文件的后端实现
+type LocalPather interface {
+	LocalPath(key string) string
+}
+
+// Lister 由可以列出对象的后端实现
+type Lister interface {
+	// List 遍历key以 prefix 开头的所有对象，fn 返回错误时停止遍历
+	List(prefix string, fn func(info ObjectInfo) error) error
+}
+
+// ErrListUnsupported 存储后端不支持列出对象
+var ErrListUnsupported = errors.New("存储后端不支持列出对象")
+
+var (
+	current Backend
+	mu      sync.RWMutex
+)
+
+// Init 根据配置初始化全局存储后端
+func Init() error {
+	var backend Backend
+
+	switch strings.ToLower(config.StorageBackend) {
+	case "", "local":
+		backend = NewLocalBackend(config.GetAssetsDir(), "/assets")
+	case "s3", "minio":
+		s3Backend, err := NewS3Backend(S3Config{
+			Endpoint:  config.S3Endpoint,
+			Region:    config.S3Region,
+			Bucket:    config.S3Bucket,
+			AccessKey: config.S3AccessKey,
+			SecretKey: config.S3SecretKey,
+			PublicURL: config.S3PublicURL,
+			PathStyle: config.S3PathStyle,
+		})
+		if err != nil {
+			return fmt.Errorf("初始化S3存储失败: %w", err)
+		}
+		backend = s3Backend
+	default:
+		return fmt.Errorf("不支持的存储后端: %s", config.StorageBackend)
+	}
+
+	SetDefault(backend)
+	log.Printf("存储后端已初始化: %s", config.StorageBackend)
+	return nil
+}
+
+// SetDefault 设置全局存储后端
+func SetDefault(backend Backend) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = backend
+}
+
+// Default 获取全局存储后端，未初始化时使用本地存储
+func Default() Backend {
+	mu.RLock()
+	backend := current
+	mu.RUnlock()
+	if backend != nil {
+		return backend
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if current == nil {
+		current = NewLocalBackend(config.GetAssetsDir(), "/assets")
+	}
+	return current
+}
+
+// CleanKey 规范化对象key，拒绝跳出资源根目录的路径
+func CleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, "\\", "/")
+	key = strings.TrimPrefix(key, "/")
+	if key == "" {
+		return "", errors.New("对象key为空")
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("非法的对象key: %s", key)
+		}
+	}
+	cleaned := path.Clean(key)
+	if cleaned == "." || cleaned == "/" {
+		return "", fmt.Errorf("非法的对象key: %s", key)
+	}
+	return cleaned, nil
+}
+
+// KeyFromAssetPath 将 /assets/... 形式的资源路径转换为对象key
+func KeyFromAssetPath(assetPath string) (string, bool) {
+	if !strings.HasPrefix(assetPath, AssetURLPrefix) {
+		return "", false
+	}
+	key, err := CleanKey(strings.TrimPrefix(assetPath, AssetURLPrefix))
+	if err != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// AssetPath 将对象key转换为数据库中保存的 /assets/... 路径
+func AssetPath(key string) string {
+	return AssetURLPrefix + strings.TrimPrefix(key, "/")
+}
+
+// Exists 判断对象是否存在
+func Exists(backend Backend, key string) bool {
+	_, err := backend.Stat(key)
+	return err == nil
+}
+
+// List 遍历前缀下的所有对象，后端不支持时返回 ErrListUnsupported
+func List(backend Backend, prefix string, fn func(info ObjectInfo) error) error {
+	lister, ok := backend.(Lister)
+	if !ok {
+		return ErrListUnsupported
+	}
+	return lister.List(prefix, fn)
+}
+
+// Move 移动对象，后端支持时直接重命名，否则复制后删除
+func Move(backend Backend, srcKey, dstKey string) error {
+	if srcKey == dstKey {
+		return nil
+	}
+
+	if mover, ok := backend.(interface{ Move(src, dst string) error }); ok {
+		return mover.Move(srcKey, dstKey)
+	}
+
+	if err := Copy(backend, srcKey, dstKey); err != nil {
+		return err
+	}
+
+	if err := backend.Delete(srcKey); err != nil {
+		log.Printf("警告：删除源对象 %s 失败: %v", srcKey, err)
+	}
+	return nil
+}
+
+// Copy 复制对象，源对象保持不变
+func Copy(backend Backend, srcKey, dstKey string) error {
+	if srcKey == dstKey {
+		return nil
+	}
+
+	reader, err := backend.Get(srcKey)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	info, _ := backend.Stat(srcKey)
+	size, contentType := int64(-1), ""
+	if info != nil {
+		size, contentType = info.Size, info.ContentType
+	}
+
+	if err := backend.Put(dstKey, reader, size, contentType); err != nil {
+		return fmt.Errorf("复制对象失败: %w", err)
+	}
+	return nil
+}
+
+// detectContentType 根据内容和扩展名检测MIME类型
+func detectContentType(key string, head []byte) string {
+	contentType := http.DetectContentType(head)
+	if contentType == "application/octet-stream" || strings.HasPrefix(contentType, "text/plain") {
+		if byExt := contentTypeByExt(key); byExt != "" {
+			return byExt
+		}
+	}
+	return contentType
+}
+
+// contentTypeByExt 根据扩展名推断MIME类型
+func contentTypeByExt(key string) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".ico":
+		return "image/x-icon"
+	case ".svg":
+		return "image/svg+xml"
+	case ".avif":
+		return "image/avif"
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	case ".json":
+		return "application/json"
+	case ".pdf":
+		return "application/pdf"
+	}
+	return ""
+}
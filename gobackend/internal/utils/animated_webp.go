@@ -0,0 +1,319 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+
+	"github.com/chai2010/webp"
+)
+
+// AnimatedWebPOptions 动画WebP编码参数
+type AnimatedWebPOptions struct {
+	Lossless bool    // 每帧使用无损编码
+	Mixed    bool    // 每帧分别尝试有损和无损编码并取较小者，优先于 Lossless
+	Quality  float32 // 有损编码质量(0-100)
+}
+
+// 动画WebP的帧偏移以2像素为单位存储
+const animFrameOffsetAlign = 2
+
+// webpFrame 待写入ANMF块的单帧数据
+type webpFrame struct {
+	rect     image.Rectangle
+	duration int    // 毫秒
+	data     []byte // ALPH + VP8 或 VP8L 块
+	hasAlpha bool
+}
+
+// EncodeAnimatedWebP 将动画GIF编码为动画WebP（VP8X + ANIM + ANMF）
+// GIF的各帧先按处置方式(disposal)合成到画布上，再只编码与上一帧相比发生变化的区域，
+// 因此GIF的帧延迟、处置方式和循环次数在播放效果上都得以保留
+func EncodeAnimatedWebP(w io.Writer, g *gif.GIF, opts AnimatedWebPOptions) error {
+	if len(g.Image) == 0 {
+		return errors.New("GIF文件没有帧")
+	}
+
+	width, height := g.Config.Width, g.Config.Height
+	if width <= 0 || height <= 0 {
+		// 部分GIF的逻辑屏幕尺寸为0，取所有帧的并集
+		var bounds image.Rectangle
+		for _, frame := range g.Image {
+			bounds = bounds.Union(frame.Bounds())
+		}
+		width, height = bounds.Max.X, bounds.Max.Y
+	}
+	if width <= 0 || height <= 0 || width > 1<<24 || height > 1<<24 {
+		return fmt.Errorf("无效的GIF尺寸: %dx%d", width, height)
+	}
+	canvasRect := image.Rect(0, 0, width, height)
+
+	canvas := image.NewRGBA(canvasRect)
+	previous := image.NewRGBA(canvasRect) // 上一个输出帧的画面
+	var frames []webpFrame
+
+	for i, frame := range g.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		var saved *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			saved = image.NewRGBA(canvasRect)
+			copy(saved.Pix, canvas.Pix)
+		}
+
+		frameRect := frame.Bounds().Intersect(canvasRect)
+		draw.Draw(canvas, frameRect, frame, frameRect.Min, draw.Over)
+
+		delay := 0
+		if i < len(g.Delay) {
+			delay = g.Delay[i]
+		}
+		duration := gifDelayToMillis(delay)
+
+		changed := changedRect(previous, canvas)
+		if i == 0 {
+			changed = canvasRect
+		}
+		if changed.Empty() {
+			// 画面没有变化，累加到上一帧的显示时间
+			frames[len(frames)-1].duration += duration
+		} else {
+			changed = alignFrameRect(changed, canvasRect)
+			data, hasAlpha, err := encodeWebPFrame(canvas, changed, opts)
+			if err != nil {
+				return fmt.Errorf("编码第 %d 帧失败: %w", i+1, err)
+			}
+			frames = append(frames, webpFrame{rect: changed, duration: duration, data: data, hasAlpha: hasAlpha})
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		// 按处置方式为下一帧准备画布
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frameRect, image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			copy(canvas.Pix, saved.Pix)
+		}
+	}
+
+	return writeAnimatedWebP(w, width, height, gifLoopCountToWebP(g.LoopCount), frames)
+}
+
+// gifDelayToMillis 将GIF帧延迟(1/100秒)转换为毫秒
+// 与主流浏览器一致，延迟不超过1的帧按100毫秒播放
+func gifDelayToMillis(delay int) int {
+	if delay <= 1 {
+		return 100
+	}
+	return delay * 10
+}
+
+// gifLoopCountToWebP 转换循环次数
+// GIF: 0 无限循环，-1 只播放一次，n 额外重复n次；WebP: 0 无限循环，n 共播放n次
+func gifLoopCountToWebP(loopCount int) int {
+	switch {
+	case loopCount == 0:
+		return 0
+	case loopCount < 0:
+		return 1
+	case loopCount+1 > 0xFFFF:
+		return 0
+	default:
+		return loopCount + 1
+	}
+}
+
+// changedRect 返回两幅画面中发生变化的像素的外接矩形
+func changedRect(a, b *image.RGBA) image.Rectangle {
+	bounds := a.Bounds()
+	minX, minY, maxX, maxY := bounds.Max.X, bounds.Max.Y, bounds.Min.X-1, bounds.Min.Y-1
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		rowA := a.Pix[(y-bounds.Min.Y)*a.Stride : (y-bounds.Min.Y)*a.Stride+bounds.Dx()*4]
+		rowB := b.Pix[(y-bounds.Min.Y)*b.Stride : (y-bounds.Min.Y)*b.Stride+bounds.Dx()*4]
+		if bytes.Equal(rowA, rowB) {
+			continue
+		}
+		for x := 0; x < bounds.Dx(); x++ {
+			if binary.LittleEndian.Uint32(rowA[x*4:]) != binary.LittleEndian.Uint32(rowB[x*4:]) {
+				if x < minX {
+					minX = x
+				}
+				if x > maxX {
+					maxX = x
+				}
+			}
+		}
+		if y < minY {
+			minY = y
+		}
+		maxY = y
+	}
+	if maxX < minX || maxY < minY {
+		return image.Rectangle{}
+	}
+	return image.Rect(minX+bounds.Min.X, minY, maxX+bounds.Min.X+1, maxY+1)
+}
+
+// alignFrameRect 将帧区域的起点对齐到偶数坐标，以满足ANMF的偏移量要求
+func alignFrameRect(r, canvas image.Rectangle) image.Rectangle {
+	r.Min.X -= r.Min.X % animFrameOffsetAlign
+	r.Min.Y -= r.Min.Y % animFrameOffsetAlign
+	return r.Intersect(canvas)
+}
+
+// encodeWebPFrame 编码画布的指定区域，返回去掉RIFF头后的图像数据块
+func encodeWebPFrame(canvas *image.RGBA, rect image.Rectangle, opts AnimatedWebPOptions) ([]byte, bool, error) {
+	sub := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(sub, sub.Bounds(), canvas, rect.Min, draw.Src)
+
+	var encoded []byte
+	var err error
+	switch {
+	case opts.Mixed:
+		lossy, errLossy := webp.EncodeRGBA(sub, opts.Quality)
+		lossless, errLossless := webp.EncodeExactLosslessRGBA(sub)
+		switch {
+		case errLossy != nil && errLossless != nil:
+			err = errLossy
+		case errLossless != nil || (errLossy == nil && len(lossy) <= len(lossless)):
+			encoded = lossy
+		default:
+			encoded = lossless
+		}
+	case opts.Lossless:
+		// 保留全透明像素的颜色，避免与上一帧拼接时出现杂色
+		encoded, err = webp.EncodeExactLosslessRGBA(sub)
+	default:
+		encoded, err = webp.EncodeRGBA(sub, opts.Quality)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := extractWebPImageChunks(encoded)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, hasTransparency(sub), nil
+}
+
+// extractWebPImageChunks 从单帧WebP文件中提取 ALPH、VP8、VP8L 块
+func extractWebPImageChunks(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errors.New("无效的WebP数据")
+	}
+
+	var out bytes.Buffer
+	for pos := 12; pos+8 <= len(data); {
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		end := pos + 8 + size + size%2
+		if pos+8+size > len(data) {
+			return nil, fmt.Errorf("WebP块 %s 长度越界", fourCC)
+		}
+		if end > len(data) {
+			end = len(data)
+		}
+		switch fourCC {
+		case "ALPH", "VP8 ", "VP8L":
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+	if out.Len() == 0 {
+		return nil, errors.New("WebP数据中没有图像块")
+	}
+	return out.Bytes(), nil
+}
+
+// hasTransparency 判断图像是否包含非完全不透明的像素
+func hasTransparency(img *image.RGBA) bool {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] != 0xFF {
+			return true
+		}
+	}
+	return false
+}
+
+// writeAnimatedWebP 按WebP扩展格式写出动画文件
+func writeAnimatedWebP(w io.Writer, width, height, loopCount int, frames []webpFrame) error {
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+
+	hasAlpha := false
+	for _, f := range frames {
+		hasAlpha = hasAlpha || f.hasAlpha
+	}
+
+	// VP8X: 标志位 + 画布尺寸
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x02 // 动画
+	if hasAlpha {
+		vp8x[0] |= 0x10
+	}
+	putUint24(vp8x[4:], width-1)
+	putUint24(vp8x[7:], height-1)
+	writeWebPChunk(&body, "VP8X", vp8x)
+
+	// ANIM: 背景色(BGRA) + 循环次数
+	anim := make([]byte, 6)
+	binary.LittleEndian.PutUint32(anim[0:], 0) // 透明背景
+	binary.LittleEndian.PutUint16(anim[4:], uint16(loopCount))
+	writeWebPChunk(&body, "ANIM", anim)
+
+	for _, f := range frames {
+		header := make([]byte, 16)
+		putUint24(header[0:], f.rect.Min.X/animFrameOffsetAlign)
+		putUint24(header[3:], f.rect.Min.Y/animFrameOffsetAlign)
+		putUint24(header[6:], f.rect.Dx()-1)
+		putUint24(header[9:], f.rect.Dy()-1)
+		duration := f.duration
+		if duration > 0xFFFFFF {
+			duration = 0xFFFFFF
+		}
+		putUint24(header[12:], duration)
+		// 画布已按GIF处置方式合成，直接覆盖变化区域：不混合、不处置
+		header[15] = 0x02
+		writeWebPChunk(&body, "ANMF", append(header, f.data...))
+	}
+
+	if body.Len() > 0xFFFFFFFF-8 {
+		return errors.New("动画WebP文件过大")
+	}
+	var riff [8]byte
+	copy(riff[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(riff[4:], uint32(body.Len()))
+	if _, err := w.Write(riff[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// writeWebPChunk 写出一个RIFF块，奇数长度补齐一个字节
+func writeWebPChunk(buf *bytes.Buffer, fourCC string, payload []byte) {
+	var header [8]byte
+	copy(header[0:4], fourCC)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(payload)))
+	buf.Write(header[:])
+	buf.Write(payload)
+	if len(payload)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+// putUint24 以小端序写入24位整数
+func putUint24(b []byte, v int) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
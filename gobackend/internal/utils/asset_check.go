@@ -0,0 +1,335 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dongman/internal/models"
+	"dongman/internal/storage"
+)
+
+// 资源检查发现的问题类型
+const (
+	AssetIssueMissing     = "missing"               // 文件不存在
+	AssetIssueEmpty       = "empty"                 // 文件大小为0
+	AssetIssueUndecodable = "undecodable"           // 无法解码为图片
+	AssetIssueContentType = "content_type_mismatch" // 扩展名或存储的Content-Type与实际格式不符
+	AssetIssueUnreadable  = "unreadable"            // 读取失败（如存储后端不可用）
+)
+
+// faviconAssetPath 网站图标的路径，由 UploadFavicon 写入
+const faviconAssetPath = "/assets/public/favicon.ico"
+
+// AssetProblem 一个有问题的资源文件及引用它的位置
+type AssetProblem struct {
+	Path       string   `json:"path"`
+	Issue      string   `json:"issue"`
+	Detail     string   `json:"detail,omitempty"`
+	Sources    []string `json:"sources"`              // 引用位置，如 resources.images#12、posts/hello.md
+	Repair     string   `json:"repair,omitempty"`     // 按文件名找到的唯一同名文件，可自动修复
+	Candidates []string `json:"candidates,omitempty"` // 同名文件不唯一时列出，需人工处理
+}
+
+// AssetCheckReport 资源完整性检查结果
+type AssetCheckReport struct {
+	CheckedAt    time.Time      `json:"checked_at"`
+	CheckedRefs  int            `json:"checked_refs"`
+	CheckedFiles int            `json:"checked_files"`
+	Summary      map[string]int `json:"summary"` // 问题类型 -> 文件数
+	Problems     []AssetProblem `json:"problems"`
+}
+
+// AssetCheckOptions 检查参数
+type AssetCheckOptions struct {
+	Deep        bool // 完整解码图片，默认只解析文件头
+	Concurrency int
+}
+
+// RepairMapping 返回可自动修复的 旧路径 -> 新路径
+func (r *AssetCheckReport) RepairMapping() map[string]string {
+	mapping := make(map[string]string)
+	for _, p := range r.Problems {
+		if p.Issue == AssetIssueMissing && p.Repair != "" {
+			mapping[p.Path] = p.Repair
+		}
+	}
+	return mapping
+}
+
+// SelectRepairs 只保留与检查得到的修复建议完全一致的条目，其余条目的旧路径按字母序返回
+func (r *AssetCheckReport) SelectRepairs(requested map[string]string) (map[string]string, []string) {
+	suggested := r.RepairMapping()
+	selected := make(map[string]string)
+	rejected := []string{}
+	for oldPath, newPath := range requested {
+		if suggested[oldPath] != "" && suggested[oldPath] == newPath {
+			selected[oldPath] = newPath
+		} else {
+			rejected = append(rejected, oldPath)
+		}
+	}
+	sort.Strings(rejected)
+	return selected, rejected
+}
+
+// CheckAssets 检查资源、补充内容、审批记录、贴纸、网站设置和文章引用的所有 /assets/... 文件
+// 报告缺失、空文件、无法解码和类型不符的文件；缺失的文件按文件名在 uploads/ 和 imgs/ 下查找同名文件作为修复建议
+func CheckAssets(opts AssetCheckOptions) (*AssetCheckReport, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 8
+	}
+
+	refs, err := models.ListAssetRefs(storage.Default())
+	if err != nil {
+		return nil, err
+	}
+	backend := storage.Default()
+	// 网站图标由前端固定引用，文件缺失同样需要报告
+	refs = append(refs, models.AssetRef{Source: "site_settings.favicon", Path: faviconAssetPath})
+
+	// 同一文件可能被多处引用，只检查一次
+	sources := make(map[string][]string)
+	var paths []string
+	for _, ref := range refs {
+		if _, ok := sources[ref.Path]; !ok {
+			paths = append(paths, ref.Path)
+		}
+		sources[ref.Path] = appendUnique(sources[ref.Path], ref.Source)
+	}
+	sort.Strings(paths)
+
+	report := &AssetCheckReport{
+		CheckedAt:    time.Now(),
+		CheckedRefs:  len(refs),
+		CheckedFiles: len(paths),
+		Summary:      make(map[string]int),
+		Problems:     []AssetProblem{},
+	}
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, opts.Concurrency)
+	)
+	for _, p := range paths {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(assetPath string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			issue, detail := checkAssetFile(backend, assetPath, opts.Deep)
+			if issue == "" {
+				return
+			}
+			mu.Lock()
+			report.Problems = append(report.Problems, AssetProblem{
+				Path:    assetPath,
+				Issue:   issue,
+				Detail:  detail,
+				Sources: sources[assetPath],
+			})
+			mu.Unlock()
+		}(p)
+	}
+	wg.Wait()
+
+	sort.Slice(report.Problems, func(i, j int) bool { return report.Problems[i].Path < report.Problems[j].Path })
+	for _, p := range report.Problems {
+		report.Summary[p.Issue]++
+	}
+
+	if report.Summary[AssetIssueMissing] > 0 {
+		suggestAssetRepairs(backend, report)
+	}
+	return report, nil
+}
+
+// checkAssetFile 检查单个文件，没有问题时返回空字符串
+func checkAssetFile(backend storage.Backend, assetPath string, deep bool) (string, string) {
+	key, ok := storage.KeyFromAssetPath(assetPath)
+	if !ok {
+		return AssetIssueMissing, "非法的资源路径"
+	}
+
+	info, err := backend.Stat(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			return AssetIssueMissing, ""
+		}
+		return AssetIssueUnreadable, err.Error()
+	}
+	if info.Size == 0 {
+		return AssetIssueEmpty, ""
+	}
+
+	// 只检查常见位图格式和 .ico 图标，.svg 等只检查存在和大小
+	isIcon := strings.EqualFold(path.Ext(key), ".ico")
+	expected := imageFormatFromExt(key)
+	if expected == "" && !isIcon {
+		return "", ""
+	}
+
+	reader, err := backend.Get(key)
+	if err != nil {
+		return AssetIssueUnreadable, err.Error()
+	}
+	var data []byte
+	if deep {
+		data, err = readAllLimited(reader, maxResizeSourceBytes)
+	} else {
+		// 解析文件头只需要前面一部分数据
+		data, err = io.ReadAll(io.LimitReader(reader, 64<<10))
+	}
+	reader.Close()
+	if err != nil {
+		if errors.Is(err, ErrSourceTooLarge) {
+			return "", ""
+		}
+		return AssetIssueUnreadable, err.Error()
+	}
+	if info.Size > 0 && info.Size < int64(len(data)) {
+		data = data[:info.Size]
+	}
+	if isIcon {
+		return checkIconData(data, info.Size)
+	}
+
+	actualType := http.DetectContentType(data)
+	if !strings.HasPrefix(actualType, "image/") {
+		return AssetIssueUndecodable, fmt.Sprintf("文件内容不是图片 (%s)", actualType)
+	}
+	if deep {
+		if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+			return AssetIssueUndecodable, err.Error()
+		}
+	} else if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return AssetIssueUndecodable, err.Error()
+	}
+
+	if actual := strings.TrimPrefix(actualType, "image/"); actual != expected {
+		return AssetIssueContentType, fmt.Sprintf("扩展名为%s，实际为%s", path.Ext(key), actual)
+	}
+	// 对象存储中保存的Content-Type会直接返回给浏览器，也需要一致
+	if storedType := strings.ToLower(info.ContentType); strings.HasPrefix(storedType, "image/") && !strings.HasPrefix(storedType, actualType) {
+		return AssetIssueContentType, fmt.Sprintf("存储的Content-Type为%s，实际为%s", info.ContentType, actualType)
+	}
+	return "", ""
+}
+
+// checkIconData 检查 .ico 文件内容，size 为文件大小（未知时为 -1）
+// 网站图标也可能直接上传PNG等图片，能解析出图片尺寸的同样视为有效
+func checkIconData(data []byte, size int64) (string, string) {
+	// ICO/CUR 文件头：保留字段0、类型1（图标）或2（光标）、图像数量
+	if len(data) >= 6 && data[0] == 0 && data[1] == 0 && (data[2] == 1 || data[2] == 2) && data[3] == 0 {
+		count := int(binary.LittleEndian.Uint16(data[4:6]))
+		if count == 0 {
+			return AssetIssueUndecodable, "图标不包含任何图像"
+		}
+		if size > 0 && int64(6+16*count) > size {
+			return AssetIssueUndecodable, "图标目录不完整"
+		}
+		// 每个目录项16字节，末尾8字节为图像数据的大小和偏移
+		for i := 0; i < count && 6+16*(i+1) <= len(data); i++ {
+			entry := data[6+16*i : 6+16*(i+1)]
+			imageSize := int64(binary.LittleEndian.Uint32(entry[8:12]))
+			offset := int64(binary.LittleEndian.Uint32(entry[12:16]))
+			if imageSize == 0 || (size > 0 && offset+imageSize > size) {
+				return AssetIssueUndecodable, fmt.Sprintf("图标的第%d个图像超出文件范围", i+1)
+			}
+		}
+		return "", ""
+	}
+
+	actualType := http.DetectContentType(data)
+	if strings.HasPrefix(actualType, "image/") {
+		if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			return "", ""
+		}
+	}
+	return AssetIssueUndecodable, fmt.Sprintf("文件内容不是图标 (%s)", actualType)
+}
+
+// suggestAssetRepairs 按文件名在 uploads/ 和 imgs/ 下查找缺失文件的同名文件，与 RestoreImagesPath 的规则一致
+func suggestAssetRepairs(backend storage.Backend, report *AssetCheckReport) {
+	byName := make(map[string][]string)
+	for _, prefix := range []string{"uploads/", "imgs/"} {
+		err := storage.List(backend, prefix, func(info storage.ObjectInfo) error {
+			if !IsDerivedImagePath(info.Key) && info.Size > 0 {
+				name := path.Base(info.Key)
+				byName[name] = append(byName[name], storage.AssetPath(info.Key))
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("查找同名文件失败，无法生成修复建议: %v", err)
+			return
+		}
+	}
+
+	for i := range report.Problems {
+		p := &report.Problems[i]
+		if p.Issue != AssetIssueMissing {
+			continue
+		}
+		candidates := byName[path.Base(p.Path)]
+		switch len(candidates) {
+		case 0:
+		case 1:
+			p.Repair = candidates[0]
+		default:
+			sort.Strings(candidates)
+			p.Candidates = candidates
+		}
+	}
+}
+
+// RepairAssets 在一个事务中把引用缺失文件的位置改写为同名文件的路径
+// 只应用仍然缺失且目标文件存在的条目，返回实际应用的映射和改写统计
+func RepairAssets(mapping map[string]string) (map[string]string, *models.AssetRewriteResult, error) {
+	backend := storage.Default()
+	applied := make(map[string]string)
+	for oldPath, newPath := range mapping {
+		oldKey, ok := storage.KeyFromAssetPath(oldPath)
+		if ok && storage.Exists(backend, oldKey) {
+			continue
+		}
+		newKey, ok := storage.KeyFromAssetPath(newPath)
+		if !ok || !storage.Exists(backend, newKey) {
+			continue
+		}
+		applied[oldPath] = newPath
+	}
+	if len(applied) == 0 {
+		return applied, &models.AssetRewriteResult{Rows: map[string]int{}}, nil
+	}
+
+	result, err := models.RewriteAssetRefs(applied, storage.Default(), false)
+	if err != nil {
+		return nil, nil, err
+	}
+	for oldPath, newPath := range applied {
+		log.Printf("已修复图片引用: %s -> %s", oldPath, newPath)
+	}
+	return applied, result, nil
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
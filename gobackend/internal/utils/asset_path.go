@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"dongman/internal/config"
+	"dongman/internal/storage"
+)
+
+// ErrInvalidAssetPath 资源路径非法，如包含 .. 或为空
+var ErrInvalidAssetPath = errors.New("非法的资源路径")
+
+// ResolveAssetPath 将图片路径解析为本地文件路径，资源目录以 config.AssetsDir 为准
+// 参数p可以是：
+//  1. 数据库中保存的URL路径，如 "/assets/imgs/13/1.jpg"
+//  2. 相对于资源目录的路径，如 "imgs/13/1.jpg"
+//  3. 资源目录内的绝对路径，如命令行工具遍历资源目录得到的文件
+//
+// 包含 .. 的路径和资源目录以外的绝对路径一律拒绝，保证结果不会越出资源目录
+func ResolveAssetPath(p string) (string, error) {
+	if strings.HasPrefix(p, storage.AssetURLPrefix) {
+		key, ok := storage.KeyFromAssetPath(p)
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrInvalidAssetPath, p)
+		}
+		return assetFilePath(key)
+	}
+
+	if filepath.IsAbs(p) {
+		assetsDir, err := filepath.Abs(config.GetAssetsDir())
+		if err != nil {
+			return "", fmt.Errorf("获取资源目录失败: %w", err)
+		}
+		cleaned := filepath.Clean(p)
+		if _, ok := relToAssetsDir(assetsDir, cleaned); !ok {
+			return "", fmt.Errorf("%w: %s 不在资源目录内", ErrInvalidAssetPath, p)
+		}
+		return cleaned, nil
+	}
+
+	key, err := storage.CleanKey(p)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidAssetPath, p)
+	}
+	return assetFilePath(key)
+}
+
+// assetFilePath 返回对象key在资源目录下的绝对路径
+func assetFilePath(key string) (string, error) {
+	assetsDir, err := filepath.Abs(config.GetAssetsDir())
+	if err != nil {
+		return "", fmt.Errorf("获取资源目录失败: %w", err)
+	}
+	return filepath.Join(assetsDir, filepath.FromSlash(key)), nil
+}
+
+// AssetPathFromLocal 将资源目录内的本地文件路径转换为 /assets/... 路径，不在资源目录内时返回 false
+func AssetPathFromLocal(localPath string) (string, bool) {
+	assetsDir, err := filepath.Abs(config.GetAssetsDir())
+	if err != nil {
+		return "", false
+	}
+	absPath, err := filepath.Abs(localPath)
+	if err != nil {
+		return "", false
+	}
+	rel, ok := relToAssetsDir(assetsDir, absPath)
+	if !ok || rel == "." {
+		return "", false
+	}
+	return storage.AssetPath(filepath.ToSlash(rel)), true
+}
+
+// relToAssetsDir 返回绝对路径相对于资源目录的路径，不在资源目录内时返回 false
+func relToAssetsDir(assetsDir, absPath string) (string, bool) {
+	rel, err := filepath.Rel(assetsDir, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"sync"
+
+	"dongman/internal/storage"
+)
+
+// AssetStage 暂存审批时要移入资源目录的图片，与数据库事务一起提交或回滚
+// Stage 只把图片（及其 .webp/.avif 版本）复制到资源目录，原文件保持不变；
+// 数据库事务提交后调用 Commit 删除原文件，事务失败时调用 Rollback 删除已复制的文件
+type AssetStage struct {
+	backend storage.Backend
+	copied  []string          // 已复制出的目标key
+	sources []string          // 提交后要删除的源key
+	renamed map[string]string // 原路径 -> 新路径
+}
+
+// reservedKeys 进行中的暂存已占用的目标key，避免并发审批把不同图片复制到同一个key后被对方回滚删除
+var (
+	reservedMu   sync.Mutex
+	reservedKeys = make(map[string]bool)
+)
+
+// NewAssetStage 使用全局存储后端创建暂存区
+func NewAssetStage() *AssetStage {
+	return &AssetStage{backend: storage.Default(), renamed: make(map[string]string)}
+}
+
+// StageToResource 把 /assets/... 下的图片复制到资源目录 imgs/{resourceID}/ 下，返回新路径
+// 目标文件已存在时在文件名后加序号，不覆盖已有文件，回滚时也不会误删
+func (s *AssetStage) StageToResource(resourceID int, imgPath string) (string, error) {
+	if newPath, ok := s.renamed[imgPath]; ok {
+		return newPath, nil
+	}
+	srcKey, ok := storage.KeyFromAssetPath(imgPath)
+	if !ok {
+		return "", fmt.Errorf("无效的图片路径: %s", imgPath)
+	}
+	if !storage.Exists(s.backend, srcKey) {
+		return "", fmt.Errorf("源文件不存在: %s", srcKey)
+	}
+
+	dir := path.Join("imgs", fmt.Sprintf("%d", resourceID))
+	if path.Dir(srcKey) == dir {
+		// 已在资源目录中，无需移动
+		s.renamed[imgPath] = imgPath
+		return imgPath, nil
+	}
+	dstKey := s.freeKey(dir, path.Base(srcKey))
+	s.copied = append(s.copied, dstKey)
+	if err := storage.Copy(s.backend, srcKey, dstKey); err != nil {
+		return "", fmt.Errorf("复制图片失败: %s -> %s, 错误: %w", srcKey, dstKey, err)
+	}
+	s.sources = append(s.sources, srcKey)
+
+	// 一并复制已生成的 .webp/.avif 版本
+	for _, suffix := range []string{WebPSiblingSuffix, AVIFSiblingSuffix} {
+		siblingKey := ImageSiblingKey(srcKey, suffix)
+		if !storage.Exists(s.backend, siblingKey) {
+			continue
+		}
+		dstSibling := ImageSiblingKey(dstKey, suffix)
+		if err := storage.Copy(s.backend, siblingKey, dstSibling); err != nil {
+			log.Printf("复制图片格式版本失败: %s, 错误: %v", siblingKey, err)
+			continue
+		}
+		s.copied = append(s.copied, dstSibling)
+		s.sources = append(s.sources, siblingKey)
+	}
+
+	newPath := storage.AssetPath(dstKey)
+	s.renamed[imgPath] = newPath
+	return newPath, nil
+}
+
+// freeKey 返回目录下未被占用的key并预留，同名文件已存在时依次尝试 name-1.ext、name-2.ext ...
+func (s *AssetStage) freeKey(dir, name string) string {
+	reservedMu.Lock()
+	defer reservedMu.Unlock()
+	key := path.Join(dir, name)
+	ext := path.Ext(name)
+	for i := 1; reservedKeys[key] || storage.Exists(s.backend, key); i++ {
+		key = path.Join(dir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext))
+	}
+	reservedKeys[key] = true
+	return key
+}
+
+// release 释放已预留的目标key
+func (s *AssetStage) release() {
+	reservedMu.Lock()
+	defer reservedMu.Unlock()
+	for _, key := range s.copied {
+		delete(reservedKeys, key)
+	}
+}
+
+// Renamed 返回已暂存图片的 原路径 -> 新路径，不包括原本就在资源目录中的图片
+func (s *AssetStage) Renamed() map[string]string {
+	result := make(map[string]string, len(s.renamed))
+	for oldPath, newPath := range s.renamed {
+		if oldPath != newPath {
+			result[oldPath] = newPath
+		}
+	}
+	return result
+}
+
+// Commit 数据库事务提交后删除原文件，删除失败只记录日志，遗留的上传文件不影响数据
+func (s *AssetStage) Commit() {
+	for _, key := range s.sources {
+		if err := s.backend.Delete(key); err != nil {
+			log.Printf("警告：删除已移动图片的原文件 %s 失败: %v", key, err)
+		}
+	}
+	if len(s.sources) > 0 {
+		log.Printf("已将 %d 个文件移入资源目录", len(s.sources))
+	}
+	s.release()
+	s.copied, s.sources = nil, nil
+}
+
+// Rollback 删除已复制到资源目录的文件，原文件保持不变
+func (s *AssetStage) Rollback() {
+	for _, key := range s.copied {
+		if err := s.backend.Delete(key); err != nil {
+			log.Printf("警告：回滚时删除文件 %s 失败: %v", key, err)
+		}
+	}
+	s.release()
+	s.copied, s.sources = nil, nil
+	s.renamed = make(map[string]string)
+}
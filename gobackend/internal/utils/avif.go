@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"errors"
+	"image"
+
+	"dongman/internal/config"
+)
+
+// ErrAVIFUnsupported 当前构建不包含AVIF编码器
+var ErrAVIFUnsupported = errors.New("当前构建不支持AVIF编码，请安装libavif并使用 -tags avif 编译")
+
+// AVIF编码质量(0-100)，与WebP的80大致相当的观感
+const avifQuality = 60
+
+// avifEncoder AVIF编码实现，由 -tags avif 编译时注册
+var avifEncoder func(img image.Image, quality int) ([]byte, error)
+
+// AVIFSupported 判断当前构建是否支持AVIF编码
+func AVIFSupported() bool {
+	return avifEncoder != nil
+}
+
+// AVIFEnabled 判断是否需要生成AVIF版本：配置开启且当前构建支持
+func AVIFEnabled() bool {
+	return config.ImageAVIF && AVIFSupported()
+}
+
+// EncodeAVIF 将图片编码为AVIF
+func EncodeAVIF(img image.Image, quality int) ([]byte, error) {
+	if avifEncoder == nil {
+		return nil, ErrAVIFUnsupported
+	}
+	return avifEncoder(img, quality)
+}
@@ -0,0 +1,98 @@
+//go:build avif
+
+package utils
+
+/*
+#cgo pkg-config: libavif
+#include <stdlib.h>
+#include <string.h>
+#include <avif/avif.h>
+
+// encodeAVIF 将RGBA像素编码为AVIF，成功时 out 需由调用方通过 avifRWDataFree 释放
+static avifResult encodeAVIF(uint8_t* pixels, uint32_t width, uint32_t height, uint32_t rowBytes,
+                             int minQuantizer, int maxQuantizer, int speed, avifRWData* out) {
+	avifImage* image = avifImageCreate(width, height, 8, AVIF_PIXEL_FORMAT_YUV420);
+	if (image == NULL) {
+		return AVIF_RESULT_UNKNOWN_ERROR;
+	}
+
+	avifRGBImage rgb;
+	avifRGBImageSetDefaults(&rgb, image);
+	rgb.format = AVIF_RGB_FORMAT_RGBA;
+	rgb.depth = 8;
+	rgb.pixels = pixels;
+	rgb.rowBytes = rowBytes;
+
+	avifResult result = avifImageRGBToYUV(image, &rgb);
+	if (result != AVIF_RESULT_OK) {
+		avifImageDestroy(image);
+		return result;
+	}
+
+	avifEncoder* encoder = avifEncoderCreate();
+	if (encoder == NULL) {
+		avifImageDestroy(image);
+		return AVIF_RESULT_UNKNOWN_ERROR;
+	}
+	encoder->speed = speed;
+	encoder->minQuantizer = minQuantizer;
+	encoder->maxQuantizer = maxQuantizer;
+	encoder->minQuantizerAlpha = AVIF_QUANTIZER_LOSSLESS;
+	encoder->maxQuantizerAlpha = AVIF_QUANTIZER_LOSSLESS;
+
+	result = avifEncoderWrite(encoder, image, out);
+	avifEncoderDestroy(encoder);
+	avifImageDestroy(image);
+	return result;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"unsafe"
+)
+
+// AVIF编码速度(0-10)，数值越大越快，6在速度和体积之间较为均衡
+const avifSpeed = 6
+
+func init() {
+	avifEncoder = encodeAVIFWithLibavif
+}
+
+// encodeAVIFWithLibavif 使用libavif编码，quality(0-100)映射为量化参数(63-0)
+func encodeAVIFWithLibavif(img image.Image, quality int) ([]byte, error) {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	if len(rgba.Pix) == 0 {
+		return nil, fmt.Errorf("图片尺寸无效: %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	if quality < 0 {
+		quality = 0
+	} else if quality > 100 {
+		quality = 100
+	}
+	quantizer := (100 - quality) * 63 / 100
+	minQuantizer := quantizer - 5
+	if minQuantizer < 0 {
+		minQuantizer = 0
+	}
+
+	// 像素数据拷贝到C内存，避免向C传递Go指针
+	pixels := C.CBytes(rgba.Pix)
+	defer C.free(pixels)
+
+	var out C.avifRWData
+	result := C.encodeAVIF((*C.uint8_t)(pixels), C.uint32_t(rgba.Rect.Dx()), C.uint32_t(rgba.Rect.Dy()),
+		C.uint32_t(rgba.Stride), C.int(minQuantizer), C.int(quantizer), C.int(avifSpeed), &out)
+	if result != C.AVIF_RESULT_OK {
+		return nil, fmt.Errorf("AVIF编码失败: %s", C.GoString(C.avifResultToString(result)))
+	}
+	defer C.avifRWDataFree(&out)
+
+	return C.GoBytes(unsafe.Pointer(out.data), C.int(out.size)), nil
+}
@@ -0,0 +1,290 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+)
+
+// 超分辨率后端名称
+const (
+	EnhancerRemote = "remote" // 第三方应用接口（原有实现）
+	EnhancerLocal  = "local"  // 本地CPU：Lanczos放大加锐化，无需联网
+	EnhancerHTTP   = "http"   // 自建模型服务，协议见 httpEnhancer
+)
+
+// EnhanceStageDownloading 下载处理结果的阶段
+const EnhanceStageDownloading = "downloading"
+
+// 超分辨率参数范围
+const (
+	DefaultEnhanceScale   = 2
+	MaxEnhanceScale       = 4
+	DefaultEnhanceSharpen = 1.0
+	MaxEnhanceSharpen     = 5.0
+
+	// maxEnhanceResultBytes HTTP后端返回图片的大小上限
+	maxEnhanceResultBytes = 100 << 20
+)
+
+// EnhanceOptions 超分辨率参数，各后端只使用与自己相关的字段
+type EnhanceOptions struct {
+	Scale   int               `json:"scale"`            // 放大倍数 1-4，remote后端固定由服务端决定
+	Sharpen float64           `json:"sharpen"`          // local后端的锐化强度（高斯sigma），0 表示不锐化
+	URL     string            `json:"-"`                // http后端地址
+	Token   string            `json:"-"`                // http后端的Bearer令牌
+	Params  map[string]string `json:"params,omitempty"` // 透传给http后端的额外表单字段
+}
+
+// Normalize 补全默认值并检查参数范围
+func (o *EnhanceOptions) Normalize() error {
+	if o.Scale == 0 {
+		o.Scale = DefaultEnhanceScale
+	}
+	if o.Scale < 1 || o.Scale > MaxEnhanceScale {
+		return fmt.Errorf("放大倍数必须在1-%d之间", MaxEnhanceScale)
+	}
+	if o.Sharpen < 0 || o.Sharpen > MaxEnhanceSharpen {
+		return fmt.Errorf("锐化强度必须在0-%g之间", MaxEnhanceSharpen)
+	}
+	return nil
+}
+
+// Enhancer 超分辨率后端
+// Enhance 读取 srcPath 的图片，将结果写入 dstPath，处理过程中通过 progress 报告进度（progress 可以为nil）
+type Enhancer interface {
+	Name() string
+	Enhance(srcPath, dstPath string, opts EnhanceOptions, progress EnhanceProgressFunc) error
+}
+
+var enhancers = map[string]Enhancer{
+	EnhancerRemote: remoteEnhancer{},
+	EnhancerLocal:  localEnhancer{},
+	EnhancerHTTP:   httpEnhancer{},
+}
+
+// GetEnhancer 按名称获取超分辨率后端
+func GetEnhancer(name string) (Enhancer, error) {
+	enhancer, ok := enhancers[name]
+	if !ok {
+		return nil, fmt.Errorf("未知的图像增强后端: %s，可选: %s", name, strings.Join(EnhancerNames(), ", "))
+	}
+	return enhancer, nil
+}
+
+// EnhancerNames 返回所有后端名称
+func EnhancerNames() []string {
+	names := make([]string, 0, len(enhancers))
+	for name := range enhancers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// remoteEnhancer 调用第三方应用接口，上传后轮询结果再下载
+type remoteEnhancer struct{}
+
+func (remoteEnhancer) Name() string { return EnhancerRemote }
+
+func (remoteEnhancer) Enhance(srcPath, dstPath string, opts EnhanceOptions, progress EnhanceProgressFunc) error {
+	result, err := EnhanceImageWithProgress(srcPath, progress)
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		return errors.New(result.ErrorMessage)
+	}
+
+	if progress != nil {
+		progress(EnhanceStageDownloading, 95)
+	}
+	if err := downloadToFile(result.EnhancedImage, dstPath); err != nil {
+		return fmt.Errorf("保存增强图片失败: %w", err)
+	}
+	return nil
+}
+
+// downloadToFile 下载URL的内容并原子地写入文件
+func downloadToFile(url, savePath string) error {
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("请求图片失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求图片返回非200状态码: %d", resp.StatusCode)
+	}
+	data, err := readAllLimited(resp.Body, maxEnhanceResultBytes)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(savePath, data)
+}
+
+// localEnhancer 在本地用Lanczos放大并锐化，效果不如模型但不依赖外部服务
+type localEnhancer struct{}
+
+func (localEnhancer) Name() string { return EnhancerLocal }
+
+func (localEnhancer) Enhance(srcPath, dstPath string, opts EnhanceOptions, progress EnhanceProgressFunc) error {
+	if progress == nil {
+		progress = func(string, int) {}
+	}
+	if err := opts.Normalize(); err != nil {
+		return err
+	}
+
+	progress(EnhanceStageProcessing, 10)
+	file, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("打开图片失败: %w", err)
+	}
+	img, err := decodeImageWithLimits(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	// 放大后的像素数不超过缩放服务的上限，超过时降低倍数
+	bounds := img.Bounds()
+	scale := opts.Scale
+	for scale > 1 && bounds.Dx()*scale*bounds.Dy()*scale > maxResizeSourcePixels {
+		scale--
+	}
+
+	progress(EnhanceStageProcessing, 30)
+	result := img
+	if scale > 1 {
+		result = imaging.Resize(img, bounds.Dx()*scale, bounds.Dy()*scale, imaging.Lanczos)
+	}
+	progress(EnhanceStageProcessing, 60)
+	if opts.Sharpen > 0 {
+		result = imaging.Sharpen(result, opts.Sharpen)
+	}
+
+	progress(EnhanceStageProcessing, 80)
+	format := imageFormatFromExt(dstPath)
+	if format == "" || format == "gif" {
+		format = "png"
+	}
+	data, err := encodeImageAs(result, format)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(dstPath, data); err != nil {
+		return err
+	}
+	progress(EnhanceStageProcessing, 100)
+	return nil
+}
+
+// httpEnhancer 调用自建的超分辨率服务，协议：
+//
+//	POST <url>，multipart/form-data 字段：image（图片文件）、scale（放大倍数）以及 Params 中的额外字段，
+//	设置了令牌时带 Authorization: Bearer <token>。
+//	成功时返回200和图片数据（Content-Type 为 image/*）；失败时返回非2xx状态码，响应体可以是 {"error": "..."}。
+type httpEnhancer struct{}
+
+func (httpEnhancer) Name() string { return EnhancerHTTP }
+
+func (httpEnhancer) Enhance(srcPath, dstPath string, opts EnhanceOptions, progress EnhanceProgressFunc) error {
+	if progress == nil {
+		progress = func(string, int) {}
+	}
+	if opts.URL == "" {
+		return errors.New("未配置图像增强服务地址")
+	}
+	if err := opts.Normalize(); err != nil {
+		return err
+	}
+
+	progress(EnhanceStageUploading, 5)
+	body, contentType, err := buildEnhanceRequestBody(srcPath, opts)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, opts.URL, body)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "image/*")
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+
+	// 服务端同步处理，模型推理可能较慢
+	progress(EnhanceStageProcessing, 20)
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求图像增强服务失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	progress(EnhanceStageDownloading, 90)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(msg, &errResp) == nil && errResp.Error != "" {
+			return fmt.Errorf("图像增强服务返回错误 (%d): %s", resp.StatusCode, errResp.Error)
+		}
+		return fmt.Errorf("图像增强服务返回状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	data, err := readAllLimited(resp.Body, maxEnhanceResultBytes)
+	if err != nil {
+		return err
+	}
+	if detected := http.DetectContentType(data); !strings.HasPrefix(detected, "image/") {
+		return fmt.Errorf("图像增强服务返回的不是图片 (%s)", detected)
+	}
+	return writeFileAtomic(dstPath, data)
+}
+
+// buildEnhanceRequestBody 构造HTTP后端的multipart请求体
+func buildEnhanceRequestBody(srcPath string, opts EnhanceOptions) (io.Reader, string, error) {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("打开图片失败: %w", err)
+	}
+	defer src.Close()
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, err := writer.CreateFormFile("image", filepath.Base(srcPath))
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := io.Copy(part, src); err != nil {
+		return nil, "", fmt.Errorf("读取图片失败: %w", err)
+	}
+	writer.WriteField("scale", strconv.Itoa(opts.Scale))
+	for key, value := range opts.Params {
+		if key == "image" || key == "scale" {
+			continue
+		}
+		writer.WriteField(key, value)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, writer.FormDataContentType(), nil
+}
@@ -0,0 +1,396 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+
+	"dongman/internal/storage"
+)
+
+// 格式协商用的同名文件后缀，如 imgs/1/a.jpg 的WebP版本为 imgs/1/a.jpg.webp
+const (
+	WebPSiblingSuffix = ".webp"
+	AVIFSiblingSuffix = ".avif"
+)
+
+// ImageSiblingKey 返回图片指定格式版本的key或路径
+func ImageSiblingKey(key, suffix string) string {
+	return key + suffix
+}
+
+// IsImageSiblingPath 判断是否为格式协商用的同名文件，如 a.jpg.webp、a.webp.avif
+func IsImageSiblingPath(p string) bool {
+	ext := strings.ToLower(path.Ext(p))
+	if ext != WebPSiblingSuffix && ext != AVIFSiblingSuffix {
+		return false
+	}
+	switch strings.ToLower(path.Ext(strings.TrimSuffix(p, path.Ext(p)))) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+// IsDerivedImagePath 判断是否为自动生成的派生图片（响应式尺寸或格式协商版本），批量处理时应跳过
+func IsDerivedImagePath(p string) bool {
+	return IsImageVariantPath(p) || IsImageSiblingPath(p)
+}
+
+// imageFormatFromExt 根据扩展名推断图片格式
+func imageFormatFromExt(p string) string {
+	switch strings.ToLower(path.Ext(p)) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".png":
+		return "png"
+	case ".gif":
+		return "gif"
+	case ".webp":
+		return "webp"
+	}
+	return ""
+}
+
+// encodeImageAs 按指定格式编码图片，不写入任何元数据
+func encodeImageAs(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	case "webp":
+		err = webp.Encode(&buf, img, &webp.Options{Lossless: false, Quality: 80})
+	default:
+		return nil, fmt.Errorf("不支持的图片格式: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("编码%s图片失败: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// buildImageSiblings 生成格式协商用的WebP和AVIF版本，返回 后缀 -> 数据
+// 原图已是WebP时不再生成WebP版本；AVIF仅在开启且当前构建支持时生成
+func buildImageSiblings(img image.Image, format string) (map[string][]byte, error) {
+	siblings := make(map[string][]byte, 2)
+	if format != "webp" {
+		data, err := encodeImageAs(img, "webp")
+		if err != nil {
+			return nil, err
+		}
+		siblings[WebPSiblingSuffix] = data
+	}
+	if AVIFEnabled() {
+		data, err := EncodeAVIF(img, avifQuality)
+		if err != nil {
+			return nil, err
+		}
+		siblings[AVIFSiblingSuffix] = data
+	}
+	return siblings, nil
+}
+
+// siblingContentType 返回格式协商版本的MIME类型
+func siblingContentType(suffix string) string {
+	if suffix == AVIFSiblingSuffix {
+		return "image/avif"
+	}
+	return "image/webp"
+}
+
+// writeLocalImageSiblings 在本地图片旁边写入WebP和AVIF版本，用于命令行批量处理
+func writeLocalImageSiblings(localPath string, img image.Image, format string) error {
+	siblings, err := buildImageSiblings(img, format)
+	if err != nil {
+		return err
+	}
+	for suffix, data := range siblings {
+		if err := writeFileAtomic(ImageSiblingKey(localPath, suffix), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免读到写了一半的文件
+func writeFileAtomic(filePath string, data []byte) error {
+	tempFile := filePath + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	if err := os.Rename(tempFile, filePath); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("替换文件失败: %w", err)
+	}
+	return nil
+}
+
+// writeConvertedImage 写出缩放后的图片
+// useWebp 为 true 时写入 .webp 文件；否则以原格式覆盖原文件，并在旁边生成 .webp/.avif 版本供按 Accept 协商
+func writeConvertedImage(img image.Image, srcFormat, outputPath string, useWebp bool) error {
+	format := "webp"
+	if !useWebp {
+		format = srcFormat
+		if imageFormatFromExt("x."+format) == "" {
+			format = imageFormatFromExt(outputPath)
+		}
+		if format == "" {
+			format = "jpeg"
+		}
+	}
+
+	data, err := encodeImageAs(img, format)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(outputPath, data); err != nil {
+		return err
+	}
+	if err := writeLocalImageSiblings(outputPath, img, format); err != nil {
+		return fmt.Errorf("生成WebP/AVIF版本失败: %w", err)
+	}
+	return nil
+}
+
+// fitImageSize 计算等比缩放到最大尺寸内的新尺寸，不放大
+// maxWidth、maxHeight 不大于0时按方向自动选择：横图1280x720，竖图600x900
+func fitImageSize(width, height, maxWidth, maxHeight int) (int, int) {
+	if maxWidth <= 0 || maxHeight <= 0 {
+		if width > height {
+			maxWidth, maxHeight = 1280, 720
+		} else {
+			maxWidth, maxHeight = 600, 900
+		}
+	}
+	ratio := float64(maxWidth) / float64(width)
+	if r := float64(maxHeight) / float64(height); r < ratio {
+		ratio = r
+	}
+	if ratio >= 1 {
+		return width, height
+	}
+	newWidth, newHeight := int(float64(width)*ratio), int(float64(height)*ratio)
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+	return newWidth, newHeight
+}
+
+// OptimizeStoredImage 处理存储后端中已批准的 /assets/... 图片
+// 超过最大尺寸时等比缩放、含有元数据时去除，以原格式写回，其他情况原文件保持不变；并生成 .webp 和可选的 .avif 版本供静态资源服务按 Accept 协商；
+// 动画GIF保持原文件不变，只生成动画WebP版本。完成后记录感知哈希并生成响应式尺寸
+func OptimizeStoredImage(assetPath string, maxWidth, maxHeight int) error {
+	key, ok := storage.KeyFromAssetPath(assetPath)
+	if !ok {
+		return fmt.Errorf("无效的图片路径: %s", assetPath)
+	}
+	if IsDerivedImagePath(key) {
+		return nil
+	}
+
+	backend := storage.Default()
+	reader, err := backend.Get(key)
+	if err != nil {
+		return err
+	}
+	data, err := readAllLimited(reader, maxResizeSourceBytes)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	format, err := detectImageTypeFromBytes(data)
+	if err != nil {
+		return err
+	}
+
+	if format == "gif" {
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("解码GIF文件失败: %w", err)
+		}
+		if len(anim.Image) > 1 {
+			return optimizeAnimatedGif(assetPath, key, anim)
+		}
+	}
+
+	src, err := decodeImageWithLimits(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	bounds := src.Bounds()
+	newWidth, newHeight := fitImageSize(bounds.Dx(), bounds.Dy(), maxWidth, maxHeight)
+	resized := newWidth != bounds.Dx() || newHeight != bounds.Dy()
+	img := src
+	if resized {
+		img = imaging.Resize(src, newWidth, newHeight, imaging.Lanczos)
+	}
+
+	// 不需要缩放且不含元数据时原文件保持不变，避免有损格式重复编码
+	rewritten := resized || hasImageMetadata(data, format)
+	if rewritten {
+		encoded, err := encodeImageAs(img, format)
+		if err != nil {
+			return err
+		}
+		if err := backend.Put(key, bytes.NewReader(encoded), int64(len(encoded)), "image/"+format); err != nil {
+			return fmt.Errorf("写回图片失败: %w", err)
+		}
+	}
+
+	siblings, err := buildImageSiblings(img, format)
+	if err != nil {
+		return fmt.Errorf("生成WebP/AVIF版本失败: %w", err)
+	}
+	for suffix, sibling := range siblings {
+		siblingKey := ImageSiblingKey(key, suffix)
+		if err := backend.Put(siblingKey, bytes.NewReader(sibling), int64(len(sibling)), siblingContentType(suffix)); err != nil {
+			return fmt.Errorf("保存 %s 失败: %w", siblingKey, err)
+		}
+	}
+
+	recordAssetDerivatives(assetPath, src, newWidth, newHeight)
+	log.Printf("成功处理图片 %s (尺寸: %dx%d, 重写原图: %v, 生成 %d 个格式版本)", assetPath, newWidth, newHeight, rewritten, len(siblings))
+	return nil
+}
+
+// hasImageMetadata 判断图片数据是否含有需要去除的元数据
+// JPEG 检查 APP1-APP15 和注释段，PNG 检查文本、EXIF 和时间块，WebP 检查 EXIF 和 XMP 块；GIF 不含EXIF，视为没有
+func hasImageMetadata(data []byte, format string) bool {
+	switch format {
+	case "jpeg":
+		return jpegHasMetadata(data)
+	case "png":
+		return pngHasMetadata(data)
+	case "webp":
+		return webpHasMetadata(data)
+	}
+	return false
+}
+
+// jpegHasMetadata 遍历图像数据之前的段，数据不完整时按含有元数据处理
+func jpegHasMetadata(data []byte) bool {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return true
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return true
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			// 填充字节
+			i++
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// 图像数据开始或结束，之后不再有元数据段
+			return false
+		case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE:
+			return true
+		}
+		i += 2 + (int(data[i+2])<<8 | int(data[i+3]))
+	}
+	return true
+}
+
+// pngHasMetadata 遍历PNG块，数据不完整时按含有元数据处理
+func pngHasMetadata(data []byte) bool {
+	const signatureLen = 8
+	if len(data) < signatureLen {
+		return true
+	}
+	for i := signatureLen; i+8 <= len(data); {
+		length := int(data[i])<<24 | int(data[i+1])<<16 | int(data[i+2])<<8 | int(data[i+3])
+		switch string(data[i+4 : i+8]) {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+			return true
+		case "IEND":
+			return false
+		}
+		if length < 0 {
+			return true
+		}
+		i += 12 + length
+	}
+	return true
+}
+
+// webpHasMetadata 查找 EXIF 和 XMP 块，数据不完整时按含有元数据处理
+func webpHasMetadata(data []byte) bool {
+	chunks, ok := webpChunkIDs(data)
+	if !ok {
+		return true
+	}
+	for _, id := range chunks {
+		if id == "EXIF" || id == "XMP " {
+			return true
+		}
+	}
+	return false
+}
+
+// webpIsLossless 判断WebP是否使用无损编码（含 VP8L 块）
+func webpIsLossless(data []byte) bool {
+	chunks, _ := webpChunkIDs(data)
+	for _, id := range chunks {
+		if id == "VP8L" {
+			return true
+		}
+	}
+	return false
+}
+
+// webpChunkIDs 遍历RIFF块返回块标识，数据不完整时第二个返回值为 false
+func webpChunkIDs(data []byte) ([]string, bool) {
+	const headerLen = 12
+	if len(data) < headerLen {
+		return nil, false
+	}
+	var ids []string
+	i := headerLen
+	for i+8 <= len(data) {
+		ids = append(ids, string(data[i:i+4]))
+		size := int(data[i+4]) | int(data[i+5])<<8 | int(data[i+6])<<16 | int(data[i+7])<<24
+		// 块大小为奇数时有一个填充字节
+		i += 8 + size + size&1
+	}
+	return ids, i == len(data)
+}
+
+// optimizeAnimatedGif 为动画GIF生成动画WebP版本，原GIF保持不变
+func optimizeAnimatedGif(assetPath, key string, anim *gif.GIF) error {
+	var buf bytes.Buffer
+	if err := EncodeAnimatedWebP(&buf, anim, AnimatedWebPOptions{Mixed: true, Quality: 80}); err != nil {
+		return fmt.Errorf("编码动画WebP失败: %w", err)
+	}
+	siblingKey := ImageSiblingKey(key, WebPSiblingSuffix)
+	if err := storage.Default().Put(siblingKey, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "image/webp"); err != nil {
+		return fmt.Errorf("保存 %s 失败: %w", siblingKey, err)
+	}
+
+	// 感知哈希和响应式尺寸使用第一帧
+	first := anim.Image[0]
+	recordAssetDerivatives(assetPath, first, first.Bounds().Dx(), first.Bounds().Dy())
+	log.Printf("成功为动画GIF %s 生成动画WebP版本 (帧数: %d)", assetPath, len(anim.Image))
+	return nil
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"math/bits"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+
+	"dongman/internal/models"
+	"dongman/internal/storage"
+)
+
+// NearDuplicateThreshold 判定为近似重复的最大汉明距离（64位dHash）
+const NearDuplicateThreshold = 10
+
+// DHash 计算图片的64位差异哈希
+// 缩放为9x8灰度图后比较每行相邻像素的亮度，对缩放、压缩和轻微调色不敏感
+func DHash(img image.Image) uint64 {
+	small := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := small.Pix[y*small.Stride+x*4]
+			right := small.Pix[y*small.Stride+(x+1)*4]
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// HammingDistance 计算两个哈希的汉明距离
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FormatImageHash 将哈希格式化为16位十六进制字符串
+func FormatImageHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// ParseImageHash 解析十六进制哈希字符串
+func ParseImageHash(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
+
+// ImageHashFromReader 从图片数据计算感知哈希
+func ImageHashFromReader(r io.Reader) (uint64, error) {
+	img, err := decodeImageWithLimits(r)
+	if err != nil {
+		return 0, err
+	}
+	return DHash(img), nil
+}
+
+// decodeImageWithLimits 读取并解码图片，解码前检查大小和像素数，并按EXIF方向矫正
+func decodeImageWithLimits(r io.Reader) (image.Image, error) {
+	data, err := readAllLimited(r, maxResizeSourceBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解析图片失败: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxResizeSourcePixels {
+		return nil, ErrSourceTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %w", err)
+	}
+	img, _ = correctImageOrientationFromReader(img, bytes.NewReader(data))
+	return img, nil
+}
+
+// readAllLimited 读取全部数据，超过 limit 字节时返回 ErrSourceTooLarge
+func readAllLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取图片失败: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrSourceTooLarge
+	}
+	return data, nil
+}
+
+// EnsureImageHash 获取 /assets/... 图片的感知哈希，数据库中没有时从存储读取计算并保存
+func EnsureImageHash(assetPath string, known map[string]string) (uint64, error) {
+	if hexHash, ok := known[assetPath]; ok {
+		if hash, err := ParseImageHash(hexHash); err == nil {
+			return hash, nil
+		}
+	}
+
+	key, ok := storage.KeyFromAssetPath(assetPath)
+	if !ok {
+		return 0, fmt.Errorf("无效的图片路径: %s", assetPath)
+	}
+	reader, err := storage.Default().Get(key)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	hash, err := ImageHashFromReader(reader)
+	if err != nil {
+		return 0, err
+	}
+	saveImageHash(assetPath, hash)
+	return hash, nil
+}
+
+// RecordImageHash 从已读入内存的图片数据计算并保存感知哈希，上传图片后调用
+func RecordImageHash(assetPath string, data []byte) error {
+	hash, err := ImageHashFromReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	saveImageHash(assetPath, hash)
+	return nil
+}
+
+// saveImageHash 保存感知哈希，数据库未初始化时忽略
+func saveImageHash(assetPath string, hash uint64) {
+	if models.DB == nil {
+		return
+	}
+	if err := models.SaveImageHash(assetPath, FormatImageHash(hash)); err != nil {
+		log.Printf("%v", err)
+	}
+}
@@ -0,0 +1,593 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"dongman/internal/config"
+)
+
+const (
+	// MaxImageOps 单次处理的最大操作数
+	MaxImageOps = 10
+	// maxWatermarkTextLength 文字水印的最大字符数
+	maxWatermarkTextLength = 100
+)
+
+// UploadErrInvalidOps 图片处理操作列表不合法
+const UploadErrInvalidOps = "INVALID_OPERATIONS"
+
+// ImageOp 一个图片处理操作，按 Op 使用对应字段
+//
+//	crop:      x/y/width/height 指定区域，或 aspect（如 "2:3"）配合 anchor 裁剪最大区域
+//	resize:    width/height/fit，与缩放服务一致，不放大原图
+//	rotate:    angle 为顺时针角度，非90度倍数时空白处填充 background（默认透明）
+//	watermark: text 或 image（/assets/... 路径，为 "upload" 时使用请求中的 watermark 文件），
+//	           position、opacity、size（文字为字号，图片为相对图片宽度的比例）、color、margin
+//	format:    format（jpeg、png、webp）和 quality（1-100）
+type ImageOp struct {
+	Op string `json:"op"`
+
+	X      int    `json:"x,omitempty"`
+	Y      int    `json:"y,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Aspect string `json:"aspect,omitempty"`
+	Anchor string `json:"anchor,omitempty"`
+
+	Fit string `json:"fit,omitempty"`
+
+	Angle      float64 `json:"angle,omitempty"`
+	Background string  `json:"background,omitempty"`
+
+	Text     string  `json:"text,omitempty"`
+	Image    string  `json:"image,omitempty"`
+	Position string  `json:"position,omitempty"`
+	Opacity  float64 `json:"opacity,omitempty"`
+	Size     float64 `json:"size,omitempty"`
+	Color    string  `json:"color,omitempty"`
+	Margin   int     `json:"margin,omitempty"`
+
+	Format  string `json:"format,omitempty"`
+	Quality int    `json:"quality,omitempty"`
+}
+
+// ProcessedImage 处理结果
+type ProcessedImage struct {
+	Data        []byte
+	Format      string // jpeg、png、webp
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// imageAnchors 裁剪和水印位置
+var imageAnchors = map[string]imaging.Anchor{
+	"center":       imaging.Center,
+	"top":          imaging.Top,
+	"bottom":       imaging.Bottom,
+	"left":         imaging.Left,
+	"right":        imaging.Right,
+	"top-left":     imaging.TopLeft,
+	"top-right":    imaging.TopRight,
+	"bottom-left":  imaging.BottomLeft,
+	"bottom-right": imaging.BottomRight,
+}
+
+func invalidOp(i int, format string, args ...interface{}) *UploadError {
+	return newUploadError(UploadErrInvalidOps, "第%d个操作: %s", i+1, fmt.Sprintf(format, args...))
+}
+
+// ValidateImageOps 检查操作列表，返回 *UploadError
+func ValidateImageOps(ops []ImageOp) error {
+	if len(ops) == 0 {
+		return newUploadError(UploadErrInvalidOps, "请至少指定一个操作")
+	}
+	if len(ops) > MaxImageOps {
+		return newUploadError(UploadErrInvalidOps, "操作过多，最多 %d 个", MaxImageOps)
+	}
+
+	for i, op := range ops {
+		switch op.Op {
+		case "crop":
+			if op.Aspect != "" {
+				if _, _, ok := parseAspect(op.Aspect); !ok {
+					return invalidOp(i, "无效的裁剪比例 %s", op.Aspect)
+				}
+			} else if op.Width <= 0 || op.Height <= 0 || op.X < 0 || op.Y < 0 {
+				return invalidOp(i, "裁剪需要指定 aspect 或正数的 width、height")
+			}
+			if _, ok := imageAnchors[op.Anchor]; op.Anchor != "" && !ok {
+				return invalidOp(i, "无效的位置 %s", op.Anchor)
+			}
+		case "resize":
+			if op.Width < 0 || op.Height < 0 || op.Width > MaxUploadImageSide || op.Height > MaxUploadImageSide {
+				return invalidOp(i, "宽高必须在 0-%d 之间", MaxUploadImageSide)
+			}
+			if op.Width == 0 && op.Height == 0 {
+				return invalidOp(i, "宽高至少指定一个")
+			}
+			if op.Width*op.Height > MaxUploadImagePixels {
+				return invalidOp(i, "目标尺寸(%dx%d)超过最大像素数 %d", op.Width, op.Height, MaxUploadImagePixels)
+			}
+			switch op.Fit {
+			case "", "contain", "cover", "fill":
+			default:
+				return invalidOp(i, "不支持的fit %s", op.Fit)
+			}
+		case "rotate":
+			if math.IsNaN(op.Angle) || math.IsInf(op.Angle, 0) {
+				return invalidOp(i, "无效的角度")
+			}
+			if _, ok := parseHexColor(op.Background); op.Background != "" && !ok {
+				return invalidOp(i, "无效的背景颜色 %s", op.Background)
+			}
+		case "watermark":
+			if (op.Text == "") == (op.Image == "") {
+				return invalidOp(i, "水印需要指定 text 或 image 之一")
+			}
+			if len([]rune(op.Text)) > maxWatermarkTextLength {
+				return invalidOp(i, "水印文字最多 %d 个字符", maxWatermarkTextLength)
+			}
+			if _, ok := imageAnchors[op.Position]; op.Position != "" && !ok {
+				return invalidOp(i, "无效的位置 %s", op.Position)
+			}
+			if op.Opacity < 0 || op.Opacity > 1 {
+				return invalidOp(i, "不透明度必须在 0-1 之间")
+			}
+			if op.Size < 0 || (op.Image != "" && op.Size > 1) || op.Size > 500 {
+				return invalidOp(i, "无效的水印大小")
+			}
+			if _, ok := parseHexColor(op.Color); op.Color != "" && !ok {
+				return invalidOp(i, "无效的颜色 %s", op.Color)
+			}
+			if op.Margin < 0 || op.Margin > 1000 {
+				return invalidOp(i, "无效的边距")
+			}
+		case "format":
+			switch normalizeImageFormat(op.Format) {
+			case "jpeg", "png", "webp":
+			default:
+				return invalidOp(i, "不支持的格式 %s，可选 jpeg、png、webp", op.Format)
+			}
+			if op.Quality < 0 || op.Quality > 100 {
+				return invalidOp(i, "质量必须在 1-100 之间")
+			}
+		default:
+			return invalidOp(i, "不支持的操作 %s", op.Op)
+		}
+	}
+	return nil
+}
+
+// ProcessImage 按顺序执行操作列表，返回编码后的图片
+// watermark 为请求中上传的水印图片（image 为 "upload" 时使用），loadAsset 用于读取 /assets/... 水印图片。
+// 输入大小和尺寸限制与上传一致；GIF只处理第一帧，未指定格式时输出PNG
+func ProcessImage(data []byte, ops []ImageOp, watermark []byte, loadAsset func(assetPath string) ([]byte, error)) (*ProcessedImage, error) {
+	if err := ValidateImageOps(ops); err != nil {
+		return nil, err
+	}
+	img, srcFormat, err := decodeProcessInput(data)
+	if err != nil {
+		return nil, err
+	}
+
+	format, quality := srcFormat, 0
+	if format == "gif" {
+		format = "png"
+	}
+	for i, op := range ops {
+		// 放大和旋转会增大图片，执行前按预计的输出尺寸检查，避免分配超大的图片
+		if w, h := opOutputSize(img, op); w > MaxUploadImageSide || h > MaxUploadImageSide || w*h > MaxUploadImagePixels {
+			return nil, invalidOp(i, "处理后尺寸(%dx%d)超过限制（最大边长 %d、最大像素数 %d）", w, h, MaxUploadImageSide, MaxUploadImagePixels)
+		}
+		switch op.Op {
+		case "crop":
+			img, err = cropImage(img, op)
+		case "resize":
+			img = resizeWithFit(img, ResizeOptions{Width: op.Width, Height: op.Height, Fit: op.Fit})
+		case "rotate":
+			img = rotateImage(img, op)
+		case "watermark":
+			img, err = watermarkImage(img, op, watermark, loadAsset)
+		case "format":
+			format, quality = normalizeImageFormat(op.Format), op.Quality
+		}
+		if err != nil {
+			if uploadErr, ok := err.(*UploadError); ok {
+				return nil, invalidOp(i, "%s", uploadErr.Message)
+			}
+			return nil, fmt.Errorf("第%d个操作(%s)失败: %w", i+1, op.Op, err)
+		}
+		if b := img.Bounds(); b.Dx() > MaxUploadImageSide || b.Dy() > MaxUploadImageSide || b.Dx()*b.Dy() > MaxUploadImagePixels {
+			return nil, invalidOp(i, "处理后尺寸(%dx%d)超过限制（最大边长 %d、最大像素数 %d）", b.Dx(), b.Dy(), MaxUploadImageSide, MaxUploadImagePixels)
+		}
+	}
+
+	out, err := encodeImageWithQuality(img, format, quality)
+	if err != nil {
+		return nil, newUploadError(UploadErrProcessFailed, "处理图片失败: %v", err)
+	}
+	return &ProcessedImage{
+		Data:        out,
+		Format:      format,
+		ContentType: "image/" + format,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}, nil
+}
+
+// opOutputSize 估算操作输出尺寸的上限：缩放按请求的宽高（只指定一边时按原图比例计算另一边，
+// 与 resizeWithFit 一样不超过原图），任意角度旋转按旋转后的外接矩形，其他操作不会增大图片，返回当前尺寸
+func opOutputSize(img image.Image, op ImageOp) (int, int) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return w, h
+	}
+	switch op.Op {
+	case "resize":
+		outW, outH := op.Width, op.Height
+		if outW == 0 {
+			outW = int(math.Ceil(float64(outH) * float64(w) / float64(h)))
+		} else if outH == 0 {
+			outH = int(math.Ceil(float64(outW) * float64(h) / float64(w)))
+		}
+		return min(outW, w), min(outH, h)
+	case "rotate":
+		rad := op.Angle * math.Pi / 180
+		sin, cos := math.Abs(math.Sin(rad)), math.Abs(math.Cos(rad))
+		// 减去误差，避免 90° 这类角度因浮点误差多算一个像素
+		return int(math.Ceil(float64(w)*cos + float64(h)*sin - 1e-6)), int(math.Ceil(float64(w)*sin + float64(h)*cos - 1e-6))
+	}
+	return w, h
+}
+
+// decodeProcessInput 按上传限制校验并解码输入图片，按EXIF方向矫正
+func decodeProcessInput(data []byte) (image.Image, string, error) {
+	if len(data) > MaxUploadImageBytes {
+		return nil, "", newUploadError(UploadErrFileTooLarge, "文件过大，最大支持 %dMB", MaxUploadImageBytes>>20)
+	}
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	imageType, err := detectImageTypeFromBytes(head)
+	if err != nil {
+		return nil, "", newUploadError(UploadErrUnsupportedType, "文件内容不是有效的图片")
+	}
+	switch imageType {
+	case "jpeg", "png", "gif", "webp":
+	default:
+		return nil, "", newUploadError(UploadErrUnsupportedType, "不支持的图片格式: %s", imageType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", newUploadError(UploadErrInvalidImage, "图片已损坏或无法解析")
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 ||
+		cfg.Width > MaxUploadImageSide || cfg.Height > MaxUploadImageSide ||
+		cfg.Width*cfg.Height > MaxUploadImagePixels {
+		return nil, "", newUploadError(UploadErrTooManyPixels, "图片尺寸过大(%dx%d)，最大边长 %d 像素",
+			cfg.Width, cfg.Height, MaxUploadImageSide)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", newUploadError(UploadErrInvalidImage, "图片已损坏或无法解析")
+	}
+	img, _ = correctImageOrientationFromReader(img, bytes.NewReader(data))
+	return img, imageType, nil
+}
+
+// cropImage 按区域或比例裁剪
+func cropImage(img image.Image, op ImageOp) (image.Image, error) {
+	bounds := img.Bounds()
+	anchor := imaging.Center
+	if op.Anchor != "" {
+		anchor = imageAnchors[op.Anchor]
+	}
+
+	if op.Aspect != "" {
+		aw, ah, _ := parseAspect(op.Aspect)
+		w, h := bounds.Dx(), bounds.Dy()
+		// 保持一边不变，取该比例下的最大区域
+		if w*ah > h*aw {
+			w = int(math.Round(float64(h) * float64(aw) / float64(ah)))
+		} else {
+			h = int(math.Round(float64(w) * float64(ah) / float64(aw)))
+		}
+		if w < 1 || h < 1 {
+			return nil, newUploadError(UploadErrInvalidOps, "图片太小，无法按 %s 裁剪", op.Aspect)
+		}
+		return imaging.CropAnchor(img, w, h, anchor), nil
+	}
+
+	rect := image.Rect(op.X, op.Y, op.X+op.Width, op.Y+op.Height).Add(bounds.Min).Intersect(bounds)
+	if rect.Empty() {
+		return nil, newUploadError(UploadErrInvalidOps, "裁剪区域超出图片范围(%dx%d)", bounds.Dx(), bounds.Dy())
+	}
+	return imaging.Crop(img, rect), nil
+}
+
+// rotateImage 顺时针旋转，90度的倍数无损旋转
+func rotateImage(img image.Image, op ImageOp) image.Image {
+	angle := math.Mod(op.Angle, 360)
+	if angle < 0 {
+		angle += 360
+	}
+	switch angle {
+	case 0:
+		return img
+	case 90:
+		return imaging.Rotate270(img)
+	case 180:
+		return imaging.Rotate180(img)
+	case 270:
+		return imaging.Rotate90(img)
+	}
+	bg := color.Color(color.Transparent)
+	if c, ok := parseHexColor(op.Background); ok {
+		bg = c
+	}
+	// imaging.Rotate 为逆时针
+	return imaging.Rotate(img, -angle, bg)
+}
+
+// watermarkImage 添加文字或图片水印
+func watermarkImage(img image.Image, op ImageOp, uploaded []byte, loadAsset func(string) ([]byte, error)) (image.Image, error) {
+	bounds := img.Bounds()
+	opacity := op.Opacity
+	if opacity == 0 {
+		opacity = 0.5
+	}
+	margin := op.Margin
+	if margin == 0 {
+		margin = max(bounds.Dx(), bounds.Dy()) / 50
+	}
+
+	var mark image.Image
+	var err error
+	if op.Text != "" {
+		size := op.Size
+		if size == 0 {
+			size = math.Max(12, float64(bounds.Dy())/25)
+		}
+		c := color.Color(color.White)
+		if parsed, ok := parseHexColor(op.Color); ok {
+			c = parsed
+		}
+		mark, err = renderWatermarkText(op.Text, size, c)
+	} else {
+		mark, err = loadWatermarkImage(op.Image, uploaded, loadAsset)
+		if err == nil {
+			ratio := op.Size
+			if ratio == 0 {
+				ratio = 0.2
+			}
+			width := int(float64(bounds.Dx()) * ratio)
+			if width < 1 {
+				width = 1
+			}
+			mark = scaleWatermark(mark, width, bounds.Dy())
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	position := "bottom-right"
+	if op.Position != "" {
+		position = op.Position
+	}
+	return imaging.Overlay(img, mark, watermarkPoint(bounds, mark.Bounds(), position, margin), opacity), nil
+}
+
+// scaleWatermark 等比缩放水印图片到宽度 width，高度不超过 maxHeight
+// 只按宽度缩放时细长的水印图片（如1x10000）会被放大成超大图片，因此同时限制高度
+func scaleWatermark(mark image.Image, width, maxHeight int) image.Image {
+	mw, mh := mark.Bounds().Dx(), mark.Bounds().Dy()
+	if mw == 0 || mh == 0 {
+		return mark
+	}
+	scale := math.Min(float64(width)/float64(mw), float64(maxHeight)/float64(mh))
+	w := max(1, int(math.Round(float64(mw)*scale)))
+	h := max(1, int(math.Round(float64(mh)*scale)))
+	return imaging.Resize(mark, w, h, imaging.Lanczos)
+}
+
+// watermarkPoint 计算水印左上角相对图片的位置
+func watermarkPoint(bounds, mark image.Rectangle, position string, margin int) image.Point {
+	w, h := bounds.Dx(), bounds.Dy()
+	mw, mh := mark.Dx(), mark.Dy()
+	x, y := (w-mw)/2, (h-mh)/2
+	if strings.Contains(position, "left") {
+		x = margin
+	} else if strings.Contains(position, "right") {
+		x = w - mw - margin
+	}
+	if strings.HasPrefix(position, "top") {
+		y = margin
+	} else if strings.HasPrefix(position, "bottom") {
+		y = h - mh - margin
+	}
+	return image.Pt(x, y)
+}
+
+// loadWatermarkImage 读取水印图片
+func loadWatermarkImage(source string, uploaded []byte, loadAsset func(string) ([]byte, error)) (image.Image, error) {
+	var data []byte
+	if source == "upload" {
+		if len(uploaded) == 0 {
+			return nil, newUploadError(UploadErrInvalidOps, "请上传水印图片（watermark 字段）")
+		}
+		data = uploaded
+	} else {
+		if !strings.HasPrefix(source, "/assets/") || loadAsset == nil {
+			return nil, newUploadError(UploadErrInvalidOps, "水印图片必须是 /assets/... 路径或 upload")
+		}
+		var err error
+		data, err = loadAsset(source)
+		if err != nil {
+			return nil, newUploadError(UploadErrInvalidOps, "读取水印图片失败: %s", source)
+		}
+	}
+	mark, _, err := decodeProcessInput(data)
+	if err != nil {
+		return nil, newUploadError(UploadErrInvalidOps, "水印图片无效: %v", err)
+	}
+	return mark, nil
+}
+
+var (
+	watermarkFont     *sfnt.Font
+	watermarkFontErr  error
+	watermarkFontOnce sync.Once
+)
+
+// loadWatermarkFont 加载水印字体，配置了 WATERMARK_FONT 时使用该字体，否则使用内置的Go字体（不含中文）
+func loadWatermarkFont() (*sfnt.Font, error) {
+	watermarkFontOnce.Do(func() {
+		data := goregular.TTF
+		if config.WatermarkFont != "" {
+			fontData, err := os.ReadFile(config.WatermarkFont)
+			if err != nil {
+				watermarkFontErr = fmt.Errorf("读取水印字体失败: %w", err)
+				return
+			}
+			data = fontData
+		}
+		watermarkFont, watermarkFontErr = opentype.Parse(data)
+		if watermarkFontErr != nil {
+			watermarkFontErr = fmt.Errorf("解析水印字体失败: %w", watermarkFontErr)
+		}
+	})
+	return watermarkFont, watermarkFontErr
+}
+
+// renderWatermarkText 将文字渲染为透明背景的图片
+func renderWatermarkText(text string, size float64, c color.Color) (image.Image, error) {
+	f, err := loadWatermarkFont()
+	if err != nil {
+		return nil, err
+	}
+	var buf sfnt.Buffer
+	for _, r := range text {
+		if idx, err := f.GlyphIndex(&buf, r); err != nil || idx == 0 {
+			return nil, newUploadError(UploadErrInvalidOps, "水印字体不支持字符 %q，请配置 WATERMARK_FONT", r)
+		}
+	}
+
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("创建字体失败: %w", err)
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	width := font.MeasureString(face, text).Ceil()
+	height := (metrics.Ascent + metrics.Descent).Ceil()
+	if width < 1 || height < 1 {
+		return nil, newUploadError(UploadErrInvalidOps, "水印文字为空")
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	drawer := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.Point26_6{X: 0, Y: metrics.Ascent},
+	}
+	drawer.DrawString(text)
+	return dst, nil
+}
+
+// encodeImageWithQuality 按格式和质量编码，quality 为0时使用默认值；JPEG不支持透明，先合成到白色背景
+func encodeImageWithQuality(img image.Image, format string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		if quality == 0 {
+			quality = 90
+		}
+		bg := image.NewRGBA(img.Bounds())
+		draw.Draw(bg, bg.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(bg, bg.Bounds(), img, img.Bounds().Min, draw.Over)
+		err = jpeg.Encode(&buf, bg, &jpeg.Options{Quality: quality})
+	case "png":
+		err = png.Encode(&buf, img)
+	case "webp":
+		if quality == 0 {
+			quality = 80
+		}
+		err = webp.Encode(&buf, img, &webp.Options{Lossless: quality == 100, Quality: float32(quality)})
+	default:
+		return nil, fmt.Errorf("不支持的图片格式: %s", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("编码%s图片失败: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// normalizeImageFormat 统一格式名称
+func normalizeImageFormat(format string) string {
+	format = strings.ToLower(format)
+	if format == "jpg" {
+		return "jpeg"
+	}
+	return format
+}
+
+// parseAspect 解析 "2:3" 形式的比例
+func parseAspect(s string) (int, int, bool) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	w, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+	h, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err1 != nil || err2 != nil || w <= 0 || h <= 0 || w > 100 || h > 100 {
+		return 0, 0, false
+	}
+	return w, h, true
+}
+
+// parseHexColor 解析 #RGB、#RRGGBB 或 #RRGGBBAA 颜色
+func parseHexColor(s string) (color.NRGBA, bool) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	if len(s) != 8 {
+		return color.NRGBA{}, false
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, true
+}
@@ -0,0 +1,309 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+
+	"dongman/internal/config"
+	"dongman/internal/storage"
+)
+
+const (
+	// MaxResizeDimension 缩放输出的最大边长
+	MaxResizeDimension = 2048
+	// maxResizeSourcePixels 允许缩放的源图最大像素数，防止解压炸弹
+	maxResizeSourcePixels = 50 * 1000 * 1000
+	// maxResizeSourceBytes 允许缩放的源图最大字节数
+	maxResizeSourceBytes = 50 << 20
+)
+
+var (
+	// ErrInvalidResizeOptions 缩放参数非法
+	ErrInvalidResizeOptions = errors.New("无效的缩放参数")
+	// ErrSourceTooLarge 源图过大，拒绝处理
+	ErrSourceTooLarge = errors.New("源图片过大")
+)
+
+// ResizeOptions 图片缩放参数
+type ResizeOptions struct {
+	Width  int    // 目标宽度，0 表示按高度等比
+	Height int    // 目标高度，0 表示按宽度等比
+	Fit    string // contain(默认，完整显示)、cover(裁剪填满)、fill(拉伸)
+	Format string // webp(默认)、jpeg、png
+}
+
+// ResizePresets 允许未签名访问的预设尺寸
+var ResizePresets = map[string]ResizeOptions{
+	"thumb":  {Width: 200, Height: 300, Fit: "cover", Format: "webp"},
+	"list":   {Width: 400, Height: 600, Fit: "contain", Format: "webp"},
+	"medium": {Width: 640, Fit: "contain", Format: "webp"},
+	"large":  {Width: 1280, Fit: "contain", Format: "webp"},
+}
+
+// Normalize 补全默认值并校验参数
+func (o *ResizeOptions) Normalize() error {
+	if o.Fit == "" {
+		o.Fit = "contain"
+	}
+	if o.Format == "" {
+		o.Format = "webp"
+	}
+	if o.Format == "jpg" {
+		o.Format = "jpeg"
+	}
+
+	if o.Width < 0 || o.Height < 0 || o.Width > MaxResizeDimension || o.Height > MaxResizeDimension {
+		return fmt.Errorf("%w: 宽高必须在 0-%d 之间", ErrInvalidResizeOptions, MaxResizeDimension)
+	}
+	if o.Width == 0 && o.Height == 0 {
+		return fmt.Errorf("%w: 宽高至少指定一个", ErrInvalidResizeOptions)
+	}
+	switch o.Fit {
+	case "contain", "cover", "fill":
+	default:
+		return fmt.Errorf("%w: 不支持的fit %s", ErrInvalidResizeOptions, o.Fit)
+	}
+	switch o.Format {
+	case "webp", "jpeg", "png":
+	default:
+		return fmt.Errorf("%w: 不支持的格式 %s", ErrInvalidResizeOptions, o.Format)
+	}
+	return nil
+}
+
+// canonical 返回参数的规范字符串，用于签名和缓存key
+func (o ResizeOptions) canonical(key string) string {
+	return fmt.Sprintf("%s|w=%d|h=%d|fit=%s|fmt=%s", key, o.Width, o.Height, o.Fit, o.Format)
+}
+
+// ContentType 返回输出格式对应的MIME类型
+func (o ResizeOptions) ContentType() string {
+	switch o.Format {
+	case "jpeg":
+		return "image/jpeg"
+	case "png":
+		return "image/png"
+	default:
+		return "image/webp"
+	}
+}
+
+var (
+	signingKey     []byte
+	signingKeyOnce sync.Once
+)
+
+// imageSigningKey 获取缩放参数签名密钥，未配置时生成随机密钥（重启后旧签名失效）
+func imageSigningKey() []byte {
+	signingKeyOnce.Do(func() {
+		if config.ImageSigningKey != "" {
+			signingKey = []byte(config.ImageSigningKey)
+			return
+		}
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			log.Printf("生成图片签名密钥失败: %v", err)
+		}
+		log.Printf("警告：未设置 IMAGE_SIGNING_KEY，使用随机密钥，重启后已签名的图片URL将失效")
+	})
+	return signingKey
+}
+
+// SignResizeOptions 对缩放参数进行HMAC签名
+func SignResizeOptions(key string, opts ResizeOptions) string {
+	mac := hmac.New(sha256.New, imageSigningKey())
+	mac.Write([]byte(opts.canonical(key)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyResizeSignature 校验缩放参数签名
+func VerifyResizeSignature(key string, opts ResizeOptions, sig string) bool {
+	expected, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, imageSigningKey())
+	mac.Write([]byte(opts.canonical(key)))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// ResizedImage 缩放结果
+type ResizedImage struct {
+	Path        string // 缓存文件路径
+	ETag        string
+	ContentType string
+	ModTime     time.Time
+}
+
+var (
+	// 限制同时进行的缩放任务数，避免CPU和内存被耗尽
+	resizeSemaphore = make(chan struct{}, runtime.NumCPU())
+
+	// 相同缓存key的并发请求只处理一次
+	resizeInflight   = make(map[string]*sync.WaitGroup)
+	resizeInflightMu sync.Mutex
+)
+
+// ResizeStoredImage 生成存储对象的缩放图片，结果缓存在磁盘上
+// 缓存key包含源对象的ETag，源图更新后自动生成新的缩放结果
+func ResizeStoredImage(key string, opts ResizeOptions) (*ResizedImage, error) {
+	if err := opts.Normalize(); err != nil {
+		return nil, err
+	}
+
+	backend := storage.Default()
+	info, err := backend.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size > maxResizeSourceBytes {
+		return nil, ErrSourceTooLarge
+	}
+
+	sum := sha256.Sum256([]byte(opts.canonical(key) + "|src=" + info.ETag))
+	cacheKey := hex.EncodeToString(sum[:])
+	cachePath := filepath.Join(config.ImageCacheDir, cacheKey[:2], cacheKey+"."+opts.Format)
+
+	result := func() (*ResizedImage, error) {
+		fileInfo, err := os.Stat(cachePath)
+		if err != nil {
+			return nil, err
+		}
+		return &ResizedImage{
+			Path:        cachePath,
+			ETag:        "\"" + cacheKey[:32] + "\"",
+			ContentType: opts.ContentType(),
+			ModTime:     fileInfo.ModTime(),
+		}, nil
+	}
+
+	if cached, err := result(); err == nil {
+		return cached, nil
+	}
+
+	// 合并相同key的并发请求
+	resizeInflightMu.Lock()
+	if wg, ok := resizeInflight[cacheKey]; ok {
+		resizeInflightMu.Unlock()
+		wg.Wait()
+		if cached, err := result(); err == nil {
+			return cached, nil
+		}
+		return nil, fmt.Errorf("生成缩放图片失败: %s", key)
+	}
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	resizeInflight[cacheKey] = wg
+	resizeInflightMu.Unlock()
+
+	defer func() {
+		resizeInflightMu.Lock()
+		delete(resizeInflight, cacheKey)
+		resizeInflightMu.Unlock()
+		wg.Done()
+	}()
+
+	resizeSemaphore <- struct{}{}
+	err = renderResizedImage(backend, key, opts, cachePath)
+	<-resizeSemaphore
+	if err != nil {
+		return nil, err
+	}
+	return result()
+}
+
+// renderResizedImage 读取源图、缩放并写入缓存文件
+func renderResizedImage(backend storage.Backend, key string, opts ResizeOptions, cachePath string) error {
+	reader, err := backend.Get(key)
+	if err != nil {
+		return err
+	}
+	srcImg, err := decodeImageWithLimits(reader)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	dstImg := resizeWithFit(srcImg, opts)
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(cachePath), ".resize-*")
+	if err != nil {
+		return fmt.Errorf("创建缓存文件失败: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+
+	switch opts.Format {
+	case "jpeg":
+		err = jpeg.Encode(tmpFile, dstImg, &jpeg.Options{Quality: 85})
+	case "png":
+		err = png.Encode(tmpFile, dstImg)
+	default:
+		err = webp.Encode(tmpFile, dstImg, &webp.Options{Lossless: false, Quality: 80})
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("编码缩放图片失败: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, cachePath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("保存缓存文件失败: %w", err)
+	}
+	return nil
+}
+
+// resizeWithFit 按fit模式缩放图片，不放大原图
+func resizeWithFit(img image.Image, opts ResizeOptions) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	w, h := opts.Width, opts.Height
+
+	// 只指定一边时统一按等比缩放处理
+	if w == 0 || h == 0 {
+		if w > srcW || h > srcH {
+			return img
+		}
+		return imaging.Resize(img, w, h, imaging.Lanczos)
+	}
+
+	switch opts.Fit {
+	case "cover":
+		// 目标尺寸超过原图时按比例缩小目标，保持裁剪比例
+		if w > srcW || h > srcH {
+			scale := math.Min(float64(srcW)/float64(w), float64(srcH)/float64(h))
+			w, h = int(float64(w)*scale), int(float64(h)*scale)
+			if w < 1 || h < 1 {
+				return img
+			}
+		}
+		return imaging.Fill(img, w, h, imaging.Center, imaging.Lanczos)
+	case "fill":
+		return imaging.Resize(img, min(w, srcW), min(h, srcH), imaging.Lanczos)
+	default:
+		// imaging.Fit 本身不会放大
+		return imaging.Fit(img, w, h, imaging.Lanczos)
+	}
+}
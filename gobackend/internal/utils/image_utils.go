@@ -6,12 +6,13 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
+	"path"
 	"path/filepath"
 	"time"
 
 	"github.com/google/uuid"
-	"dongman/internal/config"
+	"dongman/internal/models"
+	"dongman/internal/storage"
 )
 
 // CalculateFileHash 计算文件内容的SHA-256哈希值，并将文件指针重置到开头
@@ -46,30 +47,56 @@ func CalculateFileHash(file io.ReadSeeker) (string, error) {
 
 // SaveUploadedFile 保存上传的文件到uploads目录，并返回相对路径
 func SaveUploadedFile(file io.Reader, filename string) (string, error) {
-	// 检查并创建上传目录
-	uploadDir, err := ensureUploadDir()
-	if err != nil {
-		return "", err
+	// 按日期生成对象key
+	dateDir := time.Now().Format("20060102")
+	uniqueFilename := generateUniqueFilename(filename)
+	key := path.Join("uploads", dateDir, uniqueFilename)
+
+	// 写入存储后端
+	if err := storage.Default().Put(key, file, -1, ""); err != nil {
+		return "", fmt.Errorf("保存文件失败: %w", err)
 	}
 
-	// 生成唯一文件名
-	uniqueFilename := generateUniqueFilename(filename)
-	filePath := filepath.Join(uploadDir, uniqueFilename)
+	// 返回相对于服务器的路径
+	return storage.AssetPath(key), nil
+}
 
-	// 创建目标文件
-	dst, err := os.Create(filePath)
-	if err != nil {
-		return "", fmt.Errorf("创建文件失败: %w", err)
+// MoveAssetToResource 将 /assets/... 下的单个图片移动到资源目录 imgs/{resourceID}/ 下
+// 源文件不存在或移动失败时返回错误，由调用方决定是否保留原路径
+func MoveAssetToResource(resourceID int, imgPath string) (string, error) {
+	srcKey, ok := storage.KeyFromAssetPath(imgPath)
+	if !ok {
+		return "", fmt.Errorf("无效的图片路径: %s", imgPath)
 	}
-	defer dst.Close()
 
-	// 复制文件内容
-	if _, err = io.Copy(dst, file); err != nil {
-		return "", fmt.Errorf("写入文件失败: %w", err)
+	backend := storage.Default()
+	if !storage.Exists(backend, srcKey) {
+		return "", fmt.Errorf("源文件不存在: %s", srcKey)
 	}
 
-	// 返回相对于服务器的路径
-	return filepath.Join("/assets/uploads", time.Now().Format("20060102"), uniqueFilename), nil
+	dstKey := path.Join("imgs", fmt.Sprintf("%d", resourceID), path.Base(srcKey))
+	if err := storage.Move(backend, srcKey, dstKey); err != nil {
+		return "", fmt.Errorf("移动图片失败: %s -> %s, 错误: %w", srcKey, dstKey, err)
+	}
+
+	// 一并移动已生成的 .webp/.avif 版本
+	for _, suffix := range []string{WebPSiblingSuffix, AVIFSiblingSuffix} {
+		siblingKey := ImageSiblingKey(srcKey, suffix)
+		if !storage.Exists(backend, siblingKey) {
+			continue
+		}
+		if err := storage.Move(backend, siblingKey, ImageSiblingKey(dstKey, suffix)); err != nil {
+			log.Printf("移动图片格式版本失败: %s, 错误: %v", siblingKey, err)
+		}
+	}
+
+	// 同步更新感知哈希记录
+	if err := models.RenameImageHash(storage.AssetPath(srcKey), storage.AssetPath(dstKey)); err != nil {
+		log.Printf("%v", err)
+	}
+
+	log.Printf("成功移动图片: %s -> %s", srcKey, dstKey)
+	return storage.AssetPath(dstKey), nil
 }
 
 // MoveApprovedImages 移动已批准的图片到资源目录
@@ -78,15 +105,6 @@ func MoveApprovedImages(resourceID int, imagePaths []string) ([]string, error) {
 		return []string{}, nil
 	}
 
-	// 获取资源目录
-	assetsDir := config.GetAssetsDir()
-
-	// 创建目标目录
-	imgsDir := filepath.Join(assetsDir, "imgs", fmt.Sprintf("%d", resourceID))
-	if err := os.MkdirAll(imgsDir, 0755); err != nil {
-		return nil, fmt.Errorf("创建目录失败: %w", err)
-	}
-
 	// 移动每个图片
 	newPaths := make([]string, 0, len(imagePaths))
 	for _, imgPath := range imagePaths {
@@ -94,33 +112,14 @@ func MoveApprovedImages(resourceID int, imagePaths []string) ([]string, error) {
 			continue
 		}
 
-		// 提取文件名
-		filename := filepath.Base(imgPath)
-
-		// 源文件路径
-		sourcePath := filepath.Join(assetsDir, imgPath[7:]) // 去掉前面的"/assets"
-		
-		// 目标文件路径
-		destPath := filepath.Join(imgsDir, filename)
-
-		// 检查源文件是否存在
-		if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
-			log.Printf("源文件不存在: %s", sourcePath)
-			newPaths = append(newPaths, imgPath) // 保留原路径
-			continue
-		}
-
-		// 移动文件 (先复制后删除)
-		if err := moveFile(sourcePath, destPath); err != nil {
-			log.Printf("移动图片失败: %s -> %s, 错误: %v", sourcePath, destPath, err)
+		newPath, err := MoveAssetToResource(resourceID, imgPath)
+		if err != nil {
+			log.Printf("%v", err)
 			newPaths = append(newPaths, imgPath) // 保留原路径
 			continue
 		}
 
-		log.Printf("成功移动图片: %s -> %s", sourcePath, destPath)
-
 		// 更新路径
-		newPath := fmt.Sprintf("/assets/imgs/%d/%s", resourceID, filename)
 		newPaths = append(newPaths, newPath)
 	}
 
@@ -133,104 +132,18 @@ func MoveApprovedImage(resourceID int, imagePath string) (string, error) {
 		return "", nil
 	}
 
-	// 获取资源目录
-	assetsDir := config.GetAssetsDir()
-
-	// 创建目标目录
-	imgsDir := filepath.Join(assetsDir, "imgs", fmt.Sprintf("%d", resourceID))
-	if err := os.MkdirAll(imgsDir, 0755); err != nil {
-		return "", fmt.Errorf("创建目录失败: %w", err)
-	}
-
-	// 提取文件名
-	filename := filepath.Base(imagePath)
-
-	// 源文件路径
-	sourcePath := filepath.Join(assetsDir, imagePath[7:]) // 去掉前面的"/assets"
-	
-	// 目标文件路径
-	destPath := filepath.Join(imgsDir, filename)
-
-	// 检查源文件是否存在
-	if _, err := os.Stat(sourcePath); os.IsNotExist(err) {
-		log.Printf("源文件不存在: %s", sourcePath)
-		return imagePath, nil // 保留原路径
-	}
-
-	// 移动文件 (先复制后删除)
-	if err := moveFile(sourcePath, destPath); err != nil {
-		log.Printf("移动图片失败: %s -> %s, 错误: %v", sourcePath, destPath, err)
+	newPath, err := MoveAssetToResource(resourceID, imagePath)
+	if err != nil {
+		log.Printf("%v", err)
 		return imagePath, nil // 保留原路径
 	}
 
-	log.Printf("成功移动图片: %s -> %s", sourcePath, destPath)
-	
 	// 返回新路径
-	return fmt.Sprintf("/assets/imgs/%d/%s", resourceID, filename), nil
-}
-
-// moveFile 移动文件（复制后删除原文件）
-func moveFile(src, dst string) error {
-	// 检查源文件是否存在
-	if _, err := os.Stat(src); os.IsNotExist(err) {
-		return fmt.Errorf("源文件不存在: %s", src)
-	}
-	
-	// 检查目标路径
-	if _, err := os.Stat(dst); err == nil {
-		// 如果目标文件已存在，先删除它
-		if err := os.Remove(dst); err != nil {
-			return fmt.Errorf("删除已存在的目标文件失败: %w", err)
-		}
-	}
-
-	// 首先复制文件
-	if err := copyFile(src, dst); err != nil {
-		return fmt.Errorf("复制文件失败: %w", err)
-	}
-	
-	// 确认目标文件已成功创建
-	if _, err := os.Stat(dst); os.IsNotExist(err) {
-		return fmt.Errorf("目标文件创建失败: %s", dst)
-	}
-	
-	// 然后删除源文件
-	if err := os.Remove(src); err != nil {
-		log.Printf("警告：无法删除源文件 %s: %v，将重试", src, err)
-		
-		// 重试删除
-		time.Sleep(100 * time.Millisecond)
-		if err := os.Remove(src); err != nil {
-			log.Printf("警告：第二次尝试删除源文件 %s 失败: %v", src, err)
-		} else {
-			log.Printf("第二次尝试删除源文件 %s 成功", src)
-		}
-	} else {
-		log.Printf("源文件 %s 已成功删除", src)
-	}
-	
-	return nil
+	return newPath, nil
 }
 
 // 辅助函数
 
-// ensureUploadDir 确保上传目录存在
-func ensureUploadDir() (string, error) {
-	// 获取资源目录
-	assetsDir := config.GetAssetsDir()
-
-	// 按日期创建上传目录
-	dateDir := time.Now().Format("20060102")
-	uploadDir := filepath.Join(assetsDir, "uploads", dateDir)
-
-	// 确保目录存在
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return "", fmt.Errorf("创建上传目录失败: %w", err)
-	}
-
-	return uploadDir, nil
-}
-
 // generateUniqueFilename 生成唯一的文件名
 func generateUniqueFilename(originalFilename string) string {
 	// 获取文件扩展名
@@ -242,27 +155,3 @@ func generateUniqueFilename(originalFilename string) string {
 	// 生成唯一文件名
 	return fmt.Sprintf("%s%s", uuid, ext)
 }
-
-// copyFile 复制文件
-func copyFile(src, dst string) error {
-	// 打开源文件
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("打开源文件失败: %w", err)
-	}
-	defer sourceFile.Close()
-
-	// 创建目标文件
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("创建目标文件失败: %w", err)
-	}
-	defer destFile.Close()
-
-	// 复制内容
-	if _, err = io.Copy(destFile, sourceFile); err != nil {
-		return fmt.Errorf("复制文件内容失败: %w", err)
-	}
-
-	return nil
-} 
\ No newline at end of file
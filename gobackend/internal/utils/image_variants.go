@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"log"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/chai2010/webp"
+	"github.com/disintegration/imaging"
+
+	"dongman/internal/models"
+	"dongman/internal/storage"
+)
+
+// ImageVariantWidths 生成的响应式图片宽度
+var ImageVariantWidths = []int{320, 640, 1280}
+
+// 响应式图片文件名形如 xxx_w320.webp
+var imageVariantPattern = regexp.MustCompile(`_w\d+\.webp$`)
+
+// IsImageVariantPath 判断路径是否为生成的响应式图片，批量处理时应跳过
+func IsImageVariantPath(p string) bool {
+	return imageVariantPattern.MatchString(filepath.Base(p))
+}
+
+// ImageVariantKey 返回图片指定宽度的响应式图片key
+// 如 imgs/12/a.jpg -> imgs/12/a_w320.webp
+func ImageVariantKey(key string, width int) string {
+	base := strings.TrimSuffix(path.Base(key), path.Ext(key))
+	return path.Join(path.Dir(key), fmt.Sprintf("%s_w%d.webp", base, width))
+}
+
+// GenerateImageVariants 为 /assets/... 图片生成响应式尺寸和BlurHash并保存到存储后端和数据库
+// img 为已完成方向矫正的图像；宽度不小于原图的尺寸不生成，由原图代替
+func GenerateImageVariants(assetPath string, img image.Image) (*models.ImageVariant, error) {
+	bounds := img.Bounds()
+	return generateImageVariants(assetPath, img, bounds.Dx(), bounds.Dy())
+}
+
+// generateImageVariants 从 src 缩放生成响应式尺寸，width、height 为存储的图片尺寸
+// 图片在保存前被缩小时传入缩小前的图像，避免各尺寸在缩小后的图片上再次重采样
+func generateImageVariants(assetPath string, src image.Image, width, height int) (*models.ImageVariant, error) {
+	key, ok := storage.KeyFromAssetPath(assetPath)
+	if !ok {
+		return nil, fmt.Errorf("无效的图片路径: %s", assetPath)
+	}
+	if IsImageVariantPath(key) {
+		return nil, fmt.Errorf("不能为响应式图片再生成尺寸: %s", assetPath)
+	}
+
+	variant := &models.ImageVariant{
+		Width:    width,
+		Height:   height,
+		BlurHash: EncodeBlurHash(src, 4, 3),
+		Sources:  map[int]string{},
+	}
+
+	backend := storage.Default()
+	for _, width := range ImageVariantWidths {
+		if width >= variant.Width {
+			continue
+		}
+		resized := imaging.Resize(src, width, 0, imaging.Lanczos)
+
+		var buf bytes.Buffer
+		if err := webp.Encode(&buf, resized, &webp.Options{Lossless: false, Quality: 80}); err != nil {
+			return nil, fmt.Errorf("编码 %dpx 图片失败: %w", width, err)
+		}
+		variantKey := ImageVariantKey(key, width)
+		if err := backend.Put(variantKey, bytes.NewReader(buf.Bytes()), int64(buf.Len()), "image/webp"); err != nil {
+			return nil, fmt.Errorf("保存 %dpx 图片失败: %w", width, err)
+		}
+		variant.Sources[width] = storage.AssetPath(variantKey)
+	}
+	// 原图作为最大尺寸
+	variant.Sources[variant.Width] = assetPath
+
+	if models.DB != nil {
+		if err := models.SaveImageVariant(assetPath, *variant); err != nil {
+			return nil, err
+		}
+	}
+	return variant, nil
+}
+
+// GenerateStoredImageVariants 从存储后端读取图片并生成响应式尺寸，用于回填已有图片
+func GenerateStoredImageVariants(assetPath string) (*models.ImageVariant, error) {
+	key, ok := storage.KeyFromAssetPath(assetPath)
+	if !ok {
+		return nil, fmt.Errorf("无效的图片路径: %s", assetPath)
+	}
+	reader, err := storage.Default().Get(key)
+	if err != nil {
+		return nil, err
+	}
+	img, err := decodeImageWithLimits(reader)
+	reader.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	saveImageHash(assetPath, DHash(img))
+	return GenerateImageVariants(assetPath, img)
+}
+
+// recordImageDerivatives 转换完成后为资源目录内的图片记录感知哈希并生成响应式尺寸
+// src 为缩放前已完成方向矫正的图像，width、height 为保存后的图片尺寸
+func recordImageDerivatives(localPath string, src image.Image, width, height int) {
+	assetPath, ok := AssetPathFromLocal(localPath)
+	if !ok {
+		// 不在资源目录内（如临时文件），由调用方自行处理
+		return
+	}
+	recordAssetDerivatives(assetPath, src, width, height)
+}
+
+// recordAssetDerivatives 为 /assets/... 图片记录感知哈希并从缩放前的图像生成响应式尺寸
+func recordAssetDerivatives(assetPath string, src image.Image, width, height int) {
+	if IsImageVariantPath(assetPath) {
+		return
+	}
+	saveImageHash(assetPath, DHash(src))
+	if _, err := generateImageVariants(assetPath, src, width, height); err != nil {
+		log.Printf("生成响应式图片失败 %s: %v", assetPath, err)
+	}
+}
+
+// RemoveImageDerivatives 删除资源目录内图片的响应式尺寸文件及其感知哈希和尺寸记录，用于撤销转换
+// 不在资源目录内的文件没有记录，直接返回
+func RemoveImageDerivatives(localPath string) error {
+	assetPath, ok := AssetPathFromLocal(localPath)
+	if !ok {
+		return nil
+	}
+	key, _ := storage.KeyFromAssetPath(assetPath)
+	backend := storage.Default()
+	for _, width := range ImageVariantWidths {
+		if err := backend.Delete(ImageVariantKey(key, width)); err != nil {
+			return fmt.Errorf("删除 %dpx 图片失败: %w", width, err)
+		}
+	}
+	if err := models.DeleteImageVariant(assetPath); err != nil {
+		return err
+	}
+	return models.DeleteImageHash(assetPath)
+}
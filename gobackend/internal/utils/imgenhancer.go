@@ -323,6 +323,11 @@ type TaskResultResponse struct {
 
 // PollTaskResult 轮询处理结果
 func PollTaskResult(taskIDs []string, pollInterval int, maxAttempts int) (*TaskResultResponse, error) {
+	return pollTaskResult(taskIDs, pollInterval, maxAttempts, nil)
+}
+
+// pollTaskResult 轮询处理结果，每次查询到任务未完成时调用 onPending(已轮询次数, 最大次数)
+func pollTaskResult(taskIDs []string, pollInterval int, maxAttempts int, onPending func(attempt, maxAttempts int)) (*TaskResultResponse, error) {
 	if pollInterval <= 0 {
 		pollInterval = 2
 	}
@@ -441,6 +446,9 @@ func PollTaskResult(taskIDs []string, pollInterval int, maxAttempts int) (*TaskR
 
 		// 未全部完成，等待后重试
 		attempts++
+		if onPending != nil {
+			onPending(attempts, maxAttempts)
+		}
 		time.Sleep(time.Duration(pollInterval) * time.Second)
 	}
 
@@ -460,14 +468,33 @@ type SuperResolutionResult struct {
 	ErrorMessage  string
 }
 
+// 超分辨率处理的阶段，用于报告进度
+const (
+	EnhanceStageUploading  = "uploading"  // 上传图片
+	EnhanceStageProcessing = "processing" // 等待处理完成
+)
+
+// EnhanceProgressFunc 处理进度回调，percent 为 0-100 的大致进度
+type EnhanceProgressFunc func(stage string, percent int)
+
 // EnhanceImage 完整的图片超分辨率处理流程
 func EnhanceImage(imagePath string) (*SuperResolutionResult, error) {
+	return EnhanceImageWithProgress(imagePath, nil)
+}
+
+// EnhanceImageWithProgress 与 EnhanceImage 相同，处理过程中通过 progress 报告进度
+// 进度只覆盖到得到结果URL为止（最多90%），下载结果由调用方负责
+func EnhanceImageWithProgress(imagePath string, progress EnhanceProgressFunc) (*SuperResolutionResult, error) {
+	if progress == nil {
+		progress = func(string, int) {}
+	}
 	result := &SuperResolutionResult{
 		OriginalImage: imagePath,
 		Success:       false,
 	}
 
 	// 1. 获取上传URL
+	progress(EnhanceStageUploading, 5)
 	uploadURLResp, err := GetUploadURL()
 	if err != nil {
 		result.ErrorMessage = fmt.Sprintf("获取上传URL失败: %v", err)
@@ -493,6 +520,7 @@ func EnhanceImage(imagePath string) (*SuperResolutionResult, error) {
 	}
 
 	// 3. 获取任务ID
+	progress(EnhanceStageProcessing, 20)
 	taskIDResp, err := GetTaskID(imgURL)
 	if err != nil {
 		result.ErrorMessage = fmt.Sprintf("获取任务ID失败: %v", err)
@@ -506,7 +534,10 @@ func EnhanceImage(imagePath string) (*SuperResolutionResult, error) {
 	taskID := taskIDResp.TaskID
 
 	// 4. 轮询任务结果
-	taskResultResp, err := PollTaskResult([]string{taskID}, 2, 30)
+	taskResultResp, err := pollTaskResult([]string{taskID}, 2, 30, func(attempt, maxAttempts int) {
+		// 处理时间未知，按轮询次数在 20%-90% 之间推进
+		progress(EnhanceStageProcessing, 20+70*attempt/maxAttempts)
+	})
 	if err != nil {
 		result.ErrorMessage = fmt.Sprintf("轮询任务结果失败: %v", err)
 		return result, err
@@ -519,5 +550,6 @@ func EnhanceImage(imagePath string) (*SuperResolutionResult, error) {
 	// 5. 获取处理后的图片URL
 	result.EnhancedImage = taskResultResp.ImageURLs[0]
 	result.Success = true
+	progress(EnhanceStageProcessing, 90)
 	return result, nil
 } 
\ No newline at end of file
@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"dongman/internal/models"
+)
+
+// LinkKey 返回链接的规范键，两个链接的键相同即视为同一资源：
+//
+//	磁力链接为 btih:<40位小写十六进制> 或 btmh:<哈希>，base32 编码的 btih 会转换为十六进制
+//	ed2k 链接为 ed2k:<小写哈希>
+//	网盘分享链接为 <分类>:<分享ID>，分类按域名判断，同一分享的不同链接形式（如百度的 /s/1xxx 和 ?surl=xxx）键相同
+//	其他链接为去掉 www.、末尾斜杠和统计参数并排序查询参数后的URL
+func LinkKey(raw string) string {
+	raw = strings.TrimSpace(raw)
+	lower := strings.ToLower(raw)
+	switch {
+	case strings.HasPrefix(lower, "magnet:?"):
+		if key := magnetKey(raw); key != "" {
+			return key
+		}
+	case strings.HasPrefix(lower, "ed2k://|"):
+		parts := strings.Split(raw[len("ed2k://|"):], "|")
+		if len(parts) >= 4 && ed2kHashPattern.MatchString(parts[3]) {
+			return "ed2k:" + strings.ToLower(parts[3])
+		}
+	}
+
+	if category, id, ok := ParseShareLink(raw); ok {
+		return category + ":" + id
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "url:" + raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+
+	query := u.Query()
+	query.Del("pwd")
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || commonTrackingParams[lower] {
+			query.Del(key)
+		}
+	}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	if port := u.Port(); port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	key := host + strings.TrimRight(u.EscapedPath(), "/")
+	if encoded := query.Encode(); encoded != "" {
+		key += "?" + encoded
+	}
+	if u.Fragment != "" {
+		key += "#" + u.Fragment
+	}
+	return "url:" + key
+}
+
+// magnetKey 取磁力链接的哈希，同时有 v1 和 v2 哈希时使用 v1
+func magnetKey(raw string) string {
+	meta, err := ParseMagnet(raw)
+	if err != nil {
+		return ""
+	}
+	if meta.InfoHash != "" {
+		return "btih:" + meta.InfoHash
+	}
+	return "btmh:1220" + meta.InfoHashV2
+}
+
+// ParseShareLink 解析网盘分享链接，返回按域名判断的分类和分享ID，不是可识别的分享链接时 ok 为false
+// 百度网盘的分享ID不含 /s/ 路径中开头的 1，与 /share/init?surl= 参数一致
+func ParseShareLink(raw string) (category, id string, ok bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+	category = DetectLinkCategory(raw)
+	rule, isShare := shareLinkRules[category]
+	if !isShare {
+		return "", "", false
+	}
+	id = shareID(category, u, rule)
+	return category, id, id != ""
+}
+
+// shareID 从网盘分享链接中取出分享ID，无法识别时返回空字符串
+func shareID(category string, u *url.URL, rule shareLinkRule) string {
+	query := u.Query()
+	switch category {
+	case models.LinkCategoryBaidu:
+		// /share/init?surl=xxx 与 /s/1xxx 是同一个分享
+		if surl := query.Get("surl"); surl != "" {
+			return surl
+		}
+		if strings.HasPrefix(u.Path, "/s/") {
+			return strings.TrimPrefix(firstPathSegment(strings.TrimPrefix(u.Path, "/s/")), "1")
+		}
+		return ""
+	case models.LinkCategoryTianyi:
+		// /t/xxx、/web/share?code=xxx、/share.html#/t/xxx
+		if code := query.Get("code"); code != "" {
+			return code
+		}
+		if strings.HasPrefix(u.Path, "/t/") {
+			return firstPathSegment(strings.TrimPrefix(u.Path, "/t/"))
+		}
+		if strings.HasPrefix(u.Fragment, "/t/") {
+			return firstPathSegment(strings.TrimPrefix(u.Fragment, "/t/"))
+		}
+		return ""
+	}
+	for _, prefix := range rule.paths {
+		if strings.HasPrefix(u.Path, prefix) {
+			return firstPathSegment(strings.TrimPrefix(u.Path, prefix))
+		}
+	}
+	return ""
+}
+
+func firstPathSegment(path string) string {
+	path = strings.TrimLeft(path, "/")
+	if i := strings.IndexAny(path, "/?#"); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
+
+// LinkIndex 已有链接的键 -> 所在位置，用于检测重复链接
+type LinkIndex map[string]models.LinkDuplicate
+
+// Add 登记 links 中的所有链接，source 为链接所在位置（见 models.LinkSource*），已登记的键保持不变
+func (idx LinkIndex) Add(links models.LinkMap, source string) {
+	for category, items := range links {
+		for _, link := range items {
+			key := LinkKey(link.URL)
+			if _, ok := idx[key]; !ok {
+				idx[key] = models.LinkDuplicate{Key: key, ExistingCategory: category, ExistingURL: link.URL, Existing: source}
+			}
+		}
+	}
+}
+
+// Dedupe 去掉 links 中与已登记链接重复或彼此重复的链接，返回保留的链接和重复项；保留的链接以 source 登记
+func (idx LinkIndex) Dedupe(links models.LinkMap, source string) (models.LinkMap, []models.LinkDuplicate) {
+	kept := models.LinkMap{}
+	var duplicates []models.LinkDuplicate
+	for _, category := range sortedLinkCategories(links) {
+		for _, link := range links[category] {
+			key := LinkKey(link.URL)
+			if existing, ok := idx[key]; ok {
+				existing.Category = category
+				existing.URL = link.URL
+				duplicates = append(duplicates, existing)
+				continue
+			}
+			idx[key] = models.LinkDuplicate{Key: key, ExistingCategory: category, ExistingURL: link.URL, Existing: source}
+			kept[category] = append(kept[category], link)
+		}
+	}
+	return kept, duplicates
+}
+
+// sortedLinkCategories 按前端展示顺序返回 links 中的分类，保证重复检测的结果稳定
+func sortedLinkCategories(links models.LinkMap) []string {
+	categories := make([]string, 0, len(links))
+	for _, category := range models.LinkCategories {
+		if _, ok := links[category]; ok {
+			categories = append(categories, category)
+		}
+	}
+	var others []string
+	for category := range links {
+		if !models.IsLinkCategory(category) {
+			others = append(others, category)
+		}
+	}
+	sort.Strings(others)
+	return append(categories, others...)
+}
@@ -0,0 +1,31 @@
+package utils
+
+import "testing"
+
+func TestLinkKey(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"magnet:?xt=urn:btih:" + testInfoHash, "btih:" + testInfoHash},
+		{"magnet:?xt=urn:btih:AERUKZ4JVPG66AJDIVTYTK6N54ASGRLH&dn=x", "btih:" + testInfoHash},
+		// 名称中的 ; 和无效的 % 不影响取哈希
+		{"magnet:?xt=urn:btih:" + testInfoHash + "&dn=a;b%zz&tr=udp://t;1", "btih:" + testInfoHash},
+		{"magnet:?dn=x&xt=urn:btmh:1220" + testInfoHash + "0123456789abcdef01234567", "btmh:1220" + testInfoHash + "0123456789abcdef01234567"},
+		{"magnet:?dn=nohash", "url:magnet:?dn=nohash"},
+		{"ed2k://|file|a.mkv|1024|0123456789ABCDEF0123456789ABCDEF|/", "ed2k:0123456789abcdef0123456789abcdef"},
+		{"https://pan.baidu.com/s/1AbCdEf?pwd=abcd", "baidu:AbCdEf"},
+		{"https://pan.baidu.com/share/init?surl=AbCdEf", "baidu:AbCdEf"},
+		{"https://pan.quark.cn/s/abc123#/list", "quark:abc123"},
+		{"https://cloud.189.cn/web/share?code=XYZ", "tianyi:XYZ"},
+		{"https://cloud.189.cn/t/XYZ", "tianyi:XYZ"},
+		{"HTTPS://WWW.Example.com:443/path/?utm_source=a&b=2&a=1&spm=x", "url:example.com/path?a=1&b=2"},
+		{"http://example.com:8080/", "url:example.com:8080"},
+		{"not a url", "url:not a url"},
+	}
+	for _, tt := range tests {
+		if got := LinkKey(tt.raw); got != tt.want {
+			t.Errorf("LinkKey(%q) = %q，期望 %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,354 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"dongman/internal/models"
+)
+
+const (
+	// MaxLinksPerCategory 一次提交中每个分类的链接上限
+	MaxLinksPerCategory = 100
+	// maxLinkURLLength 链接的最大长度，磁力链接带tracker时可能较长
+	maxLinkURLLength = 4096
+	// maxLinkTextLength 提取码以外的文本字段（标题、备注）的最大长度
+	maxLinkTextLength = 200
+)
+
+// LinkFieldErrors 链接校验错误，键为字段路径（如 links.baidu[0].url），值为错误信息
+type LinkFieldErrors map[string]string
+
+func (e LinkFieldErrors) Error() string {
+	paths := make([]string, 0, len(e))
+	for path := range e {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if len(paths) == 0 {
+		return "链接校验失败"
+	}
+	return fmt.Sprintf("链接校验失败: %s: %s", paths[0], e[paths[0]])
+}
+
+// shareLinkRule 网盘分享链接的域名和路径规则
+type shareLinkRule struct {
+	hosts []string // 允许的域名，子域名同样匹配
+	paths []string // 分享页的路径前缀，为空时不限制路径
+}
+
+// shareLinkRules 各网盘分类的分享链接规则
+var shareLinkRules = map[string]shareLinkRule{
+	models.LinkCategoryBaidu:  {hosts: []string{"pan.baidu.com", "yun.baidu.com"}, paths: []string{"/s/", "/share/init"}},
+	models.LinkCategoryAliyun: {hosts: []string{"aliyundrive.com", "alipan.com"}, paths: []string{"/s/"}},
+	models.LinkCategoryQuark:  {hosts: []string{"pan.quark.cn"}, paths: []string{"/s/"}},
+	models.LinkCategoryPikPak: {hosts: []string{"mypikpak.com"}, paths: []string{"/s/"}},
+	models.LinkCategoryTianyi: {hosts: []string{"cloud.189.cn"}, paths: []string{"/t/", "/web/share", "/share.html"}},
+	models.LinkCategoryXunlei: {hosts: []string{"pan.xunlei.com"}, paths: []string{"/s/"}},
+	models.LinkCategoryUC:     {hosts: []string{"drive.uc.cn"}, paths: []string{"/s/"}},
+	models.LinkCategoryMobile: {hosts: []string{"caiyun.139.com", "yun.139.com"}},
+	models.LinkCategory115:    {hosts: []string{"115.com", "115cdn.com", "anxia.com"}, paths: []string{"/s/"}},
+	models.LinkCategory123:    {hosts: []string{"123pan.com", "123pan.cn", "123684.com", "123865.com", "123912.com"}, paths: []string{"/s/"}},
+}
+
+// shareTrackingParams 网盘分享链接中与内容无关的来源参数
+var shareTrackingParams = map[string]bool{
+	"from": true, "entry": true, "share_source": true, "share_medium": true,
+	"share_plat": true, "share_tag": true, "share_from": true, "sharefrom": true,
+}
+
+// commonTrackingParams 所有http链接都去掉的统计参数，utm_* 另行处理
+var commonTrackingParams = map[string]bool{
+	"spm": true, "fbclid": true, "gclid": true,
+}
+
+var (
+	// pastedURLPattern 从"链接: ... 提取码: ..."这类分享文案中找出链接
+	pastedURLPattern = regexp.MustCompile(`(?i)(?:https?://|magnet:\?|ed2k://)[^\s，。；（）()]+`)
+	// pastedPasswordPattern 分享文案中的提取码
+	pastedPasswordPattern = regexp.MustCompile(`(?:提取码|访问码|密码|pwd|code)\s*[:：=]?\s*([A-Za-z0-9]{4,8})`)
+	linkPasswordPattern   = regexp.MustCompile(`^[A-Za-z0-9]{1,16}$`)
+
+	btihHexPattern    = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+	btihBase32Pattern = regexp.MustCompile(`^[A-Za-z2-7]{32}$`)
+	btmhPattern       = regexp.MustCompile(`^1220[0-9a-fA-F]{64}$`)
+	ed2kHashPattern   = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+)
+
+// NormalizeLinks 校验并规范化提交的链接
+// raw 为请求中的 分类 -> 链接数组，条目可以是URL字符串或对象；URL和提取码都为空的条目（前端的空白行）被忽略。
+// addedBy 和 now 写入每个链接的 added_by/added_at，覆盖客户端提交的值。
+// 返回的错误为 LinkFieldErrors，包含所有无效字段
+func NormalizeLinks(raw models.JsonMap, addedBy string, now time.Time) (models.LinkMap, error) {
+	result := models.LinkMap{}
+	errs := LinkFieldErrors{}
+
+	for category, value := range raw {
+		field := "links." + category
+		if !models.IsLinkCategory(category) {
+			errs[field] = fmt.Sprintf("未知的链接分类，可选: %s", strings.Join(models.LinkCategories, ", "))
+			continue
+		}
+
+		var items []interface{}
+		switch v := value.(type) {
+		case nil:
+			continue
+		case []interface{}:
+			items = v
+		default:
+			// 兼容只提交单个链接的旧客户端
+			items = []interface{}{v}
+		}
+		if len(items) > MaxLinksPerCategory {
+			errs[field] = fmt.Sprintf("每个分类最多提交 %d 个链接", MaxLinksPerCategory)
+			continue
+		}
+
+		for i, item := range items {
+			path := fmt.Sprintf("%s[%d]", field, i)
+			link, err := models.LinkFromValue(item)
+			if err != nil {
+				errs[path] = err.Error()
+				continue
+			}
+			if strings.TrimSpace(link.URL) == "" && link.Password == "" {
+				continue
+			}
+
+			valid := true
+			if err := NormalizeLink(category, &link); err != nil {
+				var fieldErr *linkFieldError
+				if errors.As(err, &fieldErr) {
+					errs[path+"."+fieldErr.field] = fieldErr.message
+				} else {
+					errs[path+".url"] = err.Error()
+				}
+				valid = false
+			}
+			if len([]rune(link.Title)) > maxLinkTextLength {
+				errs[path+".title"] = fmt.Sprintf("标题不能超过 %d 个字符", maxLinkTextLength)
+				valid = false
+			}
+			if len([]rune(link.Note)) > maxLinkTextLength {
+				errs[path+".note"] = fmt.Sprintf("备注不能超过 %d 个字符", maxLinkTextLength)
+				valid = false
+			}
+			if !valid {
+				continue
+			}
+
+			link.AddedBy = addedBy
+			addedAt := now
+			link.AddedAt = &addedAt
+			result[category] = append(result[category], link)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return result, nil
+}
+
+// linkFieldError 链接中某个字段（url/password）的校验错误
+type linkFieldError struct {
+	field   string
+	message string
+}
+
+func (e *linkFieldError) Error() string {
+	return e.field + ": " + e.message
+}
+
+func invalidLinkURL(format string, args ...interface{}) error {
+	return &linkFieldError{field: "url", message: fmt.Sprintf(format, args...)}
+}
+
+// NormalizeLink 按分类校验并规范化单个链接：
+// 从粘贴的分享文案中提取链接和提取码，把URL中的 ?pwd= 提取到 Password，去掉统计参数
+func NormalizeLink(category string, link *models.Link) error {
+	raw := strings.TrimSpace(link.URL)
+	if raw == "" {
+		return invalidLinkURL("链接不能为空")
+	}
+
+	// "链接：https://pan.baidu.com/s/1xxx 提取码：abcd" 这类整段粘贴的文案
+	if strings.ContainsAny(raw, " \t\r\n") {
+		found := pastedURLPattern.FindString(raw)
+		if found == "" {
+			return invalidLinkURL("链接中不能包含空白字符")
+		}
+		if link.Password == "" {
+			if m := pastedPasswordPattern.FindStringSubmatch(raw); m != nil {
+				link.Password = m[1]
+			}
+		}
+		raw = found
+	}
+	if len(raw) > maxLinkURLLength {
+		return invalidLinkURL("链接不能超过 %d 个字符", maxLinkURLLength)
+	}
+
+	var (
+		normalized string
+		err        error
+	)
+	// 元数据只由服务端解析生成，不接受客户端提交的值
+	link.Meta = nil
+	switch category {
+	case models.LinkCategoryMagnet:
+		normalized, err = normalizeMagnetLink(raw, link)
+	case models.LinkCategoryEd2k:
+		normalized, err = normalizeEd2kLink(raw, link)
+	default:
+		normalized, err = normalizeHTTPLink(category, raw, link)
+	}
+	if err != nil {
+		return err
+	}
+	link.URL = normalized
+
+	if link.Password != "" && !linkPasswordPattern.MatchString(link.Password) {
+		return &linkFieldError{field: "password", message: "提取码只能包含字母和数字，最多16位"}
+	}
+	return nil
+}
+
+// normalizeHTTPLink 校验网盘分享链接和普通网页链接
+func normalizeHTTPLink(category, raw string, link *models.Link) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", invalidLinkURL("无法解析的链接")
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if category == models.LinkCategoryOthers && scheme != "" && scheme != "http" && scheme != "https" {
+		// 其他分类允许 ftp://、thunder:// 等链接，只要求格式完整
+		if u.Host == "" && u.Opaque == "" {
+			return "", invalidLinkURL("无法解析的链接")
+		}
+		return raw, nil
+	}
+	if scheme != "http" && scheme != "https" {
+		return "", invalidLinkURL("链接必须以 http:// 或 https:// 开头")
+	}
+	if u.Hostname() == "" {
+		return "", invalidLinkURL("链接缺少域名")
+	}
+	u.Scheme = scheme
+	u.Host = strings.ToLower(u.Host)
+
+	rule, isShare := shareLinkRules[category]
+	query := u.Query()
+	if pwd := strings.TrimSpace(query.Get("pwd")); pwd != "" {
+		if link.Password == "" {
+			link.Password = pwd
+		} else if !strings.EqualFold(link.Password, pwd) {
+			return "", &linkFieldError{field: "password", message: "填写的提取码与链接中的 pwd 参数不一致"}
+		}
+		query.Del("pwd")
+	}
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || commonTrackingParams[lower] || (isShare && shareTrackingParams[lower]) {
+			query.Del(key)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	if isShare {
+		if !hostMatches(u.Hostname(), rule.hosts) {
+			return "", invalidLinkURL("不是有效的%s分享链接，域名应为 %s", category, strings.Join(rule.hosts, "/"))
+		}
+		if !sharePathMatches(u, rule.paths) {
+			return "", invalidLinkURL("链接缺少分享ID")
+		}
+	}
+	return u.String(), nil
+}
+
+// hostMatches 判断域名是否为列表中的域名或其子域名
+func hostMatches(host string, hosts []string) bool {
+	for _, h := range hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// sharePathMatches 判断路径是否为分享页，路径前缀之后（或查询参数、片段中）需要有分享ID
+func sharePathMatches(u *url.URL, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return strings.Trim(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != ""
+	}
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(u.Path, prefix) {
+			continue
+		}
+		if strings.Trim(strings.TrimPrefix(u.Path, prefix), "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeMagnetLink 校验磁力链接至少包含一个有效的 btih（v1，40位十六进制或32位base32）或 btmh（v2）哈希，
+// 并用解析出的名称和大小补全链接的标题和大小
+func normalizeMagnetLink(raw string, link *models.Link) (string, error) {
+	meta, err := ParseMagnet(raw)
+	if err != nil {
+		return "", invalidLinkURL("%s", err.Error())
+	}
+	fillLinkMeta(link, meta)
+	return raw, nil
+}
+
+// normalizeEd2kLink 校验电驴文件链接 ed2k://|file|文件名|大小|哈希|/，并用文件名和大小补全链接的标题和大小
+func normalizeEd2kLink(raw string, link *models.Link) (string, error) {
+	meta, err := ParseEd2k(raw)
+	if err != nil {
+		return "", invalidLinkURL("%s", err.Error())
+	}
+	fillLinkMeta(link, meta)
+	return raw, nil
+}
+
+// fillLinkMeta 保存解析出的元数据，提交者没有填写标题和大小时使用元数据中的值
+func fillLinkMeta(link *models.Link, meta *models.LinkMeta) {
+	link.Meta = meta
+	if link.Title == "" {
+		link.Title = meta.Name
+	}
+	if link.Size == 0 {
+		link.Size = meta.Size
+	}
+}
+
+// DetectLinkCategory 根据链接推断分类，无法识别时返回 others
+func DetectLinkCategory(raw string) string {
+	raw = strings.TrimSpace(raw)
+	lower := strings.ToLower(raw)
+	switch {
+	case strings.HasPrefix(lower, "magnet:"):
+		return models.LinkCategoryMagnet
+	case strings.HasPrefix(lower, "ed2k://"):
+		return models.LinkCategoryEd2k
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return models.LinkCategoryOthers
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, category := range models.LinkCategories {
+		if rule, ok := shareLinkRules[category]; ok && hostMatches(host, rule.hosts) {
+			return category
+		}
+	}
+	return models.LinkCategoryOthers
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"dongman/internal/models"
+)
+
+// maxMagnetTrackers 磁力链接中保留的tracker数量上限
+const maxMagnetTrackers = 50
+
+// ParseMagnet 解析磁力链接的 xt（btih v1、btmh v2）、dn（名称）、xl（大小）和 tr（tracker）参数
+// 支持 xt.1、tr.1 这类带序号的写法；base32 编码的 btih 转换为十六进制。没有有效哈希时返回错误
+// 参数只按 & 分隔，名称中的 ; 和无效的 % 转义按原样保留，不会导致整个链接无法解析
+func ParseMagnet(raw string) (*models.LinkMeta, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(strings.ToLower(raw), "magnet:?") {
+		return nil, errors.New("磁力链接必须以 magnet:? 开头")
+	}
+	query := parseMagnetQuery(raw[len("magnet:?"):])
+
+	meta := &models.LinkMeta{}
+	for _, xt := range magnetParams(query, "xt") {
+		lower := strings.ToLower(xt)
+		switch {
+		case strings.HasPrefix(lower, "urn:btih:") && meta.InfoHash == "":
+			hash := xt[len("urn:btih:"):]
+			if btihHexPattern.MatchString(hash) {
+				meta.InfoHash = strings.ToLower(hash)
+			} else if btihBase32Pattern.MatchString(hash) {
+				if decoded, err := base32.StdEncoding.DecodeString(strings.ToUpper(hash)); err == nil {
+					meta.InfoHash = hex.EncodeToString(decoded)
+				}
+			}
+		case strings.HasPrefix(lower, "urn:btmh:") && meta.InfoHashV2 == "":
+			// 多重哈希格式：1220 表示 sha2-256、长度32字节
+			if hash := lower[len("urn:btmh:"):]; btmhPattern.MatchString(hash) {
+				meta.InfoHashV2 = hash[len("1220"):]
+			}
+		}
+	}
+	if meta.InfoHash == "" && meta.InfoHashV2 == "" {
+		return nil, errors.New("磁力链接缺少有效的 xt=urn:btih: 或 xt=urn:btmh: 哈希")
+	}
+
+	if names := magnetParams(query, "dn"); len(names) > 0 {
+		meta.Name = strings.TrimSpace(names[0])
+	}
+	if sizes := magnetParams(query, "xl"); len(sizes) > 0 {
+		if size, err := strconv.ParseInt(sizes[0], 10, 64); err == nil && size > 0 {
+			meta.Size = size
+		}
+	}
+	seen := make(map[string]bool)
+	for _, tr := range magnetParams(query, "tr") {
+		tr = strings.TrimSpace(tr)
+		if tr == "" || seen[tr] || len(meta.Trackers) >= maxMagnetTrackers {
+			continue
+		}
+		seen[tr] = true
+		meta.Trackers = append(meta.Trackers, tr)
+	}
+	return meta, nil
+}
+
+// parseMagnetQuery 按 & 拆分磁力链接的参数。url.ParseQuery 会拒绝 ; 和无效的 % 转义，
+// 而实际流传的磁力链接名称中经常出现这两种字符
+func parseMagnetQuery(rawQuery string) url.Values {
+	query := url.Values{}
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		key = unescapeMagnetParam(key)
+		query[key] = append(query[key], unescapeMagnetParam(value))
+	}
+	return query
+}
+
+// unescapeMagnetParam 解码参数中的 + 和有效的 %XX 转义，无效的 % 按原样保留
+func unescapeMagnetParam(s string) string {
+	if decoded, err := url.QueryUnescape(s); err == nil {
+		return decoded
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '+':
+			b.WriteByte(' ')
+		case s[i] == '%' && i+2 < len(s) && isHexDigit(s[i+1]) && isHexDigit(s[i+2]):
+			v, _ := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			b.WriteByte(byte(v))
+			i += 2
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// magnetParams 返回参数 name 及其带序号写法（name.1、name.2 ...）的全部值，不带序号的在前
+func magnetParams(query url.Values, name string) []string {
+	values := append([]string(nil), query[name]...)
+	var keys []string
+	for key := range query {
+		if strings.HasPrefix(key, name+".") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := strconv.Atoi(keys[i][len(name)+1:])
+		b, _ := strconv.Atoi(keys[j][len(name)+1:])
+		return a < b
+	})
+	for _, key := range keys {
+		values = append(values, query[key]...)
+	}
+	return values
+}
+
+// BuildMagnet 根据元数据生成磁力链接，包含哈希、名称、大小和tracker
+func BuildMagnet(meta *models.LinkMeta) string {
+	var parts []string
+	if meta.InfoHash != "" {
+		parts = append(parts, "xt=urn:btih:"+meta.InfoHash)
+	}
+	if meta.InfoHashV2 != "" {
+		parts = append(parts, "xt=urn:btmh:1220"+meta.InfoHashV2)
+	}
+	if meta.Name != "" {
+		parts = append(parts, "dn="+url.QueryEscape(meta.Name))
+	}
+	if meta.Size > 0 {
+		parts = append(parts, "xl="+strconv.FormatInt(meta.Size, 10))
+	}
+	for _, tr := range meta.Trackers {
+		parts = append(parts, "tr="+url.QueryEscape(tr))
+	}
+	return "magnet:?" + strings.Join(parts, "&")
+}
+
+// ParseEd2k 解析电驴文件链接 ed2k://|file|文件名|大小|哈希|/，文件名按URL编码解码
+func ParseEd2k(raw string) (*models.LinkMeta, error) {
+	const format = "ed2k链接格式应为 ed2k://|file|文件名|大小|哈希|/"
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(strings.ToLower(raw), "ed2k://|") {
+		return nil, errors.New(format)
+	}
+	parts := strings.Split(raw[len("ed2k://|"):], "|")
+	if len(parts) < 4 || !strings.EqualFold(parts[0], "file") {
+		return nil, errors.New(format)
+	}
+	name := strings.TrimSpace(parts[1])
+	if decoded, err := url.PathUnescape(name); err == nil {
+		name = decoded
+	}
+	if name == "" {
+		return nil, errors.New("ed2k链接缺少文件名")
+	}
+	size, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || size <= 0 {
+		return nil, errors.New("ed2k链接的文件大小无效")
+	}
+	if !ed2kHashPattern.MatchString(parts[3]) {
+		return nil, errors.New("ed2k链接的哈希应为32位十六进制")
+	}
+	if !strings.HasSuffix(raw, "/") {
+		return nil, errors.New(format)
+	}
+	return &models.LinkMeta{Hash: strings.ToLower(parts[3]), Name: name, Size: size}, nil
+}
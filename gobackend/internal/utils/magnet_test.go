@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"dongman/internal/models"
+)
+
+const testInfoHash = "0123456789abcdef0123456789abcdef01234567"
+
+func TestParseMagnet(t *testing.T) {
+	v2 := "1220" + testInfoHash + "0123456789abcdef01234567"
+	tests := []struct {
+		name    string
+		raw     string
+		want    *models.LinkMeta
+		invalid bool
+	}{
+		{
+			name: "hex btih",
+			raw:  "magnet:?xt=urn:btih:" + testInfoHash,
+			want: &models.LinkMeta{InfoHash: testInfoHash},
+		},
+		{
+			name: "uppercase hex and prefix",
+			raw:  "  MAGNET:?xt=urn:btih:0123456789ABCDEF0123456789ABCDEF01234567 ",
+			want: &models.LinkMeta{InfoHash: testInfoHash},
+		},
+		{
+			name: "base32 btih",
+			raw:  "magnet:?xt=urn:btih:AERUKZ4JVPG66AJDIVTYTK6N54ASGRLH",
+			want: &models.LinkMeta{InfoHash: testInfoHash},
+		},
+		{
+			name: "btmh only",
+			raw:  "magnet:?xt=urn:btmh:" + v2,
+			want: &models.LinkMeta{InfoHashV2: v2[len("1220"):]},
+		},
+		{
+			name: "name size and numbered trackers",
+			raw:  "magnet:?xt=urn:btih:" + testInfoHash + "&dn=%E5%90%8D%E7%A7%B0+01&xl=1024&tr.2=udp%3A%2F%2Fb&tr=udp%3A%2F%2Fa&tr.1=udp%3A%2F%2Fa",
+			want: &models.LinkMeta{InfoHash: testInfoHash, Name: "名称 01", Size: 1024, Trackers: []string{"udp://a", "udp://b"}},
+		},
+		{
+			name: "semicolon in name",
+			raw:  "magnet:?xt=urn:btih:" + testInfoHash + "&dn=Vol.1;Vol.2&tr=udp://tracker:80/announce;x",
+			want: &models.LinkMeta{InfoHash: testInfoHash, Name: "Vol.1;Vol.2", Trackers: []string{"udp://tracker:80/announce;x"}},
+		},
+		{
+			name: "stray percent in name",
+			raw:  "magnet:?xt=urn:btih:" + testInfoHash + "&dn=100%25+%E5%AE%8C%E7%BB%93+50%off",
+			want: &models.LinkMeta{InfoHash: testInfoHash, Name: "100% 完结 50%off"},
+		},
+		{
+			name: "invalid size ignored",
+			raw:  "magnet:?xt=urn:btih:" + testInfoHash + "&xl=-1",
+			want: &models.LinkMeta{InfoHash: testInfoHash},
+		},
+		{name: "missing prefix", raw: "xt=urn:btih:" + testInfoHash, invalid: true},
+		{name: "missing hash", raw: "magnet:?dn=name", invalid: true},
+		{name: "short hash", raw: "magnet:?xt=urn:btih:0123", invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := ParseMagnet(tt.raw)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("期望解析失败，得到 %+v", meta)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if !reflect.DeepEqual(meta, tt.want) {
+				t.Errorf("ParseMagnet = %+v，期望 %+v", meta, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEd2k(t *testing.T) {
+	const hash = "0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name    string
+		raw     string
+		want    *models.LinkMeta
+		invalid bool
+	}{
+		{
+			name: "plain",
+			raw:  "ed2k://|file|movie.mkv|1024|" + hash + "|/",
+			want: &models.LinkMeta{Hash: hash, Name: "movie.mkv", Size: 1024},
+		},
+		{
+			name: "encoded name and uppercase hash",
+			raw:  "ED2K://|FILE|%E5%90%8D%E7%A7%B0.mkv|2048|0123456789ABCDEF0123456789ABCDEF|h=ABC|/",
+			want: &models.LinkMeta{Hash: hash, Name: "名称.mkv", Size: 2048},
+		},
+		{
+			name: "bad escape kept",
+			raw:  "ed2k://|file|100%.mkv|1|" + hash + "|/",
+			want: &models.LinkMeta{Hash: hash, Name: "100%.mkv", Size: 1},
+		},
+		{name: "not file", raw: "ed2k://|server|1.2.3.4|4661|/", invalid: true},
+		{name: "missing name", raw: "ed2k://|file||1024|" + hash + "|/", invalid: true},
+		{name: "bad size", raw: "ed2k://|file|a.mkv|0|" + hash + "|/", invalid: true},
+		{name: "bad hash", raw: "ed2k://|file|a.mkv|1024|xyz|/", invalid: true},
+		{name: "missing trailing slash", raw: "ed2k://|file|a.mkv|1024|" + hash + "|", invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta, err := ParseEd2k(tt.raw)
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("期望解析失败，得到 %+v", meta)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if !reflect.DeepEqual(meta, tt.want) {
+				t.Errorf("ParseEd2k = %+v，期望 %+v", meta, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"dongman/internal/models"
+)
+
+const (
+	// MaxTorrentBytes 上传种子文件的最大字节数
+	MaxTorrentBytes = 10 << 20
+	// maxTorrentFiles 种子中最多列出的文件数
+	maxTorrentFiles = 10000
+	// maxBencodeDepth bencode 嵌套层数上限，防止恶意文件耗尽栈空间
+	maxBencodeDepth = 64
+)
+
+// bencodeDecoder 解码 bencode 数据：整数为 int64，字节串为 string，列表为 []interface{}，字典为 map[string]interface{}
+// 解码时记录顶层 info 字典的原始字节，用于计算信息哈希
+type bencodeDecoder struct {
+	data    []byte
+	pos     int
+	depth   int
+	infoRaw []byte
+}
+
+func (d *bencodeDecoder) decode() (interface{}, error) {
+	if d.pos >= len(d.data) {
+		return nil, errors.New("数据意外结束")
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+	if d.depth > maxBencodeDepth {
+		return nil, errors.New("嵌套层数过多")
+	}
+
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		end := bytes.IndexByte(d.data[d.pos:], 'e')
+		if end < 0 {
+			return nil, errors.New("整数缺少结束符")
+		}
+		n, err := strconv.ParseInt(string(d.data[d.pos+1:d.pos+end]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的整数: %w", err)
+		}
+		d.pos += end + 1
+		return n, nil
+	case c >= '0' && c <= '9':
+		return d.decodeString()
+	case c == 'l':
+		d.pos++
+		list := []interface{}{}
+		for {
+			if d.pos >= len(d.data) {
+				return nil, errors.New("列表缺少结束符")
+			}
+			if d.data[d.pos] == 'e' {
+				d.pos++
+				return list, nil
+			}
+			item, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+	case c == 'd':
+		d.pos++
+		dict := map[string]interface{}{}
+		for {
+			if d.pos >= len(d.data) {
+				return nil, errors.New("字典缺少结束符")
+			}
+			if d.data[d.pos] == 'e' {
+				d.pos++
+				return dict, nil
+			}
+			key, err := d.decodeString()
+			if err != nil {
+				return nil, fmt.Errorf("无效的字典键: %w", err)
+			}
+			start := d.pos
+			value, err := d.decode()
+			if err != nil {
+				return nil, err
+			}
+			if key == "info" && d.depth == 1 {
+				d.infoRaw = d.data[start:d.pos]
+			}
+			dict[key] = value
+		}
+	default:
+		return nil, fmt.Errorf("位置 %d 处的无效字符 %q", d.pos, c)
+	}
+}
+
+func (d *bencodeDecoder) decodeString() (string, error) {
+	colon := bytes.IndexByte(d.data[d.pos:], ':')
+	if colon < 0 {
+		return "", errors.New("字节串缺少长度分隔符")
+	}
+	length, err := strconv.Atoi(string(d.data[d.pos : d.pos+colon]))
+	if err != nil || length < 0 {
+		return "", errors.New("无效的字节串长度")
+	}
+	start := d.pos + colon + 1
+	if length > len(d.data)-start {
+		return "", errors.New("字节串超出数据长度")
+	}
+	d.pos = start + length
+	return string(d.data[start:d.pos]), nil
+}
+
+// ParseTorrent 解析种子文件，返回信息哈希（v1 和/或 v2）、名称、总大小、tracker 和文件列表
+// 支持单文件、多文件（v1）和 v2 的 file tree，混合种子中的填充文件不计入列表
+func ParseTorrent(data []byte) (*models.LinkMeta, error) {
+	if len(data) > MaxTorrentBytes {
+		return nil, fmt.Errorf("种子文件不能超过 %dMB", MaxTorrentBytes>>20)
+	}
+	d := &bencodeDecoder{data: data}
+	value, err := d.decode()
+	if err != nil {
+		return nil, fmt.Errorf("无法解析的种子文件: %w", err)
+	}
+	root, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("无法解析的种子文件: 顶层不是字典")
+	}
+	info, ok := root["info"].(map[string]interface{})
+	if !ok || d.infoRaw == nil {
+		return nil, errors.New("种子文件缺少 info 字典")
+	}
+
+	meta := &models.LinkMeta{Name: bencodeText(info, "name")}
+	_, hasPieces := info["pieces"]
+	version, _ := info["meta version"].(int64)
+	if hasPieces {
+		sum := sha1.Sum(d.infoRaw)
+		meta.InfoHash = hex.EncodeToString(sum[:])
+	}
+	if version == 2 {
+		sum := sha256.Sum256(d.infoRaw)
+		meta.InfoHashV2 = hex.EncodeToString(sum[:])
+	}
+	if meta.InfoHash == "" && meta.InfoHashV2 == "" {
+		return nil, errors.New("种子文件缺少 pieces 或 meta version 字段")
+	}
+
+	switch {
+	case info["files"] != nil:
+		files, _ := info["files"].([]interface{})
+		for _, f := range files {
+			file, ok := f.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if attr, _ := file["attr"].(string); strings.Contains(attr, "p") {
+				continue // 混合种子的填充文件
+			}
+			length, _ := file["length"].(int64)
+			path := bencodePath(file)
+			if path == "" || length < 0 {
+				continue
+			}
+			if err := addTorrentFile(meta, path, length); err != nil {
+				return nil, err
+			}
+		}
+	case info["file tree"] != nil:
+		tree, _ := info["file tree"].(map[string]interface{})
+		if err := walkFileTree(meta, tree, ""); err != nil {
+			return nil, err
+		}
+	default:
+		length, _ := info["length"].(int64)
+		if length < 0 {
+			return nil, errors.New("种子文件的大小无效")
+		}
+		if err := addTorrentFile(meta, meta.Name, length); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	addTracker := func(tr string) {
+		tr = strings.TrimSpace(tr)
+		if tr != "" && !seen[tr] && len(meta.Trackers) < maxMagnetTrackers {
+			seen[tr] = true
+			meta.Trackers = append(meta.Trackers, tr)
+		}
+	}
+	if announce, ok := root["announce"].(string); ok {
+		addTracker(announce)
+	}
+	if tiers, ok := root["announce-list"].([]interface{}); ok {
+		for _, tier := range tiers {
+			trackers, _ := tier.([]interface{})
+			for _, tr := range trackers {
+				if s, ok := tr.(string); ok {
+					addTracker(s)
+				}
+			}
+		}
+	}
+	return meta, nil
+}
+
+// walkFileTree 遍历 v2 种子的 file tree，文件节点为 {"": {"length": n, ...}}
+func walkFileTree(meta *models.LinkMeta, tree map[string]interface{}, prefix string) error {
+	names := make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		node, ok := tree[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "/" + name
+		}
+		if leaf, ok := node[""].(map[string]interface{}); ok {
+			length, _ := leaf["length"].(int64)
+			if err := addTorrentFile(meta, path, length); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := walkFileTree(meta, node, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addTorrentFile 把文件加入列表并累加总大小
+func addTorrentFile(meta *models.LinkMeta, path string, length int64) error {
+	if len(meta.Files) >= maxTorrentFiles {
+		return fmt.Errorf("种子中的文件超过 %d 个", maxTorrentFiles)
+	}
+	meta.Files = append(meta.Files, models.TorrentFile{Path: path, Size: length})
+	meta.Size += length
+	return nil
+}
+
+// bencodeText 读取字典中的文本字段，优先使用 <key>.utf-8
+func bencodeText(dict map[string]interface{}, key string) string {
+	if s, ok := dict[key+".utf-8"].(string); ok && s != "" {
+		return strings.ToValidUTF8(s, "�")
+	}
+	s, _ := dict[key].(string)
+	return strings.ToValidUTF8(s, "�")
+}
+
+// bencodePath 拼接多文件种子中文件的路径，优先使用 path.utf-8
+func bencodePath(file map[string]interface{}) string {
+	parts, ok := file["path.utf-8"].([]interface{})
+	if !ok || len(parts) == 0 {
+		parts, _ = file["path"].([]interface{})
+	}
+	segments := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if s, ok := p.(string); ok && s != "" {
+			segments = append(segments, strings.ToValidUTF8(s, "�"))
+		}
+	}
+	return strings.Join(segments, "/")
+}
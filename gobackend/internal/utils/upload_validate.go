@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"strings"
+
+	"github.com/chai2010/webp"
+)
+
+const (
+	// MaxUploadImageBytes 上传图片的最大字节数
+	MaxUploadImageBytes = 20 << 20
+	// MaxUploadImageSide 上传图片的最大边长
+	MaxUploadImageSide = 10000
+	// MaxUploadImagePixels 上传图片的最大像素数，防止解压炸弹
+	MaxUploadImagePixels = 40 * 1000 * 1000
+	// maxUploadGifFrames 动图最大帧数
+	maxUploadGifFrames = 1000
+	// maxUploadGifTotalPixels 动图所有帧的像素总数上限，解码时每帧单独分配内存
+	maxUploadGifTotalPixels = 100 * 1000 * 1000
+)
+
+// 上传校验错误码
+const (
+	UploadErrFileTooLarge    = "FILE_TOO_LARGE"
+	UploadErrUnsupportedExt  = "UNSUPPORTED_EXTENSION"
+	UploadErrUnsupportedType = "UNSUPPORTED_TYPE"
+	UploadErrInvalidImage    = "INVALID_IMAGE"
+	UploadErrTooManyPixels   = "IMAGE_TOO_LARGE"
+	UploadErrProcessFailed   = "PROCESS_FAILED"
+)
+
+// UploadError 上传校验错误，Code 供前端区分错误类型
+type UploadError struct {
+	Code    string
+	Message string
+}
+
+func (e *UploadError) Error() string {
+	return e.Message
+}
+
+func newUploadError(code, format string, args ...interface{}) *UploadError {
+	return &UploadError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// allowedUploadExts 允许上传的图片扩展名
+var allowedUploadExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+}
+
+// IsAllowedImageExt 判断扩展名是否为允许上传的图片类型（不区分大小写）
+func IsAllowedImageExt(filename string) bool {
+	return allowedUploadExts[strings.ToLower(filepath.Ext(filename))]
+}
+
+// SanitizedImage 校验并清理后的图片
+type SanitizedImage struct {
+	Data     []byte
+	Type     string // jpeg、png、gif、webp
+	Ext      string // 与真实类型一致的扩展名
+	Width    int
+	Height   int
+	Filename string // 扩展名已按真实类型修正的文件名
+}
+
+// SanitizeUploadedImage 校验上传图片并去除元数据
+// 1. 按文件头检测真实类型，不信任扩展名
+// 2. 解码前检查文件大小和像素尺寸，防止解压炸弹
+// 3. 按EXIF方向校正图像后重新编码，丢弃EXIF（含GPS）等元数据
+func SanitizeUploadedImage(data []byte, filename string) (*SanitizedImage, error) {
+	if len(data) > MaxUploadImageBytes {
+		return nil, newUploadError(UploadErrFileTooLarge, "文件过大，最大支持 %dMB", MaxUploadImageBytes>>20)
+	}
+	if !IsAllowedImageExt(filename) {
+		return nil, newUploadError(UploadErrUnsupportedExt, "不支持的文件类型，仅支持jpg、jpeg、png、gif和webp")
+	}
+
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	imageType, err := detectImageTypeFromBytes(head)
+	if err != nil {
+		return nil, newUploadError(UploadErrUnsupportedType, "文件内容不是有效的图片")
+	}
+	ext, ok := map[string]string{"jpeg": ".jpg", "png": ".png", "gif": ".gif", "webp": ".webp"}[imageType]
+	if !ok {
+		return nil, newUploadError(UploadErrUnsupportedType, "不支持的图片格式: %s", imageType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, newUploadError(UploadErrInvalidImage, "图片已损坏或无法解析")
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 ||
+		cfg.Width > MaxUploadImageSide || cfg.Height > MaxUploadImageSide ||
+		cfg.Width*cfg.Height > MaxUploadImagePixels {
+		return nil, newUploadError(UploadErrTooManyPixels, "图片尺寸过大(%dx%d)，最大边长 %d 像素",
+			cfg.Width, cfg.Height, MaxUploadImageSide)
+	}
+
+	var buf bytes.Buffer
+	width, height := cfg.Width, cfg.Height
+
+	if imageType == "gif" {
+		// 解码前先扫描帧数和像素总数，避免 DecodeAll 为大量帧分配内存
+		frames, pixels, err := scanGifFrames(data)
+		if err != nil {
+			return nil, newUploadError(UploadErrInvalidImage, "图片已损坏或无法解析")
+		}
+		if frames > maxUploadGifFrames {
+			return nil, newUploadError(UploadErrTooManyPixels, "动图帧数过多，最多 %d 帧", maxUploadGifFrames)
+		}
+		if pixels > maxUploadGifTotalPixels {
+			return nil, newUploadError(UploadErrTooManyPixels, "动图过大，所有帧合计最多 %d 像素", maxUploadGifTotalPixels)
+		}
+
+		// GIF不含EXIF，重新编码以丢弃注释和应用扩展块，保留动画帧
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, newUploadError(UploadErrInvalidImage, "图片已损坏或无法解析")
+		}
+		if err := gif.EncodeAll(&buf, anim); err != nil {
+			return nil, newUploadError(UploadErrProcessFailed, "处理图片失败: %v", err)
+		}
+	} else {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, newUploadError(UploadErrInvalidImage, "图片已损坏或无法解析")
+		}
+
+		// 元数据会在重新编码时丢弃，需先按EXIF方向校正
+		img, _ = correctImageOrientationFromReader(img, bytes.NewReader(data))
+		width, height = img.Bounds().Dx(), img.Bounds().Dy()
+
+		switch imageType {
+		case "jpeg":
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 92})
+		case "png":
+			err = png.Encode(&buf, img)
+		case "webp":
+			// 无损WebP保持无损，避免上传的截图、线稿等被有损压缩
+			err = webp.Encode(&buf, img, &webp.Options{Lossless: webpIsLossless(data), Quality: 90})
+		}
+		if err != nil {
+			return nil, newUploadError(UploadErrProcessFailed, "处理图片失败: %v", err)
+		}
+	}
+
+	return &SanitizedImage{
+		Data:     buf.Bytes(),
+		Type:     imageType,
+		Ext:      ext,
+		Width:    width,
+		Height:   height,
+		Filename: strings.TrimSuffix(filename, filepath.Ext(filename)) + ext,
+	}, nil
+}
+
+// scanGifFrames 按GIF块结构统计帧数和所有帧的像素总数，不解码图像数据
+func scanGifFrames(data []byte) (frames, pixels int, err error) {
+	const headerLen = 13 // 文件头6字节 + 逻辑屏幕描述符7字节
+	if len(data) < headerLen {
+		return 0, 0, fmt.Errorf("GIF文件头不完整")
+	}
+	i := headerLen
+	if flags := data[10]; flags&0x80 != 0 {
+		i += 3 << (uint(flags&0x07) + 1)
+	}
+
+	// skipSubBlocks 跳过以长度0结尾的数据子块
+	skipSubBlocks := func() error {
+		for {
+			if i >= len(data) {
+				return fmt.Errorf("GIF数据块不完整")
+			}
+			size := int(data[i])
+			i++
+			if size == 0 {
+				return nil
+			}
+			i += size
+		}
+	}
+
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // 扩展块
+			i += 2
+			if err := skipSubBlocks(); err != nil {
+				return 0, 0, err
+			}
+		case 0x2C: // 图像描述符
+			if i+10 > len(data) {
+				return 0, 0, fmt.Errorf("GIF图像描述符不完整")
+			}
+			width := int(data[i+5]) | int(data[i+6])<<8
+			height := int(data[i+7]) | int(data[i+8])<<8
+			flags := data[i+9]
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (uint(flags&0x07) + 1)
+			}
+			i++ // LZW最小码长
+			if err := skipSubBlocks(); err != nil {
+				return 0, 0, err
+			}
+			frames++
+			pixels += width * height
+			if frames > maxUploadGifFrames || pixels > maxUploadGifTotalPixels {
+				// 已超出限制，无需继续扫描
+				return frames, pixels, nil
+			}
+		case 0x3B: // 结束符
+			return frames, pixels, nil
+		default:
+			return 0, 0, fmt.Errorf("未知的GIF块: 0x%02x", data[i])
+		}
+	}
+	return frames, pixels, nil
+}
@@ -12,14 +12,12 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"bytes"
 	"sync"
 	"time"
 
-	"github.com/chai2010/webp"
 	"github.com/disintegration/imaging"
 	"github.com/rwcarlsen/goexif/exif"
 )
@@ -35,11 +33,16 @@ func detectImageType(path string) (string, error) {
 	
 	// 读取文件前512字节以检测文件类型
 	buffer := make([]byte, 512)
-	_, err = file.Read(buffer)
+	n, err := file.Read(buffer)
 	if err != nil && err != io.EOF {
 		return "", fmt.Errorf("读取文件头失败: %w", err)
 	}
 	
+	return detectImageTypeFromBytes(buffer[:n])
+}
+
+// detectImageTypeFromBytes 根据文件头数据检测图片的真实类型
+func detectImageTypeFromBytes(buffer []byte) (string, error) {
 	// 检测文件类型
 	contentType := http.DetectContentType(buffer)
 	log.Printf("检测到文件类型: %s", contentType)
@@ -73,8 +76,13 @@ func correctImageOrientation(img image.Image, imgPath string) (image.Image, erro
 	}
 	defer f.Close()
 
+	return correctImageOrientationFromReader(img, f)
+}
+
+// correctImageOrientationFromReader 从原始图片数据中读取EXIF方向并调整图像
+func correctImageOrientationFromReader(img image.Image, r io.Reader) (image.Image, error) {
 	// 尝试解码EXIF数据
-	exifData, err := exif.Decode(f)
+	exifData, err := exif.Decode(r)
 	if err != nil {
 		// 如果无法解码EXIF（可能图片没有EXIF数据），使用原始图像
 		log.Printf("解析EXIF数据失败，将使用原始方向: %v", err)
@@ -134,9 +142,9 @@ func correctImageOrientation(img image.Image, imgPath string) (image.Image, erro
 
 // ConvertToWebP 将图片转换为WebP格式，自动判断图片方向并调整尺寸
 // 参数imgPath可以是：
-//  1. 相对于assets目录的路径，如 "imgs/13/1.jpg"
-//  2. 相对于当前工作目录的路径，如 "diyu.jpg" 
-//  3. 绝对路径，如 "/Users/username/path/to/image.jpg"
+//  1. 数据库中的URL路径，如 "/assets/imgs/13/1.jpg"
+//  2. 相对于资源目录(config.AssetsDir)的路径，如 "imgs/13/1.jpg"
+//  3. 资源目录内的绝对路径，如 "/srv/assets/imgs/13/1.jpg"
 // 参数useWebpExt：是否使用.webp作为输出文件扩展名，默认为false，保持原扩展名
 // 返回转换后的图片路径
 func ConvertToWebP(imgPath string, useWebpExt ...bool) (string, error) {
@@ -146,31 +154,10 @@ func ConvertToWebP(imgPath string, useWebpExt ...bool) (string, error) {
 		useWebp = useWebpExt[0]
 	}
 	
-	// 获取工作目录
-	workDir, err := os.Getwd()
+	// 解析原图片在资源目录下的完整路径
+	srcPath, err := ResolveAssetPath(imgPath)
 	if err != nil {
-		return "", fmt.Errorf("获取工作目录失败: %w", err)
-	}
-
-	// 构建assets目录的完整路径
-	assetsDir := filepath.Join(workDir, "..", "assets")
-	
-	// 构建原图片的完整路径 - 检查多种情况
-	var srcPath string
-	
-	// 判断路径类型
-	if filepath.IsAbs(imgPath) {
-		// 1. 绝对路径
-		srcPath = imgPath
-	} else if strings.HasPrefix(imgPath, "./") || strings.HasPrefix(imgPath, "../") {
-		// 处理相对于当前目录的路径
-		srcPath = filepath.Clean(filepath.Join(workDir, imgPath))
-	} else if strings.Contains(imgPath, "/") || strings.Contains(imgPath, "\\") {
-		// 2. 包含路径分隔符，假设是相对于assets目录的路径
-		srcPath = filepath.Join(assetsDir, imgPath)
-	} else {
-		// 3. 简单文件名，假设是相对于当前工作目录
-		srcPath = filepath.Join(workDir, imgPath)
+		return "", err
 	}
 	
 	log.Printf("处理图片路径: %s -> %s", imgPath, srcPath)
@@ -295,18 +282,7 @@ func ConvertToWebP(imgPath string, useWebpExt ...bool) (string, error) {
 	}
 	
 	// 计算新尺寸，保持宽高比
-	var newWidth, newHeight int
-	widthRatio := float64(maxWidth) / float64(origWidth)
-	heightRatio := float64(maxHeight) / float64(origHeight)
-	
-	// 使用较小的比例，确保图片完全适合最大尺寸
-	resizeRatio := widthRatio
-	if heightRatio < widthRatio {
-		resizeRatio = heightRatio
-	}
-	
-	newWidth = int(float64(origWidth) * resizeRatio)
-	newHeight = int(float64(origHeight) * resizeRatio)
+	newWidth, newHeight := ResizeDimensions(origWidth, origHeight, maxWidth, maxHeight)
 	
 	// 调整图片尺寸，保持原宽高比
 	resizedImg := imaging.Resize(srcImg, newWidth, newHeight, imaging.Lanczos)
@@ -318,65 +294,28 @@ func ConvertToWebP(imgPath string, useWebpExt ...bool) (string, error) {
 		// 使用WebP扩展名
 		baseFilename := strings.TrimSuffix(filepath.Base(imgPath), filepath.Ext(imgPath))
 		
-		// 确定WebP输出的目录
-		var outputDir string
-		
-		if filepath.IsAbs(imgPath) || !strings.Contains(imgPath, "/") && !strings.Contains(imgPath, "\\") {
-			// 对于绝对路径或简单文件名，保存在同一目录下
-			outputDir = filepath.Dir(srcPath)
-			outputPath = filepath.Join(outputDir, baseFilename + ".webp")
-		} else {
-			// 对于相对于assets的路径，保持原有结构
-			baseDir := filepath.Dir(imgPath)
-			outputPath = filepath.Join(assetsDir, baseDir, baseFilename + ".webp")
-			outputDir = filepath.Dir(outputPath)
-		}
+		// WebP文件保存在原图所在目录
+		outputDir := filepath.Dir(srcPath)
+		outputPath = filepath.Join(outputDir, baseFilename + ".webp")
 		
 		// 确保目标目录存在
 		if err := os.MkdirAll(outputDir, 0755); err != nil {
 			return "", fmt.Errorf("创建目标目录失败: %w", err)
 		}
 	} else {
-		// 保持原始扩展名，直接覆盖源文件
+		// 保持原始扩展名，以原格式覆盖源文件
 		outputPath = srcPath
 	}
 	
-	// 为避免直接覆盖源文件导致问题，先创建临时文件
-	tempFile := outputPath + ".tmp"
-	dstFile, err := os.Create(tempFile)
-	if err != nil {
-		return "", fmt.Errorf("创建临时文件失败: %w", err)
-	}
-	defer func() {
-		dstFile.Close()
-		// 如果出错，删除临时文件
-		if err != nil {
-			os.Remove(tempFile)
-		}
-	}()
-	
-	// 编码为WebP格式，质量设为80%，并且不保留元数据
-	options := &webp.Options{
-		Quality: 80,
-		// WebP库默认会清除所有元数据和配置文件信息
-	}
-	err = webp.Encode(dstFile, resizedImg, options)
-	if err != nil {
-		return "", fmt.Errorf("编码WebP图片失败: %w", err)
-	}
-	
-	// 关闭文件，确保写入完成
-	dstFile.Close()
-	
-	// 重命名临时文件为目标文件名，实现原子替换
-	if err := os.Rename(tempFile, outputPath); err != nil {
-		return "", fmt.Errorf("替换原文件失败: %w", err)
+	// 写出图片：使用WebP扩展名时直接写WebP，否则以原格式写回并生成 .webp/.avif 版本，均不保留元数据
+	if err := writeConvertedImage(resizedImg, imageType, outputPath, useWebp); err != nil {
+		return "", err
 	}
 	
 	if useWebp {
 		log.Printf("成功将图片 %s 转换为WebP格式 %s (尺寸: %dx%d, 已去除元数据)", imgPath, outputPath, newWidth, newHeight)
 	} else {
-		log.Printf("成功将图片 %s 转换为WebP格式并保持原扩展名 %s (尺寸: %dx%d, 已去除元数据)", imgPath, outputPath, newWidth, newHeight)
+		log.Printf("成功处理图片 %s，保持原格式写回 %s 并生成WebP版本 (尺寸: %dx%d, 已去除元数据)", imgPath, outputPath, newWidth, newHeight)
 	}
 	
 	// 返回处理后的图片路径
@@ -385,7 +324,7 @@ func ConvertToWebP(imgPath string, useWebpExt ...bool) (string, error) {
 
 // ConvertMultipleImages 根据JSON列表批量转换多张图片
 // 参数:
-//   - imageList: 图片路径JSON字符串，如 `["img1.jpg", "img2.png"]` 或 `["/path/to/img1.jpg", "/path/to/img2.png"]`
+//   - imageList: 图片路径JSON字符串，如 `["/assets/imgs/1/a.jpg", "imgs/1/b.png"]` 或 `["/path/to/img1.jpg"]`，路径解析规则见 ResolveAssetPath
 //   - keepOriginal: 是否保留原始图片
 //   - useWebpExt: 是否使用.webp扩展名
 //   - concurrency: 并发处理的数量，0表示使用默认值(4)
@@ -493,7 +432,8 @@ func isAnimatedGif(path string) (bool, error) {
 	return len(gifData.Image) > 1, nil
 }
 
-// convertAnimatedGif 使用gif2webp将动画GIF转换为动画WebP
+// convertAnimatedGif 将动画GIF转换为动画WebP，保留帧延迟、处置方式和循环次数
+// 每帧在有损和无损编码中取较小者，不依赖外部的gif2webp命令
 func convertAnimatedGif(srcPath, outputPath string, quality float32) error {
 	// 确保输出目录存在
 	outputDir := filepath.Dir(outputPath)
@@ -501,214 +441,50 @@ func convertAnimatedGif(srcPath, outputPath string, quality float32) error {
 		return fmt.Errorf("创建输出目录失败: %w", err)
 	}
 
-	// 创建临时输出路径
-	tempOutput := outputPath + ".tmp"
-
-	// 检查gif2webp命令是否存在
-	_, err := exec.LookPath("gif2webp")
-	if err != nil {
-		log.Printf("无法找到gif2webp命令: %v", err)
-		log.Printf("请确保已安装libwebp-tools包，可以通过系统包管理器安装，例如：")
-		log.Printf("Ubuntu/Debian: sudo apt-get install webp")
-		log.Printf("CentOS/RHEL: sudo yum install libwebp-tools")
-		log.Printf("MacOS: brew install webp")
-		
-		// 尝试使用内置的GIF解码和WebP编码逻辑进行转换
-		log.Printf("尝试使用内置方法转换动画GIF...")
-		return convertAnimatedGifUsingGoLibs(srcPath, outputPath, quality)
-	}
-
-	// 检查源文件是否存在且可读
-	if _, err := os.Stat(srcPath); err != nil {
-		return fmt.Errorf("源文件检查失败: %w", err)
-	}
-	
-	// 获取绝对路径
-	srcAbsPath, err := filepath.Abs(srcPath)
-	if err != nil {
-		log.Printf("获取源文件绝对路径失败: %v，使用原路径", err)
-		srcAbsPath = srcPath
-	}
-	
-	tempAbsOutput, err := filepath.Abs(tempOutput)
-	if err != nil {
-		log.Printf("获取临时文件绝对路径失败: %v，使用原路径", err)
-		tempAbsOutput = tempOutput
-	}
-
-	// 准备gif2webp命令
-	log.Printf("使用gif2webp命令转换动画GIF: %s -> %s", srcAbsPath, tempAbsOutput)
-	
-	// 尝试两种命令行参数组合
-	success := false
-	
-	// 参数组合1: 标准方式
-	args1 := []string{
-		"-q", fmt.Sprintf("%.0f", quality),  // 质量参数
-		"-mixed",                            // 混合无损/有损
-		srcAbsPath,                          // 源文件路径
-		"-o", tempAbsOutput,                 // 输出路径
-	}
-	
-	log.Printf("尝试参数组合1: gif2webp %s", strings.Join(args1, " "))
-	cmd1 := exec.Command("gif2webp", args1...)
-	var stdout1, stderr1 bytes.Buffer
-	cmd1.Stdout = &stdout1
-	cmd1.Stderr = &stderr1
-	
-	if err := cmd1.Run(); err == nil {
-		log.Printf("参数组合1执行成功")
-		success = true
-	} else {
-		log.Printf("参数组合1执行失败: %v", err)
-		log.Printf("标准输出: %s", stdout1.String())
-		log.Printf("错误输出: %s", stderr1.String())
-		
-		// 如果第一种参数组合失败，尝试第二种
-		args2 := []string{
-			"-q", fmt.Sprintf("%.0f", quality),  // 质量参数
-			srcAbsPath,                          // 源文件路径
-			"-o", tempAbsOutput,                 // 输出路径
-		}
-		
-		log.Printf("尝试参数组合2: gif2webp %s", strings.Join(args2, " "))
-		cmd2 := exec.Command("gif2webp", args2...)
-		var stdout2, stderr2 bytes.Buffer
-		cmd2.Stdout = &stdout2
-		cmd2.Stderr = &stderr2
-		
-		if err := cmd2.Run(); err == nil {
-			log.Printf("参数组合2执行成功")
-			success = true
-		} else {
-			log.Printf("参数组合2执行失败: %v", err)
-			log.Printf("标准输出: %s", stdout2.String())
-			log.Printf("错误输出: %s", stderr2.String())
-			
-			// 第三种组合：尝试直接调用系统命令
-			commandStr := fmt.Sprintf("gif2webp -q %.0f '%s' -o '%s'", 
-				quality, srcAbsPath, tempAbsOutput)
-			log.Printf("尝试通过系统shell执行: %s", commandStr)
-			
-			shellCmd := exec.Command("sh", "-c", commandStr)
-			var stdoutShell, stderrShell bytes.Buffer
-			shellCmd.Stdout = &stdoutShell
-			shellCmd.Stderr = &stderrShell
-			
-			if err := shellCmd.Run(); err == nil {
-				log.Printf("Shell执行成功")
-				success = true
-			} else {
-				log.Printf("Shell执行失败: %v", err)
-				log.Printf("标准输出: %s", stdoutShell.String())
-				log.Printf("错误输出: %s", stderrShell.String())
-			}
-		}
-	}
-	
-	// 检查是否有任何一种方法成功
-	if !success {
-		log.Printf("所有参数组合都失败，尝试备用方法")
-		// 尝试备选方法
-		return convertAnimatedGifUsingGoLibs(srcPath, outputPath, quality)
-	}
-
-	// 检查临时文件是否存在且大小合理
-	fileInfo, err := os.Stat(tempOutput)
-	if err != nil {
-		log.Printf("无法获取临时文件信息: %v", err)
-		return fmt.Errorf("临时文件检查失败: %w", err)
-	}
-	
-	log.Printf("生成的临时文件大小: %.2f KB", float64(fileInfo.Size())/1024)
-	
-	// 如果文件太小，可能转换不成功
-	if fileInfo.Size() < 100 {
-		log.Printf("警告：生成的文件过小，可能转换不完整")
-	}
-
-	// 重命名临时文件为目标文件名，实现原子替换
-	log.Printf("重命名临时文件 %s -> %s", tempOutput, outputPath)
-	if err := os.Rename(tempOutput, outputPath); err != nil {
-		return fmt.Errorf("替换原文件失败: %w", err)
-	}
-
-	log.Printf("成功将动画GIF %s 转换为动画WebP %s", srcPath, outputPath)
-	return nil
-}
-
-// convertAnimatedGifUsingGoLibs 使用Go库将动画GIF转换为动画WebP
-// 这是一个备选方法，当外部gif2webp命令不可用时使用
-func convertAnimatedGifUsingGoLibs(srcPath, outputPath string, quality float32) error {
-	// 打开GIF文件
 	file, err := os.Open(srcPath)
 	if err != nil {
 		return fmt.Errorf("打开GIF文件失败: %w", err)
 	}
-	defer file.Close()
-
-	// 解码GIF
 	gifImg, err := gif.DecodeAll(file)
+	file.Close()
 	if err != nil {
 		return fmt.Errorf("解码GIF文件失败: %w", err)
 	}
-
 	log.Printf("成功解码GIF文件，帧数: %d", len(gifImg.Image))
 
-	// 确保至少有一帧
-	if len(gifImg.Image) == 0 {
-		return fmt.Errorf("GIF文件没有帧")
-	}
-
-	// 创建临时输出路径
+	// 先写入临时文件，完成后重命名，实现原子替换
 	tempOutput := outputPath + ".tmp"
-
-	// 创建临时文件
 	outFile, err := os.Create(tempOutput)
 	if err != nil {
 		return fmt.Errorf("创建临时文件失败: %w", err)
 	}
-	defer func() {
-		outFile.Close()
-		if err != nil {
-			os.Remove(tempOutput)
-		}
-	}()
 
-	// 获取第一帧
-	firstFrame := gifImg.Image[0]
-	
-	// 准备WebP选项
-	options := webp.Options{
-		Lossless: false,
-		Quality:  quality,
+	options := AnimatedWebPOptions{Mixed: true, Quality: quality}
+	if err := EncodeAnimatedWebP(outFile, gifImg, options); err != nil {
+		outFile.Close()
+		os.Remove(tempOutput)
+		return fmt.Errorf("编码动画WebP失败: %w", err)
 	}
-
-	// 转换第一帧
-	err = webp.Encode(outFile, firstFrame, &options)
-	if err != nil {
-		return fmt.Errorf("编码第一帧失败: %w", err)
+	if err := outFile.Close(); err != nil {
+		os.Remove(tempOutput)
+		return fmt.Errorf("写入临时文件失败: %w", err)
 	}
 
-	log.Printf("警告：内置方法目前只能转换第一帧，结果将是静态WebP")
-	log.Printf("要获得完整的动画支持，请安装并使用gif2webp命令行工具")
-
-	// 关闭文件并重命名
-	outFile.Close()
 	if err := os.Rename(tempOutput, outputPath); err != nil {
+		os.Remove(tempOutput)
 		return fmt.Errorf("替换原文件失败: %w", err)
 	}
 
-	log.Printf("成功将GIF首帧转换为静态WebP %s", outputPath)
+	log.Printf("成功将动画GIF %s 转换为动画WebP %s", srcPath, outputPath)
 	return nil
 }
 
 // ConvertToWebPWithRatio 将图片转换为WebP格式，并保持原始宽高比调整尺寸
 // 参数:
 //   - imgPath: 图片路径，可以是：
-//     1. 相对于assets目录的路径，如 "imgs/13/1.jpg"
-//     2. 相对于当前工作目录的路径，如 "diyu.jpg" 
-//     3. 绝对路径，如 "/Users/username/path/to/image.jpg"
+//     1. 数据库中的URL路径，如 "/assets/imgs/13/1.jpg"
+//     2. 相对于资源目录(config.AssetsDir)的路径，如 "imgs/13/1.jpg"
+//     3. 资源目录内的绝对路径，如 "/srv/assets/imgs/13/1.jpg"
 //   - maxWidth: 最大宽度，0表示自动判断(横图1280，竖图600)
 //   - maxHeight: 最大高度，0表示自动判断(横图720，竖图900)
 //   - keepOriginal: 是否保留原始图片 - 当使用.webp后缀且此值为false时会删除原图
@@ -722,31 +498,10 @@ func ConvertToWebPWithRatio(imgPath string, maxWidth, maxHeight int, keepOrigina
 		useWebp = useWebpExt[0]
 	}
 	
-	// 获取工作目录
-	workDir, err := os.Getwd()
+	// 解析原图片在资源目录下的完整路径
+	srcPath, err := ResolveAssetPath(imgPath)
 	if err != nil {
-		return "", fmt.Errorf("获取工作目录失败: %w", err)
-	}
-
-	// 构建assets目录的完整路径
-	assetsDir := filepath.Join(workDir, "..", "assets")
-	
-	// 构建原图片的完整路径 - 检查多种情况
-	var srcPath string
-	
-	// 判断路径类型
-	if filepath.IsAbs(imgPath) {
-		// 1. 绝对路径
-		srcPath = imgPath
-	} else if strings.HasPrefix(imgPath, "./") || strings.HasPrefix(imgPath, "../") {
-		// 处理相对于当前目录的路径
-		srcPath = filepath.Clean(filepath.Join(workDir, imgPath))
-	} else if strings.Contains(imgPath, "/") || strings.Contains(imgPath, "\\") {
-		// 2. 包含路径分隔符，假设是相对于assets目录的路径
-		srcPath = filepath.Join(assetsDir, imgPath)
-	} else {
-		// 3. 简单文件名，假设是相对于当前工作目录
-		srcPath = filepath.Join(workDir, imgPath)
+		return "", err
 	}
 	
 	log.Printf("处理图片路径: %s -> %s", imgPath, srcPath)
@@ -781,19 +536,9 @@ func ConvertToWebPWithRatio(imgPath string, maxWidth, maxHeight int, keepOrigina
 				// 使用WebP扩展名
 				baseFilename := strings.TrimSuffix(filepath.Base(imgPath), filepath.Ext(imgPath))
 				
-				// 确定WebP输出的目录
-				var outputDir string
-				
-				if filepath.IsAbs(imgPath) || (!strings.Contains(imgPath, "/") && !strings.Contains(imgPath, "\\")) {
-					// 对于绝对路径或简单文件名，保存在同一目录下
-					outputDir = filepath.Dir(srcPath)
-					outputPath = filepath.Join(outputDir, baseFilename + ".webp")
-				} else {
-					// 对于相对于assets的路径，保持原有结构
-					baseDir := filepath.Dir(imgPath)
-					outputPath = filepath.Join(assetsDir, baseDir, baseFilename + ".webp")
-					outputDir = filepath.Dir(outputPath)
-				}
+				// WebP文件保存在原图所在目录
+				outputDir := filepath.Dir(srcPath)
+				outputPath = filepath.Join(outputDir, baseFilename + ".webp")
 				
 				// 确保目标目录存在
 				if err := os.MkdirAll(outputDir, 0755); err != nil {
@@ -803,8 +548,8 @@ func ConvertToWebPWithRatio(imgPath string, maxWidth, maxHeight int, keepOrigina
 				// 当使用.webp扩展名时，原文件和新文件是不同的文件
 				originalSaved = true
 			} else {
-				// 保持原始扩展名，直接覆盖源文件
-				outputPath = srcPath
+				// 保持原GIF不变，在旁边生成动画WebP版本供按 Accept 协商
+				outputPath = ImageSiblingKey(srcPath, WebPSiblingSuffix)
 			}
 			
 			// 使用特殊转换函数处理动画GIF
@@ -812,6 +557,9 @@ func ConvertToWebPWithRatio(imgPath string, maxWidth, maxHeight int, keepOrigina
 			if err != nil {
 				return "", fmt.Errorf("转换动画GIF失败: %w", err)
 			}
+			if !useWebp {
+				return srcPath, nil
+			}
 			
 			// 如果不需要保留原始图片，且生成了新的WebP文件，则删除原图
 			if !keepOriginal && originalSaved {
@@ -933,18 +681,7 @@ func ConvertToWebPWithRatio(imgPath string, maxWidth, maxHeight int, keepOrigina
 	}
 	
 	// 计算新尺寸，保持宽高比
-	var newWidth, newHeight int
-	widthRatio := float64(maxWidth) / float64(origWidth)
-	heightRatio := float64(maxHeight) / float64(origHeight)
-	
-	// 使用较小的比例，确保图片完全适合最大尺寸
-	resizeRatio := widthRatio
-	if heightRatio < widthRatio {
-		resizeRatio = heightRatio
-	}
-	
-	newWidth = int(float64(origWidth) * resizeRatio)
-	newHeight = int(float64(origHeight) * resizeRatio)
+	newWidth, newHeight := ResizeDimensions(origWidth, origHeight, maxWidth, maxHeight)
 	
 	// 调整图片尺寸，保持原宽高比
 	resizedImg := imaging.Resize(srcImg, newWidth, newHeight, imaging.Lanczos)
@@ -957,19 +694,9 @@ func ConvertToWebPWithRatio(imgPath string, maxWidth, maxHeight int, keepOrigina
 		// 使用WebP扩展名
 		baseFilename := strings.TrimSuffix(filepath.Base(imgPath), filepath.Ext(imgPath))
 		
-		// 确定WebP输出的目录
-		var outputDir string
-		
-		if filepath.IsAbs(imgPath) || !strings.Contains(imgPath, "/") && !strings.Contains(imgPath, "\\") {
-			// 对于绝对路径或简单文件名，保存在同一目录下
-			outputDir = filepath.Dir(srcPath)
-			outputPath = filepath.Join(outputDir, baseFilename + ".webp")
-		} else {
-			// 对于相对于assets的路径，保持原有结构
-			baseDir := filepath.Dir(imgPath)
-			outputPath = filepath.Join(assetsDir, baseDir, baseFilename + ".webp")
-			outputDir = filepath.Dir(outputPath)
-		}
+		// WebP文件保存在原图所在目录
+		outputDir := filepath.Dir(srcPath)
+		outputPath = filepath.Join(outputDir, baseFilename + ".webp")
 		
 		// 确保目标目录存在
 		if err := os.MkdirAll(outputDir, 0755); err != nil {
@@ -979,48 +706,24 @@ func ConvertToWebPWithRatio(imgPath string, maxWidth, maxHeight int, keepOrigina
 		// 当使用.webp扩展名时，原文件和新文件是不同的文件
 		originalSaved = true
 	} else {
-		// 保持原始扩展名，直接覆盖源文件
+		// 保持原始扩展名，以原格式覆盖源文件
 		outputPath = srcPath
 	}
 	
-	// 为避免直接覆盖源文件导致问题，先创建临时文件
-	tempFile := outputPath + ".tmp"
-	dstFile, err := os.Create(tempFile)
-	if err != nil {
-		return "", fmt.Errorf("创建临时文件失败: %w", err)
+	// 写出图片：使用WebP扩展名时直接写WebP，否则以原格式写回并生成 .webp/.avif 版本，均不保留元数据
+	if err := writeConvertedImage(resizedImg, imageType, outputPath, useWebp); err != nil {
+		return "", err
 	}
-	defer func() {
-		dstFile.Close()
-		// 如果出错，删除临时文件
-		if err != nil {
-			os.Remove(tempFile)
-		}
-	}()
 	
-	// 编码为WebP格式，质量设为80%，并且不保留元数据
-	options := &webp.Options{
-		Quality: 80,
-		// WebP库默认会清除所有元数据和配置文件信息
-	}
-	err = webp.Encode(dstFile, resizedImg, options)
-	if err != nil {
-		return "", fmt.Errorf("编码WebP图片失败: %w", err)
-	}
-	
-	// 关闭文件，确保写入完成
-	dstFile.Close()
-	
-	// 重命名临时文件为目标文件名，实现原子替换
-	if err := os.Rename(tempFile, outputPath); err != nil {
-		return "", fmt.Errorf("替换原文件失败: %w", err)
-	}
+	// 记录感知哈希（审核时检测近似重复图片）并生成响应式尺寸
+	recordImageDerivatives(outputPath, srcImg, newWidth, newHeight)
 	
 	// 记录日志
 	if useWebp {
 		log.Printf("成功将图片 %s 转换为WebP格式 %s (尺寸: %dx%d, 已去除元数据)", 
 			imgPath, outputPath, newWidth, newHeight)
 	} else {
-		log.Printf("成功将图片 %s 转换为WebP格式并保持原扩展名 %s (尺寸: %dx%d, 已去除元数据)", 
+		log.Printf("成功处理图片 %s，保持原格式写回 %s 并生成WebP版本 (尺寸: %dx%d, 已去除元数据)", 
 			imgPath, outputPath, newWidth, newHeight)
 	}
 	
@@ -1036,6 +739,50 @@ func ConvertToWebPWithRatio(imgPath string, maxWidth, maxHeight int, keepOrigina
 	return outputPath, nil
 }
 
+// ResizeDimensions 计算转换时的输出尺寸：按较小的比例等比缩放到最大尺寸
+// maxWidth、maxHeight 不大于0时按方向自动选择：横图1280x720，竖图600x900
+func ResizeDimensions(width, height, maxWidth, maxHeight int) (int, int) {
+	if maxWidth <= 0 || maxHeight <= 0 {
+		if width > height {
+			maxWidth, maxHeight = 1280, 720
+		} else {
+			maxWidth, maxHeight = 600, 900
+		}
+	}
+	
+	// 使用较小的比例，确保图片完全适合最大尺寸
+	resizeRatio := float64(maxWidth) / float64(width)
+	if heightRatio := float64(maxHeight) / float64(height); heightRatio < resizeRatio {
+		resizeRatio = heightRatio
+	}
+	return int(float64(width) * resizeRatio), int(float64(height) * resizeRatio)
+}
+
+// OrientedImageSize 读取图片尺寸，EXIF方向为旋转90度时交换宽高，与转换时的方向矫正一致
+func OrientedImageSize(path string) (int, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, fmt.Errorf("解析图片失败: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return cfg.Width, cfg.Height, nil
+	}
+	if exifData, err := exif.Decode(file); err == nil {
+		if tag, err := exifData.Get(exif.Orientation); err == nil {
+			if orientation, err := tag.Int(0); err == nil && orientation >= 5 && orientation <= 8 {
+				return cfg.Height, cfg.Width, nil
+			}
+		}
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
 // BatchProcessImages 批量处理指定目录下的图片
 // 参数:
 //   - dirPath: 要处理的目录路径
@@ -1072,7 +819,7 @@ func BatchProcessImages(dirPath string, recursive, keepOriginal bool, useWebpExt
 		
 		ext := strings.ToLower(filepath.Ext(filePath))
 		// 只处理常见图片格式
-		if ext == ".jpg" || ext == ".jpeg" || ext == ".png" || ext == ".gif" || ext == ".webp" {
+		if (ext == ".jpg" || ext == ".jpeg" || ext == ".png" || ext == ".gif" || ext == ".webp") && !IsDerivedImagePath(filePath) {
 			log.Printf("处理文件: %s", filePath)
 			
 			// 打开图片确定方向
@@ -1178,7 +925,7 @@ func ProcessDirectorySync(dirPath string, recursive, keepOriginal bool, useWebpE
 		
 		ext := strings.ToLower(filepath.Ext(path))
 		// 只处理常见图片格式
-		if ext == ".jpg" || ext == ".jpeg" || ext == ".png" || ext == ".gif" || ext == ".webp" {
+		if (ext == ".jpg" || ext == ".jpeg" || ext == ".png" || ext == ".gif" || ext == ".webp") && !IsDerivedImagePath(path) {
 			log.Printf("处理文件: %s", path)
 			
 			// 打开图片确定方向